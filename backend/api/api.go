@@ -6,15 +6,20 @@ import (
 
 	"github.com/gorilla/handlers"
 	"github.com/gorilla/mux"
+	"github.com/joaquinleonarg/wdml-mtg/backend/api/routes/auction"
 	"github.com/joaquinleonarg/wdml-mtg/backend/api/routes/auth"
 	"github.com/joaquinleonarg/wdml-mtg/backend/api/routes/boosterpacks"
 	"github.com/joaquinleonarg/wdml-mtg/backend/api/routes/collection"
 	"github.com/joaquinleonarg/wdml-mtg/backend/api/routes/deck"
+	"github.com/joaquinleonarg/wdml-mtg/backend/api/routes/ledger"
 	"github.com/joaquinleonarg/wdml-mtg/backend/api/routes/match"
+	"github.com/joaquinleonarg/wdml-mtg/backend/api/routes/notification"
 	"github.com/joaquinleonarg/wdml-mtg/backend/api/routes/season"
 	"github.com/joaquinleonarg/wdml-mtg/backend/api/routes/tournament"
 	"github.com/joaquinleonarg/wdml-mtg/backend/api/routes/tournament_player"
 	"github.com/joaquinleonarg/wdml-mtg/backend/api/routes/tournament_post"
+	"github.com/joaquinleonarg/wdml-mtg/backend/api/routes/trade"
+	"github.com/joaquinleonarg/wdml-mtg/backend/api/routes/wishlist"
 	"github.com/joaquinleonarg/wdml-mtg/backend/config"
 )
 
@@ -33,6 +38,11 @@ func StartServer() {
 	season.RegisterEndpoints(router)
 	match.RegisterEndpoints(router)
 	tournament_post.RegisterEndpoints(router)
+	trade.RegisterEndpoints(router)
+	auction.RegisterEndpoints(router)
+	ledger.RegisterEndpoints(router)
+	wishlist.RegisterEndpoints(router)
+	notification.RegisterEndpoints(router)
 
 	originsOk := handlers.AllowedOrigins([]string{config.Config.CorsOrigin})
 	credentialsOk := handlers.AllowCredentials()
@@ -0,0 +1,173 @@
+package auction
+
+import (
+	"errors"
+	"time"
+
+	"github.com/joaquinleonarg/wdml-mtg/backend/db"
+	"github.com/joaquinleonarg/wdml-mtg/backend/domain"
+	apiErrors "github.com/joaquinleonarg/wdml-mtg/backend/errors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	DEFAULT_LISTING_HOURS = 24
+	MAX_LISTING_HOURS     = 24 * 7
+)
+
+// CreateListing puts copies of one of the user's cards on sale, for a fixed price or as an auction starting at
+// the given price. The copies leave the collection until the listing ends.
+func CreateListing(userID, tournamentID, ownedCardID string, count int, listingType domain.ListingType, price, hours int) (string, error) {
+	if listingType != domain.ListingTypeFixedPrice && listingType != domain.ListingTypeAuction {
+		return "", apiErrors.ErrBadRequest
+	}
+	if hours == 0 {
+		hours = DEFAULT_LISTING_HOURS
+	}
+	if count <= 0 || price <= 0 || hours < 0 || hours > MAX_LISTING_HOURS {
+		return "", apiErrors.ErrBadRequest
+	}
+
+	seller, err := db.GetTournamentPlayer(tournamentID, userID)
+	if err != nil {
+		return "", mapAuctionError(err)
+	}
+	dbOwnedCardID, err := primitive.ObjectIDFromHex(ownedCardID)
+	if err != nil {
+		return "", apiErrors.ErrBadRequest
+	}
+
+	listingID, err := db.CreateAuctionListing(domain.AuctionListing{
+		TournamentID: seller.TournamentID,
+		SellerID:     seller.ID,
+		OwnedCardID:  dbOwnedCardID,
+		Count:        count,
+		Type:         listingType,
+		Price:        price,
+		EndsAt:       primitive.NewDateTimeFromTime(time.Now().Add(time.Duration(hours) * time.Hour)),
+	})
+	if err != nil {
+		return "", mapAuctionError(err)
+	}
+	return listingID.Hex(), nil
+}
+
+// GetListings returns the listings of a tournament on the given status (active by default). If mine is set,
+// only the user's own listings are returned.
+func GetListings(userID, tournamentID string, status domain.ListingStatus, mine bool) ([]domain.AuctionListing, error) {
+	tournamentPlayer, err := db.GetTournamentPlayer(tournamentID, userID)
+	if err != nil {
+		return nil, mapAuctionError(err)
+	}
+	if status == "" {
+		status = domain.ListingStatusActive
+	}
+	sellerID := ""
+	if mine {
+		sellerID = tournamentPlayer.ID.Hex()
+	}
+	listings, err := db.GetAuctionListings(tournamentID, sellerID, []domain.ListingStatus{status})
+	if err != nil {
+		return nil, mapAuctionError(err)
+	}
+	return listings, nil
+}
+
+// GetListing returns a listing of one of the user's tournaments
+func GetListing(userID, listingID string) (*domain.AuctionListing, error) {
+	listing, _, err := getListingForPlayer(userID, listingID)
+	if err != nil {
+		return nil, err
+	}
+	return listing, nil
+}
+
+// PlaceBid bids on an auction. The coins are held until the user is outbid or the auction ends.
+func PlaceBid(userID, listingID string, amount int) error {
+	listing, bidder, err := getListingForPlayer(userID, listingID)
+	if err != nil {
+		return err
+	}
+	if listing.SellerID == bidder.ID {
+		return apiErrors.ErrBadRequest
+	}
+	if amount < domain.MinimumBid(*listing) {
+		return apiErrors.ErrBadRequest
+	}
+	if amount > bidder.GameResources.Coins {
+		return apiErrors.ErrNotEnough
+	}
+
+	err = db.PlaceAuctionBid(listingID, bidder.ID.Hex(), amount)
+	if err != nil {
+		return mapAuctionError(err)
+	}
+	return nil
+}
+
+// BuyListing buys a fixed price listing
+func BuyListing(userID, listingID string) error {
+	listing, buyer, err := getListingForPlayer(userID, listingID)
+	if err != nil {
+		return err
+	}
+	if listing.SellerID == buyer.ID {
+		return apiErrors.ErrBadRequest
+	}
+	if listing.Price > buyer.GameResources.Coins {
+		return apiErrors.ErrNotEnough
+	}
+
+	err = db.BuyAuctionListing(listingID, buyer.ID.Hex())
+	if err != nil {
+		return mapAuctionError(err)
+	}
+	return nil
+}
+
+// CancelListing takes a listing without bids off the market, giving the card back to the seller. Only the
+// seller or a moderator can cancel it.
+func CancelListing(userID, listingID string) error {
+	listing, tournamentPlayer, err := getListingForPlayer(userID, listingID)
+	if err != nil {
+		return err
+	}
+	isModerator := tournamentPlayer.AccessLevel == domain.AccessLevelAdministrator || tournamentPlayer.AccessLevel == domain.AccessLevelModerator
+	if listing.SellerID != tournamentPlayer.ID && !isModerator {
+		return apiErrors.ErrUnauthorized
+	}
+
+	err = db.CancelAuctionListing(listingID)
+	if err != nil {
+		return mapAuctionError(err)
+	}
+	return nil
+}
+
+func getListingForPlayer(userID, listingID string) (*domain.AuctionListing, *domain.TournamentPlayer, error) {
+	listing, err := db.GetAuctionListingByID(listingID)
+	if err != nil {
+		return nil, nil, mapAuctionError(err)
+	}
+	tournamentPlayer, err := db.GetTournamentPlayer(listing.TournamentID.Hex(), userID)
+	if err != nil {
+		return nil, nil, apiErrors.ErrUnauthorized
+	}
+	return listing, tournamentPlayer, nil
+}
+
+func mapAuctionError(err error) error {
+	switch {
+	case errors.Is(err, db.ErrInvalidID):
+		return apiErrors.ErrBadRequest
+	case errors.Is(err, db.ErrNotFound):
+		return apiErrors.ErrNotFound
+	case errors.Is(err, db.ErrNotEnough):
+		return apiErrors.ErrNotEnough
+	case errors.Is(err, db.ErrCardsInUse):
+		return apiErrors.ErrCardsInDeck
+	case errors.Is(err, db.ErrInvalidState):
+		return apiErrors.ErrInvalidState
+	}
+	return apiErrors.ErrInternal
+}
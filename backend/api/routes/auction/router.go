@@ -0,0 +1,272 @@
+package auction
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/joaquinleonarg/wdml-mtg/backend/api/response"
+	"github.com/joaquinleonarg/wdml-mtg/backend/domain"
+	"github.com/rs/zerolog/log"
+)
+
+func RegisterEndpoints(r *mux.Router) {
+	r = r.PathPrefix("/auction").Subrouter()
+	r.HandleFunc("", GetListingsHandler).Methods(http.MethodGet)
+	r.HandleFunc("/listing", GetListingHandler).Methods(http.MethodGet)
+	r.HandleFunc("/listing", CreateListingHandler).Methods(http.MethodPost)
+	r.HandleFunc("/bid", PlaceBidHandler).Methods(http.MethodPost)
+	r.HandleFunc("/buy", BuyListingHandler).Methods(http.MethodPost)
+	r.HandleFunc("/cancel", CancelListingHandler).Methods(http.MethodPost)
+}
+
+type ListingIDRequest struct {
+	ListingID string `json:"listing_id"`
+}
+
+type EmptyResponse struct{}
+
+//
+// ENDPOINT: Get the listings of a tournament
+//
+
+type GetListingsResponse struct {
+	Listings []domain.AuctionListing `json:"listings"`
+}
+
+func GetListingsHandler(w http.ResponseWriter, r *http.Request) {
+	log := log.With().Ctx(r.Context()).Str("path", r.URL.Path).Logger()
+
+	// Get user ID from request context
+	userID, ok := r.Context().Value("user_id").(string)
+	if userID == "" || !ok {
+		log.Debug().
+			Msg("failed to read user id from context")
+		http.Error(w, "", http.StatusForbidden)
+		return
+	}
+
+	// Get tournament ID and filters from query
+	tournamentID := r.URL.Query().Get("tournament_id")
+	if tournamentID == "" {
+		http.Error(w, "", http.StatusBadRequest)
+		return
+	}
+	status := domain.ListingStatus(r.URL.Query().Get("status"))
+	mine := r.URL.Query().Get("mine") == "true"
+
+	listings, err := GetListings(userID, tournamentID, status, mine)
+	if err != nil {
+		log.Debug().Err(err).Msg("failed to get listings")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write(response.NewErrorResponse(err))
+		return
+	}
+
+	// Send response back
+	w.WriteHeader(http.StatusOK)
+	w.Write(response.NewDataResponse(GetListingsResponse{Listings: listings}))
+}
+
+//
+// ENDPOINT: Get a listing by its id
+//
+
+type GetListingResponse struct {
+	Listing domain.AuctionListing `json:"listing"`
+}
+
+func GetListingHandler(w http.ResponseWriter, r *http.Request) {
+	log := log.With().Ctx(r.Context()).Str("path", r.URL.Path).Logger()
+
+	// Get user ID from request context
+	userID, ok := r.Context().Value("user_id").(string)
+	if userID == "" || !ok {
+		log.Debug().
+			Msg("failed to read user id from context")
+		http.Error(w, "", http.StatusForbidden)
+		return
+	}
+
+	// Get listing ID from query
+	listingID := r.URL.Query().Get("listing_id")
+	if listingID == "" {
+		http.Error(w, "", http.StatusBadRequest)
+		return
+	}
+
+	listing, err := GetListing(userID, listingID)
+	if err != nil {
+		log.Debug().Err(err).Msg("failed to get listing")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write(response.NewErrorResponse(err))
+		return
+	}
+
+	// Send response back
+	w.WriteHeader(http.StatusOK)
+	w.Write(response.NewDataResponse(GetListingResponse{Listing: *listing}))
+}
+
+//
+// ENDPOINT: List a card for a fixed price or as a timed auction
+//
+
+type CreateListingRequest struct {
+	TournamentID string             `json:"tournament_id"`
+	OwnedCardID  string             `json:"owned_card_id"`
+	Count        int                `json:"count"`
+	Type         domain.ListingType `json:"type"`
+	// Price for fixed price listings, starting bid for auctions
+	Price int `json:"price"`
+	// How long the listing lasts, 24 hours if not set
+	Hours int `json:"hours"`
+}
+
+type CreateListingResponse struct {
+	ListingID string `json:"listing_id"`
+}
+
+func CreateListingHandler(w http.ResponseWriter, r *http.Request) {
+	log := log.With().Ctx(r.Context()).Str("path", r.URL.Path).Logger()
+
+	// Get user ID from request context
+	userID, ok := r.Context().Value("user_id").(string)
+	if userID == "" || !ok {
+		log.Debug().
+			Msg("failed to read user id from context")
+		http.Error(w, "", http.StatusForbidden)
+		return
+	}
+
+	// Decode body data
+	var createListingRequest CreateListingRequest
+	err := json.NewDecoder(r.Body).Decode(&createListingRequest)
+	if err != nil {
+		log.Debug().
+			Err(err).
+			Msg("failed to read request body")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write(response.NewErrorResponse(err))
+		return
+	}
+
+	listingID, err := CreateListing(
+		userID,
+		createListingRequest.TournamentID,
+		createListingRequest.OwnedCardID,
+		createListingRequest.Count,
+		createListingRequest.Type,
+		createListingRequest.Price,
+		createListingRequest.Hours,
+	)
+	if err != nil {
+		log.Debug().Err(err).Msg("failed to create listing")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write(response.NewErrorResponse(err))
+		return
+	}
+
+	// Send response back
+	w.WriteHeader(http.StatusOK)
+	w.Write(response.NewDataResponse(CreateListingResponse{ListingID: listingID}))
+}
+
+//
+// ENDPOINT: Bid on an auction
+//
+
+type PlaceBidRequest struct {
+	ListingID string `json:"listing_id"`
+	Amount    int    `json:"amount"`
+}
+
+func PlaceBidHandler(w http.ResponseWriter, r *http.Request) {
+	log := log.With().Ctx(r.Context()).Str("path", r.URL.Path).Logger()
+
+	// Get user ID from request context
+	userID, ok := r.Context().Value("user_id").(string)
+	if userID == "" || !ok {
+		log.Debug().
+			Msg("failed to read user id from context")
+		http.Error(w, "", http.StatusForbidden)
+		return
+	}
+
+	// Decode body data
+	var placeBidRequest PlaceBidRequest
+	err := json.NewDecoder(r.Body).Decode(&placeBidRequest)
+	if err != nil {
+		log.Debug().
+			Err(err).
+			Msg("failed to read request body")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write(response.NewErrorResponse(err))
+		return
+	}
+
+	err = PlaceBid(userID, placeBidRequest.ListingID, placeBidRequest.Amount)
+	if err != nil {
+		log.Debug().Err(err).Msg("failed to place bid")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write(response.NewErrorResponse(err))
+		return
+	}
+
+	// Send response back
+	w.WriteHeader(http.StatusOK)
+	w.Write(response.NewDataResponse(EmptyResponse{}))
+}
+
+//
+// ENDPOINT: Buy a fixed price listing
+//
+
+func BuyListingHandler(w http.ResponseWriter, r *http.Request) {
+	handleListingAction(w, r, BuyListing)
+}
+
+//
+// ENDPOINT: Cancel a listing nobody bid on
+//
+
+func CancelListingHandler(w http.ResponseWriter, r *http.Request) {
+	handleListingAction(w, r, CancelListing)
+}
+
+func handleListingAction(w http.ResponseWriter, r *http.Request, action func(userID, listingID string) error) {
+	log := log.With().Ctx(r.Context()).Str("path", r.URL.Path).Logger()
+
+	// Get user ID from request context
+	userID, ok := r.Context().Value("user_id").(string)
+	if userID == "" || !ok {
+		log.Debug().
+			Msg("failed to read user id from context")
+		http.Error(w, "", http.StatusForbidden)
+		return
+	}
+
+	// Decode body data
+	var listingIDRequest ListingIDRequest
+	err := json.NewDecoder(r.Body).Decode(&listingIDRequest)
+	if err != nil {
+		log.Debug().
+			Err(err).
+			Msg("failed to read request body")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write(response.NewErrorResponse(err))
+		return
+	}
+
+	err = action(userID, listingIDRequest.ListingID)
+	if err != nil {
+		log.Debug().Err(err).Msg("failed to update listing")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write(response.NewErrorResponse(err))
+		return
+	}
+
+	// Send response back
+	w.WriteHeader(http.StatusOK)
+	w.Write(response.NewDataResponse(EmptyResponse{}))
+}
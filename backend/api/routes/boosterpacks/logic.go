@@ -3,12 +3,15 @@ package boosterpacks
 import (
 	"errors"
 	"strings"
+	"sync"
 
+	"github.com/joaquinleonarg/wdml-mtg/backend/config"
 	"github.com/joaquinleonarg/wdml-mtg/backend/db"
 	"github.com/joaquinleonarg/wdml-mtg/backend/domain"
 	apiErrors "github.com/joaquinleonarg/wdml-mtg/backend/errors"
 	boostergen "github.com/joaquinleonarg/wdml-mtg/backend/internal/booster_gen"
 	"github.com/rs/zerolog/log"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 func GetTournamentBoosterPacks() ([]domain.BoosterPack, error) {
@@ -73,6 +76,9 @@ func AddTournamentBoosterPacks(userID, tournamentID string, boosterPack AddTourn
 		SetCode:     boosterPack.SetCode,
 		Name:        setName,
 		Description: setDescription,
+	}, domain.LedgerCause{
+		ActorID: tournament_player.UserID,
+		Reason:  domain.LedgerReasonBoosterDistribution,
 	})
 	if err != nil {
 		if errors.Is(err, db.ErrNotFound) {
@@ -84,15 +90,56 @@ func AddTournamentBoosterPacks(userID, tournamentID string, boosterPack AddTourn
 	return nil
 }
 
-func OpenBoosterPack(userID, tournamentID string, setCode string) ([]domain.CardData, error) {
-	cards, err := boostergen.GenerateBooster(strings.ToLower(setCode), boostergen.GetBoosterDataFromDb)
+const MAX_IDEMPOTENCY_KEY_LENGTH = 128
 
+// OpenBoosterPack opens one of the player's packs. The cards are drawn with a seed derived from the opening's ID,
+// which is recorded with them. Requests repeating an idempotency key get the opening that key already made, without
+// opening another pack.
+func OpenBoosterPack(userID, tournamentID string, setCode string, idempotencyKey string) (*domain.BoosterOpening, error) {
+	dbUserID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, apiErrors.ErrBadRequest
+	}
+	if len(idempotencyKey) > MAX_IDEMPOTENCY_KEY_LENGTH {
+		return nil, apiErrors.ErrBadRequest
+	}
+
+	// Skip generating the pack when the request is a retry
+	if idempotencyKey != "" {
+		existing, err := db.GetBoosterOpeningByIdempotencyKey(tournamentID, userID, idempotencyKey)
+		if err == nil {
+			return replayedOpening(existing, setCode)
+		}
+		if !errors.Is(err, db.ErrNotFound) {
+			log.Debug().Err(err).Msg("failed to get booster opening")
+			if errors.Is(err, db.ErrInvalidID) {
+				return nil, apiErrors.ErrBadRequest
+			}
+			return nil, apiErrors.ErrInternal
+		}
+	}
+
+	boosterPack, err := boostergen.GetBoosterDataFromDb(strings.ToLower(setCode))
+	if err != nil {
+		log.Debug().Err(err).Msg("failed to get booster pack")
+		if errors.Is(err, db.ErrNotFound) {
+			return nil, apiErrors.ErrNotFound
+		}
+		return nil, apiErrors.ErrInternal
+	}
+
+	drawn, err := drawOpening(primitive.NewObjectID(), boosterPack, setCode)
 	if err != nil {
 		log.Debug().Err(err).Msg("failed to generate booster pack")
 		return nil, apiErrors.ErrInternal
 	}
+	drawn.IdempotencyKey = idempotencyKey
 
-	err = db.ConsumeBoosterPackForTournamentPlayer(userID, tournamentID, setCode, cards)
+	opening, err := db.ConsumeBoosterPackForTournamentPlayer(userID, tournamentID, drawn, domain.LedgerCause{
+		ActorID:   dbUserID,
+		Reason:    domain.LedgerReasonBoosterOpen,
+		RelatedID: drawn.ID,
+	})
 	if err != nil {
 		log.Debug().Err(err).Msg("failed to open booster pack")
 		if errors.Is(err, db.ErrNotFound) {
@@ -103,7 +150,228 @@ func OpenBoosterPack(userID, tournamentID string, setCode string) ([]domain.Card
 		}
 		return nil, apiErrors.ErrInternal
 	}
-	return cards, nil
+	if opening.ID != drawn.ID {
+		// A concurrent request with the same key opened it first
+		return replayedOpening(opening, setCode)
+	}
+
+	// The pack is already open, so failing to notify doesn't fail the request
+	puller, err := db.GetTournamentPlayer(tournamentID, userID)
+	if err == nil {
+		err = db.NotifyWishlistedPulls(puller, opening.Cards)
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("failed to notify wishlisted pulls")
+	}
+	return opening, nil
+}
+
+// drawOpening draws the cards of a new opening of the pack, with a seed derived from the opening's ID
+func drawOpening(openingID primitive.ObjectID, boosterPack *domain.BoosterPack, setCode string) (domain.BoosterOpening, error) {
+	seed := boostergen.OpeningSeed(config.Config.BoosterSeedSecret, openingID.Hex())
+	cards, err := boostergen.GenerateBooster(boosterPack.SetCode, boostergen.GetBoosterDataPassthrough(*boosterPack), boostergen.NewSeededRandom(seed))
+	if err != nil {
+		return domain.BoosterOpening{}, err
+	}
+	return domain.BoosterOpening{
+		ID:          openingID,
+		SetCode:     setCode,
+		Seed:        boostergen.EncodeSeed(seed),
+		BoosterPack: *boosterPack,
+		Cards:       cards,
+	}, nil
+}
+
+const (
+	// Enough for a booster box
+	MAX_BULK_OPEN_PACKS = 36
+	// Packs generated at the same time, each of them doing its own Scryfall lookups
+	BULK_OPEN_WORKERS = 4
+)
+
+// OpenBoosterPacks opens count of the player's packs of the set, or all of them (up to MAX_BULK_OPEN_PACKS) if count
+// is 0. The packs are generated concurrently, then granted together in one transaction. Requests repeating an
+// idempotency key get the openings that key already made.
+// reveal is called with each pack as soon as it's drawn, so they can be shown one by one; they only belong to the
+// player once this returns without error.
+func OpenBoosterPacks(userID, tournamentID string, setCode string, count int, idempotencyKey string, reveal func(domain.BoosterOpening)) ([]domain.BoosterOpening, error) {
+	dbUserID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, apiErrors.ErrBadRequest
+	}
+	if count < 0 || len(idempotencyKey) > MAX_IDEMPOTENCY_KEY_LENGTH {
+		return nil, apiErrors.ErrBadRequest
+	}
+
+	// Skip generating the packs when the request is a retry
+	if idempotencyKey != "" {
+		existing, err := db.GetBoosterOpeningByIdempotencyKey(tournamentID, userID, idempotencyKey)
+		if err == nil {
+			openings, err := replayedBatch(existing, setCode)
+			if err != nil {
+				return nil, err
+			}
+			for _, opening := range openings {
+				reveal(opening)
+			}
+			return openings, nil
+		}
+		if !errors.Is(err, db.ErrNotFound) {
+			log.Debug().Err(err).Msg("failed to get booster opening")
+			if errors.Is(err, db.ErrInvalidID) {
+				return nil, apiErrors.ErrBadRequest
+			}
+			return nil, apiErrors.ErrInternal
+		}
+	}
+
+	// Check how many packs of the set the player has
+	tournamentPlayer, err := db.GetTournamentPlayer(tournamentID, userID)
+	if err != nil {
+		log.Debug().Err(err).Msg("failed to get tournament player")
+		if errors.Is(err, db.ErrNotFound) {
+			return nil, apiErrors.ErrNotFound
+		}
+		if errors.Is(err, db.ErrInvalidID) {
+			return nil, apiErrors.ErrBadRequest
+		}
+		return nil, apiErrors.ErrInternal
+	}
+	available := 0
+	for _, ownedPack := range tournamentPlayer.GameResources.BoosterPacks {
+		if ownedPack.SetCode == setCode {
+			available += ownedPack.Available
+		}
+	}
+	if count == 0 {
+		count = available
+	}
+	count = min(count, MAX_BULK_OPEN_PACKS)
+	if count == 0 {
+		return nil, apiErrors.ErrNotFound
+	}
+	if count > available {
+		return nil, apiErrors.ErrNotEnough
+	}
+
+	boosterPack, err := boostergen.GetBoosterDataFromDb(strings.ToLower(setCode))
+	if err != nil {
+		log.Debug().Err(err).Msg("failed to get booster pack")
+		if errors.Is(err, db.ErrNotFound) {
+			return nil, apiErrors.ErrNotFound
+		}
+		return nil, apiErrors.ErrInternal
+	}
+
+	// IDs are made in order, so the batch can be read back in the order it was drawn
+	openingIDs := make([]primitive.ObjectID, count)
+	for i := range openingIDs {
+		openingIDs[i] = primitive.NewObjectID()
+	}
+
+	// Generate the packs with a bounded pool of workers, revealing each one as it's done
+	type drawnOpening struct {
+		index   int
+		opening domain.BoosterOpening
+		err     error
+	}
+	indexes := make(chan int)
+	drawn := make(chan drawnOpening)
+	var wg sync.WaitGroup
+	for range min(BULK_OPEN_WORKERS, count) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				opening, err := drawOpening(openingIDs[i], boosterPack, setCode)
+				drawn <- drawnOpening{index: i, opening: opening, err: err}
+			}
+		}()
+	}
+	go func() {
+		for i := range count {
+			indexes <- i
+		}
+		close(indexes)
+		wg.Wait()
+		close(drawn)
+	}()
+
+	openings := make([]domain.BoosterOpening, count)
+	drawErrors := []error{}
+	for result := range drawn {
+		if result.err != nil {
+			drawErrors = append(drawErrors, result.err)
+			continue
+		}
+		openings[result.index] = result.opening
+		// Nothing else is shown once the batch can't be opened
+		if len(drawErrors) == 0 {
+			reveal(result.opening)
+		}
+	}
+	if err := errors.Join(drawErrors...); err != nil {
+		log.Debug().Err(err).Msg("failed to generate booster packs")
+		return nil, apiErrors.ErrInternal
+	}
+
+	batchID := primitive.NewObjectID()
+	cards := []domain.CardData{}
+	for i := range openings {
+		openings[i].BatchID = batchID
+		cards = append(cards, openings[i].Cards...)
+	}
+	// The key is kept on the first opening, it identifies the whole batch
+	openings[0].IdempotencyKey = idempotencyKey
+	opened, err := db.ConsumeBoosterPacksForTournamentPlayer(userID, tournamentID, openings, domain.LedgerCause{
+		ActorID:   dbUserID,
+		Reason:    domain.LedgerReasonBoosterOpen,
+		RelatedID: batchID,
+	})
+	if err != nil {
+		log.Debug().Err(err).Msg("failed to open booster packs")
+		if errors.Is(err, db.ErrNotFound) {
+			return nil, apiErrors.ErrNotEnough
+		}
+		if errors.Is(err, db.ErrInvalidID) {
+			return nil, apiErrors.ErrBadRequest
+		}
+		return nil, apiErrors.ErrInternal
+	}
+	if opened[0].ID != openings[0].ID {
+		// A concurrent request with the same key opened them first
+		return replayedBatch(&opened[0], setCode)
+	}
+
+	// The packs are already open, so failing to notify doesn't fail the request
+	err = db.NotifyWishlistedPulls(tournamentPlayer, cards)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to notify wishlisted pulls")
+	}
+	return openings, nil
+}
+
+// replayedOpening returns the opening an idempotency key already made, as long as it was a single opening of the
+// same set
+func replayedOpening(opening *domain.BoosterOpening, setCode string) (*domain.BoosterOpening, error) {
+	if opening.SetCode != setCode || opening.BatchID != primitive.NilObjectID {
+		return nil, apiErrors.ErrBadRequest
+	}
+	return opening, nil
+}
+
+// replayedBatch returns the openings of the bulk open an idempotency key already made, as long as it was for the
+// same set
+func replayedBatch(opening *domain.BoosterOpening, setCode string) ([]domain.BoosterOpening, error) {
+	if opening.SetCode != setCode || opening.BatchID == primitive.NilObjectID {
+		return nil, apiErrors.ErrBadRequest
+	}
+	openings, err := db.GetBoosterOpeningBatch(opening)
+	if err != nil {
+		log.Debug().Err(err).Msg("failed to get booster openings")
+		return nil, apiErrors.ErrInternal
+	}
+	return openings, nil
 }
 
 func CreateNewBoosterPack(boosterPack domain.BoosterPack) error {
@@ -135,3 +403,155 @@ func BuyBoosterPack(tournamentID, userID, boosterPackID string) error {
 	}
 	return nil
 }
+
+const (
+	DEFAULT_SIMULATED_PACKS = 1000
+	MAX_SIMULATED_PACKS     = 10000
+)
+
+type BoosterSimulation struct {
+	boostergen.Simulation
+	// What the pack costs on the tournament's store, 0 if it isn't sold there
+	StoreCoinPrice int `json:"store_coin_price"`
+}
+
+// SimulateBoosterPack opens simulated packs of a set without granting them, to measure its pull rates. Only
+// administrators can run it. If publish is set, the measured odds are shown to the tournament's players.
+func SimulateBoosterPack(userID, tournamentID, setCode string, packs int, publish bool) (*BoosterSimulation, error) {
+	tournamentPlayer, err := db.GetTournamentPlayer(tournamentID, userID)
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			return nil, apiErrors.ErrNotFound
+		}
+		return nil, apiErrors.ErrInternal
+	}
+	if tournamentPlayer.AccessLevel != domain.AccessLevelAdministrator {
+		return nil, apiErrors.ErrUnauthorized
+	}
+	tournament, err := db.GetTournamentByID(tournamentID)
+	if err != nil {
+		return nil, apiErrors.ErrInternal
+	}
+	boosterPack, err := db.GetPackBySetCode(strings.ToLower(setCode))
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			return nil, apiErrors.ErrNotFound
+		}
+		return nil, apiErrors.ErrInternal
+	}
+
+	if packs <= 0 {
+		packs = DEFAULT_SIMULATED_PACKS
+	}
+	packs = min(packs, MAX_SIMULATED_PACKS)
+
+	simulation, err := boostergen.SimulateBoosters(boosterPack, packs, tournament.CoinValues)
+	if err != nil {
+		log.Debug().Err(err).Msg("failed to simulate booster packs")
+		return nil, apiErrors.ErrInternal
+	}
+	result := BoosterSimulation{Simulation: *simulation}
+	for _, storePack := range tournament.Store.BoosterPacks {
+		if storePack.BoosterPackID == boosterPack.ID {
+			result.StoreCoinPrice = domain.StoreCoinPrice(storePack, tournament.CoinValues)
+			break
+		}
+	}
+
+	if publish {
+		err = db.PublishBoosterOdds(tournamentPlayer.TournamentID, boosterPack.SetCode, simulation.Odds)
+		if err != nil {
+			return nil, apiErrors.ErrInternal
+		}
+	}
+	return &result, nil
+}
+
+// GetBoosterOdds returns the odds published for a set's packs on a tournament
+func GetBoosterOdds(tournamentID, setCode string) (*domain.BoosterOdds, error) {
+	boosterPack, err := db.GetPackBySetCode(strings.ToLower(setCode))
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			return nil, apiErrors.ErrNotFound
+		}
+		return nil, apiErrors.ErrInternal
+	}
+	published, err := db.GetPublishedBoosterOdds(tournamentID, boosterPack.SetCode)
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			return nil, apiErrors.ErrNotFound
+		}
+		if errors.Is(err, db.ErrInvalidID) {
+			return nil, apiErrors.ErrBadRequest
+		}
+		return nil, apiErrors.ErrInternal
+	}
+	return &published.Odds, nil
+}
+
+// GetBoosterOpening returns a recorded pack opening. Players can see their own openings; administrators and
+// moderators can see anyone's.
+func GetBoosterOpening(userID, openingID string) (*domain.BoosterOpening, error) {
+	opening, err := db.GetBoosterOpeningByID(openingID)
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			return nil, apiErrors.ErrNotFound
+		}
+		if errors.Is(err, db.ErrInvalidID) {
+			return nil, apiErrors.ErrBadRequest
+		}
+		return nil, apiErrors.ErrInternal
+	}
+	requester, err := db.GetTournamentPlayer(opening.TournamentID.Hex(), userID)
+	if err != nil {
+		return nil, apiErrors.ErrUnauthorized
+	}
+	if requester.ID != opening.TournamentPlayerID && requester.AccessLevel != domain.AccessLevelAdministrator && requester.AccessLevel != domain.AccessLevelModerator {
+		return nil, apiErrors.ErrUnauthorized
+	}
+	return opening, nil
+}
+
+type RederivedOpening struct {
+	Opening domain.BoosterOpening `json:"opening"`
+	// Cards drawn again from the seed derived from the opening's ID
+	Cards []domain.CardData `json:"cards"`
+	// Whether the stored seed is the one derived from the opening's ID
+	SeedMatches bool `json:"seed_matches"`
+	// Whether the same cards were drawn again, on the same order
+	CardsMatch bool `json:"cards_match"`
+}
+
+// RederiveBoosterOpening draws a recorded opening again, to check the player got what the seed gives. Only
+// administrators and moderators can do it. Cards can only differ if Scryfall changed the cards of a filter since.
+func RederiveBoosterOpening(userID, openingID string) (*RederivedOpening, error) {
+	opening, err := GetBoosterOpening(userID, openingID)
+	if err != nil {
+		return nil, err
+	}
+	requester, err := db.GetTournamentPlayer(opening.TournamentID.Hex(), userID)
+	if err != nil {
+		return nil, apiErrors.ErrUnauthorized
+	}
+	if requester.AccessLevel != domain.AccessLevelAdministrator && requester.AccessLevel != domain.AccessLevelModerator {
+		return nil, apiErrors.ErrUnauthorized
+	}
+
+	seed := boostergen.OpeningSeed(config.Config.BoosterSeedSecret, opening.ID.Hex())
+	cards, err := boostergen.GenerateBooster(opening.BoosterPack.SetCode, boostergen.GetBoosterDataPassthrough(opening.BoosterPack), boostergen.NewSeededRandom(seed))
+	if err != nil {
+		log.Debug().Err(err).Msg("failed to generate booster pack again")
+		return nil, apiErrors.ErrInternal
+	}
+
+	cardsMatch := len(cards) == len(opening.Cards)
+	for i := 0; cardsMatch && i < len(cards); i++ {
+		cardsMatch = domain.CardVariantKey(cards[i]) == domain.CardVariantKey(opening.Cards[i])
+	}
+	return &RederivedOpening{
+		Opening:     *opening,
+		Cards:       cards,
+		SeedMatches: boostergen.EncodeSeed(seed) == opening.Seed,
+		CardsMatch:  cardsMatch,
+	}, nil
+}
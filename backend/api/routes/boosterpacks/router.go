@@ -16,9 +16,14 @@ func RegisterEndpoints(r *mux.Router) {
 	r.HandleFunc("/tournament", GetTournamentBoosterPacksHandler).Methods(http.MethodGet)
 	r.HandleFunc("/tournament", AddTournamentBoosterPacksHandler).Methods(http.MethodPost)
 	r.HandleFunc("/open", OpenBoosterPackHandler).Methods(http.MethodPost)
+	r.HandleFunc("/open/bulk", OpenBoosterPacksHandler).Methods(http.MethodPost)
 	r.HandleFunc("/", CreateBoosterPackHandler).Methods(http.MethodPost)
 	r.HandleFunc("/", UpdateBoosterPackHandler).Methods(http.MethodPut)
 	r.HandleFunc("/buy", BuyStoreBoosterPackHandler).Methods(http.MethodPost)
+	r.HandleFunc("/simulate", SimulateBoosterPackHandler).Methods(http.MethodPost)
+	r.HandleFunc("/odds", GetBoosterOddsHandler).Methods(http.MethodGet)
+	r.HandleFunc("/opening", GetBoosterOpeningHandler).Methods(http.MethodGet)
+	r.HandleFunc("/opening/rederive", RederiveBoosterOpeningHandler).Methods(http.MethodPost)
 }
 
 //
@@ -111,10 +116,13 @@ func AddTournamentBoosterPacksHandler(w http.ResponseWriter, r *http.Request) {
 
 type OpenBoosterPackRequest struct {
 	SetCode string `json:"set_code"`
+	// Optional. Retrying with the same key returns the same cards instead of opening another pack
+	IdempotencyKey string `json:"idempotency_key"`
 }
 
 type OpenBoosterPackResponse struct {
-	CardData []domain.CardData `json:"card_data"`
+	OpeningID string            `json:"opening_id"`
+	CardData  []domain.CardData `json:"card_data"`
 }
 
 func OpenBoosterPackHandler(w http.ResponseWriter, r *http.Request) {
@@ -145,7 +153,7 @@ func OpenBoosterPackHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Try to open the pack, add the cards to the collection and get them here to send in the response
-	cards, err := OpenBoosterPack(userID, tournamentID, openBoosterPackRequest.SetCode)
+	opening, err := OpenBoosterPack(userID, tournamentID, openBoosterPackRequest.SetCode, openBoosterPackRequest.IdempotencyKey)
 	if err != nil {
 		log.Debug().Err(err).Msg("failed to open booster pack")
 		w.WriteHeader(http.StatusBadRequest)
@@ -155,7 +163,7 @@ func OpenBoosterPackHandler(w http.ResponseWriter, r *http.Request) {
 
 	// Send response back
 	w.WriteHeader(http.StatusOK)
-	w.Write(response.NewDataResponse(OpenBoosterPackResponse{CardData: cards}))
+	w.Write(response.NewDataResponse(OpenBoosterPackResponse{OpeningID: opening.ID.Hex(), CardData: opening.Cards}))
 }
 
 // TODO: Restrict the request body
@@ -264,3 +272,258 @@ func BuyStoreBoosterPackHandler(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 	w.Write(response.NewDataResponse(BuyStoreBoosterPackResponse{}))
 }
+
+//
+// ENDPOINT: Open several of the player's packs of a set at once, or all of them
+//
+
+type OpenBoosterPacksRequest struct {
+	SetCode string `json:"set_code"`
+	// Packs to open, 0 for all of the set
+	Count int `json:"count"`
+	// Optional. Retrying with the same key returns the same packs instead of opening others
+	IdempotencyKey string `json:"idempotency_key"`
+}
+
+// One line of the response of OpenBoosterPacksHandler
+type OpenBoosterPacksLine struct {
+	// A pack, as soon as it's drawn
+	Pack *OpenBoosterPackResponse `json:"pack,omitempty"`
+	// Set on the last line, once the packs are granted
+	Committed bool `json:"committed"`
+	// The openings that were granted. They're only different from the packs shown if a concurrent request with the
+	// same idempotency key opened its packs first.
+	OpeningIDs []string `json:"opening_ids,omitempty"`
+}
+
+// OpenBoosterPacksHandler streams newline delimited JSON: one OpenBoosterPacksLine per pack as soon as it's drawn, so
+// the packs can be revealed one by one, then a last line saying whether they were granted. Errors found before the
+// first pack is drawn get a regular error response; later ones are sent as the last line.
+func OpenBoosterPacksHandler(w http.ResponseWriter, r *http.Request) {
+	log := log.With().Ctx(r.Context()).Str("path", r.URL.Path).Logger()
+
+	// Get user ID from context
+	userID, err := auth.GetUserIDFromContext(r.Context())
+	if err != nil {
+		http.Error(w, "", http.StatusBadRequest)
+		return
+	}
+
+	// Get tournament ID from query
+	tournamentID := r.URL.Query().Get("tournament_id")
+	if tournamentID == "" {
+		http.Error(w, "", http.StatusBadRequest)
+		return
+	}
+
+	// Parse request
+	var openBoosterPacksRequest OpenBoosterPacksRequest
+	err = json.NewDecoder(r.Body).Decode(&openBoosterPacksRequest)
+	if err != nil {
+		http.Error(w, "", http.StatusBadRequest)
+		return
+	}
+
+	// The response starts with the first pack
+	streaming := false
+	flusher, _ := w.(http.Flusher)
+	writeLine := func(line []byte) {
+		if !streaming {
+			w.Header().Set("Content-Type", "application/x-ndjson")
+			w.WriteHeader(http.StatusOK)
+			streaming = true
+		}
+		w.Write(line)
+		w.Write([]byte("\n"))
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+
+	openings, err := OpenBoosterPacks(userID, tournamentID, openBoosterPacksRequest.SetCode, openBoosterPacksRequest.Count, openBoosterPacksRequest.IdempotencyKey, func(opening domain.BoosterOpening) {
+		writeLine(response.NewDataResponse(OpenBoosterPacksLine{
+			Pack: &OpenBoosterPackResponse{OpeningID: opening.ID.Hex(), CardData: opening.Cards},
+		}))
+	})
+	if err != nil {
+		log.Debug().Err(err).Msg("failed to open booster packs")
+		if !streaming {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write(response.NewErrorResponse(err))
+			return
+		}
+		writeLine(response.NewErrorResponse(err))
+		return
+	}
+
+	// Say the packs were granted
+	openingIDs := make([]string, len(openings))
+	for i, opening := range openings {
+		openingIDs[i] = opening.ID.Hex()
+	}
+	writeLine(response.NewDataResponse(OpenBoosterPacksLine{Committed: true, OpeningIDs: openingIDs}))
+}
+
+//
+// ENDPOINT: Open simulated packs without granting them and report their pull rates, optionally publishing them
+//
+
+type SimulateBoosterPackRequest struct {
+	SetCode string `json:"set_code"`
+	Packs   int    `json:"packs"`
+	Publish bool   `json:"publish"`
+}
+
+type SimulateBoosterPackResponse struct {
+	Simulation BoosterSimulation `json:"simulation"`
+}
+
+func SimulateBoosterPackHandler(w http.ResponseWriter, r *http.Request) {
+	log := log.With().Ctx(r.Context()).Str("path", r.URL.Path).Logger()
+
+	// Get user ID from context
+	userID, err := auth.GetUserIDFromContext(r.Context())
+	if err != nil {
+		http.Error(w, "", http.StatusBadRequest)
+		return
+	}
+
+	// Get tournament ID from query
+	tournamentID := r.URL.Query().Get("tournament_id")
+	if tournamentID == "" {
+		http.Error(w, "", http.StatusBadRequest)
+		return
+	}
+
+	// Decode body data
+	var req SimulateBoosterPackRequest
+	err = json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		log.Debug().
+			Err(err).
+			Msg("failed to read request body")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write(response.NewErrorResponse(err))
+		return
+	}
+
+	simulation, err := SimulateBoosterPack(userID, tournamentID, req.SetCode, req.Packs, req.Publish)
+	if err != nil {
+		log.Debug().Err(err).Msg("failed to simulate booster packs")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write(response.NewErrorResponse(err))
+		return
+	}
+
+	// Send response back
+	w.WriteHeader(http.StatusOK)
+	w.Write(response.NewDataResponse(SimulateBoosterPackResponse{Simulation: *simulation}))
+}
+
+//
+// ENDPOINT: Get the odds of a set's packs published on a tournament
+//
+
+type GetBoosterOddsResponse struct {
+	Odds *domain.BoosterOdds `json:"odds"`
+}
+
+func GetBoosterOddsHandler(w http.ResponseWriter, r *http.Request) {
+	log := log.With().Ctx(r.Context()).Str("path", r.URL.Path).Logger()
+
+	// Get tournament ID and set code from query
+	tournamentID := r.URL.Query().Get("tournament_id")
+	setCode := r.URL.Query().Get("set_code")
+	if tournamentID == "" || setCode == "" {
+		http.Error(w, "", http.StatusBadRequest)
+		return
+	}
+
+	odds, err := GetBoosterOdds(tournamentID, setCode)
+	if err != nil {
+		log.Debug().Err(err).Msg("failed to get booster odds")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write(response.NewErrorResponse(err))
+		return
+	}
+
+	// Send response back
+	w.WriteHeader(http.StatusOK)
+	w.Write(response.NewDataResponse(GetBoosterOddsResponse{Odds: odds}))
+}
+
+//
+// ENDPOINT: Get a recorded pack opening, with the seed it was drawn with
+//
+
+type GetBoosterOpeningResponse struct {
+	Opening *domain.BoosterOpening `json:"opening"`
+}
+
+func GetBoosterOpeningHandler(w http.ResponseWriter, r *http.Request) {
+	log := log.With().Ctx(r.Context()).Str("path", r.URL.Path).Logger()
+
+	// Get user ID from context
+	userID, err := auth.GetUserIDFromContext(r.Context())
+	if err != nil {
+		http.Error(w, "", http.StatusBadRequest)
+		return
+	}
+
+	// Get opening ID from query
+	openingID := r.URL.Query().Get("opening_id")
+	if openingID == "" {
+		http.Error(w, "", http.StatusBadRequest)
+		return
+	}
+
+	opening, err := GetBoosterOpening(userID, openingID)
+	if err != nil {
+		log.Debug().Err(err).Msg("failed to get booster opening")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write(response.NewErrorResponse(err))
+		return
+	}
+
+	// Send response back
+	w.WriteHeader(http.StatusOK)
+	w.Write(response.NewDataResponse(GetBoosterOpeningResponse{Opening: opening}))
+}
+
+//
+// ENDPOINT: Draw a recorded pack opening again from its seed, to resolve disputes
+//
+
+type RederiveBoosterOpeningResponse struct {
+	Rederived *RederivedOpening `json:"rederived"`
+}
+
+func RederiveBoosterOpeningHandler(w http.ResponseWriter, r *http.Request) {
+	log := log.With().Ctx(r.Context()).Str("path", r.URL.Path).Logger()
+
+	// Get user ID from context
+	userID, err := auth.GetUserIDFromContext(r.Context())
+	if err != nil {
+		http.Error(w, "", http.StatusBadRequest)
+		return
+	}
+
+	// Get opening ID from query
+	openingID := r.URL.Query().Get("opening_id")
+	if openingID == "" {
+		http.Error(w, "", http.StatusBadRequest)
+		return
+	}
+
+	rederived, err := RederiveBoosterOpening(userID, openingID)
+	if err != nil {
+		log.Debug().Err(err).Msg("failed to rederive booster opening")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write(response.NewErrorResponse(err))
+		return
+	}
+
+	// Send response back
+	w.WriteHeader(http.StatusOK)
+	w.Write(response.NewDataResponse(RederiveBoosterOpeningResponse{Rederived: rederived}))
+}
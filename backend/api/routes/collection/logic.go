@@ -1,7 +1,10 @@
 package collection
 
 import (
+	"cmp"
+	"errors"
 	"fmt"
+	"io"
 	"slices"
 	"strconv"
 	"strings"
@@ -11,146 +14,339 @@ import (
 	"github.com/joaquinleonarg/wdml-mtg/backend/domain"
 	apiErrors "github.com/joaquinleonarg/wdml-mtg/backend/errors"
 	boostergen "github.com/joaquinleonarg/wdml-mtg/backend/internal/booster_gen"
+	cardquery "github.com/joaquinleonarg/wdml-mtg/backend/internal/card_query"
+	collectionexport "github.com/joaquinleonarg/wdml-mtg/backend/internal/collection_export"
+	collectionimport "github.com/joaquinleonarg/wdml-mtg/backend/internal/collection_import"
+	collectionstats "github.com/joaquinleonarg/wdml-mtg/backend/internal/collection_stats"
+	deckvalidation "github.com/joaquinleonarg/wdml-mtg/backend/internal/deck_validation"
 	"github.com/joaquinleonarg/wdml-mtg/backend/pkg/scryfall"
 	"github.com/rs/zerolog/log"
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
-func GetCollectionCards(userID, tournamentID, filters string, count, page int) ([]domain.OwnedCard, int, error) {
-	log.Debug().Str("filters", filters).Send()
-	dbFilters := []db.CardFilter{}
+// GetCollectionCards returns a page of the player's cards that match the query, or of another player's if their
+// privacy settings let the user see them. The query can be written on the collection query language, or as the older
+// filters list ("name=x+rarity=y").
+func GetCollectionCards(userID, tournamentID, tournamentPlayerID, query, filters, sort string, count, page int) ([]domain.OwnedCard, int, error) {
+	ownerID, err := collectionOwner(userID, tournamentID, tournamentPlayerID)
+	if err != nil {
+		return nil, 0, err
+	}
+	cardFilter, cardSort, err := compileCollectionQuery(query, filters, sort)
+	if err != nil {
+		return nil, 0, err
+	}
+	cards, total, err := db.GetCardsFromTournamentPlayer(ownerID, tournamentID, cardFilter, cardSort, count, page)
+	if err != nil {
+		if errors.Is(err, db.ErrInvalidID) {
+			return nil, 0, apiErrors.ErrBadRequest
+		}
+		return nil, 0, apiErrors.ErrInternal
+	}
+	return cards, total, nil
+}
+
+// collectionOwner returns the user whose collection is read: the user's own if no tournament player is given, or the
+// given player's if the user can see it
+func collectionOwner(userID, tournamentID, tournamentPlayerID string) (string, error) {
+	viewer, err := db.GetTournamentPlayer(tournamentID, userID)
+	if err != nil {
+		viewer = nil
+		if errors.Is(err, db.ErrInvalidID) {
+			return "", apiErrors.ErrBadRequest
+		}
+		if !errors.Is(err, db.ErrNotFound) {
+			return "", apiErrors.ErrInternal
+		}
+	}
+	if tournamentPlayerID == "" {
+		if viewer == nil {
+			return "", apiErrors.ErrUnauthorized
+		}
+		return userID, nil
+	}
+
+	owner, err := db.GetTournamentPlayerByID(tournamentPlayerID)
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			return "", apiErrors.ErrNotFound
+		}
+		if errors.Is(err, db.ErrInvalidID) {
+			return "", apiErrors.ErrBadRequest
+		}
+		return "", apiErrors.ErrInternal
+	}
+	if owner.TournamentID.Hex() != tournamentID {
+		return "", apiErrors.ErrNotFound
+	}
+	if !domain.CanSeeCollection(*owner, viewer) {
+		return "", apiErrors.ErrUnauthorized
+	}
+	return owner.UserID.Hex(), nil
+}
+
+func compileCollectionQuery(query, filters, sort string) (bson.M, bson.D, error) {
+	if query == "" {
+		query = legacyFiltersToQuery(filters)
+	}
+	log.Debug().Str("query", query).Str("sort", sort).Send()
+
+	node, err := cardquery.Parse(query)
+	if err != nil {
+		log.Debug().Err(err).Msg("failed to parse collection query")
+		return nil, nil, apiErrors.ErrBadRequest
+	}
+	cardFilter, err := cardquery.Compile(node)
+	if err != nil {
+		log.Debug().Err(err).Msg("failed to compile collection query")
+		return nil, nil, apiErrors.ErrBadRequest
+	}
+	cardSort, err := cardquery.ParseSort(sort)
+	if err != nil {
+		log.Debug().Err(err).Msg("failed to parse collection sort")
+		return nil, nil, apiErrors.ErrBadRequest
+	}
+	return cardFilter, cardSort, nil
+}
+
+// legacyFiltersToQuery translates the "name=x+tags=a -b+color=WU" filters into the query language.
+// Empty filters are skipped.
+func legacyFiltersToQuery(filters string) string {
+	terms := []string{}
 	for _, filter := range strings.Split(filters, "+") {
-		for _, filterOperation := range []db.CardFilterOperation{
-			db.CardFilterOperationEq,
-			db.CardFilterOperationLt,
-			db.CardFilterOperationGt,
-		} {
-			for _, filterType := range []db.CardFilterType{
-				db.CardFilterTypeName,
-				db.CardFilterTypeTags,
-				db.CardFilterTypeRarity,
-				db.CardFilterTypeColor,
-				db.CardFilterTypeTypes,
-				db.CardFilterTypeOracle,
-				db.CardFilterTypeSetCode,
-				db.CardFilterTypeMV,
-			} {
-				if strings.Contains(filter, string(filterOperation)) {
-					splitted := strings.Split(filter, string(filterOperation))
-					if splitted[1] == "" {
-						continue
-					}
-					if splitted[0] == string(filterType) {
-						dbFilters = append(dbFilters, db.CardFilter{
-							Type:      filterType,
-							Operation: filterOperation,
-							Value:     splitted[1],
-						})
-					}
-					continue
+		var field, value, operator string
+		found := false
+		for _, legacyOperator := range []string{"=", "<", ">"} {
+			if field, value, found = strings.Cut(filter, legacyOperator); found {
+				operator = legacyOperator
+				break
+			}
+		}
+		value = strings.TrimSpace(value)
+		if !found || value == "" {
+			continue
+		}
+
+		switch field {
+		case "name", "oracle":
+			terms = append(terms, fmt.Sprintf("%s:%s", field, strconv.Quote(value)))
+		case "tags":
+			for _, tag := range strings.Fields(value) {
+				if strings.HasPrefix(tag, "-") {
+					terms = append(terms, fmt.Sprintf("-tag=%s", strconv.Quote(strings.TrimPrefix(tag, "-"))))
+				} else {
+					terms = append(terms, fmt.Sprintf("tag=%s", strconv.Quote(tag)))
 				}
 			}
+		case "types":
+			terms = append(terms, fmt.Sprintf("type:%s", strconv.Quote(value)))
+		case "color":
+			// Colors used to be "all of these" for "=" and exact for "<"
+			switch operator {
+			case "=":
+				operator = ">="
+			case "<":
+				operator = "="
+			}
+			terms = append(terms, fmt.Sprintf("color%s%s", operator, strconv.Quote(value)))
+		case "rarity", "setcode", "mv":
+			terms = append(terms, fmt.Sprintf("%s%s%s", field, operator, strconv.Quote(value)))
 		}
 	}
-	return db.GetCardsFromTournamentPlayer(userID, tournamentID, dbFilters, count, page)
+	return strings.Join(terms, " ")
+}
+
+// Cards read from the database at once when exporting a collection
+const EXPORT_PAGE_SIZE = 500
+
+// ExportCollection writes every card of the player's collection that matches the query in the given format, or of
+// another player's if the user can see it. Cards are written as they are read, one page at a time.
+func ExportCollection(w io.Writer, userID, tournamentID, tournamentPlayerID, query, filters, sort string, format collectionexport.Format) error {
+	ownerID, err := collectionOwner(userID, tournamentID, tournamentPlayerID)
+	if err != nil {
+		return err
+	}
+
+	writer, err := collectionexport.NewWriter(format, w)
+	if err != nil {
+		return apiErrors.ErrBadRequest
+	}
+
+	cardFilter, cardSort, err := compileCollectionQuery(query, filters, sort)
+	if err != nil {
+		return err
+	}
+	for page := 1; ; page++ {
+		cards, total, err := db.GetCardsFromTournamentPlayer(ownerID, tournamentID, cardFilter, cardSort, EXPORT_PAGE_SIZE, page)
+		if err != nil {
+			return apiErrors.ErrInternal
+		}
+		err = writer.Write(cards)
+		if err != nil {
+			return err
+		}
+		if page*EXPORT_PAGE_SIZE >= total {
+			break
+		}
+	}
+	return writer.Close()
 }
 
 func GetOwnedCardById(cardId string) (domain.OwnedCard, error) {
 	return db.GetOwnedCardById(cardId)
 }
 
-func ImportCollection(importCardCsv [][]string, userID, tournamentID string) error {
-	dbTournamentID, err := primitive.ObjectIDFromHex(tournamentID)
+// Cards requested to Scryfall on each collection request
+const SCRYFALL_IDENTIFIERS_PER_REQUEST = 75
+
+type ImportedCard struct {
+	Line int             `json:"line"`
+	Card domain.CardData `json:"card"`
+	// Copies on the file
+	Count int `json:"count"`
+	// Copies added to the collection, the rest are converted into coins
+	Kept      int `json:"kept"`
+	Converted int `json:"converted"`
+	Coins     int `json:"coins"`
+}
+
+type CollectionImportReport struct {
+	Format    collectionimport.Format         `json:"format"`
+	Matched   []ImportedCard                  `json:"matched"`
+	Unmatched []collectionimport.UnmatchedRow `json:"unmatched"`
+	Coins     int                             `json:"coins"`
+	DryRun    bool                            `json:"dry_run"`
+}
+
+// ImportCollection reads a collection export and adds its cards to the player's collection. Copies of a card beyond
+// the tournament's limit, or the fourth if it has none, counting the ones already owned, are converted into coins.
+// On a dry run, nothing is stored.
+func ImportCollection(rawCollection, userID, tournamentID string, dryRun bool) (*CollectionImportReport, error) {
+	tournamentPlayer, err := db.GetTournamentPlayer(tournamentID, userID)
 	if err != nil {
-		return apiErrors.ErrBadRequest
+		if errors.Is(err, db.ErrNotFound) {
+			return nil, apiErrors.ErrUnauthorized
+		}
+		if errors.Is(err, db.ErrInvalidID) {
+			return nil, apiErrors.ErrBadRequest
+		}
+		return nil, apiErrors.ErrInternal
 	}
-	dbUserID, err := primitive.ObjectIDFromHex(userID)
+
+	format, rows, unmatched, err := collectionimport.Parse(rawCollection)
 	if err != nil {
-		return apiErrors.ErrBadRequest
+		return nil, apiErrors.ErrBadRequest
+	}
+	report := CollectionImportReport{
+		Format:    format,
+		Matched:   []ImportedCard{},
+		Unmatched: unmatched,
+		DryRun:    dryRun,
 	}
-	cards := make([]domain.CardData, 0, len(importCardCsv))
-	cardsBySetCode := make([]scryfall.CardsByIdentifier, 0, len(importCardCsv))
-	cum := 0
-	scryfallRequestBody := scryfall.ScryfallCollectionRequest{Identifiers: []scryfallapi.CardIdentifier{}}
-	// Pos 3 = set, Pos 9 = collectors number
-	for i, card := range importCardCsv {
-		if i > 0 {
-			amt, err := strconv.Atoi(card[0])
-			if err != nil {
-				return err
+
+	// Find the cards on Scryfall
+	for batchStart := 0; batchStart < len(rows); batchStart += SCRYFALL_IDENTIFIERS_PER_REQUEST {
+		batch := rows[batchStart:min(batchStart+SCRYFALL_IDENTIFIERS_PER_REQUEST, len(rows))]
+		scryfallRequestBody := scryfall.ScryfallCollectionRequest{Identifiers: make([]scryfallapi.CardIdentifier, 0, len(batch))}
+		for _, row := range batch {
+			scryfallRequestBody.Identifiers = append(scryfallRequestBody.Identifiers, row.Identifier())
+		}
+		scryCards, err := scryfall.GetAllCardsByIdentifiers(scryfallRequestBody)
+		if err != nil {
+			log.Error().Err(err).Msg("failed to get cards from scryfall")
+			return nil, apiErrors.ErrInternal
+		}
+
+		for _, row := range batch {
+			index := slices.IndexFunc(scryCards, row.Matches)
+			if index == -1 {
+				report.Unmatched = append(report.Unmatched, collectionimport.UnmatchedRow{
+					Line:   row.Line,
+					Raw:    fmt.Sprintf("%d %s (%s) %s", row.Count, row.Name, row.SetCode, row.CollectorNumber),
+					Reason: "card not found",
+				})
+				continue
 			}
-			cardsBySetCode = append(cardsBySetCode, scryfall.CardsByIdentifier{Identifier: scryfallapi.CardIdentifier{Set: card[3], CollectorNumber: card[9]}, Amount: amt})
-			scryfallRequestBody.Identifiers = append(scryfallRequestBody.Identifiers, scryfallapi.CardIdentifier{Set: card[3], CollectorNumber: card[9]})
-
-			cum += 1
-			// When cum == 75, query scryfall and add cards to slice of OwnedCard
-			if cum == 75 {
-				cum = 0
-
-				// Pegada a scry
-				scryCardData, err := scryfall.GetAllCardsByIdentifiers(scryfallRequestBody)
-				if err != nil {
-					return err
-				}
-				for _, card := range scryCardData {
-					cardData := scryfall.GetCardDataFromScryCard(card)
-					cards = append(cards, cardData)
-				}
-				scryfallRequestBody.Identifiers = []scryfallapi.CardIdentifier{}
+			card := scryfall.GetCardDataFromScryCard(scryCards[index])
+			if row.Finish != "" && scryfall.HasFinish(scryCards[index], row.Finish) {
+				card.Finish = row.Finish
+			}
+			if row.Language != "" {
+				card.Language = row.Language
 			}
+			report.Matched = append(report.Matched, ImportedCard{
+				Line:  row.Line,
+				Card:  card,
+				Count: row.Count,
+			})
 		}
 	}
-	if cum > 0 {
-		cum = 0
-		// Request to Scryfall
-		scryCardData, err := scryfall.GetAllCardsByIdentifiers(scryfallRequestBody)
-		if err != nil {
-			return err
+
+	tournament, err := db.GetTournamentByID(tournamentID)
+	if err != nil {
+		return nil, apiErrors.ErrInternal
+	}
+
+	// Copies already owned count towards the limit
+	names := []string{}
+	for _, importedCard := range report.Matched {
+		if !slices.Contains(names, importedCard.Card.Name) {
+			names = append(names, importedCard.Card.Name)
 		}
-		for _, card := range scryCardData {
-			cardData := scryfall.GetCardDataFromScryCard(card)
-			cards = append(cards, cardData)
-		}
-		scryfallRequestBody.Identifiers = []scryfallapi.CardIdentifier{}
-
-	}
-
-	ownedCards := make([]domain.OwnedCard, 0, len(cardsBySetCode))
-	coinsToAdd := 0
-	for _, cardIdent := range cardsBySetCode {
-		var foundCard domain.CardData
-		for _, cardData := range cards {
-			if strings.ToLower(cardData.SetCode) == cardIdent.Identifier.Set && cardData.CollectorNumber == cardIdent.Identifier.CollectorNumber {
-				foundCard = cardData
-				if cardIdent.Amount > 4 && !slices.Contains(cardData.Types, "Basic") {
-					coins := (cardIdent.Amount - 4)
-					switch foundCard.Rarity {
-					case domain.CardRaritySpecial:
-						coinsToAdd += coins * domain.SPECIAL_TO_COIN
-					case domain.CardRarityMythic:
-						coinsToAdd += coins * domain.MYTHIC_TO_COIN
-					case domain.CardRarityRare:
-						coinsToAdd += coins * domain.RARE_TO_COIN
-					case domain.CardRarityUncommon:
-						coinsToAdd += coins * domain.UNCOMMON_TO_COIN
-					case domain.CardRarityCommon:
-						coinsToAdd += coins * domain.COMMON_TO_COIN
-					}
-					cardIdent.Amount = 4
-				}
-			}
+	}
+	ownedCards, err := db.GetOwnedCardsByNames(tournamentID, userID, names)
+	if err != nil {
+		return nil, apiErrors.ErrInternal
+	}
+	copiesByName := make(map[string]int)
+	for _, ownedCard := range ownedCards {
+		copiesByName[ownedCard.CardData.Name] += ownedCard.Count
+	}
+
+	// Imports always convert extra copies, using the tournament's limit if it has one
+	maxCopies := 4
+	if tournament.DuplicateProtection.Enabled {
+		maxCopies = tournament.DuplicateProtection.MaxCopies
+	}
+
+	ownedCardsToAdd := make([]domain.OwnedCard, 0, len(report.Matched))
+	for i, importedCard := range report.Matched {
+		kept := importedCard.Count
+		if !deckvalidation.IgnoresCopyLimit(importedCard.Card) {
+			kept = min(kept, max(maxCopies-copiesByName[importedCard.Card.Name], 0))
 		}
-		newOwnedCard := domain.OwnedCard{
-			ID:           primitive.NewObjectID(),
-			CardData:     foundCard,
-			TournamentID: dbTournamentID,
-			UserID:       dbUserID,
-			Count:        cardIdent.Amount,
+		copiesByName[importedCard.Card.Name] += kept
+		report.Matched[i].Kept = kept
+		report.Matched[i].Converted = importedCard.Count - kept
+		report.Matched[i].Coins = report.Matched[i].Converted * domain.CardCoinValue(importedCard.Card, tournament.CoinValues)
+		report.Coins += report.Matched[i].Coins
+
+		if kept > 0 {
+			ownedCardsToAdd = append(ownedCardsToAdd, domain.OwnedCard{
+				ID:           primitive.NewObjectID(),
+				CardData:     importedCard.Card,
+				TournamentID: tournamentPlayer.TournamentID,
+				UserID:       tournamentPlayer.UserID,
+				Tags:         []string{},
+				Count:        kept,
+			})
 		}
-		ownedCards = append(ownedCards, newOwnedCard)
 	}
-	db.AddCoinsToTournamentPlayer(coinsToAdd, userID, tournamentID)
-	return db.ImportCollection(ownedCards)
+
+	if dryRun {
+		return &report, nil
+	}
+
+	cause := domain.LedgerCause{
+		ActorID: tournamentPlayer.UserID,
+		Reason:  domain.LedgerReasonCollectionImport,
+	}
+	err = db.ImportCollection(ownedCardsToAdd, tournamentPlayer.TournamentID, tournamentPlayer.ID, report.Coins, cause)
+	if err != nil {
+		return nil, apiErrors.ErrInternal
+	}
+	return &report, nil
 }
 
 func SetTagsToOwnedCard(ownerID, ownedCardID string, tags []string) error {
@@ -162,63 +358,602 @@ func SetTagsToOwnedCard(ownerID, ownedCardID string, tags []string) error {
 		return apiErrors.ErrUnauthorized
 	}
 
-	ownedCard.Tags = tags
-	db.UpdateOwnedCard(ownedCard)
+	if tags == nil {
+		tags = []string{}
+	}
+	// Only the tags are written, the count may have changed since the card was read
+	err = db.UpdateOwnedCardTags(ownedCard.ID, tags)
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			return apiErrors.ErrNotFound
+		}
+		return apiErrors.ErrInternal
+	}
 
 	return nil
 }
 
-func TradeUpCards(cards map[string]int, ownerID, tournamentID string) ([]domain.CardData, error) {
-	weightBySet := make(map[string]int, 0)
-	weightByRarity := make(map[string]int, 0)
-	totalCardCount := 0
-	for ownedCardId, count := range cards {
-		totalCardCount += count
-		ownedCard, err := db.GetOwnedCardById(ownedCardId)
-		if err != nil {
-			return nil, apiErrors.ErrBadRequest
+// GetTags returns every tag on the player's collection, with how many cards have it
+func GetTags(userID, tournamentID string) ([]domain.TagCount, error) {
+	tagCounts, err := db.GetTagCounts(userID, tournamentID)
+	if err != nil {
+		return nil, mapTagError(err)
+	}
+	return tagCounts, nil
+}
+
+// TagCollectionCards adds a tag to, or removes it from, every card on the player's collection that matches the
+// query. The query is the same one used to browse the collection. The amount of cards changed is returned.
+func TagCollectionCards(userID, tournamentID, query, filters, tag string, remove bool) (int, error) {
+	tag = strings.TrimSpace(tag)
+	if tag == "" {
+		return 0, apiErrors.ErrBadRequest
+	}
+	cardFilter, _, err := compileCollectionQuery(query, filters, "")
+	if err != nil {
+		return 0, err
+	}
+
+	changed, err := db.SetTagOnCards(userID, tournamentID, cardFilter, tag, remove)
+	if err != nil {
+		return 0, mapTagError(err)
+	}
+	return changed, nil
+}
+
+// RenameTag replaces a tag with another across the player's whole collection
+func RenameTag(userID, tournamentID, tag, newTag string) (int, error) {
+	newTag = strings.TrimSpace(newTag)
+	if tag == "" || newTag == "" || tag == newTag {
+		return 0, apiErrors.ErrBadRequest
+	}
+
+	renamed, err := db.RenameTag(userID, tournamentID, tag, newTag)
+	if err != nil {
+		return 0, mapTagError(err)
+	}
+	return renamed, nil
+}
+
+// DeleteTag removes a tag from every card of the player's collection
+func DeleteTag(userID, tournamentID, tag string) (int, error) {
+	if tag == "" {
+		return 0, apiErrors.ErrBadRequest
+	}
+
+	deleted, err := db.SetTagOnCards(userID, tournamentID, bson.M{"tags": tag}, tag, true)
+	if err != nil {
+		return 0, mapTagError(err)
+	}
+	return deleted, nil
+}
+
+func mapTagError(err error) error {
+	if errors.Is(err, db.ErrInvalidID) {
+		return apiErrors.ErrBadRequest
+	}
+	return apiErrors.ErrInternal
+}
+
+type TradeUpRecipeOdds struct {
+	Recipe domain.TradeUpRecipe `json:"recipe"`
+	// Set or rarity are empty when they depend on the traded cards
+	Odds []domain.TradeUpOdds `json:"odds"`
+}
+
+// GetTradeUpRecipes returns the recipes players can trade up their cards with on a tournament, and the odds of
+// what each of them gives
+func GetTradeUpRecipes(tournamentID string) ([]TradeUpRecipeOdds, error) {
+	tournament, err := db.GetTournamentByID(tournamentID)
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			return nil, apiErrors.ErrNotFound
 		}
-		if ownedCard.UserID.Hex() != ownerID {
-			return nil, apiErrors.ErrUnauthorized
+		if errors.Is(err, db.ErrInvalidID) {
+			return nil, apiErrors.ErrBadRequest
 		}
-		weightBySet[ownedCard.CardData.SetCode] += count
-		weightByRarity[string(ownedCard.CardData.Rarity)] += count
+		return nil, apiErrors.ErrInternal
 	}
 
-	if totalCardCount != 30 {
-		return nil, apiErrors.ErrBadRequest
+	recipes := domain.TournamentTradeUpRecipes(*tournament)
+	recipeOdds := make([]TradeUpRecipeOdds, 0, len(recipes))
+	for _, recipe := range recipes {
+		recipeOdds = append(recipeOdds, TradeUpRecipeOdds{
+			Recipe: recipe,
+			Odds:   domain.CalculateTradeUpOdds(recipe, nil, nil),
+		})
 	}
+	return recipeOdds, nil
+}
 
-	options := make([]domain.Option, 0)
-	for set, setWeight := range weightBySet {
-		for rarity, rarityWeight := range weightByRarity {
-			options = append(options, domain.Option{Filter: fmt.Sprintf("set:%s rarity:%s", set, rarity), Weight: setWeight * rarityWeight})
-		}
+// PreviewTradeUp returns the odds of what trading up the cards with a recipe would give, without trading them
+func PreviewTradeUp(cards map[string]int, recipeID, ownerID, tournamentID string) ([]domain.TradeUpOdds, error) {
+	_, odds, err := prepareTradeUp(cards, recipeID, ownerID, tournamentID)
+	if err != nil {
+		return nil, err
+	}
+	return odds, nil
+}
+
+// TradeUpCards trades the cards in for new ones, following one of the tournament's recipes
+func TradeUpCards(cards map[string]int, recipeID, ownerID, tournamentID string) ([]domain.CardData, error) {
+	recipe, odds, err := prepareTradeUp(cards, recipeID, ownerID, tournamentID)
+	if err != nil {
+		return nil, err
+	}
+
+	options := make([]domain.Option, 0, len(odds))
+	for _, option := range odds {
+		options = append(options, domain.Option{Filter: fmt.Sprintf("set:%s rarity:%s", option.SetCode, option.Rarity), Weight: option.Weight})
 	}
 	boosterPack := domain.BoosterPack{
 		SetCode:   "TRADEUP",
-		CardCount: 5,
-		Filter:    "-type:basic",
+		CardCount: recipe.OutputCount,
+		Filter:    recipe.Filter,
 		Slots: []domain.BoosterPackSlot{
 			{
 				Options: options,
 				Filter:  "",
-				Count:   5,
+				Count:   recipe.OutputCount,
 			},
 		},
 	}
 
 	log.Info().Interface("booster", boosterPack).Send()
 
-	cardsToAdd, err := boostergen.GenerateBooster("TRADEUP", boostergen.GetBoosterDataPassthrough(boosterPack))
+	cardsToAdd, err := boostergen.GenerateBooster("TRADEUP", boostergen.GetBoosterDataPassthrough(boosterPack), boostergen.NewRandom())
 	if err != nil {
 		return nil, apiErrors.ErrInternal
 	}
 
 	err = db.TradeUpCards(cards, cardsToAdd, tournamentID, ownerID)
 	if err != nil {
-		return nil, apiErrors.ErrInternal
+		log.Debug().Err(err).Msg("failed to trade up cards")
+		return nil, mapCardRemovalError(err)
 	}
 
 	return cardsToAdd, nil
 }
+
+// prepareTradeUp finds the recipe and checks that the cards can be traded up with it. If no recipe is chosen, the
+// tournament must have only one. The odds of what the cards would give are returned.
+func prepareTradeUp(cards map[string]int, recipeID, ownerID, tournamentID string) (*domain.TradeUpRecipe, []domain.TradeUpOdds, error) {
+	tournament, err := db.GetTournamentByID(tournamentID)
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			return nil, nil, apiErrors.ErrNotFound
+		}
+		if errors.Is(err, db.ErrInvalidID) {
+			return nil, nil, apiErrors.ErrBadRequest
+		}
+		return nil, nil, apiErrors.ErrInternal
+	}
+
+	var recipe *domain.TradeUpRecipe
+	recipes := domain.TournamentTradeUpRecipes(*tournament)
+	for i := range recipes {
+		if recipes[i].ID == recipeID || (recipeID == "" && len(recipes) == 1) {
+			recipe = &recipes[i]
+			break
+		}
+	}
+	if recipe == nil {
+		return nil, nil, apiErrors.ErrBadRequest
+	}
+
+	weightBySet := make(map[string]int, 0)
+	weightByRarity := make(map[domain.CardRarity]int, 0)
+	totalCardCount := 0
+	for ownedCardId, count := range cards {
+		ownedCard, err := db.GetOwnedCardById(ownedCardId)
+		if err != nil {
+			return nil, nil, apiErrors.ErrBadRequest
+		}
+		if ownedCard.UserID.Hex() != ownerID || ownedCard.TournamentID != tournament.ID {
+			return nil, nil, apiErrors.ErrUnauthorized
+		}
+		if count <= 0 || count > ownedCard.Count {
+			return nil, nil, apiErrors.ErrBadRequest
+		}
+		if len(recipe.InputRarities) > 0 && !slices.Contains(recipe.InputRarities, ownedCard.CardData.Rarity) {
+			return nil, nil, apiErrors.ErrBadRequest
+		}
+		totalCardCount += count
+		weightBySet[ownedCard.CardData.SetCode] += count
+		weightByRarity[ownedCard.CardData.Rarity] += count
+	}
+
+	if totalCardCount != recipe.InputCount {
+		return nil, nil, apiErrors.ErrBadRequest
+	}
+
+	return recipe, domain.CalculateTradeUpOdds(*recipe, weightBySet, weightByRarity), nil
+}
+
+// GetCollectionStats returns the stats and set completion of the player's collection, or of another player's if the
+// user can see it
+func GetCollectionStats(userID, tournamentID, tournamentPlayerID string) (*collectionstats.CollectionStats, error) {
+	ownerID, err := collectionOwner(userID, tournamentID, tournamentPlayerID)
+	if err != nil {
+		return nil, err
+	}
+
+	cards, err := db.GetOwnedCardsForTournament(tournamentID, ownerID)
+	if err != nil {
+		return nil, apiErrors.ErrInternal
+	}
+	stats := collectionstats.Compute(cards, getSetTotals(cards))
+	return &stats, nil
+}
+
+type PlayerCollectionStats struct {
+	TournamentPlayerID primitive.ObjectID              `json:"tournament_player_id"`
+	UserID             primitive.ObjectID              `json:"user_id"`
+	Username           string                          `json:"username"`
+	Stats              collectionstats.CollectionStats `json:"stats"`
+}
+
+type TournamentCollectionStats struct {
+	Stats   collectionstats.CollectionStats `json:"stats"`
+	Players []PlayerCollectionStats         `json:"players"`
+}
+
+// GetTournamentCollectionStats returns the stats of every collection on a tournament, and of all of them together.
+// Only administrators and moderators can see them.
+func GetTournamentCollectionStats(userID, tournamentID string) (*TournamentCollectionStats, error) {
+	tournamentPlayer, err := db.GetTournamentPlayer(tournamentID, userID)
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			return nil, apiErrors.ErrUnauthorized
+		}
+		if errors.Is(err, db.ErrInvalidID) {
+			return nil, apiErrors.ErrBadRequest
+		}
+		return nil, apiErrors.ErrInternal
+	}
+	if tournamentPlayer.AccessLevel != domain.AccessLevelAdministrator && tournamentPlayer.AccessLevel != domain.AccessLevelModerator {
+		return nil, apiErrors.ErrUnauthorized
+	}
+
+	tournamentPlayers, users, err := db.GetTournamentPlayers(tournamentID)
+	if err != nil {
+		return nil, apiErrors.ErrInternal
+	}
+	cards, err := db.GetOwnedCardsForTournament(tournamentID, "")
+	if err != nil {
+		return nil, apiErrors.ErrInternal
+	}
+	setTotals := getSetTotals(cards)
+
+	cardsByUser := make(map[primitive.ObjectID][]domain.OwnedCard)
+	for _, card := range cards {
+		cardsByUser[card.UserID] = append(cardsByUser[card.UserID], card)
+	}
+
+	tournamentStats := TournamentCollectionStats{
+		Stats:   collectionstats.Compute(cards, setTotals),
+		Players: make([]PlayerCollectionStats, 0, len(tournamentPlayers)),
+	}
+	for _, player := range tournamentPlayers {
+		playerStats := PlayerCollectionStats{
+			TournamentPlayerID: player.ID,
+			UserID:             player.UserID,
+			Stats:              collectionstats.Compute(cardsByUser[player.UserID], setTotals),
+		}
+		for _, user := range users {
+			if user.ID == player.UserID {
+				playerStats.Username = user.Username
+				break
+			}
+		}
+		tournamentStats.Players = append(tournamentStats.Players, playerStats)
+	}
+	return &tournamentStats, nil
+}
+
+// getSetTotals finds how many unique cards there are on each set the cards belong to. Sets that can't be found
+// are left out, and their completion reported as unknown.
+func getSetTotals(cards []domain.OwnedCard) map[string]collectionstats.SetTotals {
+	setTotals := make(map[string]collectionstats.SetTotals)
+	setNames := make(map[string]string)
+	sets, err := scryfall.GetAllSets()
+	if err != nil {
+		log.Error().Err(err).Msg("failed to get sets from scryfall")
+	}
+	for _, set := range sets {
+		setNames[set.Code] = set.Name
+	}
+
+	for _, card := range cards {
+		setCode := card.CardData.SetCode
+		if _, ok := setTotals[setCode]; ok {
+			continue
+		}
+		totals := collectionstats.SetTotals{
+			Name:     setNames[setCode],
+			ByRarity: make(map[domain.CardRarity]int),
+		}
+		setTotals[setCode] = totals
+
+		setCards, err := scryfall.GetSetCards(strings.ToLower(setCode))
+		if err != nil {
+			log.Error().Err(err).Str("set_code", setCode).Msg("failed to get set cards from scryfall")
+			continue
+		}
+		seenNames := make(map[string]bool)
+		for _, setCard := range setCards {
+			if seenNames[setCard.Name] {
+				continue
+			}
+			seenNames[setCard.Name] = true
+			totals.Unique += 1
+			totals.ByRarity[domain.CardRarity(setCard.Rarity)] += 1
+		}
+		setTotals[setCode] = totals
+	}
+	return setTotals
+}
+
+type DisenchantTarget string
+
+const (
+	DisenchantTargetCoins     DisenchantTarget = "dt_coins"
+	DisenchantTargetWildcards DisenchantTarget = "dt_wildcards"
+)
+
+type DisenchantResult struct {
+	Coins            int                   `json:"coins"`
+	WildcardProgress domain.OwnedWildcards `json:"wildcard_progress"`
+}
+
+// DisenchantCards trades the chosen cards for coins, by the *_TO_COIN rates, or for progress towards wildcards
+// of their rarity. Copies used by any of the player's decks can't be disenchanted.
+func DisenchantCards(cards map[string]int, target DisenchantTarget, ownerID, tournamentID string) (*DisenchantResult, error) {
+	if target != DisenchantTargetCoins && target != DisenchantTargetWildcards {
+		return nil, apiErrors.ErrBadRequest
+	}
+	tournamentPlayer, err := db.GetTournamentPlayer(tournamentID, ownerID)
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			return nil, apiErrors.ErrUnauthorized
+		}
+		if errors.Is(err, db.ErrInvalidID) {
+			return nil, apiErrors.ErrBadRequest
+		}
+		return nil, apiErrors.ErrInternal
+	}
+	decks, err := db.GetDecksForTournamentPlayer(tournamentPlayer.ID.Hex())
+	if err != nil {
+		return nil, apiErrors.ErrInternal
+	}
+
+	result := DisenchantResult{}
+	for ownedCardID, count := range cards {
+		ownedCard, err := db.GetOwnedCardById(ownedCardID)
+		if err != nil {
+			return nil, apiErrors.ErrBadRequest
+		}
+		if ownedCard.UserID != tournamentPlayer.UserID || ownedCard.TournamentID != tournamentPlayer.TournamentID {
+			return nil, apiErrors.ErrUnauthorized
+		}
+		if count <= 0 || count > ownedCard.Count {
+			return nil, apiErrors.ErrBadRequest
+		}
+
+		// Every deck must still have all of its copies
+		for _, deck := range decks {
+			used := 0
+			for _, deckCard := range deck.Cards {
+				if deckCard.OwnedCardID == ownedCard.ID {
+					used += deckCard.Count
+				}
+			}
+			if ownedCard.Count-count < used {
+				return nil, apiErrors.ErrCardsInDeck
+			}
+		}
+
+		switch target {
+		case DisenchantTargetCoins:
+			result.Coins += count * domain.CoinsForRarity(ownedCard.CardData.Rarity)
+		case DisenchantTargetWildcards:
+			result.WildcardProgress = domain.AddWildcards(result.WildcardProgress, ownedCard.CardData.Rarity, count)
+		}
+	}
+
+	err = db.DisenchantCards(tournamentPlayer.ID.Hex(), cards, result.Coins, result.WildcardProgress, domain.LedgerCause{
+		ActorID: tournamentPlayer.UserID,
+		Reason:  domain.LedgerReasonDisenchant,
+	})
+	if err != nil {
+		log.Debug().Err(err).Msg("failed to disenchant cards")
+		return nil, mapCardRemovalError(err)
+	}
+	return &result, nil
+}
+
+// mapCardRemovalError maps the errors of taking cards out of a collection
+func mapCardRemovalError(err error) error {
+	switch {
+	case errors.Is(err, db.ErrInvalidID):
+		return apiErrors.ErrBadRequest
+	case errors.Is(err, db.ErrNotFound):
+		return apiErrors.ErrNotFound
+	case errors.Is(err, db.ErrNotEnough):
+		return apiErrors.ErrNotEnough
+	case errors.Is(err, db.ErrCardsInUse):
+		return apiErrors.ErrCardsInDeck
+	}
+	return apiErrors.ErrInternal
+}
+
+const (
+	DEFAULT_TIMELINE_PAGE_SIZE = 50
+	MAX_TIMELINE_PAGE_SIZE     = 200
+)
+
+// GetCardProvenance returns where the copies of an owned card came from: every time its printing was acquired
+// or removed from the owner's collection, and the previous owners on trades and auctions. Owners can see their
+// own cards' provenance; administrators and moderators can see anyone's.
+func GetCardProvenance(userID, ownedCardID string) ([]domain.CardMovement, error) {
+	ownedCard, err := db.GetOwnedCardById(ownedCardID)
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			return nil, apiErrors.ErrNotFound
+		}
+		return nil, apiErrors.ErrBadRequest
+	}
+	owner, err := db.GetTournamentPlayer(ownedCard.TournamentID.Hex(), ownedCard.UserID.Hex())
+	if err != nil {
+		return nil, apiErrors.ErrInternal
+	}
+	if owner.UserID.Hex() != userID {
+		requester, err := db.GetTournamentPlayer(ownedCard.TournamentID.Hex(), userID)
+		if err != nil {
+			return nil, apiErrors.ErrUnauthorized
+		}
+		if requester.AccessLevel != domain.AccessLevelAdministrator && requester.AccessLevel != domain.AccessLevelModerator {
+			return nil, apiErrors.ErrUnauthorized
+		}
+	}
+
+	movements, err := db.GetCardProvenance(owner.ID.Hex(), ownedCard.CardData)
+	if err != nil {
+		return nil, apiErrors.ErrInternal
+	}
+	return movements, nil
+}
+
+// GetAcquisitionTimeline returns a page of the cards a player acquired, newest first. Players can see their own
+// timeline, which is the default; administrators and moderators can see anyone's.
+func GetAcquisitionTimeline(userID, tournamentID, tournamentPlayerID string, count, page int) ([]domain.CardMovement, int, error) {
+	requester, err := db.GetTournamentPlayer(tournamentID, userID)
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			return nil, 0, apiErrors.ErrUnauthorized
+		}
+		if errors.Is(err, db.ErrInvalidID) {
+			return nil, 0, apiErrors.ErrBadRequest
+		}
+		return nil, 0, apiErrors.ErrInternal
+	}
+	if tournamentPlayerID == "" {
+		tournamentPlayerID = requester.ID.Hex()
+	}
+	if tournamentPlayerID != requester.ID.Hex() {
+		if requester.AccessLevel != domain.AccessLevelAdministrator && requester.AccessLevel != domain.AccessLevelModerator {
+			return nil, 0, apiErrors.ErrUnauthorized
+		}
+	}
+
+	if count <= 0 {
+		count = DEFAULT_TIMELINE_PAGE_SIZE
+	}
+	count = min(count, MAX_TIMELINE_PAGE_SIZE)
+	page = max(page, 1)
+
+	movements, total, err := db.GetCardMovements(tournamentID, tournamentPlayerID, true, count, page)
+	if err != nil {
+		if errors.Is(err, db.ErrInvalidID) {
+			return nil, 0, apiErrors.ErrBadRequest
+		}
+		return nil, 0, apiErrors.ErrInternal
+	}
+	return movements, total, nil
+}
+
+// CardOwner is a player that has copies of a card to spare
+type CardOwner struct {
+	TournamentPlayerID primitive.ObjectID `json:"tournament_player_id"`
+	Username           string             `json:"username"`
+	OwnedCardID        primitive.ObjectID `json:"owned_card_id"`
+	CardData           domain.CardData    `json:"card_data"`
+	Count              int                `json:"count"`
+	// Copies used by the owner's decks
+	InUse int `json:"in_use"`
+}
+
+// FindCardOwners returns the other players of the tournament that own a card, optionally of a set. Copies that are
+// all used by the owner's decks, and collections the user can't see, are left out.
+func FindCardOwners(userID, tournamentID, cardName, setCode string) ([]CardOwner, error) {
+	requester, err := db.GetTournamentPlayer(tournamentID, userID)
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			return nil, apiErrors.ErrUnauthorized
+		}
+		if errors.Is(err, db.ErrInvalidID) {
+			return nil, apiErrors.ErrBadRequest
+		}
+		return nil, apiErrors.ErrInternal
+	}
+	if strings.TrimSpace(cardName) == "" {
+		return nil, apiErrors.ErrBadRequest
+	}
+
+	ownedCards, err := db.GetOwnedCardsByNames(tournamentID, "", []string{strings.TrimSpace(cardName)})
+	if err != nil {
+		return nil, apiErrors.ErrInternal
+	}
+	tournamentPlayers, users, err := db.GetTournamentPlayers(tournamentID)
+	if err != nil {
+		return nil, apiErrors.ErrInternal
+	}
+	playersByUser := map[primitive.ObjectID]domain.TournamentPlayer{}
+	for _, tournamentPlayer := range tournamentPlayers {
+		playersByUser[tournamentPlayer.UserID] = tournamentPlayer
+	}
+	usernames := map[primitive.ObjectID]string{}
+	for _, user := range users {
+		usernames[user.ID] = user.Username
+	}
+
+	candidates := []domain.OwnedCard{}
+	ownedCardIDs := []primitive.ObjectID{}
+	for _, ownedCard := range ownedCards {
+		if ownedCard.UserID == requester.UserID {
+			continue
+		}
+		if setCode != "" && !strings.EqualFold(ownedCard.CardData.SetCode, setCode) {
+			continue
+		}
+		owner, ok := playersByUser[ownedCard.UserID]
+		if !ok || !domain.CanSeeCollection(owner, requester) {
+			continue
+		}
+		candidates = append(candidates, ownedCard)
+		ownedCardIDs = append(ownedCardIDs, ownedCard.ID)
+	}
+	decks, err := db.GetDecksWithOwnedCards(ownedCardIDs)
+	if err != nil {
+		return nil, apiErrors.ErrInternal
+	}
+
+	owners := []CardOwner{}
+	for _, ownedCard := range candidates {
+		owner := playersByUser[ownedCard.UserID]
+		ownerDecks := []domain.Deck{}
+		for _, deck := range decks {
+			if deck.TournamentPlayerID == owner.ID {
+				ownerDecks = append(ownerDecks, deck)
+			}
+		}
+		inUse := domain.CopiesInUse(ownerDecks, ownedCard.ID)
+		if inUse >= ownedCard.Count {
+			continue
+		}
+		owners = append(owners, CardOwner{
+			TournamentPlayerID: owner.ID,
+			Username:           usernames[owner.UserID],
+			OwnedCardID:        ownedCard.ID,
+			CardData:           ownedCard.CardData,
+			Count:              ownedCard.Count,
+			InUse:              inUse,
+		})
+	}
+
+	// Players with the most copies to spare first
+	slices.SortFunc(owners, func(a, b CardOwner) int {
+		return cmp.Or(cmp.Compare(b.Count-b.InUse, a.Count-a.InUse), cmp.Compare(a.Username, b.Username))
+	})
+	return owners, nil
+}
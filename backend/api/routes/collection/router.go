@@ -1,8 +1,9 @@
 package collection
 
 import (
-	"encoding/csv"
 	"encoding/json"
+	"fmt"
+	"io"
 	"math"
 	"net/http"
 	"strconv"
@@ -11,6 +12,8 @@ import (
 	"github.com/joaquinleonarg/wdml-mtg/backend/api/response"
 	"github.com/joaquinleonarg/wdml-mtg/backend/api/routes/auth"
 	"github.com/joaquinleonarg/wdml-mtg/backend/domain"
+	collectionexport "github.com/joaquinleonarg/wdml-mtg/backend/internal/collection_export"
+	collectionstats "github.com/joaquinleonarg/wdml-mtg/backend/internal/collection_stats"
 	"github.com/rs/zerolog/log"
 )
 
@@ -18,8 +21,21 @@ func RegisterEndpoints(r *mux.Router) {
 	r = r.PathPrefix("/collection").Subrouter()
 	r.HandleFunc("", GetCollectionHandler).Methods(http.MethodGet)
 	r.HandleFunc("/import", ImportCollectionHandler).Methods(http.MethodPost)
+	r.HandleFunc("/export", ExportCollectionHandler).Methods(http.MethodGet)
+	r.HandleFunc("/stats", GetCollectionStatsHandler).Methods(http.MethodGet)
+	r.HandleFunc("/stats/tournament", GetTournamentCollectionStatsHandler).Methods(http.MethodGet)
 	r.HandleFunc("/tag", SetTagsForCollectionCardHandler).Methods(http.MethodPost)
+	r.HandleFunc("/tags", GetTagsHandler).Methods(http.MethodGet)
+	r.HandleFunc("/tags/bulk", BulkTagHandler).Methods(http.MethodPost)
+	r.HandleFunc("/tags/rename", RenameTagHandler).Methods(http.MethodPost)
+	r.HandleFunc("/tags/delete", DeleteTagHandler).Methods(http.MethodPost)
 	r.HandleFunc("/tradeup", TradeUpCardsHandler).Methods(http.MethodPost)
+	r.HandleFunc("/tradeup/recipes", GetTradeUpRecipesHandler).Methods(http.MethodGet)
+	r.HandleFunc("/tradeup/preview", PreviewTradeUpHandler).Methods(http.MethodPost)
+	r.HandleFunc("/disenchant", DisenchantCardsHandler).Methods(http.MethodPost)
+	r.HandleFunc("/provenance", GetCardProvenanceHandler).Methods(http.MethodGet)
+	r.HandleFunc("/timeline", GetAcquisitionTimelineHandler).Methods(http.MethodGet)
+	r.HandleFunc("/owners", FindCardOwnersHandler).Methods(http.MethodGet)
 }
 
 //
@@ -42,6 +58,8 @@ func GetCollectionHandler(w http.ResponseWriter, r *http.Request) {
 	if tournamentID == "" {
 		http.Error(w, "", http.StatusBadRequest)
 	}
+	// Another player's collection, if set
+	tournamentPlayerID := r.URL.Query().Get("tournament_player_id")
 
 	// Get filters, count and page
 	count := 0
@@ -70,7 +88,10 @@ func GetCollectionHandler(w http.ResponseWriter, r *http.Request) {
 		page = val
 	}
 
+	// The query language takes precedence over the older filters
+	cardQuery := r.URL.Query().Get("query")
 	filterQuery := r.URL.Query().Get("filters")
+	sortQuery := r.URL.Query().Get("sort")
 
 	// Get user ID from request context
 	userID, ok := r.Context().Value("user_id").(string)
@@ -81,11 +102,12 @@ func GetCollectionHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	cards, total, err := GetCollectionCards(userID, tournamentID, filterQuery, count, page)
-
+	cards, total, err := GetCollectionCards(userID, tournamentID, tournamentPlayerID, cardQuery, filterQuery, sortQuery, count, page)
 	if err != nil {
 		log.Debug().Err(err).Msg("failed to get cards from collection")
-		w.WriteHeader(http.StatusInternalServerError)
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write(response.NewErrorResponse(err))
+		return
 	}
 
 	// Send response back
@@ -99,6 +121,14 @@ func GetCollectionHandler(w http.ResponseWriter, r *http.Request) {
 	))
 }
 
+//
+// ENDPOINT: Import a collection export from ManaBox, Moxfield, Deckbox, Delver Lens, TCGplayer or Arena
+//
+
+type ImportCollectionResponse struct {
+	Report *CollectionImportReport `json:"report"`
+}
+
 func ImportCollectionHandler(w http.ResponseWriter, r *http.Request) {
 	log := log.With().Ctx(r.Context()).Str("path", r.URL.Path).Logger()
 
@@ -106,29 +136,91 @@ func ImportCollectionHandler(w http.ResponseWriter, r *http.Request) {
 	userID, err := auth.GetUserIDFromContext(r.Context())
 	if err != nil {
 		http.Error(w, "", http.StatusBadRequest)
+		return
 	}
 
-	// Get tournament ID from query
+	// Get tournament ID and dry run from query
 	tournamentID := r.URL.Query().Get("tournament_id")
 	if tournamentID == "" {
 		http.Error(w, "", http.StatusBadRequest)
+		return
 	}
-	// Decode body data
-	csvReader := csv.NewReader(r.Body)
-	allCards, err := csvReader.ReadAll()
+	dryRun := r.URL.Query().Get("dry_run") == "true"
+
+	// Read body data
+	rawCollection, err := io.ReadAll(r.Body)
 	if err != nil {
-		log.Debug().Err(err).Msg("failed to import cards")
-		w.WriteHeader(http.StatusInternalServerError)
+		log.Debug().Err(err).Msg("failed to read request body")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write(response.NewErrorResponse(err))
+		return
 	}
-	err = ImportCollection(allCards, userID, tournamentID)
+
+	report, err := ImportCollection(string(rawCollection), userID, tournamentID, dryRun)
 	if err != nil {
 		log.Debug().Err(err).Msg("failed to import cards")
-		w.WriteHeader(http.StatusInternalServerError)
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write(response.NewErrorResponse(err))
+		return
 	}
 
 	// Send response back
 	w.WriteHeader(http.StatusOK)
-	w.Write(response.NewDataResponse(EmptyResponse{}))
+	w.Write(response.NewDataResponse(ImportCollectionResponse{Report: report}))
+}
+
+//
+// ENDPOINT: Export the collection as Moxfield or ManaBox CSV, Arena text or JSON
+//
+
+func ExportCollectionHandler(w http.ResponseWriter, r *http.Request) {
+	log := log.With().Ctx(r.Context()).Str("path", r.URL.Path).Logger()
+
+	// Get user ID from context
+	userID, err := auth.GetUserIDFromContext(r.Context())
+	if err != nil {
+		http.Error(w, "", http.StatusBadRequest)
+		return
+	}
+
+	// Get tournament ID, player, format, filters and sort from query
+	tournamentID := r.URL.Query().Get("tournament_id")
+	format := collectionexport.Format(r.URL.Query().Get("format"))
+	if tournamentID == "" || format == "" {
+		http.Error(w, "", http.StatusBadRequest)
+		return
+	}
+	tournamentPlayerID := r.URL.Query().Get("tournament_player_id")
+	cardQuery := r.URL.Query().Get("query")
+	filterQuery := r.URL.Query().Get("filters")
+	sortQuery := r.URL.Query().Get("sort")
+
+	contentType, extension := collectionexport.ContentType(format)
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"collection.%s\"", extension))
+
+	// Cards are written directly to the response, so errors can only be reported before the first one
+	exportWriter := &exportResponseWriter{ResponseWriter: w}
+	err = ExportCollection(exportWriter, userID, tournamentID, tournamentPlayerID, cardQuery, filterQuery, sortQuery, format)
+	if err != nil {
+		log.Debug().Err(err).Msg("failed to export collection")
+		if !exportWriter.started {
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Del("Content-Disposition")
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write(response.NewErrorResponse(err))
+		}
+	}
+}
+
+type exportResponseWriter struct {
+	http.ResponseWriter
+	started bool
+}
+
+func (ew *exportResponseWriter) Write(data []byte) (int, error) {
+	ew.started = true
+	return ew.ResponseWriter.Write(data)
 }
 
 //
@@ -178,8 +270,209 @@ func SetTagsForCollectionCardHandler(w http.ResponseWriter, r *http.Request) {
 	w.Write(response.NewDataResponse(SetTagsForCollectionCardResponse{}))
 }
 
+//
+// ENDPOINT: List the tags on the player's collection and how many cards have each
+//
+
+type GetTagsResponse struct {
+	Tags []domain.TagCount `json:"tags"`
+}
+
+func GetTagsHandler(w http.ResponseWriter, r *http.Request) {
+	log := log.With().Ctx(r.Context()).Str("path", r.URL.Path).Logger()
+
+	// Get user ID from context
+	userID, err := auth.GetUserIDFromContext(r.Context())
+	if err != nil {
+		http.Error(w, "", http.StatusBadRequest)
+		return
+	}
+
+	// Get tournament ID from query
+	tournamentID := r.URL.Query().Get("tournament_id")
+	if tournamentID == "" {
+		http.Error(w, "", http.StatusBadRequest)
+		return
+	}
+
+	tags, err := GetTags(userID, tournamentID)
+	if err != nil {
+		log.Debug().Err(err).Msg("failed to get tags")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write(response.NewErrorResponse(err))
+		return
+	}
+
+	// Send response back
+	w.WriteHeader(http.StatusOK)
+	w.Write(response.NewDataResponse(GetTagsResponse{Tags: tags}))
+}
+
+//
+// ENDPOINT: Add or remove a tag on every card of the player's collection matching a query
+//
+
+type BulkTagRequest struct {
+	Query   string `json:"query"`
+	Filters string `json:"filters"`
+	Tag     string `json:"tag"`
+	Remove  bool   `json:"remove"`
+}
+
+type TagChangeResponse struct {
+	Changed int `json:"changed"`
+}
+
+func BulkTagHandler(w http.ResponseWriter, r *http.Request) {
+	log := log.With().Ctx(r.Context()).Str("path", r.URL.Path).Logger()
+
+	// Get user ID from context
+	userID, err := auth.GetUserIDFromContext(r.Context())
+	if err != nil {
+		http.Error(w, "", http.StatusBadRequest)
+		return
+	}
+
+	// Get tournament ID from query
+	tournamentID := r.URL.Query().Get("tournament_id")
+	if tournamentID == "" {
+		http.Error(w, "", http.StatusBadRequest)
+		return
+	}
+
+	// Decode body data
+	var req BulkTagRequest
+	err = json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		log.Debug().
+			Err(err).
+			Msg("failed to read request body")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write(response.NewErrorResponse(err))
+		return
+	}
+
+	changed, err := TagCollectionCards(userID, tournamentID, req.Query, req.Filters, req.Tag, req.Remove)
+	if err != nil {
+		log.Debug().Err(err).Msg("failed to tag cards")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write(response.NewErrorResponse(err))
+		return
+	}
+
+	// Send response back
+	w.WriteHeader(http.StatusOK)
+	w.Write(response.NewDataResponse(TagChangeResponse{Changed: changed}))
+}
+
+//
+// ENDPOINT: Rename a tag across the player's collection
+//
+
+type RenameTagRequest struct {
+	Tag    string `json:"tag"`
+	NewTag string `json:"new_tag"`
+}
+
+func RenameTagHandler(w http.ResponseWriter, r *http.Request) {
+	log := log.With().Ctx(r.Context()).Str("path", r.URL.Path).Logger()
+
+	// Get user ID from context
+	userID, err := auth.GetUserIDFromContext(r.Context())
+	if err != nil {
+		http.Error(w, "", http.StatusBadRequest)
+		return
+	}
+
+	// Get tournament ID from query
+	tournamentID := r.URL.Query().Get("tournament_id")
+	if tournamentID == "" {
+		http.Error(w, "", http.StatusBadRequest)
+		return
+	}
+
+	// Decode body data
+	var req RenameTagRequest
+	err = json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		log.Debug().
+			Err(err).
+			Msg("failed to read request body")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write(response.NewErrorResponse(err))
+		return
+	}
+
+	changed, err := RenameTag(userID, tournamentID, req.Tag, req.NewTag)
+	if err != nil {
+		log.Debug().Err(err).Msg("failed to rename tag")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write(response.NewErrorResponse(err))
+		return
+	}
+
+	// Send response back
+	w.WriteHeader(http.StatusOK)
+	w.Write(response.NewDataResponse(TagChangeResponse{Changed: changed}))
+}
+
+//
+// ENDPOINT: Delete a tag from the player's collection
+//
+
+type DeleteTagRequest struct {
+	Tag string `json:"tag"`
+}
+
+func DeleteTagHandler(w http.ResponseWriter, r *http.Request) {
+	log := log.With().Ctx(r.Context()).Str("path", r.URL.Path).Logger()
+
+	// Get user ID from context
+	userID, err := auth.GetUserIDFromContext(r.Context())
+	if err != nil {
+		http.Error(w, "", http.StatusBadRequest)
+		return
+	}
+
+	// Get tournament ID from query
+	tournamentID := r.URL.Query().Get("tournament_id")
+	if tournamentID == "" {
+		http.Error(w, "", http.StatusBadRequest)
+		return
+	}
+
+	// Decode body data
+	var req DeleteTagRequest
+	err = json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		log.Debug().
+			Err(err).
+			Msg("failed to read request body")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write(response.NewErrorResponse(err))
+		return
+	}
+
+	changed, err := DeleteTag(userID, tournamentID, req.Tag)
+	if err != nil {
+		log.Debug().Err(err).Msg("failed to delete tag")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write(response.NewErrorResponse(err))
+		return
+	}
+
+	// Send response back
+	w.WriteHeader(http.StatusOK)
+	w.Write(response.NewDataResponse(TagChangeResponse{Changed: changed}))
+}
+
+//
+// ENDPOINT: Trade up cards with one of the tournament's recipes
+//
+
 type TradeUpCardsRequest struct {
-	Cards map[string]int `json:"cards"`
+	Cards    map[string]int `json:"cards"`
+	RecipeID string         `json:"recipe_id"`
 }
 
 type TradeUpCardsResponse struct {
@@ -217,7 +510,7 @@ func TradeUpCardsHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	cards, err := TradeUpCards(req.Cards, ownerID, tournamentID)
+	cards, err := TradeUpCards(req.Cards, req.RecipeID, ownerID, tournamentID)
 
 	if err != nil {
 		w.WriteHeader(http.StatusBadRequest)
@@ -229,3 +522,374 @@ func TradeUpCardsHandler(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 	w.Write(response.NewDataResponse(TradeUpCardsResponse{Cards: cards}))
 }
+
+//
+// ENDPOINT: Get the tournament's trade up recipes and their odds
+//
+
+type GetTradeUpRecipesResponse struct {
+	Recipes []TradeUpRecipeOdds `json:"recipes"`
+}
+
+func GetTradeUpRecipesHandler(w http.ResponseWriter, r *http.Request) {
+	log := log.With().Ctx(r.Context()).Str("path", r.URL.Path).Logger()
+
+	// Get tournament ID from query
+	tournamentID := r.URL.Query().Get("tournament_id")
+	if tournamentID == "" {
+		http.Error(w, "", http.StatusBadRequest)
+		return
+	}
+
+	recipes, err := GetTradeUpRecipes(tournamentID)
+	if err != nil {
+		log.Debug().Err(err).Msg("failed to get trade up recipes")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write(response.NewErrorResponse(err))
+		return
+	}
+
+	// Send response back
+	w.WriteHeader(http.StatusOK)
+	w.Write(response.NewDataResponse(GetTradeUpRecipesResponse{Recipes: recipes}))
+}
+
+//
+// ENDPOINT: Get the odds of trading up cards with a recipe, without trading them
+//
+
+type PreviewTradeUpResponse struct {
+	Odds []domain.TradeUpOdds `json:"odds"`
+}
+
+func PreviewTradeUpHandler(w http.ResponseWriter, r *http.Request) {
+	log := log.With().Ctx(r.Context()).Str("path", r.URL.Path).Logger()
+
+	// Get user ID from request context
+	ownerID, ok := r.Context().Value("user_id").(string)
+	if ownerID == "" || !ok {
+		log.Debug().
+			Msg("failed to read user id from context")
+		http.Error(w, "", http.StatusForbidden)
+		return
+	}
+
+	// Get tournament ID from query
+	tournamentID := r.URL.Query().Get("tournament_id")
+	if tournamentID == "" {
+		http.Error(w, "", http.StatusBadRequest)
+		return
+	}
+
+	// Decode body data
+	var req TradeUpCardsRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		log.Debug().
+			Err(err).
+			Msg("failed to read request body")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write(response.NewErrorResponse(err))
+		return
+	}
+
+	odds, err := PreviewTradeUp(req.Cards, req.RecipeID, ownerID, tournamentID)
+	if err != nil {
+		log.Debug().Err(err).Msg("failed to preview trade up")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write(response.NewErrorResponse(err))
+		return
+	}
+
+	// Send response back
+	w.WriteHeader(http.StatusOK)
+	w.Write(response.NewDataResponse(PreviewTradeUpResponse{Odds: odds}))
+}
+
+//
+// ENDPOINT: Get the stats and set completion of the player's collection
+//
+
+type GetCollectionStatsResponse struct {
+	Stats *collectionstats.CollectionStats `json:"stats"`
+}
+
+func GetCollectionStatsHandler(w http.ResponseWriter, r *http.Request) {
+	log := log.With().Ctx(r.Context()).Str("path", r.URL.Path).Logger()
+
+	// Get user ID from context
+	userID, err := auth.GetUserIDFromContext(r.Context())
+	if err != nil {
+		http.Error(w, "", http.StatusBadRequest)
+		return
+	}
+
+	// Get tournament ID and player from query
+	tournamentID := r.URL.Query().Get("tournament_id")
+	if tournamentID == "" {
+		http.Error(w, "", http.StatusBadRequest)
+		return
+	}
+	tournamentPlayerID := r.URL.Query().Get("tournament_player_id")
+
+	stats, err := GetCollectionStats(userID, tournamentID, tournamentPlayerID)
+	if err != nil {
+		log.Debug().Err(err).Msg("failed to get collection stats")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write(response.NewErrorResponse(err))
+		return
+	}
+
+	// Send response back
+	w.WriteHeader(http.StatusOK)
+	w.Write(response.NewDataResponse(GetCollectionStatsResponse{Stats: stats}))
+}
+
+//
+// ENDPOINT: Get the stats of every collection on a tournament
+//
+
+type GetTournamentCollectionStatsResponse struct {
+	Stats *TournamentCollectionStats `json:"stats"`
+}
+
+func GetTournamentCollectionStatsHandler(w http.ResponseWriter, r *http.Request) {
+	log := log.With().Ctx(r.Context()).Str("path", r.URL.Path).Logger()
+
+	// Get user ID from context
+	userID, err := auth.GetUserIDFromContext(r.Context())
+	if err != nil {
+		http.Error(w, "", http.StatusBadRequest)
+		return
+	}
+
+	// Get tournament ID from query
+	tournamentID := r.URL.Query().Get("tournament_id")
+	if tournamentID == "" {
+		http.Error(w, "", http.StatusBadRequest)
+		return
+	}
+
+	stats, err := GetTournamentCollectionStats(userID, tournamentID)
+	if err != nil {
+		log.Debug().Err(err).Msg("failed to get tournament collection stats")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write(response.NewErrorResponse(err))
+		return
+	}
+
+	// Send response back
+	w.WriteHeader(http.StatusOK)
+	w.Write(response.NewDataResponse(GetTournamentCollectionStatsResponse{Stats: stats}))
+}
+
+//
+// ENDPOINT: Disenchant cards into coins or wildcard progress
+//
+
+type DisenchantCardsRequest struct {
+	Cards  map[string]int   `json:"cards"`
+	Target DisenchantTarget `json:"target"`
+}
+
+type DisenchantCardsResponse struct {
+	Result *DisenchantResult `json:"result"`
+}
+
+func DisenchantCardsHandler(w http.ResponseWriter, r *http.Request) {
+	log := log.With().Ctx(r.Context()).Str("path", r.URL.Path).Logger()
+
+	// Get user ID from request context
+	ownerID, ok := r.Context().Value("user_id").(string)
+	if ownerID == "" || !ok {
+		log.Debug().
+			Msg("failed to read user id from context")
+		http.Error(w, "", http.StatusForbidden)
+		return
+	}
+
+	// Get tournament ID from query
+	tournamentID := r.URL.Query().Get("tournament_id")
+	if tournamentID == "" {
+		http.Error(w, "", http.StatusBadRequest)
+		return
+	}
+
+	// Decode body data
+	var req DisenchantCardsRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		log.Debug().
+			Err(err).
+			Msg("failed to read request body")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write(response.NewErrorResponse(err))
+		return
+	}
+
+	result, err := DisenchantCards(req.Cards, req.Target, ownerID, tournamentID)
+	if err != nil {
+		log.Debug().Err(err).Msg("failed to disenchant cards")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write(response.NewErrorResponse(err))
+		return
+	}
+
+	// Send response back
+	w.WriteHeader(http.StatusOK)
+	w.Write(response.NewDataResponse(DisenchantCardsResponse{Result: result}))
+}
+
+//
+// ENDPOINT: Get where the copies of an owned card came from
+//
+
+type GetCardProvenanceResponse struct {
+	Movements []domain.CardMovement `json:"movements"`
+}
+
+func GetCardProvenanceHandler(w http.ResponseWriter, r *http.Request) {
+	log := log.With().Ctx(r.Context()).Str("path", r.URL.Path).Logger()
+
+	// Get user ID from context
+	userID, err := auth.GetUserIDFromContext(r.Context())
+	if err != nil {
+		http.Error(w, "", http.StatusBadRequest)
+		return
+	}
+
+	// Get owned card ID from query
+	ownedCardID := r.URL.Query().Get("owned_card_id")
+	if ownedCardID == "" {
+		http.Error(w, "", http.StatusBadRequest)
+		return
+	}
+
+	movements, err := GetCardProvenance(userID, ownedCardID)
+	if err != nil {
+		log.Debug().Err(err).Msg("failed to get card provenance")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write(response.NewErrorResponse(err))
+		return
+	}
+
+	// Send response back
+	w.WriteHeader(http.StatusOK)
+	w.Write(response.NewDataResponse(GetCardProvenanceResponse{Movements: movements}))
+}
+
+//
+// ENDPOINT: Browse the cards a player acquired, newest first
+//
+
+type GetAcquisitionTimelineResponse struct {
+	Movements   []domain.CardMovement `json:"movements"`
+	CurrentPage int                   `json:"current_page"`
+	TotalPages  int                   `json:"total_pages"`
+}
+
+func GetAcquisitionTimelineHandler(w http.ResponseWriter, r *http.Request) {
+	log := log.With().Ctx(r.Context()).Str("path", r.URL.Path).Logger()
+
+	// Get user ID from context
+	userID, err := auth.GetUserIDFromContext(r.Context())
+	if err != nil {
+		http.Error(w, "", http.StatusBadRequest)
+		return
+	}
+
+	// Get tournament ID and player from query
+	tournamentID := r.URL.Query().Get("tournament_id")
+	if tournamentID == "" {
+		http.Error(w, "", http.StatusBadRequest)
+		return
+	}
+	tournamentPlayerID := r.URL.Query().Get("tournament_player_id")
+
+	count := 0
+	countQuery := r.URL.Query().Get("count")
+	if countQuery != "" {
+		val, err := strconv.Atoi(countQuery)
+		if err != nil {
+			log.Debug().
+				Msg("failed to read count from query")
+			http.Error(w, "", http.StatusBadRequest)
+			return
+		}
+		count = val
+	}
+
+	page := 1
+	pageQuery := r.URL.Query().Get("page")
+	if pageQuery != "" {
+		val, err := strconv.Atoi(pageQuery)
+		if err != nil {
+			log.Debug().
+				Msg("failed to read page from query")
+			http.Error(w, "", http.StatusBadRequest)
+			return
+		}
+		page = val
+	}
+
+	movements, total, err := GetAcquisitionTimeline(userID, tournamentID, tournamentPlayerID, count, page)
+	if err != nil {
+		log.Debug().Err(err).Msg("failed to get acquisition timeline")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write(response.NewErrorResponse(err))
+		return
+	}
+
+	if count <= 0 {
+		count = DEFAULT_TIMELINE_PAGE_SIZE
+	}
+	count = min(count, MAX_TIMELINE_PAGE_SIZE)
+
+	// Send response back
+	w.WriteHeader(http.StatusOK)
+	w.Write(response.NewDataResponse(GetAcquisitionTimelineResponse{
+		Movements:   movements,
+		CurrentPage: max(page, 1),
+		TotalPages:  int(math.Ceil(float64(total) / float64(count))),
+	}))
+}
+
+//
+// ENDPOINT: Find the other players of the tournament that have copies of a card to spare
+//
+
+type FindCardOwnersResponse struct {
+	Owners []CardOwner `json:"owners"`
+}
+
+func FindCardOwnersHandler(w http.ResponseWriter, r *http.Request) {
+	log := log.With().Ctx(r.Context()).Str("path", r.URL.Path).Logger()
+
+	// Get user ID from context
+	userID, err := auth.GetUserIDFromContext(r.Context())
+	if err != nil {
+		http.Error(w, "", http.StatusBadRequest)
+		return
+	}
+
+	// Get tournament ID and card from query
+	tournamentID := r.URL.Query().Get("tournament_id")
+	cardName := r.URL.Query().Get("card_name")
+	if tournamentID == "" || cardName == "" {
+		http.Error(w, "", http.StatusBadRequest)
+		return
+	}
+	setCode := r.URL.Query().Get("set_code")
+
+	owners, err := FindCardOwners(userID, tournamentID, cardName, setCode)
+	if err != nil {
+		log.Debug().Err(err).Msg("failed to find card owners")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write(response.NewErrorResponse(err))
+		return
+	}
+
+	// Send response back
+	w.WriteHeader(http.StatusOK)
+	w.Write(response.NewDataResponse(FindCardOwnersResponse{Owners: owners}))
+}
@@ -2,27 +2,98 @@ package deck
 
 import (
 	"errors"
+	"slices"
 
 	"github.com/joaquinleonarg/wdml-mtg/backend/db"
 	"github.com/joaquinleonarg/wdml-mtg/backend/domain"
 	apiErrors "github.com/joaquinleonarg/wdml-mtg/backend/errors"
+	deckstats "github.com/joaquinleonarg/wdml-mtg/backend/internal/deck_stats"
+	deckvalidation "github.com/joaquinleonarg/wdml-mtg/backend/internal/deck_validation"
+	"github.com/joaquinleonarg/wdml-mtg/backend/internal/decklist"
+	"github.com/joaquinleonarg/wdml-mtg/backend/pkg/scryfall"
+	"github.com/rs/zerolog/log"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
-func GetDeckById(deckID string) (*domain.Deck, []domain.OwnedCard, error) {
-	return db.GetDeckByID(deckID)
+// GetDeckById returns a deck and its cards, if the owner's privacy settings let the user see them
+func GetDeckById(userID, deckID string) (*domain.Deck, []domain.OwnedCard, error) {
+	deck, cards, _, err := getVisibleDeck(userID, deckID)
+	return deck, cards, err
+}
+
+// getVisibleDeck returns a deck, its cards and its owner, if the user can see the owner's decks
+func getVisibleDeck(userID, deckID string) (*domain.Deck, []domain.OwnedCard, *domain.TournamentPlayer, error) {
+	deck, cards, err := db.GetDeckByID(deckID)
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			return nil, nil, nil, apiErrors.ErrNotFound
+		}
+		if errors.Is(err, db.ErrInvalidID) {
+			return nil, nil, nil, apiErrors.ErrBadRequest
+		}
+		return nil, nil, nil, apiErrors.ErrInternal
+	}
+	owner, err := db.GetTournamentPlayerByID(deck.TournamentPlayerID.Hex())
+	if err != nil {
+		return nil, nil, nil, apiErrors.ErrInternal
+	}
+	viewer, err := getViewer(owner.TournamentID.Hex(), userID)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if !domain.CanSeeDecks(*owner, viewer) {
+		return nil, nil, nil, apiErrors.ErrUnauthorized
+	}
+	return deck, cards, owner, nil
+}
+
+// getViewer returns the user's player on a tournament, or nil if they don't play it
+func getViewer(tournamentID, userID string) (*domain.TournamentPlayer, error) {
+	viewer, err := db.GetTournamentPlayer(tournamentID, userID)
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			return nil, nil
+		}
+		if errors.Is(err, db.ErrInvalidID) {
+			return nil, apiErrors.ErrBadRequest
+		}
+		return nil, apiErrors.ErrInternal
+	}
+	return viewer, nil
 }
 
 func DeleteDeckByID(deckID string) error {
 	return db.DeleteDeckByID(deckID)
 }
 
-func GetDecksForTournamentPlayer(tournamentID, userID string) ([]domain.Deck, error) {
-	tournamentPlayer, err := db.GetTournamentPlayer(tournamentID, userID)
+// GetDecksForTournamentPlayer returns the user's decks on a tournament, or another player's if their privacy settings
+// let the user see them
+func GetDecksForTournamentPlayer(tournamentID, userID, tournamentPlayerID string) ([]domain.Deck, error) {
+	tournamentPlayer, err := getViewer(tournamentID, userID)
 	if err != nil {
-		if errors.Is(err, db.ErrNotFound) {
+		return nil, err
+	}
+	if tournamentPlayerID != "" {
+		owner, err := db.GetTournamentPlayerByID(tournamentPlayerID)
+		if err != nil {
+			if errors.Is(err, db.ErrNotFound) {
+				return nil, apiErrors.ErrNotFound
+			}
+			if errors.Is(err, db.ErrInvalidID) {
+				return nil, apiErrors.ErrBadRequest
+			}
+			return nil, apiErrors.ErrInternal
+		}
+		if owner.TournamentID.Hex() != tournamentID {
 			return nil, apiErrors.ErrNotFound
 		}
-		return nil, apiErrors.ErrInternal
+		if !domain.CanSeeDecks(*owner, tournamentPlayer) {
+			return nil, apiErrors.ErrUnauthorized
+		}
+		tournamentPlayer = owner
+	}
+	if tournamentPlayer == nil {
+		return nil, apiErrors.ErrNotFound
 	}
 	decks, err := db.GetDecksForTournamentPlayer(tournamentPlayer.ID.Hex())
 	if err != nil {
@@ -77,3 +148,665 @@ func RemoveCardFromDeck(ownedCardID, deckID string, board domain.DeckBoard, amou
 		amount,
 	)
 }
+
+type DeckImportLine struct {
+	Name            string           `json:"name"`
+	SetCode         string           `json:"set_code"`
+	CollectorNumber string           `json:"collector_number"`
+	Board           domain.DeckBoard `json:"board"`
+	Requested       int              `json:"requested"`
+	Resolved        int              `json:"resolved"`
+}
+
+type WildcardCraft struct {
+	Name   string            `json:"name"`
+	Rarity domain.CardRarity `json:"rarity"`
+	Count  int               `json:"count"`
+}
+
+type DeckImportReport struct {
+	Imported        []DeckImportLine      `json:"imported"`
+	Missing         []DeckImportLine      `json:"missing"`
+	Insufficient    []DeckImportLine      `json:"insufficient"`
+	TooManyCopies   []DeckImportLine      `json:"too_many_copies"`
+	Unparsed        []string              `json:"unparsed"`
+	Crafts          []WildcardCraft       `json:"crafts"`
+	WildcardsNeeded domain.OwnedWildcards `json:"wildcards_needed"`
+	CanCraft        bool                  `json:"can_craft"`
+}
+
+// maxImportedCopies is the most copies of a card, by name, an import adds or suggests crafting; unless the card
+// ignores the copy limit
+const maxImportedCopies = 4
+
+// ImportDeck parses a pasted decklist and adds every card it can find on the player's collection to the deck.
+// Cards that aren't owned, or not in enough copies, are reported back; optionally with the wildcards needed to craft them.
+func ImportDeck(userID, deckID, rawDecklist string, replace, suggestCrafts bool) (*DeckImportReport, error) {
+	deck, deckOwnedCards, err := db.GetDeckByID(deckID)
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			return nil, apiErrors.ErrNotFound
+		}
+		if errors.Is(err, db.ErrInvalidID) {
+			return nil, apiErrors.ErrBadRequest
+		}
+		return nil, apiErrors.ErrInternal
+	}
+	tournamentPlayer, err := db.GetTournamentPlayerByID(deck.TournamentPlayerID.Hex())
+	if err != nil {
+		return nil, apiErrors.ErrInternal
+	}
+	if tournamentPlayer.UserID.Hex() != userID {
+		return nil, apiErrors.ErrUnauthorized
+	}
+
+	entries, unparsed := decklist.Parse(rawDecklist)
+	if len(entries) == 0 {
+		return nil, apiErrors.ErrBadRequest
+	}
+
+	names := []string{}
+	for _, entry := range entries {
+		if !slices.Contains(names, entry.Name) {
+			names = append(names, entry.Name)
+		}
+	}
+	ownedCards, err := db.GetOwnedCardsByNames(tournamentPlayer.TournamentID.Hex(), userID, names)
+	if err != nil {
+		return nil, apiErrors.ErrInternal
+	}
+
+	// Copies already used by the deck, unless it's going to be replaced
+	usedByCard := make(map[primitive.ObjectID]int)
+	copiesByName := make(map[string]int)
+	if !replace {
+		ownedCardsByID := make(map[primitive.ObjectID]domain.OwnedCard, len(deckOwnedCards))
+		for _, ownedCard := range deckOwnedCards {
+			ownedCardsByID[ownedCard.ID] = ownedCard
+		}
+		for _, deckCard := range deck.Cards {
+			usedByCard[deckCard.OwnedCardID] += deckCard.Count
+			if ownedCard, ok := ownedCardsByID[deckCard.OwnedCardID]; ok {
+				copiesByName[decklist.NormalizeName(ownedCard.CardData.Name)] += deckCard.Count
+			}
+		}
+	}
+
+	report := DeckImportReport{
+		Imported:      []DeckImportLine{},
+		Missing:       []DeckImportLine{},
+		Insufficient:  []DeckImportLine{},
+		TooManyCopies: []DeckImportLine{},
+		Unparsed:      unparsed,
+		Crafts:        []WildcardCraft{},
+	}
+	deckCards := []domain.DeckCard{}
+	cardByName := make(map[string]domain.CardData)
+
+	for _, entry := range entries {
+		name := decklist.NormalizeName(entry.Name)
+		line := DeckImportLine{
+			Name:            entry.Name,
+			SetCode:         entry.SetCode,
+			CollectorNumber: entry.CollectorNumber,
+			Board:           entry.Board,
+			Requested:       entry.Count,
+		}
+
+		// Prefer the exact printing, then the same set, then any other printing
+		candidates := []domain.OwnedCard{}
+		for _, ownedCard := range ownedCards {
+			if decklist.NormalizeName(ownedCard.CardData.Name) == name {
+				candidates = append(candidates, ownedCard)
+			}
+		}
+		slices.SortStableFunc(candidates, func(a, b domain.OwnedCard) int {
+			return printingPreference(a, entry) - printingPreference(b, entry)
+		})
+
+		requested := entry.Count
+		if len(candidates) > 0 {
+			cardByName[name] = candidates[0].CardData
+			if !deckvalidation.IgnoresCopyLimit(candidates[0].CardData) && entry.Board != domain.MaybeBoard {
+				allowed := max(maxImportedCopies-copiesByName[name], 0)
+				if requested > allowed {
+					requested = allowed
+					tooMany := line
+					tooMany.Resolved = allowed
+					report.TooManyCopies = append(report.TooManyCopies, tooMany)
+				}
+			}
+		}
+
+		remaining := requested
+		for _, candidate := range candidates {
+			if remaining == 0 {
+				break
+			}
+			available := candidate.Count - usedByCard[candidate.ID]
+			if available <= 0 {
+				continue
+			}
+			taken := min(available, remaining)
+			deckCards = append(deckCards, domain.DeckCard{
+				OwnedCardID: candidate.ID,
+				Count:       taken,
+				Board:       entry.Board,
+			})
+			usedByCard[candidate.ID] += taken
+			remaining -= taken
+		}
+		line.Resolved = requested - remaining
+		if entry.Board != domain.MaybeBoard {
+			copiesByName[name] += line.Resolved
+		}
+
+		switch {
+		case line.Resolved == 0 && requested > 0:
+			report.Missing = append(report.Missing, line)
+		case line.Resolved < requested:
+			report.Insufficient = append(report.Insufficient, line)
+		case line.Resolved > 0:
+			report.Imported = append(report.Imported, line)
+		}
+	}
+
+	if len(deckCards) > 0 || replace {
+		err = db.AddDeckCardsToDeck(deckID, deckCards, replace, domain.DeckRevisionReasonImport)
+		if err != nil {
+			return nil, apiErrors.ErrInternal
+		}
+	}
+
+	if suggestCrafts {
+		suggestWildcardCrafts(&report, cardByName, copiesByName, tournamentPlayer.GameResources.Wildcards)
+	}
+
+	return &report, nil
+}
+
+func printingPreference(card domain.OwnedCard, entry decklist.Entry) int {
+	if entry.SetCode == "" || card.CardData.SetCode != entry.SetCode {
+		return 2
+	}
+	if entry.CollectorNumber != "" && card.CardData.CollectorNumber == entry.CollectorNumber {
+		return 0
+	}
+	return 1
+}
+
+// suggestWildcardCrafts fills the report with the wildcards needed to craft every missing copy the deck can legally
+// have, looking up cards the player doesn't own any printing of. copiesByName has the copies already on the deck.
+func suggestWildcardCrafts(report *DeckImportReport, cardByName map[string]domain.CardData, copiesByName map[string]int, owned domain.OwnedWildcards) {
+	lines := append(append([]DeckImportLine{}, report.Missing...), report.Insufficient...)
+	for _, line := range lines {
+		name := decklist.NormalizeName(line.Name)
+		card, ok := cardByName[name]
+		if !ok {
+			scryCard, err := scryfall.GetCardByName(line.Name, line.SetCode)
+			if err != nil {
+				log.Debug().Err(err).Str("name", line.Name).Msg("failed to find card to craft")
+				continue
+			}
+			card = scryfall.GetCardDataFromScryCard(scryCard)
+			cardByName[name] = card
+		}
+
+		count := line.Requested - line.Resolved
+		if line.Board != domain.MaybeBoard && !deckvalidation.IgnoresCopyLimit(card) {
+			count = min(count, max(maxImportedCopies-copiesByName[name], 0))
+			copiesByName[name] += count
+		}
+		if count == 0 {
+			continue
+		}
+		report.Crafts = append(report.Crafts, WildcardCraft{Name: line.Name, Rarity: card.Rarity, Count: count})
+		report.WildcardsNeeded = domain.AddWildcards(report.WildcardsNeeded, card.Rarity, count)
+	}
+
+	report.CanCraft = report.WildcardsNeeded.CommonCount <= owned.CommonCount &&
+		report.WildcardsNeeded.UncommonCount <= owned.UncommonCount &&
+		report.WildcardsNeeded.RareCount <= owned.RareCount &&
+		report.WildcardsNeeded.MythicRareCount <= owned.MythicRareCount &&
+		report.WildcardsNeeded.MasterpieceCount <= owned.MasterpieceCount
+}
+
+// ValidateDeck checks a deck against a format profile and the tournament's banlist.
+// If no format is given, the tournament's default format is used, falling back to limited.
+func ValidateDeck(userID, deckID string, format domain.DeckFormat) ([]deckvalidation.Violation, domain.DeckFormat, error) {
+	deck, cards, tournamentPlayer, err := getVisibleDeck(userID, deckID)
+	if err != nil {
+		return nil, "", err
+	}
+	tournament, err := db.GetTournamentByID(tournamentPlayer.TournamentID.Hex())
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			return nil, "", apiErrors.ErrNotFound
+		}
+		return nil, "", apiErrors.ErrInternal
+	}
+
+	if format == "" {
+		format = tournament.DeckRules.Format
+	}
+	if format == "" {
+		format = domain.DeckFormatLimited
+	}
+	profile, ok := deckvalidation.Profiles[format]
+	if !ok {
+		return nil, "", apiErrors.ErrBadRequest
+	}
+
+	entries, violations := deckEntries(deck, cards)
+	violations = append(violations, deckvalidation.Validate(profile, entries, tournament.DeckRules.Banlist)...)
+	return violations, format, nil
+}
+
+// deckEntries pairs every card on a deck with its data, reporting the cards that are no longer on the collection
+func deckEntries(deck *domain.Deck, cards []domain.OwnedCard) ([]deckvalidation.DeckEntry, []deckvalidation.Violation) {
+	cardsByID := make(map[primitive.ObjectID]domain.OwnedCard, len(cards))
+	for _, card := range cards {
+		cardsByID[card.ID] = card
+	}
+
+	entries := make([]deckvalidation.DeckEntry, 0, len(deck.Cards))
+	violations := []deckvalidation.Violation{}
+	for _, deckCard := range deck.Cards {
+		card, ok := cardsByID[deckCard.OwnedCardID]
+		if !ok {
+			violations = append(violations, deckvalidation.Violation{
+				Rule:    "missing_card",
+				Message: "card is no longer on the collection",
+			})
+			continue
+		}
+		if deckCard.Count > card.Count {
+			violations = append(violations, deckvalidation.Violation{
+				Rule:     "not_enough_copies",
+				Message:  "deck uses more copies than the ones on the collection",
+				CardName: card.CardData.Name,
+			})
+		}
+		entries = append(entries, deckvalidation.DeckEntry{
+			Card:  card.CardData,
+			Count: deckCard.Count,
+			Board: deckCard.Board,
+		})
+	}
+	return entries, violations
+}
+
+// RegisterDeck snapshots a deck for a season, or for a single match of a season. The deck must be valid for the
+// match's gamemode, or the tournament's format for season registrations. When registering for a match without a deck,
+// the player's season registration is used instead.
+func RegisterDeck(userID, deckID, seasonID, matchID string) (*domain.DeckRegistration, error) {
+	var match *domain.Match
+	if matchID != "" {
+		var err error
+		match, err = db.GetMatchByID(matchID)
+		if err != nil {
+			if errors.Is(err, db.ErrNotFound) {
+				return nil, apiErrors.ErrNotFound
+			}
+			if errors.Is(err, db.ErrInvalidID) {
+				return nil, apiErrors.ErrBadRequest
+			}
+			return nil, apiErrors.ErrInternal
+		}
+		if match.Completed {
+			return nil, apiErrors.ErrBadRequest
+		}
+		seasonID = match.SeasonID.Hex()
+	}
+	if seasonID == "" {
+		return nil, apiErrors.ErrBadRequest
+	}
+
+	season, err := db.GetSeasonByID(seasonID)
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			return nil, apiErrors.ErrNotFound
+		}
+		if errors.Is(err, db.ErrInvalidID) {
+			return nil, apiErrors.ErrBadRequest
+		}
+		return nil, apiErrors.ErrInternal
+	}
+	tournamentPlayer, err := db.GetTournamentPlayer(season.TournamentID.Hex(), userID)
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			return nil, apiErrors.ErrUnauthorized
+		}
+		return nil, apiErrors.ErrInternal
+	}
+	if match != nil && !slices.ContainsFunc(match.PlayersData, func(playerData domain.MatchPlayerData) bool {
+		return playerData.TournamentPlayerID == tournamentPlayer.ID
+	}) {
+		return nil, apiErrors.ErrUnauthorized
+	}
+
+	// Use the season's registration for the match
+	if deckID == "" {
+		if match == nil {
+			return nil, apiErrors.ErrBadRequest
+		}
+		return linkSeasonRegistrationToMatch(tournamentPlayer.ID.Hex(), seasonID, matchID)
+	}
+
+	deck, cards, err := db.GetDeckByID(deckID)
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			return nil, apiErrors.ErrNotFound
+		}
+		if errors.Is(err, db.ErrInvalidID) {
+			return nil, apiErrors.ErrBadRequest
+		}
+		return nil, apiErrors.ErrInternal
+	}
+	if deck.TournamentPlayerID != tournamentPlayer.ID {
+		return nil, apiErrors.ErrUnauthorized
+	}
+
+	format := domain.DeckFormat("")
+	if match != nil {
+		tournament, err := db.GetTournamentByID(season.TournamentID.Hex())
+		if err != nil {
+			return nil, apiErrors.ErrInternal
+		}
+		format = deckvalidation.FormatForGamemode(match.Gamemode, tournament.DeckRules.Format)
+	}
+	violations, format, err := ValidateDeck(userID, deckID, format)
+	if err != nil {
+		return nil, err
+	}
+	if len(violations) > 0 {
+		return nil, apiErrors.ErrInvalidDeck
+	}
+
+	// Snapshot the deck contents
+	cardsByID := make(map[primitive.ObjectID]domain.OwnedCard, len(cards))
+	for _, card := range cards {
+		cardsByID[card.ID] = card
+	}
+	registeredCards := make([]domain.RegisteredCard, 0, len(deck.Cards))
+	for _, deckCard := range deck.Cards {
+		registeredCards = append(registeredCards, domain.RegisteredCard{
+			OwnedCardID: deckCard.OwnedCardID,
+			CardData:    cardsByID[deckCard.OwnedCardID].CardData,
+			Count:       deckCard.Count,
+			Board:       deckCard.Board,
+		})
+	}
+	deckRegistration := domain.DeckRegistration{
+		TournamentID:       season.TournamentID,
+		TournamentPlayerID: tournamentPlayer.ID,
+		DeckID:             deck.ID,
+		SeasonID:           season.ID,
+		Name:               deck.Name,
+		Format:             format,
+		Cards:              registeredCards,
+	}
+	if match != nil {
+		deckRegistration.MatchID = match.ID
+	}
+
+	deckRegistration.ID, err = db.CreateDeckRegistration(deckRegistration)
+	if err != nil {
+		if errors.Is(err, db.ErrAlreadyExists) {
+			return nil, apiErrors.ErrDuplicatedResource
+		}
+		if errors.Is(err, db.ErrNotFound) {
+			return nil, apiErrors.ErrNotFound
+		}
+		return nil, apiErrors.ErrInternal
+	}
+	return &deckRegistration, nil
+}
+
+func linkSeasonRegistrationToMatch(tournamentPlayerID, seasonID, matchID string) (*domain.DeckRegistration, error) {
+	deckRegistrations, err := db.GetDeckRegistrations("", tournamentPlayerID, seasonID, "")
+	if err != nil {
+		return nil, apiErrors.ErrInternal
+	}
+	for _, deckRegistration := range deckRegistrations {
+		if deckRegistration.MatchID != primitive.NilObjectID {
+			continue
+		}
+		err = db.LinkDeckRegistrationToMatch(matchID, tournamentPlayerID, deckRegistration.ID.Hex())
+		if err != nil {
+			if errors.Is(err, db.ErrNotFound) {
+				return nil, apiErrors.ErrDuplicatedResource
+			}
+			return nil, apiErrors.ErrInternal
+		}
+		return &deckRegistration, nil
+	}
+	return nil, apiErrors.ErrNotFound
+}
+
+// GetDeckRegistrationByID returns a registration, as long as the owner's privacy settings let the user see their decks
+func GetDeckRegistrationByID(userID, deckRegistrationID string) (*domain.DeckRegistration, error) {
+	deckRegistration, err := db.GetDeckRegistrationByID(deckRegistrationID)
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			return nil, apiErrors.ErrNotFound
+		}
+		if errors.Is(err, db.ErrInvalidID) {
+			return nil, apiErrors.ErrBadRequest
+		}
+		return nil, apiErrors.ErrInternal
+	}
+	owner, err := db.GetTournamentPlayerByID(deckRegistration.TournamentPlayerID.Hex())
+	if err != nil {
+		return nil, apiErrors.ErrInternal
+	}
+	viewer, err := getViewer(owner.TournamentID.Hex(), userID)
+	if err != nil {
+		return nil, err
+	}
+	if !domain.CanSeeDecks(*owner, viewer) {
+		return nil, apiErrors.ErrUnauthorized
+	}
+	return deckRegistration, nil
+}
+
+// GetDeckRegistrations lists registrations. The cards of the ones whose owner hides their decks from the user are
+// left out.
+func GetDeckRegistrations(userID, tournamentID, tournamentPlayerID, seasonID, matchID string) ([]domain.DeckRegistration, error) {
+	if tournamentID == "" && tournamentPlayerID == "" && seasonID == "" && matchID == "" {
+		return nil, apiErrors.ErrBadRequest
+	}
+	deckRegistrations, err := db.GetDeckRegistrations(tournamentID, tournamentPlayerID, seasonID, matchID)
+	if err != nil {
+		if errors.Is(err, db.ErrInvalidID) {
+			return nil, apiErrors.ErrBadRequest
+		}
+		return nil, apiErrors.ErrInternal
+	}
+
+	// Owners and viewers are looked up once per player and tournament
+	owners := make(map[primitive.ObjectID]*domain.TournamentPlayer)
+	viewers := make(map[primitive.ObjectID]*domain.TournamentPlayer)
+	for i, deckRegistration := range deckRegistrations {
+		owner, ok := owners[deckRegistration.TournamentPlayerID]
+		if !ok {
+			owner, err = db.GetTournamentPlayerByID(deckRegistration.TournamentPlayerID.Hex())
+			if err != nil {
+				return nil, apiErrors.ErrInternal
+			}
+			owners[deckRegistration.TournamentPlayerID] = owner
+		}
+		viewer, ok := viewers[owner.TournamentID]
+		if !ok {
+			viewer, err = getViewer(owner.TournamentID.Hex(), userID)
+			if err != nil {
+				return nil, err
+			}
+			viewers[owner.TournamentID] = viewer
+		}
+		if !domain.CanSeeDecks(*owner, viewer) {
+			deckRegistrations[i].Cards = []domain.RegisteredCard{}
+		}
+	}
+	return deckRegistrations, nil
+}
+
+func GetDeckStats(userID, deckID string) (*deckstats.DeckStats, error) {
+	deck, cards, _, err := getVisibleDeck(userID, deckID)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, _ := deckEntries(deck, cards)
+	stats := deckstats.Compute(entries)
+	return &stats, nil
+}
+
+func GetDeckRevisions(userID, deckID string) ([]domain.DeckRevision, error) {
+	_, _, _, err := getVisibleDeck(userID, deckID)
+	if err != nil {
+		return nil, err
+	}
+	revisions, err := db.GetDeckRevisions(deckID)
+	if err != nil {
+		if errors.Is(err, db.ErrInvalidID) {
+			return nil, apiErrors.ErrBadRequest
+		}
+		return nil, apiErrors.ErrInternal
+	}
+	return revisions, nil
+}
+
+type DeckRevisionChange struct {
+	domain.DeckCardChange
+	Card domain.CardData `json:"card"`
+}
+
+// DiffDeckRevisions compares two revisions of a deck. If no target revision is given, the current deck is used.
+func DiffDeckRevisions(userID, deckID, fromRevisionID, toRevisionID string) ([]DeckRevisionChange, error) {
+	deck, _, _, err := getVisibleDeck(userID, deckID)
+	if err != nil {
+		return nil, err
+	}
+
+	fromRevision, err := getDeckRevision(deck.ID, fromRevisionID)
+	if err != nil {
+		return nil, err
+	}
+	toCards := deck.Cards
+	if toRevisionID != "" {
+		toRevision, err := getDeckRevision(deck.ID, toRevisionID)
+		if err != nil {
+			return nil, err
+		}
+		toCards = toRevision.Cards
+	}
+
+	cardChanges := domain.DiffDeckCards(fromRevision.Cards, toCards)
+	ownedCardIDs := make([]primitive.ObjectID, 0, len(cardChanges))
+	for _, change := range cardChanges {
+		ownedCardIDs = append(ownedCardIDs, change.OwnedCardID)
+	}
+	ownedCards, err := db.GetOwnedCardsByIDs(ownedCardIDs)
+	if err != nil {
+		return nil, apiErrors.ErrInternal
+	}
+	cardDataByID := make(map[primitive.ObjectID]domain.CardData, len(ownedCards))
+	for _, ownedCard := range ownedCards {
+		cardDataByID[ownedCard.ID] = ownedCard.CardData
+	}
+
+	changes := make([]DeckRevisionChange, 0, len(cardChanges))
+	for _, change := range cardChanges {
+		changes = append(changes, DeckRevisionChange{
+			DeckCardChange: change,
+			Card:           cardDataByID[change.OwnedCardID],
+		})
+	}
+	return changes, nil
+}
+
+// getDeckRevision finds a revision, making sure it belongs to the given deck
+func getDeckRevision(deckID primitive.ObjectID, deckRevisionID string) (*domain.DeckRevision, error) {
+	revision, err := db.GetDeckRevisionByID(deckRevisionID)
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			return nil, apiErrors.ErrNotFound
+		}
+		if errors.Is(err, db.ErrInvalidID) {
+			return nil, apiErrors.ErrBadRequest
+		}
+		return nil, apiErrors.ErrInternal
+	}
+	if revision.DeckID != deckID {
+		return nil, apiErrors.ErrNotFound
+	}
+	return revision, nil
+}
+
+// RestoreDeckRevision sets the deck's cards back to the ones on an old revision. Cards that are no longer
+// on the player's collection, or not in enough copies, are restored partially and returned.
+func RestoreDeckRevision(userID, deckID, deckRevisionID string) ([]domain.DeckCard, error) {
+	deck, _, err := db.GetDeckByID(deckID)
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			return nil, apiErrors.ErrNotFound
+		}
+		if errors.Is(err, db.ErrInvalidID) {
+			return nil, apiErrors.ErrBadRequest
+		}
+		return nil, apiErrors.ErrInternal
+	}
+	tournamentPlayer, err := db.GetTournamentPlayerByID(deck.TournamentPlayerID.Hex())
+	if err != nil {
+		return nil, apiErrors.ErrInternal
+	}
+	if tournamentPlayer.UserID.Hex() != userID {
+		return nil, apiErrors.ErrUnauthorized
+	}
+
+	revision, err := getDeckRevision(deck.ID, deckRevisionID)
+	if err != nil {
+		return nil, err
+	}
+
+	ownedCardIDs := make([]primitive.ObjectID, 0, len(revision.Cards))
+	for _, deckCard := range revision.Cards {
+		ownedCardIDs = append(ownedCardIDs, deckCard.OwnedCardID)
+	}
+	ownedCards, err := db.GetOwnedCardsByIDs(ownedCardIDs)
+	if err != nil {
+		return nil, apiErrors.ErrInternal
+	}
+	availableByID := make(map[primitive.ObjectID]int, len(ownedCards))
+	for _, ownedCard := range ownedCards {
+		availableByID[ownedCard.ID] = ownedCard.Count
+	}
+
+	deckCards := []domain.DeckCard{}
+	unavailable := []domain.DeckCard{}
+	for _, deckCard := range revision.Cards {
+		restored := min(deckCard.Count, availableByID[deckCard.OwnedCardID])
+		availableByID[deckCard.OwnedCardID] -= restored
+		if restored > 0 {
+			deckCards = append(deckCards, domain.DeckCard{
+				OwnedCardID: deckCard.OwnedCardID,
+				Count:       restored,
+				Board:       deckCard.Board,
+			})
+		}
+		if restored < deckCard.Count {
+			unavailable = append(unavailable, domain.DeckCard{
+				OwnedCardID: deckCard.OwnedCardID,
+				Count:       deckCard.Count - restored,
+				Board:       deckCard.Board,
+			})
+		}
+	}
+
+	err = db.AddDeckCardsToDeck(deckID, deckCards, true, domain.DeckRevisionReasonRestore)
+	if err != nil {
+		return nil, apiErrors.ErrInternal
+	}
+	return unavailable, nil
+}
@@ -8,6 +8,8 @@ import (
 	"github.com/joaquinleonarg/wdml-mtg/backend/api/response"
 	"github.com/joaquinleonarg/wdml-mtg/backend/api/routes/auth"
 	"github.com/joaquinleonarg/wdml-mtg/backend/domain"
+	deckstats "github.com/joaquinleonarg/wdml-mtg/backend/internal/deck_stats"
+	deckvalidation "github.com/joaquinleonarg/wdml-mtg/backend/internal/deck_validation"
 	"github.com/rs/zerolog/log"
 )
 
@@ -19,6 +21,15 @@ func RegisterEndpoints(r *mux.Router) {
 	r.HandleFunc("/card", AddOwnedCardToDeckHandler).Methods(http.MethodPost)
 	r.HandleFunc("/card/remove", RemoveCardFromDeckHandler).Methods(http.MethodPost)
 	r.HandleFunc("/remove", DeleteDeckHandler).Methods(http.MethodGet)
+	r.HandleFunc("/import", ImportDeckHandler).Methods(http.MethodPost)
+	r.HandleFunc("/validate", ValidateDeckHandler).Methods(http.MethodGet)
+	r.HandleFunc("/stats", GetDeckStatsHandler).Methods(http.MethodGet)
+	r.HandleFunc("/revisions", GetDeckRevisionsHandler).Methods(http.MethodGet)
+	r.HandleFunc("/revisions/diff", DiffDeckRevisionsHandler).Methods(http.MethodGet)
+	r.HandleFunc("/revisions/restore", RestoreDeckRevisionHandler).Methods(http.MethodPost)
+	r.HandleFunc("/register", RegisterDeckHandler).Methods(http.MethodPost)
+	r.HandleFunc("/registration", GetDeckRegistrationHandler).Methods(http.MethodGet)
+	r.HandleFunc("/registrations", GetDeckRegistrationsHandler).Methods(http.MethodGet)
 }
 
 //
@@ -33,17 +44,26 @@ type GetDeckByIdResponse struct {
 func GetDeckByIdHandler(w http.ResponseWriter, r *http.Request) {
 	log := log.With().Ctx(r.Context()).Str("path", r.URL.Path).Logger()
 
+	// Get user ID from context
+	userID, err := auth.GetUserIDFromContext(r.Context())
+	if err != nil {
+		http.Error(w, "", http.StatusBadRequest)
+		return
+	}
+
 	// Get deck ID from query
 	deckId := r.URL.Query().Get("deck_id")
 	if deckId == "" {
 		http.Error(w, "", http.StatusBadRequest)
+		return
 	}
 
-	deck, cards, err := GetDeckById(deckId)
+	deck, cards, err := GetDeckById(userID, deckId)
 	if err != nil {
 		log.Debug().Err(err).Msg("failed to get deck data")
-		w.Write(response.NewErrorResponse(err))
 		w.WriteHeader(http.StatusBadRequest)
+		w.Write(response.NewErrorResponse(err))
+		return
 	}
 
 	// Send response back
@@ -102,8 +122,11 @@ func GetDecksForTournamentPlayerHandler(w http.ResponseWriter, r *http.Request)
 		http.Error(w, "", http.StatusBadRequest)
 	}
 
+	// Another player's decks, if set
+	tournamentPlayerID := r.URL.Query().Get("tournament_player_id")
+
 	// Get tournament player, then get their decks
-	decks, err := GetDecksForTournamentPlayer(tournamentID, userID)
+	decks, err := GetDecksForTournamentPlayer(tournamentID, userID, tournamentPlayerID)
 	if err != nil {
 		log.Debug().Err(err).Msg("failed to get deck data")
 		w.Write(response.NewErrorResponse(err))
@@ -280,3 +303,395 @@ func RemoveCardFromDeckHandler(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 	w.Write(response.NewDataResponse(RemoveCardFromDeckResponse{}))
 }
+
+//
+// ENDPOINT: Import a pasted decklist, using the cards on the player's collection
+//
+
+type ImportDeckRequest struct {
+	DeckID        string `json:"deck_id"`
+	Decklist      string `json:"decklist"`
+	Replace       bool   `json:"replace"`
+	SuggestCrafts bool   `json:"suggest_crafts"`
+}
+
+type ImportDeckResponse struct {
+	Report *DeckImportReport `json:"report"`
+}
+
+func ImportDeckHandler(w http.ResponseWriter, r *http.Request) {
+	log := log.With().Ctx(r.Context()).Str("path", r.URL.Path).Logger()
+
+	// Get user ID from request context
+	ownerID, ok := r.Context().Value("user_id").(string)
+	if ownerID == "" || !ok {
+		log.Debug().
+			Msg("failed to read user id from context")
+		http.Error(w, "", http.StatusForbidden)
+		return
+	}
+
+	// Decode body data
+	var req ImportDeckRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		log.Debug().
+			Err(err).
+			Msg("failed to read request body")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write(response.NewErrorResponse(err))
+		return
+	}
+
+	// Resolve every line against the collection and add the cards found to the deck
+	report, err := ImportDeck(ownerID, req.DeckID, req.Decklist, req.Replace, req.SuggestCrafts)
+	if err != nil {
+		log.Debug().Err(err).Msg("failed to import deck")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write(response.NewErrorResponse(err))
+		return
+	}
+
+	// Send response back
+	w.WriteHeader(http.StatusOK)
+	w.Write(response.NewDataResponse(ImportDeckResponse{Report: report}))
+}
+
+//
+// ENDPOINT: Validate a deck against a format and the tournament's banlist
+//
+
+type ValidateDeckResponse struct {
+	Format     domain.DeckFormat          `json:"format"`
+	Valid      bool                       `json:"valid"`
+	Violations []deckvalidation.Violation `json:"violations"`
+}
+
+func ValidateDeckHandler(w http.ResponseWriter, r *http.Request) {
+	log := log.With().Ctx(r.Context()).Str("path", r.URL.Path).Logger()
+
+	// Get user ID from context
+	userID, err := auth.GetUserIDFromContext(r.Context())
+	if err != nil {
+		http.Error(w, "", http.StatusBadRequest)
+		return
+	}
+
+	// Get deck ID and format from query
+	deckID := r.URL.Query().Get("deck_id")
+	if deckID == "" {
+		http.Error(w, "", http.StatusBadRequest)
+		return
+	}
+	format := domain.DeckFormat(r.URL.Query().Get("format"))
+
+	violations, format, err := ValidateDeck(userID, deckID, format)
+	if err != nil {
+		log.Debug().Err(err).Msg("failed to validate deck")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write(response.NewErrorResponse(err))
+		return
+	}
+
+	// Send response back
+	w.WriteHeader(http.StatusOK)
+	w.Write(response.NewDataResponse(ValidateDeckResponse{
+		Format:     format,
+		Valid:      len(violations) == 0,
+		Violations: violations,
+	}))
+}
+
+//
+// ENDPOINT: Register a deck for a season or match, locking its contents
+//
+
+type RegisterDeckRequest struct {
+	DeckID   string `json:"deck_id"`
+	SeasonID string `json:"season_id"`
+	MatchID  string `json:"match_id"`
+}
+
+type RegisterDeckResponse struct {
+	DeckRegistration *domain.DeckRegistration `json:"deck_registration"`
+}
+
+func RegisterDeckHandler(w http.ResponseWriter, r *http.Request) {
+	log := log.With().Ctx(r.Context()).Str("path", r.URL.Path).Logger()
+
+	// Get user ID from request context
+	ownerID, ok := r.Context().Value("user_id").(string)
+	if ownerID == "" || !ok {
+		log.Debug().
+			Msg("failed to read user id from context")
+		http.Error(w, "", http.StatusForbidden)
+		return
+	}
+
+	// Decode body data
+	var req RegisterDeckRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		log.Debug().
+			Err(err).
+			Msg("failed to read request body")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write(response.NewErrorResponse(err))
+		return
+	}
+
+	// Validate and snapshot the deck
+	deckRegistration, err := RegisterDeck(ownerID, req.DeckID, req.SeasonID, req.MatchID)
+	if err != nil {
+		log.Debug().Err(err).Msg("failed to register deck")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write(response.NewErrorResponse(err))
+		return
+	}
+
+	// Send response back
+	w.WriteHeader(http.StatusOK)
+	w.Write(response.NewDataResponse(RegisterDeckResponse{DeckRegistration: deckRegistration}))
+}
+
+//
+// ENDPOINT: Get a deck registration by ID
+//
+
+type GetDeckRegistrationResponse struct {
+	DeckRegistration *domain.DeckRegistration `json:"deck_registration"`
+}
+
+func GetDeckRegistrationHandler(w http.ResponseWriter, r *http.Request) {
+	log := log.With().Ctx(r.Context()).Str("path", r.URL.Path).Logger()
+
+	// Get user ID from context
+	userID, err := auth.GetUserIDFromContext(r.Context())
+	if err != nil {
+		http.Error(w, "", http.StatusBadRequest)
+		return
+	}
+
+	// Get registration ID from query
+	deckRegistrationID := r.URL.Query().Get("deck_registration_id")
+	if deckRegistrationID == "" {
+		http.Error(w, "", http.StatusBadRequest)
+		return
+	}
+
+	deckRegistration, err := GetDeckRegistrationByID(userID, deckRegistrationID)
+	if err != nil {
+		log.Debug().Err(err).Msg("failed to get deck registration")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write(response.NewErrorResponse(err))
+		return
+	}
+
+	// Send response back
+	w.WriteHeader(http.StatusOK)
+	w.Write(response.NewDataResponse(GetDeckRegistrationResponse{DeckRegistration: deckRegistration}))
+}
+
+//
+// ENDPOINT: List deck registrations for a tournament, player, season or match
+//
+
+type GetDeckRegistrationsResponse struct {
+	DeckRegistrations []domain.DeckRegistration `json:"deck_registrations"`
+}
+
+func GetDeckRegistrationsHandler(w http.ResponseWriter, r *http.Request) {
+	log := log.With().Ctx(r.Context()).Str("path", r.URL.Path).Logger()
+
+	// Get user ID from context
+	userID, err := auth.GetUserIDFromContext(r.Context())
+	if err != nil {
+		http.Error(w, "", http.StatusBadRequest)
+		return
+	}
+
+	// Get filters from query
+	deckRegistrations, err := GetDeckRegistrations(
+		userID,
+		r.URL.Query().Get("tournament_id"),
+		r.URL.Query().Get("tournament_player_id"),
+		r.URL.Query().Get("season_id"),
+		r.URL.Query().Get("match_id"),
+	)
+	if err != nil {
+		log.Debug().Err(err).Msg("failed to get deck registrations")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write(response.NewErrorResponse(err))
+		return
+	}
+
+	// Send response back
+	w.WriteHeader(http.StatusOK)
+	w.Write(response.NewDataResponse(GetDeckRegistrationsResponse{DeckRegistrations: deckRegistrations}))
+}
+
+//
+// ENDPOINT: Get a deck's mana curve, colors, types and draw odds
+//
+
+type GetDeckStatsResponse struct {
+	Stats *deckstats.DeckStats `json:"stats"`
+}
+
+func GetDeckStatsHandler(w http.ResponseWriter, r *http.Request) {
+	log := log.With().Ctx(r.Context()).Str("path", r.URL.Path).Logger()
+
+	// Get user ID from context
+	userID, err := auth.GetUserIDFromContext(r.Context())
+	if err != nil {
+		http.Error(w, "", http.StatusBadRequest)
+		return
+	}
+
+	// Get deck ID from query
+	deckID := r.URL.Query().Get("deck_id")
+	if deckID == "" {
+		http.Error(w, "", http.StatusBadRequest)
+		return
+	}
+
+	stats, err := GetDeckStats(userID, deckID)
+	if err != nil {
+		log.Debug().Err(err).Msg("failed to get deck stats")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write(response.NewErrorResponse(err))
+		return
+	}
+
+	// Send response back
+	w.WriteHeader(http.StatusOK)
+	w.Write(response.NewDataResponse(GetDeckStatsResponse{Stats: stats}))
+}
+
+//
+// ENDPOINT: Get every revision of a deck
+//
+
+type GetDeckRevisionsResponse struct {
+	Revisions []domain.DeckRevision `json:"revisions"`
+}
+
+func GetDeckRevisionsHandler(w http.ResponseWriter, r *http.Request) {
+	log := log.With().Ctx(r.Context()).Str("path", r.URL.Path).Logger()
+
+	// Get user ID from context
+	userID, err := auth.GetUserIDFromContext(r.Context())
+	if err != nil {
+		http.Error(w, "", http.StatusBadRequest)
+		return
+	}
+
+	// Get deck ID from query
+	deckID := r.URL.Query().Get("deck_id")
+	if deckID == "" {
+		http.Error(w, "", http.StatusBadRequest)
+		return
+	}
+
+	revisions, err := GetDeckRevisions(userID, deckID)
+	if err != nil {
+		log.Debug().Err(err).Msg("failed to get deck revisions")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write(response.NewErrorResponse(err))
+		return
+	}
+
+	// Send response back
+	w.WriteHeader(http.StatusOK)
+	w.Write(response.NewDataResponse(GetDeckRevisionsResponse{Revisions: revisions}))
+}
+
+//
+// ENDPOINT: Compare two revisions of a deck, or a revision with the current deck
+//
+
+type DiffDeckRevisionsResponse struct {
+	Changes []DeckRevisionChange `json:"changes"`
+}
+
+func DiffDeckRevisionsHandler(w http.ResponseWriter, r *http.Request) {
+	log := log.With().Ctx(r.Context()).Str("path", r.URL.Path).Logger()
+
+	// Get user ID from context
+	userID, err := auth.GetUserIDFromContext(r.Context())
+	if err != nil {
+		http.Error(w, "", http.StatusBadRequest)
+		return
+	}
+
+	// Get deck and revision IDs from query
+	deckID := r.URL.Query().Get("deck_id")
+	fromRevisionID := r.URL.Query().Get("from_revision_id")
+	toRevisionID := r.URL.Query().Get("to_revision_id")
+	if deckID == "" || fromRevisionID == "" {
+		http.Error(w, "", http.StatusBadRequest)
+		return
+	}
+
+	changes, err := DiffDeckRevisions(userID, deckID, fromRevisionID, toRevisionID)
+	if err != nil {
+		log.Debug().Err(err).Msg("failed to diff deck revisions")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write(response.NewErrorResponse(err))
+		return
+	}
+
+	// Send response back
+	w.WriteHeader(http.StatusOK)
+	w.Write(response.NewDataResponse(DiffDeckRevisionsResponse{Changes: changes}))
+}
+
+//
+// ENDPOINT: Restore a deck to an old revision
+//
+
+type RestoreDeckRevisionRequest struct {
+	DeckID         string `json:"deck_id"`
+	DeckRevisionID string `json:"deck_revision_id"`
+}
+
+type RestoreDeckRevisionResponse struct {
+	Unavailable []domain.DeckCard `json:"unavailable"`
+}
+
+func RestoreDeckRevisionHandler(w http.ResponseWriter, r *http.Request) {
+	log := log.With().Ctx(r.Context()).Str("path", r.URL.Path).Logger()
+
+	// Get user ID from request context
+	userID, ok := r.Context().Value("user_id").(string)
+	if userID == "" || !ok {
+		log.Debug().
+			Msg("failed to read user id from context")
+		http.Error(w, "", http.StatusForbidden)
+		return
+	}
+
+	// Decode body data
+	var req RestoreDeckRevisionRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		log.Debug().
+			Err(err).
+			Msg("failed to read request body")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write(response.NewErrorResponse(err))
+		return
+	}
+
+	unavailable, err := RestoreDeckRevision(userID, req.DeckID, req.DeckRevisionID)
+	if err != nil {
+		log.Debug().Err(err).Msg("failed to restore deck revision")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write(response.NewErrorResponse(err))
+		return
+	}
+
+	// Send response back
+	w.WriteHeader(http.StatusOK)
+	w.Write(response.NewDataResponse(RestoreDeckRevisionResponse{Unavailable: unavailable}))
+}
@@ -0,0 +1,130 @@
+package ledger
+
+import (
+	"errors"
+
+	"github.com/joaquinleonarg/wdml-mtg/backend/db"
+	"github.com/joaquinleonarg/wdml-mtg/backend/domain"
+	apiErrors "github.com/joaquinleonarg/wdml-mtg/backend/errors"
+)
+
+const (
+	DEFAULT_PAGE_SIZE = 50
+	MAX_PAGE_SIZE     = 200
+)
+
+// BalancesReport compares the balances derived from the ledger with the ones stored on the player
+type BalancesReport struct {
+	Ledger []domain.LedgerBalance `json:"ledger"`
+	Stored []domain.LedgerBalance `json:"stored"`
+	// Amount missing from the ledger for it to match the stored balances
+	Mismatches []domain.LedgerBalance `json:"mismatches"`
+}
+
+// GetLedgerEntries returns a page of a player's resource history. Players can only see their own history, which
+// is the default; moderators can see any player's, or the whole tournament's if all is set.
+func GetLedgerEntries(userID, tournamentID, tournamentPlayerID string, all bool, resource domain.LedgerResource, count, page int) ([]domain.LedgerEntry, int, error) {
+	tournamentPlayerID, err := authorizeLedgerAccess(userID, tournamentID, tournamentPlayerID)
+	if err != nil {
+		return nil, 0, err
+	}
+	if all {
+		requester, err := db.GetTournamentPlayer(tournamentID, userID)
+		if err != nil {
+			return nil, 0, mapLedgerError(err)
+		}
+		if !isModerator(requester) {
+			return nil, 0, apiErrors.ErrUnauthorized
+		}
+		tournamentPlayerID = ""
+	}
+
+	if count <= 0 {
+		count = DEFAULT_PAGE_SIZE
+	}
+	count = min(count, MAX_PAGE_SIZE)
+	page = max(page, 1)
+
+	entries, total, err := db.GetLedgerEntries(tournamentID, tournamentPlayerID, resource, count, page)
+	if err != nil {
+		return nil, 0, mapLedgerError(err)
+	}
+	return entries, total, nil
+}
+
+// GetBalances derives a player's balances from the ledger and compares them with the stored ones
+func GetBalances(userID, tournamentID, tournamentPlayerID string) (*BalancesReport, error) {
+	tournamentPlayerID, err := authorizeLedgerAccess(userID, tournamentID, tournamentPlayerID)
+	if err != nil {
+		return nil, err
+	}
+	tournamentPlayer, err := db.GetTournamentPlayerByID(tournamentPlayerID)
+	if err != nil {
+		return nil, mapLedgerError(err)
+	}
+	ledgerBalances, err := db.GetLedgerBalances(tournamentPlayerID)
+	if err != nil {
+		return nil, mapLedgerError(err)
+	}
+
+	stored := domain.ResourceBalances(*tournamentPlayer)
+	return &BalancesReport{
+		Ledger:     ledgerBalances,
+		Stored:     stored,
+		Mismatches: domain.DiffBalances(ledgerBalances, stored),
+	}, nil
+}
+
+// ReconcileLedger records opening balances for the players whose resources predate the ledger (administrators only)
+func ReconcileLedger(userID, tournamentID string) (int, error) {
+	requester, err := db.GetTournamentPlayer(tournamentID, userID)
+	if err != nil {
+		return 0, mapLedgerError(err)
+	}
+	if requester.AccessLevel != domain.AccessLevelAdministrator {
+		return 0, apiErrors.ErrUnauthorized
+	}
+
+	reconciled, err := db.ReconcileLedger(tournamentID, userID)
+	if err != nil {
+		return 0, mapLedgerError(err)
+	}
+	return reconciled, nil
+}
+
+// authorizeLedgerAccess returns the tournament player whose ledger is requested, defaulting to the user's own
+func authorizeLedgerAccess(userID, tournamentID, tournamentPlayerID string) (string, error) {
+	requester, err := db.GetTournamentPlayer(tournamentID, userID)
+	if err != nil {
+		return "", mapLedgerError(err)
+	}
+	if tournamentPlayerID == "" || tournamentPlayerID == requester.ID.Hex() {
+		return requester.ID.Hex(), nil
+	}
+	if !isModerator(requester) {
+		return "", apiErrors.ErrUnauthorized
+	}
+
+	tournamentPlayer, err := db.GetTournamentPlayerByID(tournamentPlayerID)
+	if err != nil {
+		return "", mapLedgerError(err)
+	}
+	if tournamentPlayer.TournamentID != requester.TournamentID {
+		return "", apiErrors.ErrBadRequest
+	}
+	return tournamentPlayerID, nil
+}
+
+func isModerator(tournamentPlayer *domain.TournamentPlayer) bool {
+	return tournamentPlayer.AccessLevel == domain.AccessLevelAdministrator || tournamentPlayer.AccessLevel == domain.AccessLevelModerator
+}
+
+func mapLedgerError(err error) error {
+	switch {
+	case errors.Is(err, db.ErrInvalidID):
+		return apiErrors.ErrBadRequest
+	case errors.Is(err, db.ErrNotFound):
+		return apiErrors.ErrNotFound
+	}
+	return apiErrors.ErrInternal
+}
@@ -0,0 +1,180 @@
+package ledger
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/joaquinleonarg/wdml-mtg/backend/api/response"
+	"github.com/joaquinleonarg/wdml-mtg/backend/domain"
+	"github.com/rs/zerolog/log"
+)
+
+func RegisterEndpoints(r *mux.Router) {
+	r = r.PathPrefix("/ledger").Subrouter()
+	r.HandleFunc("", GetLedgerEntriesHandler).Methods(http.MethodGet)
+	r.HandleFunc("/balances", GetBalancesHandler).Methods(http.MethodGet)
+	r.HandleFunc("/reconcile", ReconcileLedgerHandler).Methods(http.MethodPost)
+}
+
+//
+// ENDPOINT: Browse the history of a player's coins, packs, wildcards and points
+//
+
+type GetLedgerEntriesResponse struct {
+	Entries     []domain.LedgerEntry `json:"entries"`
+	CurrentPage int                  `json:"current_page"`
+	TotalPages  int                  `json:"total_pages"`
+}
+
+func GetLedgerEntriesHandler(w http.ResponseWriter, r *http.Request) {
+	log := log.With().Ctx(r.Context()).Str("path", r.URL.Path).Logger()
+
+	// Get user ID from request context
+	userID, ok := r.Context().Value("user_id").(string)
+	if userID == "" || !ok {
+		log.Debug().
+			Msg("failed to read user id from context")
+		http.Error(w, "", http.StatusForbidden)
+		return
+	}
+
+	// Get tournament ID and filters from query
+	tournamentID := r.URL.Query().Get("tournament_id")
+	if tournamentID == "" {
+		http.Error(w, "", http.StatusBadRequest)
+		return
+	}
+	tournamentPlayerID := r.URL.Query().Get("tournament_player_id")
+	all := r.URL.Query().Get("all") == "true"
+	resource := domain.LedgerResource(r.URL.Query().Get("resource"))
+
+	count := 0
+	countQuery := r.URL.Query().Get("count")
+	if countQuery != "" {
+		val, err := strconv.Atoi(countQuery)
+		if err != nil {
+			log.Debug().
+				Msg("failed to read count from query")
+			http.Error(w, "", http.StatusBadRequest)
+			return
+		}
+		count = val
+	}
+
+	page := 1
+	pageQuery := r.URL.Query().Get("page")
+	if pageQuery != "" {
+		val, err := strconv.Atoi(pageQuery)
+		if err != nil {
+			log.Debug().
+				Msg("failed to read page from query")
+			http.Error(w, "", http.StatusBadRequest)
+			return
+		}
+		page = val
+	}
+
+	entries, total, err := GetLedgerEntries(userID, tournamentID, tournamentPlayerID, all, resource, count, page)
+	if err != nil {
+		log.Debug().Err(err).Msg("failed to get ledger entries")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write(response.NewErrorResponse(err))
+		return
+	}
+
+	if count <= 0 {
+		count = DEFAULT_PAGE_SIZE
+	}
+	count = min(count, MAX_PAGE_SIZE)
+
+	// Send response back
+	w.WriteHeader(http.StatusOK)
+	w.Write(response.NewDataResponse(GetLedgerEntriesResponse{
+		Entries:     entries,
+		CurrentPage: max(page, 1),
+		TotalPages:  int(math.Ceil(float64(total) / float64(count))),
+	}))
+}
+
+//
+// ENDPOINT: Get a player's balances as derived from the ledger, next to the stored ones
+//
+
+type GetBalancesResponse struct {
+	Balances BalancesReport `json:"balances"`
+}
+
+func GetBalancesHandler(w http.ResponseWriter, r *http.Request) {
+	log := log.With().Ctx(r.Context()).Str("path", r.URL.Path).Logger()
+
+	// Get user ID from request context
+	userID, ok := r.Context().Value("user_id").(string)
+	if userID == "" || !ok {
+		log.Debug().
+			Msg("failed to read user id from context")
+		http.Error(w, "", http.StatusForbidden)
+		return
+	}
+
+	// Get tournament ID and tournament player ID from query
+	tournamentID := r.URL.Query().Get("tournament_id")
+	if tournamentID == "" {
+		http.Error(w, "", http.StatusBadRequest)
+		return
+	}
+	tournamentPlayerID := r.URL.Query().Get("tournament_player_id")
+
+	balances, err := GetBalances(userID, tournamentID, tournamentPlayerID)
+	if err != nil {
+		log.Debug().Err(err).Msg("failed to get balances")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write(response.NewErrorResponse(err))
+		return
+	}
+
+	// Send response back
+	w.WriteHeader(http.StatusOK)
+	w.Write(response.NewDataResponse(GetBalancesResponse{Balances: *balances}))
+}
+
+//
+// ENDPOINT: Record opening balances for players whose resources predate the ledger (administrators only)
+//
+
+type ReconcileLedgerResponse struct {
+	Reconciled int `json:"reconciled"`
+}
+
+func ReconcileLedgerHandler(w http.ResponseWriter, r *http.Request) {
+	log := log.With().Ctx(r.Context()).Str("path", r.URL.Path).Logger()
+
+	// Get user ID from request context
+	userID, ok := r.Context().Value("user_id").(string)
+	if userID == "" || !ok {
+		log.Debug().
+			Msg("failed to read user id from context")
+		http.Error(w, "", http.StatusForbidden)
+		return
+	}
+
+	// Get tournament ID from query
+	tournamentID := r.URL.Query().Get("tournament_id")
+	if tournamentID == "" {
+		http.Error(w, "", http.StatusBadRequest)
+		return
+	}
+
+	reconciled, err := ReconcileLedger(userID, tournamentID)
+	if err != nil {
+		log.Debug().Err(err).Msg("failed to reconcile ledger")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write(response.NewErrorResponse(err))
+		return
+	}
+
+	// Send response back
+	w.WriteHeader(http.StatusOK)
+	w.Write(response.NewDataResponse(ReconcileLedgerResponse{Reconciled: reconciled}))
+}
@@ -0,0 +1,57 @@
+package notification
+
+import (
+	"errors"
+
+	"github.com/joaquinleonarg/wdml-mtg/backend/db"
+	"github.com/joaquinleonarg/wdml-mtg/backend/domain"
+	apiErrors "github.com/joaquinleonarg/wdml-mtg/backend/errors"
+)
+
+const (
+	DEFAULT_PAGE_SIZE = 50
+	MAX_PAGE_SIZE     = 200
+)
+
+// GetNotifications returns a page of the user's notifications on a tournament, newest first
+func GetNotifications(userID, tournamentID string, unreadOnly bool, count, page int) ([]domain.Notification, int, error) {
+	tournamentPlayer, err := db.GetTournamentPlayer(tournamentID, userID)
+	if err != nil {
+		return nil, 0, mapNotificationError(err)
+	}
+
+	if count <= 0 {
+		count = DEFAULT_PAGE_SIZE
+	}
+	count = min(count, MAX_PAGE_SIZE)
+	page = max(page, 1)
+
+	notifications, total, err := db.GetNotifications(tournamentPlayer.ID.Hex(), unreadOnly, count, page)
+	if err != nil {
+		return nil, 0, mapNotificationError(err)
+	}
+	return notifications, total, nil
+}
+
+// MarkNotificationsRead marks the user's notifications as read, or all of them if no IDs are given
+func MarkNotificationsRead(userID, tournamentID string, notificationIDs []string) (int, error) {
+	tournamentPlayer, err := db.GetTournamentPlayer(tournamentID, userID)
+	if err != nil {
+		return 0, mapNotificationError(err)
+	}
+	marked, err := db.MarkNotificationsRead(tournamentPlayer.ID.Hex(), notificationIDs)
+	if err != nil {
+		return 0, mapNotificationError(err)
+	}
+	return marked, nil
+}
+
+func mapNotificationError(err error) error {
+	switch {
+	case errors.Is(err, db.ErrNotFound):
+		return apiErrors.ErrNotFound
+	case errors.Is(err, db.ErrInvalidID):
+		return apiErrors.ErrBadRequest
+	}
+	return apiErrors.ErrInternal
+}
@@ -0,0 +1,150 @@
+package notification
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/joaquinleonarg/wdml-mtg/backend/api/response"
+	"github.com/joaquinleonarg/wdml-mtg/backend/api/routes/auth"
+	"github.com/joaquinleonarg/wdml-mtg/backend/domain"
+	"github.com/rs/zerolog/log"
+)
+
+func RegisterEndpoints(r *mux.Router) {
+	r = r.PathPrefix("/notification").Subrouter()
+	r.HandleFunc("", GetNotificationsHandler).Methods(http.MethodGet)
+	r.HandleFunc("/read", MarkNotificationsReadHandler).Methods(http.MethodPost)
+}
+
+//
+// ENDPOINT: Browse the player's notifications, newest first
+//
+
+type GetNotificationsResponse struct {
+	Notifications []domain.Notification `json:"notifications"`
+	CurrentPage   int                   `json:"current_page"`
+	TotalPages    int                   `json:"total_pages"`
+}
+
+func GetNotificationsHandler(w http.ResponseWriter, r *http.Request) {
+	log := log.With().Ctx(r.Context()).Str("path", r.URL.Path).Logger()
+
+	// Get user ID from context
+	userID, err := auth.GetUserIDFromContext(r.Context())
+	if err != nil {
+		http.Error(w, "", http.StatusBadRequest)
+		return
+	}
+
+	// Get tournament ID and filters from query
+	tournamentID := r.URL.Query().Get("tournament_id")
+	if tournamentID == "" {
+		http.Error(w, "", http.StatusBadRequest)
+		return
+	}
+	unreadOnly := r.URL.Query().Get("unread") == "true"
+
+	count := 0
+	countQuery := r.URL.Query().Get("count")
+	if countQuery != "" {
+		val, err := strconv.Atoi(countQuery)
+		if err != nil {
+			log.Debug().
+				Msg("failed to read count from query")
+			http.Error(w, "", http.StatusBadRequest)
+			return
+		}
+		count = val
+	}
+
+	page := 1
+	pageQuery := r.URL.Query().Get("page")
+	if pageQuery != "" {
+		val, err := strconv.Atoi(pageQuery)
+		if err != nil {
+			log.Debug().
+				Msg("failed to read page from query")
+			http.Error(w, "", http.StatusBadRequest)
+			return
+		}
+		page = val
+	}
+
+	notifications, total, err := GetNotifications(userID, tournamentID, unreadOnly, count, page)
+	if err != nil {
+		log.Debug().Err(err).Msg("failed to get notifications")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write(response.NewErrorResponse(err))
+		return
+	}
+
+	if count <= 0 {
+		count = DEFAULT_PAGE_SIZE
+	}
+	count = min(count, MAX_PAGE_SIZE)
+
+	// Send response back
+	w.WriteHeader(http.StatusOK)
+	w.Write(response.NewDataResponse(GetNotificationsResponse{
+		Notifications: notifications,
+		CurrentPage:   max(page, 1),
+		TotalPages:    int(math.Ceil(float64(total) / float64(count))),
+	}))
+}
+
+//
+// ENDPOINT: Mark the player's notifications as read, or all of them if no IDs are sent
+//
+
+type MarkNotificationsReadRequest struct {
+	NotificationIDs []string `json:"notification_ids"`
+}
+
+type MarkNotificationsReadResponse struct {
+	Marked int `json:"marked"`
+}
+
+func MarkNotificationsReadHandler(w http.ResponseWriter, r *http.Request) {
+	log := log.With().Ctx(r.Context()).Str("path", r.URL.Path).Logger()
+
+	// Get user ID from context
+	userID, err := auth.GetUserIDFromContext(r.Context())
+	if err != nil {
+		http.Error(w, "", http.StatusBadRequest)
+		return
+	}
+
+	// Get tournament ID from query
+	tournamentID := r.URL.Query().Get("tournament_id")
+	if tournamentID == "" {
+		http.Error(w, "", http.StatusBadRequest)
+		return
+	}
+
+	// Decode body data
+	var req MarkNotificationsReadRequest
+	err = json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		log.Debug().
+			Err(err).
+			Msg("failed to read request body")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write(response.NewErrorResponse(err))
+		return
+	}
+
+	marked, err := MarkNotificationsRead(userID, tournamentID, req.NotificationIDs)
+	if err != nil {
+		log.Debug().Err(err).Msg("failed to mark notifications as read")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write(response.NewErrorResponse(err))
+		return
+	}
+
+	// Send response back
+	w.WriteHeader(http.StatusOK)
+	w.Write(response.NewDataResponse(MarkNotificationsReadResponse{Marked: marked}))
+}
@@ -6,6 +6,7 @@ import (
 	"github.com/joaquinleonarg/wdml-mtg/backend/db"
 	"github.com/joaquinleonarg/wdml-mtg/backend/domain"
 	apiErrors "github.com/joaquinleonarg/wdml-mtg/backend/errors"
+	deckvalidation "github.com/joaquinleonarg/wdml-mtg/backend/internal/deck_validation"
 	"github.com/rs/zerolog/log"
 )
 
@@ -25,7 +26,7 @@ func GetTournamentsForUser(userID string) ([]domain.Tournament, error) {
 	return tournaments, nil
 }
 
-func GetTournamentPlayers(tournamentID string) ([]domain.TournamentPlayer, []domain.User, error) {
+func GetTournamentPlayers(userID, tournamentID string) ([]domain.TournamentPlayer, []domain.User, error) {
 	tournament_players, users, err := db.GetTournamentPlayers(tournamentID)
 
 	// Redact sensitive information
@@ -34,6 +35,18 @@ func GetTournamentPlayers(tournamentID string) ([]domain.TournamentPlayer, []dom
 		users[index].Email = ""
 	}
 
+	// Hide what each player's privacy settings don't let the user see
+	var viewer *domain.TournamentPlayer
+	for index := range tournament_players {
+		if tournament_players[index].UserID.Hex() == userID {
+			viewer = &tournament_players[index]
+			break
+		}
+	}
+	for index, tournamentPlayer := range tournament_players {
+		tournament_players[index] = domain.VisibleTournamentPlayer(tournamentPlayer, viewer)
+	}
+
 	return tournament_players, users, err
 }
 
@@ -134,5 +147,109 @@ func GetStore(tournamentID string) (*domain.Store, error) {
 		}
 	}
 
+	// Packs priced in dollars show what they cost in coins
+	for i, boosterPack := range tournament.Store.BoosterPacks {
+		tournament.Store.BoosterPacks[i].CoinPrice = domain.StoreCoinPrice(boosterPack, tournament.CoinValues)
+	}
 	return &tournament.Store, nil
 }
+
+// updateTournamentSettings stores new settings on the tournament if the user is one of its administrators or
+// moderators. validate, if given, checks the settings and fills in their defaults before update stores them.
+func updateTournamentSettings(tournamentID, userID string, validate func() error, update func() error) error {
+	// Check if the user is an admin or moderator
+	tournamentPlayer, err := db.GetTournamentPlayer(tournamentID, userID)
+	if err != nil {
+		return apiErrors.ErrInternal
+	}
+	if tournamentPlayer.AccessLevel != domain.AccessLevelAdministrator && tournamentPlayer.AccessLevel != domain.AccessLevelModerator {
+		return apiErrors.ErrUnauthorized
+	}
+
+	if validate != nil {
+		err = validate()
+		if err != nil {
+			return err
+		}
+	}
+
+	err = update()
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			return apiErrors.ErrNotFound
+		}
+		return apiErrors.ErrInternal
+	}
+
+	return nil
+}
+
+func UpdateDeckRules(tournamentID, userID string, deckRules domain.DeckRules) error {
+	validate := func() error {
+		// Check that the format exists
+		if _, ok := deckvalidation.Profiles[deckRules.Format]; !ok && deckRules.Format != "" {
+			return apiErrors.ErrBadRequest
+		}
+		if deckRules.Banlist == nil {
+			deckRules.Banlist = []string{}
+		}
+		return nil
+	}
+	return updateTournamentSettings(tournamentID, userID, validate, func() error {
+		return db.UpdateTournamentDeckRules(tournamentID, deckRules)
+	})
+}
+
+func UpdateDuplicateProtection(tournamentID, userID string, duplicateProtection domain.DuplicateProtection) error {
+	validate := func() error {
+		if duplicateProtection.Enabled && duplicateProtection.MaxCopies < 1 {
+			return apiErrors.ErrBadRequest
+		}
+		return nil
+	}
+	return updateTournamentSettings(tournamentID, userID, validate, func() error {
+		return db.UpdateTournamentDuplicateProtection(tournamentID, duplicateProtection)
+	})
+}
+
+func UpdateTradeRules(tournamentID, userID string, tradeRules domain.TradeRules) error {
+	return updateTournamentSettings(tournamentID, userID, nil, func() error {
+		return db.UpdateTournamentTradeRules(tournamentID, tradeRules)
+	})
+}
+
+// UpdateTradeUpRecipes replaces the ways players can trade up their cards. An empty list brings back the default
+// recipe.
+func UpdateTradeUpRecipes(tournamentID, userID string, recipes []domain.TradeUpRecipe) error {
+	validate := func() error {
+		if recipes == nil {
+			recipes = []domain.TradeUpRecipe{}
+		}
+		recipeIDs := make(map[string]bool, len(recipes))
+		for _, recipe := range recipes {
+			if err := domain.ValidateTradeUpRecipe(recipe); err != nil || recipeIDs[recipe.ID] {
+				return apiErrors.ErrBadRequest
+			}
+			recipeIDs[recipe.ID] = true
+		}
+		return nil
+	}
+	return updateTournamentSettings(tournamentID, userID, validate, func() error {
+		return db.UpdateTournamentTradeUpRecipes(tournamentID, recipes)
+	})
+}
+
+func UpdateCoinValues(tournamentID, userID string, coinValues domain.CoinValues) error {
+	validate := func() error {
+		if coinValues.PriceBased && coinValues.CoinsPerUSD <= 0 {
+			return apiErrors.ErrBadRequest
+		}
+		if coinValues.CoinsPerUSD < 0 || coinValues.MinimumCoins < 0 {
+			return apiErrors.ErrBadRequest
+		}
+		return nil
+	}
+	return updateTournamentSettings(tournamentID, userID, validate, func() error {
+		return db.UpdateTournamentCoinValues(tournamentID, coinValues)
+	})
+}
@@ -19,6 +19,11 @@ func RegisterEndpoints(r *mux.Router) {
 	r.HandleFunc("/tournament_player", GetTournamentPlayersHandler).Methods(http.MethodGet)
 	r.HandleFunc("/store/update", UpdateStoreHandler).Methods(http.MethodPost)
 	r.HandleFunc("/store", GetStoreHandler).Methods(http.MethodGet)
+	r.HandleFunc("/deck_rules/update", UpdateDeckRulesHandler).Methods(http.MethodPost)
+	r.HandleFunc("/duplicate_protection/update", UpdateDuplicateProtectionHandler).Methods(http.MethodPost)
+	r.HandleFunc("/trade_rules/update", UpdateTradeRulesHandler).Methods(http.MethodPost)
+	r.HandleFunc("/trade_up_recipes/update", UpdateTradeUpRecipesHandler).Methods(http.MethodPost)
+	r.HandleFunc("/coin_values/update", UpdateCoinValuesHandler).Methods(http.MethodPost)
 }
 
 //
@@ -100,6 +105,15 @@ type GetTournamentPlayersResponse struct {
 func GetTournamentPlayersHandler(w http.ResponseWriter, r *http.Request) {
 	log := log.With().Ctx(r.Context()).Str("path", r.URL.Path).Logger()
 
+	// Get user ID from request context
+	userID, ok := r.Context().Value("user_id").(string)
+	if userID == "" || !ok {
+		log.Debug().
+			Msg("failed to read user id from context")
+		http.Error(w, "", http.StatusForbidden)
+		return
+	}
+
 	// Get tournament ID from query
 	tournamentID := r.URL.Query().Get("tournament_id")
 	if tournamentID == "" {
@@ -107,7 +121,7 @@ func GetTournamentPlayersHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Get the tournament players
-	tournament_players, users, err := GetTournamentPlayers(tournamentID)
+	tournament_players, users, err := GetTournamentPlayers(userID, tournamentID)
 	if err != nil {
 		log.Debug().Err(err).Msg("failed to get tournament")
 		w.Write(response.NewErrorResponse(err))
@@ -259,3 +273,118 @@ func GetStoreHandler(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 	w.Write(response.NewDataResponse(GetStoreRequest{Store: *store}))
 }
+
+// handleTournamentSettingsUpdate reads the user and tournament of a settings update, decodes the body into request
+// and runs update, sending back the response if it succeeds
+func handleTournamentSettingsUpdate(w http.ResponseWriter, r *http.Request, request interface{}, updateResponse interface{}, update func(tournamentID, userID string) error) {
+	log := log.With().Ctx(r.Context()).Str("path", r.URL.Path).Logger()
+
+	// Get user ID from request context
+	userID, ok := r.Context().Value("user_id").(string)
+	if userID == "" || !ok {
+		log.Debug().
+			Msg("failed to read user id from context")
+		http.Error(w, "", http.StatusForbidden)
+		return
+	}
+
+	// Get tournament ID from query
+	tournamentID := r.URL.Query().Get("tournament_id")
+	if tournamentID == "" {
+		http.Error(w, "", http.StatusBadRequest)
+		return
+	}
+
+	// Decode body data
+	err := json.NewDecoder(r.Body).Decode(request)
+	if err != nil {
+		log.Debug().
+			Err(err).
+			Msg("failed to read request body")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write(response.NewErrorResponse(err))
+		return
+	}
+
+	// Update the settings
+	err = update(tournamentID, userID)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write(response.NewErrorResponse(err))
+		return
+	}
+
+	// Send response back
+	w.WriteHeader(http.StatusOK)
+	w.Write(response.NewDataResponse(updateResponse))
+}
+
+type UpdateDeckRulesRequest struct {
+	DeckRules domain.DeckRules `json:"deck_rules"`
+}
+
+type UpdateDeckRulesResponse struct{}
+
+// ENDPOINT: Update the default deck format and banlist
+func UpdateDeckRulesHandler(w http.ResponseWriter, r *http.Request) {
+	var request UpdateDeckRulesRequest
+	handleTournamentSettingsUpdate(w, r, &request, UpdateDeckRulesResponse{}, func(tournamentID, userID string) error {
+		return UpdateDeckRules(tournamentID, userID, request.DeckRules)
+	})
+}
+
+type UpdateDuplicateProtectionRequest struct {
+	DuplicateProtection domain.DuplicateProtection `json:"duplicate_protection"`
+}
+
+type UpdateDuplicateProtectionResponse struct{}
+
+// ENDPOINT: Update how many copies of a card players can get before the rest are converted into coins
+func UpdateDuplicateProtectionHandler(w http.ResponseWriter, r *http.Request) {
+	var request UpdateDuplicateProtectionRequest
+	handleTournamentSettingsUpdate(w, r, &request, UpdateDuplicateProtectionResponse{}, func(tournamentID, userID string) error {
+		return UpdateDuplicateProtection(tournamentID, userID, request.DuplicateProtection)
+	})
+}
+
+type UpdateTradeRulesRequest struct {
+	TradeRules domain.TradeRules `json:"trade_rules"`
+}
+
+type UpdateTradeRulesResponse struct{}
+
+// ENDPOINT: Update whether trades between players need a moderator's approval
+func UpdateTradeRulesHandler(w http.ResponseWriter, r *http.Request) {
+	var request UpdateTradeRulesRequest
+	handleTournamentSettingsUpdate(w, r, &request, UpdateTradeRulesResponse{}, func(tournamentID, userID string) error {
+		return UpdateTradeRules(tournamentID, userID, request.TradeRules)
+	})
+}
+
+type UpdateTradeUpRecipesRequest struct {
+	TradeUpRecipes []domain.TradeUpRecipe `json:"trade_up_recipes"`
+}
+
+type UpdateTradeUpRecipesResponse struct{}
+
+// ENDPOINT: Update the recipes players can trade up their cards with
+func UpdateTradeUpRecipesHandler(w http.ResponseWriter, r *http.Request) {
+	var request UpdateTradeUpRecipesRequest
+	handleTournamentSettingsUpdate(w, r, &request, UpdateTradeUpRecipesResponse{}, func(tournamentID, userID string) error {
+		return UpdateTradeUpRecipes(tournamentID, userID, request.TradeUpRecipes)
+	})
+}
+
+type UpdateCoinValuesRequest struct {
+	CoinValues domain.CoinValues `json:"coin_values"`
+}
+
+type UpdateCoinValuesResponse struct{}
+
+// ENDPOINT: Update whether cards and booster packs are valued by their market price
+func UpdateCoinValuesHandler(w http.ResponseWriter, r *http.Request) {
+	var request UpdateCoinValuesRequest
+	handleTournamentSettingsUpdate(w, r, &request, UpdateCoinValuesResponse{}, func(tournamentID, userID string) error {
+		return UpdateCoinValues(tournamentID, userID, request.CoinValues)
+	})
+}
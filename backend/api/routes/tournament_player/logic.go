@@ -16,6 +16,54 @@ func GetTournamentPlayerByID(tournamentPlayerID string) (*domain.TournamentPlaye
 func GetTournamentPlayersForUser(userID string) ([]domain.TournamentPlayer, error) {
 	return db.GetTournamentPlayersForUser(userID)
 }
+
+// GetVisibleTournamentPlayersForUser returns another user's tournament players, without what their privacy settings
+// hide from the viewer
+func GetVisibleTournamentPlayersForUser(viewerID, userID string) ([]domain.TournamentPlayer, error) {
+	tournamentPlayers, err := db.GetTournamentPlayersForUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	if viewerID == userID {
+		return tournamentPlayers, nil
+	}
+	for index, tournamentPlayer := range tournamentPlayers {
+		viewer, err := db.GetTournamentPlayer(tournamentPlayer.TournamentID.Hex(), viewerID)
+		if err != nil {
+			if !errors.Is(err, db.ErrNotFound) {
+				return nil, apiErrors.ErrInternal
+			}
+			viewer = nil
+		}
+		tournamentPlayers[index] = domain.VisibleTournamentPlayer(tournamentPlayer, viewer)
+	}
+	return tournamentPlayers, nil
+}
+
+// UpdatePrivacySettings changes who can see the user's collection, decks and coins on a tournament
+func UpdatePrivacySettings(userID, tournamentID string, privacy domain.PrivacySettings) error {
+	if domain.ValidatePrivacySettings(privacy) != nil {
+		return apiErrors.ErrBadRequest
+	}
+	tournamentPlayer, err := db.GetTournamentPlayer(tournamentID, userID)
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			return apiErrors.ErrNotFound
+		}
+		if errors.Is(err, db.ErrInvalidID) {
+			return apiErrors.ErrBadRequest
+		}
+		return apiErrors.ErrInternal
+	}
+	err = db.UpdateTournamentPlayerPrivacy(tournamentPlayer.ID.Hex(), privacy)
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			return apiErrors.ErrNotFound
+		}
+		return apiErrors.ErrInternal
+	}
+	return nil
+}
 func GetBoosterPacksForTournamentPlayer(tournamentID, userID string) ([]domain.OwnedBoosterPack, error) {
 	return db.GetAvailablePacksForTournamentPlayer(tournamentID, userID)
 }
@@ -65,18 +113,32 @@ func CreateTournamentPlayer(rawUserID string, createTournamentPlayerRequest Crea
 	return tournamentID.Hex(), nil
 }
 
-func AddCoinsToTournamentPlayer(tPlayerID string, coins int) error {
+func AddCoinsToTournamentPlayer(userID, tPlayerID string, coins int) error {
 	tPlayer, err := db.GetTournamentPlayerByID(tPlayerID)
 	if err != nil {
 		return err
 	}
-	return db.AddCoinsToTournamentPlayer(coins, tPlayer.UserID.Hex(), tPlayer.TournamentID.Hex())
+	dbUserID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return apiErrors.ErrBadRequest
+	}
+	return db.AddCoinsToTournamentPlayer(coins, tPlayer.UserID.Hex(), tPlayer.TournamentID.Hex(), domain.LedgerCause{
+		ActorID: dbUserID,
+		Reason:  domain.LedgerReasonAdminGrant,
+	})
 }
 
-func AddPointsToTournamentPlayer(tPlayerID string, coins int) error {
+func AddPointsToTournamentPlayer(userID, tPlayerID string, coins int) error {
 	tPlayer, err := db.GetTournamentPlayerByID(tPlayerID)
 	if err != nil {
 		return err
 	}
-	return db.AddPointsToTournamentPlayer(coins, tPlayer.UserID.Hex(), tPlayer.TournamentID.Hex())
+	dbUserID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return apiErrors.ErrBadRequest
+	}
+	return db.AddPointsToTournamentPlayer(coins, tPlayer.UserID.Hex(), tPlayer.TournamentID.Hex(), domain.LedgerCause{
+		ActorID: dbUserID,
+		Reason:  domain.LedgerReasonAdminGrant,
+	})
 }
@@ -21,6 +21,7 @@ func RegisterEndpoints(r *mux.Router) {
 	r.HandleFunc("", CreateTournamentPlayerHandler).Methods(http.MethodPost)
 	r.HandleFunc("/coins", AddCoinsToTournamentPlayerHandler).Methods(http.MethodPost)
 	r.HandleFunc("/points", AddPointsToTournamentPlayerHandler).Methods(http.MethodPost)
+	r.HandleFunc("/privacy", UpdatePrivacySettingsHandler).Methods(http.MethodPost)
 }
 
 type GetPacksForTournamentPlayerResponse struct {
@@ -159,6 +160,13 @@ type GetTournamentPlayersForUserResponse struct {
 func GetTournamentPlayersForUserHandler(w http.ResponseWriter, r *http.Request) {
 	log := log.With().Ctx(r.Context()).Str("path", r.URL.Path).Logger()
 
+	// Get the viewer's id
+	viewerID, err := auth.GetUserIDFromContext(r.Context())
+	if err != nil {
+		http.Error(w, "", http.StatusBadRequest)
+		return
+	}
+
 	// Get id
 	vars := mux.Vars(r)
 	userID, ok := vars["userID"]
@@ -167,7 +175,7 @@ func GetTournamentPlayersForUserHandler(w http.ResponseWriter, r *http.Request)
 	}
 
 	// Try to get tournament players
-	tournamentPlayers, err := GetTournamentPlayersForUser(userID)
+	tournamentPlayers, err := GetVisibleTournamentPlayersForUser(viewerID, userID)
 
 	// Write response
 	if err != nil {
@@ -219,6 +227,15 @@ type EmptyResponse struct {
 func AddCoinsToTournamentPlayerHandler(w http.ResponseWriter, r *http.Request) {
 	log := log.With().Ctx(r.Context()).Str("path", r.URL.Path).Logger()
 
+	// Get user ID from request context
+	userID, ok := r.Context().Value("user_id").(string)
+	if userID == "" || !ok {
+		log.Debug().
+			Msg("failed to read user id from context")
+		http.Error(w, "", http.StatusForbidden)
+		return
+	}
+
 	// Get tournament player ID from request context
 	tPlayerID := r.URL.Query().Get("tournament_player_id")
 	if tPlayerID == "" {
@@ -237,7 +254,7 @@ func AddCoinsToTournamentPlayerHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = AddCoinsToTournamentPlayer(tPlayerID, req.Coins)
+	err = AddCoinsToTournamentPlayer(userID, tPlayerID, req.Coins)
 
 	if err != nil {
 		w.WriteHeader(http.StatusBadRequest)
@@ -257,6 +274,15 @@ type AddPointsToTournamentPlayerRequest struct {
 func AddPointsToTournamentPlayerHandler(w http.ResponseWriter, r *http.Request) {
 	log := log.With().Ctx(r.Context()).Str("path", r.URL.Path).Logger()
 
+	// Get user ID from request context
+	userID, ok := r.Context().Value("user_id").(string)
+	if userID == "" || !ok {
+		log.Debug().
+			Msg("failed to read user id from context")
+		http.Error(w, "", http.StatusForbidden)
+		return
+	}
+
 	// Get tournament player ID from request context
 	tPlayerID := r.URL.Query().Get("tournament_player_id")
 	if tPlayerID == "" {
@@ -274,8 +300,55 @@ func AddPointsToTournamentPlayerHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	err = AddPointsToTournamentPlayer(tPlayerID, req.Points)
+	err = AddPointsToTournamentPlayer(userID, tPlayerID, req.Points)
+
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write(response.NewErrorResponse(err))
+		return
+	}
+
+	// Send response back
+	w.WriteHeader(http.StatusOK)
+	w.Write(response.NewDataResponse(EmptyResponse{}))
+}
+
+type UpdatePrivacySettingsRequest struct {
+	Privacy domain.PrivacySettings `json:"privacy"`
+}
+
+func UpdatePrivacySettingsHandler(w http.ResponseWriter, r *http.Request) {
+	log := log.With().Ctx(r.Context()).Str("path", r.URL.Path).Logger()
+
+	// Get user ID from request context
+	userID, ok := r.Context().Value("user_id").(string)
+	if userID == "" || !ok {
+		log.Debug().
+			Msg("failed to read user id from context")
+		http.Error(w, "", http.StatusForbidden)
+		return
+	}
+
+	// Get tournament ID from query
+	tournamentID := r.URL.Query().Get("tournament_id")
+	if tournamentID == "" {
+		http.Error(w, "", http.StatusBadRequest)
+		return
+	}
+
+	// Decode body data
+	var req UpdatePrivacySettingsRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		log.Debug().
+			Err(err).
+			Msg("failed to read request body")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write(response.NewErrorResponse(err))
+		return
+	}
 
+	err = UpdatePrivacySettings(userID, tournamentID, req.Privacy)
 	if err != nil {
 		w.WriteHeader(http.StatusBadRequest)
 		w.Write(response.NewErrorResponse(err))
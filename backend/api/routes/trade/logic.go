@@ -0,0 +1,382 @@
+package trade
+
+import (
+	"errors"
+	"math"
+
+	"github.com/joaquinleonarg/wdml-mtg/backend/db"
+	"github.com/joaquinleonarg/wdml-mtg/backend/domain"
+	apiErrors "github.com/joaquinleonarg/wdml-mtg/backend/errors"
+	"github.com/rs/zerolog/log"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Offer is what one side of a trade gives, as sent by the client
+type Offer struct {
+	// Owned card ID -> copies
+	Cards map[string]int `json:"cards"`
+	Coins int            `json:"coins"`
+	// Set code -> unopened packs
+	BoosterPacks map[string]int `json:"booster_packs"`
+}
+
+// ProposeTrade creates a trade from the user to another player of the same tournament
+func ProposeTrade(userID, tournamentID, recipientID string, offered, requested Offer) (string, error) {
+	proposer, err := db.GetTournamentPlayer(tournamentID, userID)
+	if err != nil {
+		return "", mapTradeError(err)
+	}
+	return createTrade(proposer, recipientID, offered, requested, primitive.NilObjectID)
+}
+
+// CounterTrade replaces a pending trade sent to the user with a new one going the other way
+func CounterTrade(userID, tradeID string, offered, requested Offer) (string, error) {
+	trade, tournamentPlayer, err := getTradeForPlayer(userID, tradeID)
+	if err != nil {
+		return "", err
+	}
+	if trade.RecipientID != tournamentPlayer.ID {
+		return "", apiErrors.ErrUnauthorized
+	}
+	if trade.Status != domain.TradeStatusPending {
+		return "", apiErrors.ErrInvalidState
+	}
+	return createTrade(tournamentPlayer, trade.ProposerID.Hex(), offered, requested, trade.ID)
+}
+
+func createTrade(proposer *domain.TournamentPlayer, recipientID string, offered, requested Offer, counteredTradeID primitive.ObjectID) (string, error) {
+	recipient, err := db.GetTournamentPlayerByID(recipientID)
+	if err != nil {
+		return "", mapTradeError(err)
+	}
+	if recipient.TournamentID != proposer.TournamentID || recipient.ID == proposer.ID {
+		return "", apiErrors.ErrBadRequest
+	}
+
+	proposerOffer, err := buildOffer(offered, proposer)
+	if err != nil {
+		return "", err
+	}
+	recipientOffer, err := buildOffer(requested, recipient)
+	if err != nil {
+		return "", err
+	}
+	if isEmptyOffer(proposerOffer) && isEmptyOffer(recipientOffer) {
+		return "", apiErrors.ErrBadRequest
+	}
+
+	tradeID, err := db.CreateTrade(domain.Trade{
+		TournamentID:     proposer.TournamentID,
+		ProposerID:       proposer.ID,
+		RecipientID:      recipient.ID,
+		ProposerOffer:    proposerOffer,
+		RecipientOffer:   recipientOffer,
+		CounteredTradeID: counteredTradeID,
+	})
+	if err != nil {
+		return "", mapTradeError(err)
+	}
+	return tradeID.Hex(), nil
+}
+
+// buildOffer checks the owner currently has everything on the offer. It's checked again when the trade is settled.
+func buildOffer(offer Offer, owner *domain.TournamentPlayer) (domain.TradeOffer, error) {
+	tradeOffer := domain.TradeOffer{
+		Cards:        []domain.TradeCard{},
+		Coins:        offer.Coins,
+		BoosterPacks: []domain.OwnedBoosterPack{},
+	}
+
+	for ownedCardID, count := range offer.Cards {
+		ownedCard, err := db.GetOwnedCardById(ownedCardID)
+		if err != nil {
+			return tradeOffer, apiErrors.ErrBadRequest
+		}
+		if ownedCard.UserID != owner.UserID || ownedCard.TournamentID != owner.TournamentID {
+			return tradeOffer, apiErrors.ErrBadRequest
+		}
+		if count <= 0 {
+			return tradeOffer, apiErrors.ErrBadRequest
+		}
+		if count > ownedCard.Count {
+			return tradeOffer, apiErrors.ErrNotEnough
+		}
+		tradeOffer.Cards = append(tradeOffer.Cards, domain.TradeCard{
+			OwnedCardID: ownedCard.ID,
+			Count:       count,
+			CardData:    ownedCard.CardData,
+		})
+	}
+
+	if offer.Coins < 0 {
+		return tradeOffer, apiErrors.ErrBadRequest
+	}
+	if offer.Coins > owner.GameResources.Coins {
+		return tradeOffer, apiErrors.ErrNotEnough
+	}
+
+	for setCode, count := range offer.BoosterPacks {
+		if count <= 0 {
+			return tradeOffer, apiErrors.ErrBadRequest
+		}
+		found := false
+		for _, pack := range owner.GameResources.BoosterPacks {
+			if pack.SetCode == setCode {
+				if pack.Available < count {
+					return tradeOffer, apiErrors.ErrNotEnough
+				}
+				pack.Available = count
+				tradeOffer.BoosterPacks = append(tradeOffer.BoosterPacks, pack)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return tradeOffer, apiErrors.ErrNotEnough
+		}
+	}
+
+	return tradeOffer, nil
+}
+
+func isEmptyOffer(offer domain.TradeOffer) bool {
+	return len(offer.Cards) == 0 && offer.Coins == 0 && len(offer.BoosterPacks) == 0
+}
+
+// AcceptTrade accepts a trade sent to the user. If the tournament requires approval, the trade waits for a
+// moderator, otherwise it's settled right away.
+func AcceptTrade(userID, tradeID string) (domain.TradeStatus, error) {
+	trade, tournamentPlayer, err := getTradeForPlayer(userID, tradeID)
+	if err != nil {
+		return "", err
+	}
+	if trade.RecipientID != tournamentPlayer.ID {
+		return "", apiErrors.ErrUnauthorized
+	}
+
+	tournament, err := db.GetTournamentByID(trade.TournamentID.Hex())
+	if err != nil {
+		return "", apiErrors.ErrInternal
+	}
+	if tournament.TradeRules.RequireApproval {
+		err = db.UpdateTradeStatus(tradeID, []domain.TradeStatus{domain.TradeStatusPending}, domain.TradeStatusAwaitingApproval, "")
+		if err != nil {
+			return "", mapTradeError(err)
+		}
+		return domain.TradeStatusAwaitingApproval, nil
+	}
+
+	err = db.SettleTrade(tradeID, []domain.TradeStatus{domain.TradeStatusPending}, "", userID)
+	if err != nil {
+		log.Debug().Err(err).Str("trade_id", tradeID).Msg("failed to settle trade")
+		return "", mapTradeError(err)
+	}
+	return domain.TradeStatusCompleted, nil
+}
+
+// DeclineTrade declines a pending trade sent to the user
+func DeclineTrade(userID, tradeID string) error {
+	trade, tournamentPlayer, err := getTradeForPlayer(userID, tradeID)
+	if err != nil {
+		return err
+	}
+	if trade.RecipientID != tournamentPlayer.ID {
+		return apiErrors.ErrUnauthorized
+	}
+	err = db.UpdateTradeStatus(tradeID, []domain.TradeStatus{domain.TradeStatusPending}, domain.TradeStatusDeclined, "")
+	if err != nil {
+		return mapTradeError(err)
+	}
+	return nil
+}
+
+// CancelTrade withdraws a trade the user proposed, as long as it wasn't settled yet
+func CancelTrade(userID, tradeID string) error {
+	trade, tournamentPlayer, err := getTradeForPlayer(userID, tradeID)
+	if err != nil {
+		return err
+	}
+	if trade.ProposerID != tournamentPlayer.ID {
+		return apiErrors.ErrUnauthorized
+	}
+	err = db.UpdateTradeStatus(tradeID, []domain.TradeStatus{domain.TradeStatusPending, domain.TradeStatusAwaitingApproval}, domain.TradeStatusCancelled, "")
+	if err != nil {
+		return mapTradeError(err)
+	}
+	return nil
+}
+
+// ApproveTrade settles a trade that was waiting for a moderator
+func ApproveTrade(userID, tradeID string) error {
+	moderator, err := getTradeModerator(userID, tradeID)
+	if err != nil {
+		return err
+	}
+	err = db.SettleTrade(tradeID, []domain.TradeStatus{domain.TradeStatusAwaitingApproval}, moderator.ID.Hex(), userID)
+	if err != nil {
+		log.Debug().Err(err).Str("trade_id", tradeID).Msg("failed to settle trade")
+		return mapTradeError(err)
+	}
+	return nil
+}
+
+// RejectTrade discards a trade that was waiting for a moderator
+func RejectTrade(userID, tradeID string) error {
+	moderator, err := getTradeModerator(userID, tradeID)
+	if err != nil {
+		return err
+	}
+	err = db.UpdateTradeStatus(tradeID, []domain.TradeStatus{domain.TradeStatusAwaitingApproval}, domain.TradeStatusRejected, moderator.ID.Hex())
+	if err != nil {
+		return mapTradeError(err)
+	}
+	return nil
+}
+
+// GetTrade returns a trade, if the user is part of it or moderates its tournament
+func GetTrade(userID, tradeID string) (*domain.Trade, error) {
+	trade, err := db.GetTradeByID(tradeID)
+	if err != nil {
+		return nil, mapTradeError(err)
+	}
+	tournamentPlayer, err := db.GetTournamentPlayer(trade.TournamentID.Hex(), userID)
+	if err != nil {
+		return nil, apiErrors.ErrUnauthorized
+	}
+	if trade.ProposerID != tournamentPlayer.ID && trade.RecipientID != tournamentPlayer.ID && !isModerator(tournamentPlayer) {
+		return nil, apiErrors.ErrUnauthorized
+	}
+	return trade, nil
+}
+
+// GetTradesForPlayer returns the trades the user sent or received on a tournament
+func GetTradesForPlayer(userID, tournamentID string, status domain.TradeStatus) ([]domain.Trade, error) {
+	tournamentPlayer, err := db.GetTournamentPlayer(tournamentID, userID)
+	if err != nil {
+		return nil, mapTradeError(err)
+	}
+	statuses := []domain.TradeStatus{}
+	if status != "" {
+		statuses = append(statuses, status)
+	}
+	trades, err := db.GetTrades(tournamentID, tournamentPlayer.ID.Hex(), statuses)
+	if err != nil {
+		return nil, mapTradeError(err)
+	}
+	return trades, nil
+}
+
+// GetTradesAwaitingApproval returns the trades of a tournament a moderator has to review
+func GetTradesAwaitingApproval(userID, tournamentID string) ([]domain.Trade, error) {
+	tournamentPlayer, err := db.GetTournamentPlayer(tournamentID, userID)
+	if err != nil {
+		return nil, mapTradeError(err)
+	}
+	if !isModerator(tournamentPlayer) {
+		return nil, apiErrors.ErrUnauthorized
+	}
+	trades, err := db.GetTrades(tournamentID, "", []domain.TradeStatus{domain.TradeStatusAwaitingApproval})
+	if err != nil {
+		return nil, mapTradeError(err)
+	}
+	return trades, nil
+}
+
+func getTradeForPlayer(userID, tradeID string) (*domain.Trade, *domain.TournamentPlayer, error) {
+	trade, err := db.GetTradeByID(tradeID)
+	if err != nil {
+		return nil, nil, mapTradeError(err)
+	}
+	tournamentPlayer, err := db.GetTournamentPlayer(trade.TournamentID.Hex(), userID)
+	if err != nil {
+		return nil, nil, apiErrors.ErrUnauthorized
+	}
+	return trade, tournamentPlayer, nil
+}
+
+func getTradeModerator(userID, tradeID string) (*domain.TournamentPlayer, error) {
+	_, tournamentPlayer, err := getTradeForPlayer(userID, tradeID)
+	if err != nil {
+		return nil, err
+	}
+	if !isModerator(tournamentPlayer) {
+		return nil, apiErrors.ErrUnauthorized
+	}
+	return tournamentPlayer, nil
+}
+
+func isModerator(tournamentPlayer *domain.TournamentPlayer) bool {
+	return tournamentPlayer.AccessLevel == domain.AccessLevelAdministrator || tournamentPlayer.AccessLevel == domain.AccessLevelModerator
+}
+
+func mapTradeError(err error) error {
+	switch {
+	case errors.Is(err, db.ErrInvalidID):
+		return apiErrors.ErrBadRequest
+	case errors.Is(err, db.ErrNotFound):
+		return apiErrors.ErrNotFound
+	case errors.Is(err, db.ErrNotEnough):
+		return apiErrors.ErrNotEnough
+	case errors.Is(err, db.ErrCardsInUse):
+		return apiErrors.ErrCardsInDeck
+	case errors.Is(err, db.ErrInvalidState):
+		return apiErrors.ErrInvalidState
+	}
+	return apiErrors.ErrInternal
+}
+
+// OfferValue is what one side of a trade is worth
+type OfferValue struct {
+	Coins int     `json:"coins"`
+	USD   float64 `json:"usd"`
+	EUR   float64 `json:"eur"`
+}
+
+// TradeBalance compares what each side of a trade gives, so players can tell whether it's fair
+type TradeBalance struct {
+	Proposer  OfferValue `json:"proposer"`
+	Recipient OfferValue `json:"recipient"`
+}
+
+// GetTradeBalance values both sides of a trade with the tournament's coin values. Coins are worth their face value,
+// and packs what they cost on the tournament's store.
+func GetTradeBalance(userID, tradeID string) (*TradeBalance, error) {
+	trade, err := GetTrade(userID, tradeID)
+	if err != nil {
+		return nil, err
+	}
+	tournament, err := db.GetTournamentByID(trade.TournamentID.Hex())
+	if err != nil {
+		return nil, mapTradeError(err)
+	}
+	return &TradeBalance{
+		Proposer:  valueOffer(trade.ProposerOffer, tournament),
+		Recipient: valueOffer(trade.RecipientOffer, tournament),
+	}, nil
+}
+
+func valueOffer(offer domain.TradeOffer, tournament *domain.Tournament) OfferValue {
+	value := OfferValue{Coins: offer.Coins}
+	for _, card := range offer.Cards {
+		value.Coins += domain.CardCoinValue(card.CardData, tournament.CoinValues) * card.Count
+		value.USD += domain.CardPriceUSD(card.CardData) * float64(card.Count)
+		value.EUR += domain.CardPriceEUR(card.CardData) * float64(card.Count)
+	}
+	for _, boosterPack := range offer.BoosterPacks {
+		// Packs that aren't sold on the store aren't worth anything
+		pack, err := db.GetPackBySetCode(boosterPack.SetCode)
+		if err != nil {
+			continue
+		}
+		for _, storePack := range tournament.Store.BoosterPacks {
+			if storePack.BoosterPackID == pack.ID {
+				value.Coins += domain.StoreCoinPrice(storePack, tournament.CoinValues) * boosterPack.Available
+				value.USD += storePack.USDPrice * float64(boosterPack.Available)
+				break
+			}
+		}
+	}
+	value.USD = math.Round(value.USD*100) / 100
+	value.EUR = math.Round(value.EUR*100) / 100
+	return value
+}
@@ -0,0 +1,409 @@
+package trade
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/joaquinleonarg/wdml-mtg/backend/api/response"
+	"github.com/joaquinleonarg/wdml-mtg/backend/domain"
+	"github.com/rs/zerolog/log"
+)
+
+func RegisterEndpoints(r *mux.Router) {
+	r = r.PathPrefix("/trade").Subrouter()
+	r.HandleFunc("", GetTradeHandler).Methods(http.MethodGet)
+	r.HandleFunc("", ProposeTradeHandler).Methods(http.MethodPost)
+	r.HandleFunc("/balance", GetTradeBalanceHandler).Methods(http.MethodGet)
+	r.HandleFunc("/tournament_player", GetTradesForPlayerHandler).Methods(http.MethodGet)
+	r.HandleFunc("/counter", CounterTradeHandler).Methods(http.MethodPost)
+	r.HandleFunc("/accept", AcceptTradeHandler).Methods(http.MethodPost)
+	r.HandleFunc("/decline", DeclineTradeHandler).Methods(http.MethodPost)
+	r.HandleFunc("/cancel", CancelTradeHandler).Methods(http.MethodPost)
+	r.HandleFunc("/pending_approval", GetTradesAwaitingApprovalHandler).Methods(http.MethodGet)
+	r.HandleFunc("/approve", ApproveTradeHandler).Methods(http.MethodPost)
+	r.HandleFunc("/reject", RejectTradeHandler).Methods(http.MethodPost)
+}
+
+type TradeIDRequest struct {
+	TradeID string `json:"trade_id"`
+}
+
+type EmptyResponse struct{}
+
+//
+// ENDPOINT: Get a trade by its id
+//
+
+type GetTradeResponse struct {
+	Trade domain.Trade `json:"trade"`
+}
+
+func GetTradeHandler(w http.ResponseWriter, r *http.Request) {
+	log := log.With().Ctx(r.Context()).Str("path", r.URL.Path).Logger()
+
+	// Get user ID from request context
+	userID, ok := r.Context().Value("user_id").(string)
+	if userID == "" || !ok {
+		log.Debug().
+			Msg("failed to read user id from context")
+		http.Error(w, "", http.StatusForbidden)
+		return
+	}
+
+	// Get trade ID from query
+	tradeID := r.URL.Query().Get("trade_id")
+	if tradeID == "" {
+		http.Error(w, "", http.StatusBadRequest)
+		return
+	}
+
+	trade, err := GetTrade(userID, tradeID)
+	if err != nil {
+		log.Debug().Err(err).Msg("failed to get trade")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write(response.NewErrorResponse(err))
+		return
+	}
+
+	// Send response back
+	w.WriteHeader(http.StatusOK)
+	w.Write(response.NewDataResponse(GetTradeResponse{Trade: *trade}))
+}
+
+//
+// ENDPOINT: Get what each side of a trade is worth
+//
+
+type GetTradeBalanceResponse struct {
+	Balance TradeBalance `json:"balance"`
+}
+
+func GetTradeBalanceHandler(w http.ResponseWriter, r *http.Request) {
+	log := log.With().Ctx(r.Context()).Str("path", r.URL.Path).Logger()
+
+	// Get user ID from request context
+	userID, ok := r.Context().Value("user_id").(string)
+	if userID == "" || !ok {
+		log.Debug().
+			Msg("failed to read user id from context")
+		http.Error(w, "", http.StatusForbidden)
+		return
+	}
+
+	// Get trade ID from query
+	tradeID := r.URL.Query().Get("trade_id")
+	if tradeID == "" {
+		http.Error(w, "", http.StatusBadRequest)
+		return
+	}
+
+	balance, err := GetTradeBalance(userID, tradeID)
+	if err != nil {
+		log.Debug().Err(err).Msg("failed to get trade balance")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write(response.NewErrorResponse(err))
+		return
+	}
+
+	// Send response back
+	w.WriteHeader(http.StatusOK)
+	w.Write(response.NewDataResponse(GetTradeBalanceResponse{Balance: *balance}))
+}
+
+//
+// ENDPOINT: Get the trades a player sent or received, optionally filtered by status
+//
+
+type GetTradesResponse struct {
+	Trades []domain.Trade `json:"trades"`
+}
+
+func GetTradesForPlayerHandler(w http.ResponseWriter, r *http.Request) {
+	log := log.With().Ctx(r.Context()).Str("path", r.URL.Path).Logger()
+
+	// Get user ID from request context
+	userID, ok := r.Context().Value("user_id").(string)
+	if userID == "" || !ok {
+		log.Debug().
+			Msg("failed to read user id from context")
+		http.Error(w, "", http.StatusForbidden)
+		return
+	}
+
+	// Get tournament ID and status from query
+	tournamentID := r.URL.Query().Get("tournament_id")
+	if tournamentID == "" {
+		http.Error(w, "", http.StatusBadRequest)
+		return
+	}
+	status := domain.TradeStatus(r.URL.Query().Get("status"))
+
+	trades, err := GetTradesForPlayer(userID, tournamentID, status)
+	if err != nil {
+		log.Debug().Err(err).Msg("failed to get trades")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write(response.NewErrorResponse(err))
+		return
+	}
+
+	// Send response back
+	w.WriteHeader(http.StatusOK)
+	w.Write(response.NewDataResponse(GetTradesResponse{Trades: trades}))
+}
+
+//
+// ENDPOINT: Propose a trade to another player of the tournament
+//
+
+type ProposeTradeRequest struct {
+	TournamentID string `json:"tournament_id"`
+	// Tournament player the trade is proposed to
+	RecipientID string `json:"recipient_id"`
+	Offered     Offer  `json:"offered"`
+	Requested   Offer  `json:"requested"`
+}
+
+type ProposeTradeResponse struct {
+	TradeID string `json:"trade_id"`
+}
+
+func ProposeTradeHandler(w http.ResponseWriter, r *http.Request) {
+	log := log.With().Ctx(r.Context()).Str("path", r.URL.Path).Logger()
+
+	// Get user ID from request context
+	userID, ok := r.Context().Value("user_id").(string)
+	if userID == "" || !ok {
+		log.Debug().
+			Msg("failed to read user id from context")
+		http.Error(w, "", http.StatusForbidden)
+		return
+	}
+
+	// Decode body data
+	var proposeTradeRequest ProposeTradeRequest
+	err := json.NewDecoder(r.Body).Decode(&proposeTradeRequest)
+	if err != nil {
+		log.Debug().
+			Err(err).
+			Msg("failed to read request body")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write(response.NewErrorResponse(err))
+		return
+	}
+
+	tradeID, err := ProposeTrade(
+		userID,
+		proposeTradeRequest.TournamentID,
+		proposeTradeRequest.RecipientID,
+		proposeTradeRequest.Offered,
+		proposeTradeRequest.Requested,
+	)
+	if err != nil {
+		log.Debug().Err(err).Msg("failed to propose trade")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write(response.NewErrorResponse(err))
+		return
+	}
+
+	// Send response back
+	w.WriteHeader(http.StatusOK)
+	w.Write(response.NewDataResponse(ProposeTradeResponse{TradeID: tradeID}))
+}
+
+//
+// ENDPOINT: Answer a trade with a different offer
+//
+
+type CounterTradeRequest struct {
+	TradeID   string `json:"trade_id"`
+	Offered   Offer  `json:"offered"`
+	Requested Offer  `json:"requested"`
+}
+
+func CounterTradeHandler(w http.ResponseWriter, r *http.Request) {
+	log := log.With().Ctx(r.Context()).Str("path", r.URL.Path).Logger()
+
+	// Get user ID from request context
+	userID, ok := r.Context().Value("user_id").(string)
+	if userID == "" || !ok {
+		log.Debug().
+			Msg("failed to read user id from context")
+		http.Error(w, "", http.StatusForbidden)
+		return
+	}
+
+	// Decode body data
+	var counterTradeRequest CounterTradeRequest
+	err := json.NewDecoder(r.Body).Decode(&counterTradeRequest)
+	if err != nil {
+		log.Debug().
+			Err(err).
+			Msg("failed to read request body")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write(response.NewErrorResponse(err))
+		return
+	}
+
+	tradeID, err := CounterTrade(userID, counterTradeRequest.TradeID, counterTradeRequest.Offered, counterTradeRequest.Requested)
+	if err != nil {
+		log.Debug().Err(err).Msg("failed to counter trade")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write(response.NewErrorResponse(err))
+		return
+	}
+
+	// Send response back
+	w.WriteHeader(http.StatusOK)
+	w.Write(response.NewDataResponse(ProposeTradeResponse{TradeID: tradeID}))
+}
+
+//
+// ENDPOINT: Accept a trade. It's settled right away unless the tournament requires a moderator's approval.
+//
+
+type AcceptTradeResponse struct {
+	Status domain.TradeStatus `json:"status"`
+}
+
+func AcceptTradeHandler(w http.ResponseWriter, r *http.Request) {
+	log := log.With().Ctx(r.Context()).Str("path", r.URL.Path).Logger()
+
+	// Get user ID from request context
+	userID, ok := r.Context().Value("user_id").(string)
+	if userID == "" || !ok {
+		log.Debug().
+			Msg("failed to read user id from context")
+		http.Error(w, "", http.StatusForbidden)
+		return
+	}
+
+	// Decode body data
+	var tradeIDRequest TradeIDRequest
+	err := json.NewDecoder(r.Body).Decode(&tradeIDRequest)
+	if err != nil {
+		log.Debug().
+			Err(err).
+			Msg("failed to read request body")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write(response.NewErrorResponse(err))
+		return
+	}
+
+	status, err := AcceptTrade(userID, tradeIDRequest.TradeID)
+	if err != nil {
+		log.Debug().Err(err).Msg("failed to accept trade")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write(response.NewErrorResponse(err))
+		return
+	}
+
+	// Send response back
+	w.WriteHeader(http.StatusOK)
+	w.Write(response.NewDataResponse(AcceptTradeResponse{Status: status}))
+}
+
+//
+// ENDPOINT: Decline a trade
+//
+
+func DeclineTradeHandler(w http.ResponseWriter, r *http.Request) {
+	handleTradeAction(w, r, DeclineTrade)
+}
+
+//
+// ENDPOINT: Cancel a trade the player proposed
+//
+
+func CancelTradeHandler(w http.ResponseWriter, r *http.Request) {
+	handleTradeAction(w, r, CancelTrade)
+}
+
+//
+// ENDPOINT: Approve a trade, for tournaments that require it (moderators only)
+//
+
+func ApproveTradeHandler(w http.ResponseWriter, r *http.Request) {
+	handleTradeAction(w, r, ApproveTrade)
+}
+
+//
+// ENDPOINT: Reject a trade, for tournaments that require approval (moderators only)
+//
+
+func RejectTradeHandler(w http.ResponseWriter, r *http.Request) {
+	handleTradeAction(w, r, RejectTrade)
+}
+
+func handleTradeAction(w http.ResponseWriter, r *http.Request, action func(userID, tradeID string) error) {
+	log := log.With().Ctx(r.Context()).Str("path", r.URL.Path).Logger()
+
+	// Get user ID from request context
+	userID, ok := r.Context().Value("user_id").(string)
+	if userID == "" || !ok {
+		log.Debug().
+			Msg("failed to read user id from context")
+		http.Error(w, "", http.StatusForbidden)
+		return
+	}
+
+	// Decode body data
+	var tradeIDRequest TradeIDRequest
+	err := json.NewDecoder(r.Body).Decode(&tradeIDRequest)
+	if err != nil {
+		log.Debug().
+			Err(err).
+			Msg("failed to read request body")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write(response.NewErrorResponse(err))
+		return
+	}
+
+	err = action(userID, tradeIDRequest.TradeID)
+	if err != nil {
+		log.Debug().Err(err).Msg("failed to update trade")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write(response.NewErrorResponse(err))
+		return
+	}
+
+	// Send response back
+	w.WriteHeader(http.StatusOK)
+	w.Write(response.NewDataResponse(EmptyResponse{}))
+}
+
+//
+// ENDPOINT: Get the trades of a tournament waiting for approval (moderators only)
+//
+
+func GetTradesAwaitingApprovalHandler(w http.ResponseWriter, r *http.Request) {
+	log := log.With().Ctx(r.Context()).Str("path", r.URL.Path).Logger()
+
+	// Get user ID from request context
+	userID, ok := r.Context().Value("user_id").(string)
+	if userID == "" || !ok {
+		log.Debug().
+			Msg("failed to read user id from context")
+		http.Error(w, "", http.StatusForbidden)
+		return
+	}
+
+	// Get tournament ID from query
+	tournamentID := r.URL.Query().Get("tournament_id")
+	if tournamentID == "" {
+		http.Error(w, "", http.StatusBadRequest)
+		return
+	}
+
+	trades, err := GetTradesAwaitingApproval(userID, tournamentID)
+	if err != nil {
+		log.Debug().Err(err).Msg("failed to get trades")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write(response.NewErrorResponse(err))
+		return
+	}
+
+	// Send response back
+	w.WriteHeader(http.StatusOK)
+	w.Write(response.NewDataResponse(GetTradesResponse{Trades: trades}))
+}
@@ -0,0 +1,86 @@
+package wishlist
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/joaquinleonarg/wdml-mtg/backend/db"
+	"github.com/joaquinleonarg/wdml-mtg/backend/domain"
+	apiErrors "github.com/joaquinleonarg/wdml-mtg/backend/errors"
+	"github.com/joaquinleonarg/wdml-mtg/backend/pkg/scryfall"
+	"github.com/rs/zerolog/log"
+)
+
+// GetWishlist returns the cards the user is looking for on a tournament
+func GetWishlist(userID, tournamentID string) ([]domain.WishlistCard, error) {
+	tournamentPlayer, err := db.GetTournamentPlayer(tournamentID, userID)
+	if err != nil {
+		return nil, mapWishlistError(err)
+	}
+	wishlist, err := db.GetWishlist(tournamentPlayer.ID.Hex())
+	if err != nil {
+		return nil, mapWishlistError(err)
+	}
+	return wishlist, nil
+}
+
+// AddToWishlist adds a card to the user's wishlist. The name is looked up on Scryfall, so it's stored the same way
+// pulled cards name it. If a set is given, only printings of that set are wanted.
+func AddToWishlist(userID, tournamentID, cardName, setCode, note string) (string, error) {
+	tournamentPlayer, err := db.GetTournamentPlayer(tournamentID, userID)
+	if err != nil {
+		return "", mapWishlistError(err)
+	}
+	if strings.TrimSpace(cardName) == "" {
+		return "", apiErrors.ErrBadRequest
+	}
+
+	card, err := scryfall.GetCardByName(cardName, setCode)
+	if err != nil {
+		log.Debug().Err(err).Str("name", cardName).Msg("failed to find wishlisted card")
+		return "", apiErrors.ErrNotFound
+	}
+	if setCode != "" && !strings.EqualFold(card.Set, setCode) {
+		return "", apiErrors.ErrNotFound
+	}
+	if setCode != "" {
+		setCode = strings.ToUpper(card.Set)
+	}
+
+	wishlistCardID, err := db.AddWishlistCard(domain.WishlistCard{
+		TournamentID:       tournamentPlayer.TournamentID,
+		TournamentPlayerID: tournamentPlayer.ID,
+		CardName:           card.Name,
+		SetCode:            setCode,
+		Note:               note,
+	})
+	if err != nil {
+		return "", mapWishlistError(err)
+	}
+	return wishlistCardID.Hex(), nil
+}
+
+// RemoveFromWishlist takes a card out of the user's wishlist
+func RemoveFromWishlist(userID, tournamentID, wishlistCardID string) error {
+	tournamentPlayer, err := db.GetTournamentPlayer(tournamentID, userID)
+	if err != nil {
+		return mapWishlistError(err)
+	}
+	err = db.RemoveWishlistCard(tournamentPlayer.ID.Hex(), wishlistCardID)
+	if err != nil {
+		return mapWishlistError(err)
+	}
+	return nil
+}
+
+func mapWishlistError(err error) error {
+	switch {
+	case errors.Is(err, db.ErrNotFound):
+		return apiErrors.ErrNotFound
+	case errors.Is(err, db.ErrInvalidID):
+		return apiErrors.ErrBadRequest
+	case errors.Is(err, db.ErrAlreadyExists):
+		return apiErrors.ErrDuplicatedResource
+	}
+	return apiErrors.ErrInternal
+}
@@ -0,0 +1,166 @@
+package wishlist
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/joaquinleonarg/wdml-mtg/backend/api/response"
+	"github.com/joaquinleonarg/wdml-mtg/backend/api/routes/auth"
+	"github.com/joaquinleonarg/wdml-mtg/backend/domain"
+	"github.com/rs/zerolog/log"
+)
+
+func RegisterEndpoints(r *mux.Router) {
+	r = r.PathPrefix("/wishlist").Subrouter()
+	r.HandleFunc("", GetWishlistHandler).Methods(http.MethodGet)
+	r.HandleFunc("", AddToWishlistHandler).Methods(http.MethodPost)
+	r.HandleFunc("/remove", RemoveFromWishlistHandler).Methods(http.MethodPost)
+}
+
+type EmptyResponse struct{}
+
+//
+// ENDPOINT: Get the cards the player is looking for
+//
+
+type GetWishlistResponse struct {
+	Wishlist []domain.WishlistCard `json:"wishlist"`
+}
+
+func GetWishlistHandler(w http.ResponseWriter, r *http.Request) {
+	log := log.With().Ctx(r.Context()).Str("path", r.URL.Path).Logger()
+
+	// Get user ID from context
+	userID, err := auth.GetUserIDFromContext(r.Context())
+	if err != nil {
+		http.Error(w, "", http.StatusBadRequest)
+		return
+	}
+
+	// Get tournament ID from query
+	tournamentID := r.URL.Query().Get("tournament_id")
+	if tournamentID == "" {
+		http.Error(w, "", http.StatusBadRequest)
+		return
+	}
+
+	wishlist, err := GetWishlist(userID, tournamentID)
+	if err != nil {
+		log.Debug().Err(err).Msg("failed to get wishlist")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write(response.NewErrorResponse(err))
+		return
+	}
+
+	// Send response back
+	w.WriteHeader(http.StatusOK)
+	w.Write(response.NewDataResponse(GetWishlistResponse{Wishlist: wishlist}))
+}
+
+//
+// ENDPOINT: Add a card to the player's wishlist
+//
+
+type AddToWishlistRequest struct {
+	CardName string `json:"card_name"`
+	// Optional, any printing is wanted if empty
+	SetCode string `json:"set_code"`
+	Note    string `json:"note"`
+}
+
+type AddToWishlistResponse struct {
+	WishlistCardID string `json:"wishlist_card_id"`
+}
+
+func AddToWishlistHandler(w http.ResponseWriter, r *http.Request) {
+	log := log.With().Ctx(r.Context()).Str("path", r.URL.Path).Logger()
+
+	// Get user ID from context
+	userID, err := auth.GetUserIDFromContext(r.Context())
+	if err != nil {
+		http.Error(w, "", http.StatusBadRequest)
+		return
+	}
+
+	// Get tournament ID from query
+	tournamentID := r.URL.Query().Get("tournament_id")
+	if tournamentID == "" {
+		http.Error(w, "", http.StatusBadRequest)
+		return
+	}
+
+	// Decode body data
+	var req AddToWishlistRequest
+	err = json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		log.Debug().
+			Err(err).
+			Msg("failed to read request body")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write(response.NewErrorResponse(err))
+		return
+	}
+
+	wishlistCardID, err := AddToWishlist(userID, tournamentID, req.CardName, req.SetCode, req.Note)
+	if err != nil {
+		log.Debug().Err(err).Msg("failed to add card to wishlist")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write(response.NewErrorResponse(err))
+		return
+	}
+
+	// Send response back
+	w.WriteHeader(http.StatusOK)
+	w.Write(response.NewDataResponse(AddToWishlistResponse{WishlistCardID: wishlistCardID}))
+}
+
+//
+// ENDPOINT: Remove a card from the player's wishlist
+//
+
+type RemoveFromWishlistRequest struct {
+	WishlistCardID string `json:"wishlist_card_id"`
+}
+
+func RemoveFromWishlistHandler(w http.ResponseWriter, r *http.Request) {
+	log := log.With().Ctx(r.Context()).Str("path", r.URL.Path).Logger()
+
+	// Get user ID from context
+	userID, err := auth.GetUserIDFromContext(r.Context())
+	if err != nil {
+		http.Error(w, "", http.StatusBadRequest)
+		return
+	}
+
+	// Get tournament ID from query
+	tournamentID := r.URL.Query().Get("tournament_id")
+	if tournamentID == "" {
+		http.Error(w, "", http.StatusBadRequest)
+		return
+	}
+
+	// Decode body data
+	var req RemoveFromWishlistRequest
+	err = json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		log.Debug().
+			Err(err).
+			Msg("failed to read request body")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write(response.NewErrorResponse(err))
+		return
+	}
+
+	err = RemoveFromWishlist(userID, tournamentID, req.WishlistCardID)
+	if err != nil {
+		log.Debug().Err(err).Msg("failed to remove card from wishlist")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write(response.NewErrorResponse(err))
+		return
+	}
+
+	// Send response back
+	w.WriteHeader(http.StatusOK)
+	w.Write(response.NewDataResponse(EmptyResponse{}))
+}
@@ -15,6 +15,10 @@ type ServerConfig struct {
 	MongoUser     string
 	MongoPassword string
 	CorsOrigin    string
+	// Scryfall bulk data file card prices are read from. Prices aren't refreshed if empty.
+	ScryfallBulkDataPath string
+	// Secret the seed of each pack opening is derived from
+	BoosterSeedSecret string
 }
 
 var Config = ServerConfig{}
@@ -56,6 +60,15 @@ func Load() error {
 		return fmt.Errorf("missing CORS_ORIGIN env variable")
 	}
 
+	// Kept apart from SECRET_KEY so rotating the auth key doesn't change how pack openings are rederived
+	boosterSeedSecret := os.Getenv("BOOSTER_SEED_SECRET")
+	if boosterSeedSecret == "" || boosterSeedSecret == secretKey {
+		return fmt.Errorf("invalid BOOSTER_SEED_SECRET env variable, it must be set and differ from SECRET_KEY")
+	}
+
+	// Optional
+	scryfallBulkDataPath := os.Getenv("SCRYFALL_BULK_DATA_PATH")
+
 	Config = ServerConfig{
 		ApiPort:       apiPort,
 		SecretKey:     secretKey,
@@ -63,6 +76,9 @@ func Load() error {
 		MongoUser:     mongoUser,
 		MongoPassword: mongoPassword,
 		CorsOrigin:    corsOrigin,
+
+		ScryfallBulkDataPath: scryfallBulkDataPath,
+		BoosterSeedSecret:    boosterSeedSecret,
 	}
 	return nil
 }
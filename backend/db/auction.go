@@ -0,0 +1,510 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/joaquinleonarg/wdml-mtg/backend/domain"
+	"github.com/rs/zerolog/log"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// CreateAuctionListing lists copies of an owned card, taking them out of the seller's collection
+func CreateAuctionListing(listing domain.AuctionListing) (primitive.ObjectID, error) {
+	if listing.ID != primitive.NilObjectID {
+		return primitive.NilObjectID, ErrObjectIDProvided
+	}
+	listing.ID = primitive.NewObjectID()
+	listing.Status = domain.ListingStatusActive
+	listing.Bids = []domain.AuctionBid{}
+	listing.CreatedAt = primitive.NewDateTimeFromTime(time.Now())
+	listing.UpdatedAt = primitive.NewDateTimeFromTime(time.Now())
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*20)
+	defer cancel()
+
+	// Begin transaction
+	session, err := MongoDatabaseClient.
+		StartSession()
+	if err != nil {
+		return primitive.NilObjectID, fmt.Errorf("%w: %v", ErrInternal, err)
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(ctx mongo.SessionContext) (interface{}, error) {
+		seller, err := findTournamentPlayer(ctx, listing.SellerID)
+		if err != nil {
+			return nil, err
+		}
+		decks, err := findDecksForTournamentPlayer(ctx, seller.ID)
+		if err != nil {
+			return nil, err
+		}
+		ownedCard, err := removeOwnedCard(ctx, listing.OwnedCardID, listing.Count, seller, decks, domain.LedgerCause{
+			ActorID:   seller.UserID,
+			Reason:    domain.LedgerReasonAuctionListing,
+			RelatedID: listing.ID,
+		})
+		if err != nil {
+			return nil, err
+		}
+		listing.CardData = ownedCard.CardData
+
+		_, err = MongoDatabaseClient.
+			Database(DB_MAIN).
+			Collection(COLLECTION_AUCTION_LISTINGS).
+			InsertOne(ctx, listing)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInternal, err)
+		}
+		return nil, nil
+	})
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+	return listing.ID, nil
+}
+
+func GetAuctionListingByID(listingID string) (*domain.AuctionListing, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*20)
+	defer cancel()
+	dbListingID, err := primitive.ObjectIDFromHex(listingID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidID, err)
+	}
+	return findAuctionListing(ctx, dbListingID)
+}
+
+func findAuctionListing(ctx context.Context, listingID primitive.ObjectID) (*domain.AuctionListing, error) {
+	// Find listing
+	result := MongoDatabaseClient.
+		Database(DB_MAIN).
+		Collection(COLLECTION_AUCTION_LISTINGS).
+		FindOne(ctx, bson.M{"_id": listingID})
+	if err := result.Err(); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, fmt.Errorf("%w: %v", ErrNotFound, err)
+		}
+		return nil, fmt.Errorf("%w: %v", ErrInternal, err)
+	}
+
+	// Decode listing
+	var listing *domain.AuctionListing
+	err := result.Decode(&listing)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInternal, err)
+	}
+	return listing, nil
+}
+
+// GetAuctionListings returns the listings of a tournament, the ones ending sooner first. If a seller is given,
+// only their listings are returned; if statuses are given, only listings on those statuses.
+func GetAuctionListings(tournamentID, sellerID string, statuses []domain.ListingStatus) ([]domain.AuctionListing, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*20)
+	defer cancel()
+	dbTournamentID, err := primitive.ObjectIDFromHex(tournamentID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidID, err)
+	}
+
+	filter := bson.M{"tournament_id": dbTournamentID}
+	if sellerID != "" {
+		dbSellerID, err := primitive.ObjectIDFromHex(sellerID)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidID, err)
+		}
+		filter["seller_id"] = dbSellerID
+	}
+	if len(statuses) > 0 {
+		filter["status"] = bson.M{"$in": statuses}
+	}
+
+	// Find listings
+	cursor, err := MongoDatabaseClient.
+		Database(DB_MAIN).
+		Collection(COLLECTION_AUCTION_LISTINGS).
+		Find(ctx,
+			filter,
+			options.Find().SetSort(bson.D{{Key: "ends_at", Value: 1}, {Key: "_id", Value: 1}}),
+		)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInternal, err)
+	}
+
+	// Decode listings
+	listings := []domain.AuctionListing{}
+	err = cursor.All(ctx, &listings)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInternal, err)
+	}
+	return listings, nil
+}
+
+// PlaceAuctionBid escrows the bidder's coins on an auction and gives the previous highest bidder theirs back
+func PlaceAuctionBid(listingID, bidderID string, amount int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*20)
+	defer cancel()
+	dbListingID, err := primitive.ObjectIDFromHex(listingID)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidID, err)
+	}
+	dbBidderID, err := primitive.ObjectIDFromHex(bidderID)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidID, err)
+	}
+
+	// Begin transaction
+	session, err := MongoDatabaseClient.
+		StartSession()
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInternal, err)
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(ctx mongo.SessionContext) (interface{}, error) {
+		listing, err := findAuctionListing(ctx, dbListingID)
+		if err != nil {
+			return nil, err
+		}
+		if listing.Status != domain.ListingStatusActive || listing.Type != domain.ListingTypeAuction || listing.EndsAt.Time().Before(time.Now()) {
+			return nil, fmt.Errorf("%w: listing is not open for bids", ErrInvalidState)
+		}
+		if amount < domain.MinimumBid(*listing) {
+			return nil, fmt.Errorf("%w: bid must be at least %d", ErrInvalidState, domain.MinimumBid(*listing))
+		}
+
+		bidder, err := findTournamentPlayer(ctx, dbBidderID)
+		if err != nil {
+			return nil, err
+		}
+
+		// Escrow the new bid and release the previous one
+		err = takeCoins(ctx, listing.TournamentID, bidder.ID, amount, domain.LedgerCause{
+			ActorID:   bidder.UserID,
+			Reason:    domain.LedgerReasonAuctionBid,
+			RelatedID: listing.ID,
+		})
+		if err != nil {
+			return nil, err
+		}
+		if listing.HighestBidderID != primitive.NilObjectID {
+			err = giveCoins(ctx, listing.TournamentID, listing.HighestBidderID, listing.HighestBid, domain.LedgerCause{
+				ActorID:   bidder.UserID,
+				Reason:    domain.LedgerReasonAuctionRefund,
+				RelatedID: listing.ID,
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		_, err = MongoDatabaseClient.
+			Database(DB_MAIN).
+			Collection(COLLECTION_AUCTION_LISTINGS).
+			UpdateByID(ctx, listing.ID, bson.M{
+				"$set": bson.M{
+					"highest_bid":       amount,
+					"highest_bidder_id": dbBidderID,
+					"updated_at":        primitive.NewDateTimeFromTime(time.Now()),
+				},
+				"$push": bson.M{"bids": domain.AuctionBid{
+					BidderID:  dbBidderID,
+					Amount:    amount,
+					CreatedAt: primitive.NewDateTimeFromTime(time.Now()),
+				}},
+			})
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInternal, err)
+		}
+		return nil, nil
+	})
+	return err
+}
+
+// BuyAuctionListing buys a fixed price listing, paying the seller and adding the card to the buyer's collection
+func BuyAuctionListing(listingID, buyerID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*20)
+	defer cancel()
+	dbListingID, err := primitive.ObjectIDFromHex(listingID)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidID, err)
+	}
+	dbBuyerID, err := primitive.ObjectIDFromHex(buyerID)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidID, err)
+	}
+
+	// Begin transaction
+	session, err := MongoDatabaseClient.
+		StartSession()
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInternal, err)
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(ctx mongo.SessionContext) (interface{}, error) {
+		listing, err := findAuctionListing(ctx, dbListingID)
+		if err != nil {
+			return nil, err
+		}
+		if listing.Status != domain.ListingStatusActive || listing.Type != domain.ListingTypeFixedPrice || listing.EndsAt.Time().Before(time.Now()) {
+			return nil, fmt.Errorf("%w: listing can't be bought", ErrInvalidState)
+		}
+		buyer, err := findTournamentPlayer(ctx, dbBuyerID)
+		if err != nil {
+			return nil, err
+		}
+
+		err = takeCoins(ctx, listing.TournamentID, buyer.ID, listing.Price, domain.LedgerCause{
+			ActorID:   buyer.UserID,
+			Reason:    domain.LedgerReasonAuctionPurchase,
+			RelatedID: listing.ID,
+		})
+		if err != nil {
+			return nil, err
+		}
+		err = giveCoins(ctx, listing.TournamentID, listing.SellerID, listing.Price, domain.LedgerCause{
+			ActorID:   buyer.UserID,
+			Reason:    domain.LedgerReasonAuctionSale,
+			RelatedID: listing.ID,
+		})
+		if err != nil {
+			return nil, err
+		}
+		err = addOwnedCard(ctx, listing.CardData, listing.Count, buyer, domain.LedgerCause{
+			ActorID:   buyer.UserID,
+			Reason:    domain.LedgerReasonAuctionPurchase,
+			RelatedID: listing.ID,
+		})
+		if err != nil {
+			return nil, err
+		}
+		return nil, closeAuctionListing(ctx, listing.ID, domain.ListingStatusSold, buyer.ID)
+	})
+	return err
+}
+
+// CancelAuctionListing ends a listing nobody bid on, giving the card back to the seller
+func CancelAuctionListing(listingID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*20)
+	defer cancel()
+	dbListingID, err := primitive.ObjectIDFromHex(listingID)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidID, err)
+	}
+
+	// Begin transaction
+	session, err := MongoDatabaseClient.
+		StartSession()
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInternal, err)
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(ctx mongo.SessionContext) (interface{}, error) {
+		listing, err := findAuctionListing(ctx, dbListingID)
+		if err != nil {
+			return nil, err
+		}
+		if listing.Status != domain.ListingStatusActive || len(listing.Bids) > 0 {
+			return nil, fmt.Errorf("%w: listing can't be cancelled", ErrInvalidState)
+		}
+		err = returnListedCard(ctx, listing)
+		if err != nil {
+			return nil, err
+		}
+		return nil, closeAuctionListing(ctx, listing.ID, domain.ListingStatusCancelled, primitive.NilObjectID)
+	})
+	return err
+}
+
+// SettleExpiredAuctionListings ends every active listing past its end date. Auctions with bids are sold to the
+// highest bidder, whose escrowed coins go to the seller; the rest of the cards go back to their sellers.
+func SettleExpiredAuctionListings() (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*20)
+	defer cancel()
+
+	// Find expired listings
+	cursor, err := MongoDatabaseClient.
+		Database(DB_MAIN).
+		Collection(COLLECTION_AUCTION_LISTINGS).
+		Find(ctx, bson.M{
+			"status":  domain.ListingStatusActive,
+			"ends_at": bson.M{"$lte": primitive.NewDateTimeFromTime(time.Now())},
+		})
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrInternal, err)
+	}
+	var listings []domain.AuctionListing
+	err = cursor.All(ctx, &listings)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrInternal, err)
+	}
+
+	settled := 0
+	for _, listing := range listings {
+		err := settleAuctionListing(listing.ID)
+		if err != nil {
+			log.Error().Err(err).Str("listing_id", listing.ID.Hex()).Msg("failed to settle auction listing")
+			continue
+		}
+		settled++
+	}
+	return settled, nil
+}
+
+func settleAuctionListing(listingID primitive.ObjectID) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*20)
+	defer cancel()
+
+	// Begin transaction
+	session, err := MongoDatabaseClient.
+		StartSession()
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInternal, err)
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(ctx mongo.SessionContext) (interface{}, error) {
+		// Read it again, it could have been settled since it was found
+		listing, err := findAuctionListing(ctx, listingID)
+		if err != nil {
+			return nil, err
+		}
+		if listing.Status != domain.ListingStatusActive {
+			return nil, nil
+		}
+
+		if listing.HighestBidderID == primitive.NilObjectID {
+			err = returnListedCard(ctx, listing)
+			if err != nil {
+				return nil, err
+			}
+			return nil, closeAuctionListing(ctx, listing.ID, domain.ListingStatusExpired, primitive.NilObjectID)
+		}
+
+		buyer, err := findTournamentPlayer(ctx, listing.HighestBidderID)
+		if err != nil {
+			return nil, err
+		}
+		err = giveCoins(ctx, listing.TournamentID, listing.SellerID, listing.HighestBid, domain.LedgerCause{
+			Reason:    domain.LedgerReasonAuctionSale,
+			RelatedID: listing.ID,
+		})
+		if err != nil {
+			return nil, err
+		}
+		err = addOwnedCard(ctx, listing.CardData, listing.Count, buyer, domain.LedgerCause{
+			Reason:    domain.LedgerReasonAuctionPurchase,
+			RelatedID: listing.ID,
+		})
+		if err != nil {
+			return nil, err
+		}
+		return nil, closeAuctionListing(ctx, listing.ID, domain.ListingStatusSold, buyer.ID)
+	})
+	return err
+}
+
+// returnListedCard gives the listed copies back to the seller, recorded as a return rather than an acquisition
+func returnListedCard(ctx context.Context, listing *domain.AuctionListing) error {
+	seller, err := findTournamentPlayer(ctx, listing.SellerID)
+	if err != nil {
+		return err
+	}
+
+	// The copies were already the seller's, so they go back as they were, without duplicate protection
+	now := primitive.NewDateTimeFromTime(time.Now())
+	filter := cardVariantFilter(listing.CardData)
+	filter["tournament_id"] = seller.TournamentID
+	filter["user_id"] = seller.UserID
+	_, err = MongoDatabaseClient.
+		Database(DB_MAIN).
+		Collection(COLLECTION_CARD_COLLECTION).
+		UpdateOne(ctx, filter, bson.M{
+			"$inc": bson.M{"count": listing.Count},
+			"$set": bson.M{"updated_at": now},
+			"$setOnInsert": bson.M{
+				"_id":           primitive.NewObjectID(),
+				"tournament_id": seller.TournamentID,
+				"user_id":       seller.UserID,
+				"tags":          []string{},
+				"card_data":     listing.CardData,
+				"created_at":    now,
+			},
+		}, options.Update().SetUpsert(true))
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInternal, err)
+	}
+
+	return recordCardMovements(ctx, domain.LedgerCause{
+		Reason:    domain.LedgerReasonAuctionReturn,
+		RelatedID: listing.ID,
+	}, seller.TournamentID, seller.ID, []domain.CardMovement{{CardData: listing.CardData, Count: listing.Count}})
+}
+
+func closeAuctionListing(ctx context.Context, listingID primitive.ObjectID, status domain.ListingStatus, buyerID primitive.ObjectID) error {
+	result, err := MongoDatabaseClient.
+		Database(DB_MAIN).
+		Collection(COLLECTION_AUCTION_LISTINGS).
+		UpdateOne(ctx,
+			bson.M{"_id": listingID, "status": domain.ListingStatusActive},
+			bson.M{"$set": bson.M{
+				"status":     status,
+				"buyer_id":   buyerID,
+				"updated_at": primitive.NewDateTimeFromTime(time.Now()),
+			}},
+		)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInternal, err)
+	}
+	if result.MatchedCount == 0 {
+		return fmt.Errorf("%w: listing is not active", ErrInvalidState)
+	}
+	return nil
+}
+
+// takeCoins removes coins from a tournament player, failing if they don't have enough
+func takeCoins(ctx context.Context, tournamentID, tournamentPlayerID primitive.ObjectID, coins int, cause domain.LedgerCause) error {
+	result, err := MongoDatabaseClient.
+		Database(DB_MAIN).
+		Collection(COLLECTION_TOURNAMENT_PLAYERS).
+		UpdateOne(ctx,
+			bson.M{"_id": tournamentPlayerID, "game_resources.coins": bson.M{"$gte": coins}},
+			bson.M{
+				"$inc": bson.M{"game_resources.coins": -coins},
+				"$set": bson.M{"updated_at": primitive.NewDateTimeFromTime(time.Now())},
+			},
+		)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInternal, err)
+	}
+	if result.MatchedCount == 0 {
+		return fmt.Errorf("%w: not enough coins", ErrNotEnough)
+	}
+	return recordLedgerChanges(ctx, cause, tournamentID, tournamentPlayerID, []domain.LedgerBalance{
+		{Resource: domain.LedgerResourceCoins, Amount: -coins},
+	})
+}
+
+func giveCoins(ctx context.Context, tournamentID, tournamentPlayerID primitive.ObjectID, coins int, cause domain.LedgerCause) error {
+	result, err := MongoDatabaseClient.
+		Database(DB_MAIN).
+		Collection(COLLECTION_TOURNAMENT_PLAYERS).
+		UpdateByID(ctx, tournamentPlayerID, bson.M{
+			"$inc": bson.M{"game_resources.coins": coins},
+			"$set": bson.M{"updated_at": primitive.NewDateTimeFromTime(time.Now())},
+		})
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInternal, err)
+	}
+	if result.MatchedCount == 0 {
+		return fmt.Errorf("%w: tournament player %s", ErrNotFound, tournamentPlayerID.Hex())
+	}
+	return recordLedgerChanges(ctx, cause, tournamentID, tournamentPlayerID, []domain.LedgerBalance{
+		{Resource: domain.LedgerResourceCoins, Amount: coins},
+	})
+}
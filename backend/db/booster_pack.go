@@ -9,6 +9,7 @@ import (
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 func GetPackBySetCode(setCode string) (*domain.BoosterPack, error) {
@@ -121,12 +122,74 @@ func UpdateBoosterPack(boosterPack domain.BoosterPack) error {
 		if err != nil {
 			return nil, fmt.Errorf("%w: %v", ErrInternal, err)
 		}
+
+		// The odds have to be measured again on every tournament
+		_, err = MongoDatabaseClient.
+			Database(DB_MAIN).
+			Collection(COLLECTION_BOOSTER_ODDS).
+			DeleteMany(ctx, bson.M{"set_code": boosterPack.SetCode})
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInternal, err)
+		}
 		return resultInsert, nil
 	})
 
 	return err
 }
 
+// PublishBoosterOdds stores the pull rates of a set's packs for the tournament's players to see, replacing the ones
+// published before
+func PublishBoosterOdds(tournamentID primitive.ObjectID, setCode string, odds domain.BoosterOdds) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*20)
+	defer cancel()
+
+	_, err := MongoDatabaseClient.
+		Database(DB_MAIN).
+		Collection(COLLECTION_BOOSTER_ODDS).
+		UpdateOne(ctx,
+			bson.M{"tournament_id": tournamentID, "set_code": setCode},
+			bson.M{
+				"$set":         bson.M{"odds": odds},
+				"$setOnInsert": bson.M{"_id": primitive.NewObjectID()},
+			},
+			options.Update().SetUpsert(true),
+		)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInternal, err)
+	}
+	return nil
+}
+
+// GetPublishedBoosterOdds returns the pull rates of a set's packs published on a tournament
+func GetPublishedBoosterOdds(tournamentID, setCode string) (*domain.PublishedBoosterOdds, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*20)
+	defer cancel()
+	dbTournamentID, err := primitive.ObjectIDFromHex(tournamentID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidID, err)
+	}
+
+	// Find odds
+	result := MongoDatabaseClient.
+		Database(DB_MAIN).
+		Collection(COLLECTION_BOOSTER_ODDS).
+		FindOne(ctx, bson.M{"tournament_id": dbTournamentID, "set_code": setCode})
+	if err := result.Err(); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, fmt.Errorf("%w: %v", ErrNotFound, err)
+		}
+		return nil, fmt.Errorf("%w: %v", ErrInternal, err)
+	}
+
+	// Decode odds
+	var odds *domain.PublishedBoosterOdds
+	err = result.Decode(&odds)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInternal, err)
+	}
+	return odds, nil
+}
+
 func GetAllBoosterPacks() ([]domain.BoosterPack, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second*20)
 	defer cancel()
@@ -270,38 +333,121 @@ func BuyBoosterPack(tournamentID, userID, boosterPackID string) error {
 		if !found {
 			return nil, ErrNotFound
 		}
-		if tournamentPlayer.GameResources.Coins < foundStoreBoosterPack.CoinPrice {
-			return nil, ErrInternal
+		cause := domain.LedgerCause{
+			ActorID:   dbUserID,
+			Reason:    domain.LedgerReasonBoosterPurchase,
+			RelatedID: dbBoosterPackID,
 		}
-		tournamentPlayer.GameResources.Coins -= foundStoreBoosterPack.CoinPrice
-
-		// Packs the user already has
-		seenPacks := make(map[string]int, len(tournamentPlayer.GameResources.BoosterPacks))
-		for index, pack := range tournamentPlayer.GameResources.BoosterPacks {
-			seenPacks[pack.SetCode] = index
+		coinPrice := domain.StoreCoinPrice(foundStoreBoosterPack, tournament.CoinValues)
+		err = takeCoins(ctx, tournamentPlayer.TournamentID, tournamentPlayer.ID, coinPrice, cause)
+		if err != nil {
+			return nil, err
 		}
-		if index, ok := seenPacks[boosterPack.SetCode]; ok {
-			tournamentPlayer.GameResources.BoosterPacks[index].Available += 1
-		} else {
-			tournamentPlayer.GameResources.BoosterPacks = append(tournamentPlayer.GameResources.BoosterPacks, domain.OwnedBoosterPack{
-				Available:   1,
-				SetCode:     boosterPack.SetCode,
-				Name:        boosterPack.Name,
-				Description: boosterPack.Description,
-			})
+
+		// Add the pack to the ones the user already has
+		return nil, addPacksToTournamentPlayer(ctx, tournamentPlayer.ID, domain.OwnedBoosterPack{
+			Available:   1,
+			SetCode:     boosterPack.SetCode,
+			Name:        boosterPack.Name,
+			Description: boosterPack.Description,
+		}, cause)
+	})
+
+	return err
+}
+
+func GetBoosterOpeningByID(openingID string) (*domain.BoosterOpening, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*20)
+	defer cancel()
+	dbOpeningID, err := primitive.ObjectIDFromHex(openingID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidID, err)
+	}
+
+	// Find opening
+	result := MongoDatabaseClient.
+		Database(DB_MAIN).
+		Collection(COLLECTION_BOOSTER_OPENINGS).
+		FindOne(ctx, bson.M{"_id": dbOpeningID})
+	if err := result.Err(); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, fmt.Errorf("%w: %v", ErrNotFound, err)
 		}
-		// Update the tournament player
-		updateResult, err := MongoDatabaseClient.
-			Database(DB_MAIN).
-			Collection(COLLECTION_TOURNAMENT_PLAYERS).
-			UpdateByID(ctx, tournamentPlayer.ID, bson.M{"$set": tournamentPlayer})
+		return nil, fmt.Errorf("%w: %v", ErrInternal, err)
+	}
 
-		if err != nil || updateResult.MatchedCount == 0 {
-			return nil, fmt.Errorf("%w: %v", ErrInternal, err)
+	// Decode opening
+	var opening *domain.BoosterOpening
+	err = result.Decode(&opening)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInternal, err)
+	}
+	return opening, nil
+}
+
+// GetBoosterOpeningByIdempotencyKey returns the player's opening made with the given idempotency key
+func GetBoosterOpeningByIdempotencyKey(tournamentID, userID, idempotencyKey string) (*domain.BoosterOpening, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*20)
+	defer cancel()
+
+	tournamentPlayer, err := GetTournamentPlayer(tournamentID, userID)
+	if err != nil {
+		return nil, err
+	}
+	return findBoosterOpeningByIdempotencyKey(ctx, tournamentPlayer.ID, idempotencyKey)
+}
+
+func findBoosterOpeningByIdempotencyKey(ctx context.Context, tournamentPlayerID primitive.ObjectID, idempotencyKey string) (*domain.BoosterOpening, error) {
+	result := MongoDatabaseClient.
+		Database(DB_MAIN).
+		Collection(COLLECTION_BOOSTER_OPENINGS).
+		FindOne(ctx, bson.M{"tournament_player_id": tournamentPlayerID, "idempotency_key": idempotencyKey})
+	if err := result.Err(); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, fmt.Errorf("%w: %v", ErrNotFound, err)
 		}
+		return nil, fmt.Errorf("%w: %v", ErrInternal, err)
+	}
 
-		return nil, nil
-	})
+	// Decode opening
+	var opening *domain.BoosterOpening
+	err := result.Decode(&opening)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInternal, err)
+	}
+	return opening, nil
+}
 
-	return err
+// GetBoosterOpeningBatch returns the openings of the opening's bulk open, in the order they were drawn. An opening
+// that isn't part of one is returned alone.
+func GetBoosterOpeningBatch(opening *domain.BoosterOpening) ([]domain.BoosterOpening, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*20)
+	defer cancel()
+
+	return findBoosterOpeningBatch(ctx, opening)
+}
+
+func findBoosterOpeningBatch(ctx context.Context, opening *domain.BoosterOpening) ([]domain.BoosterOpening, error) {
+	if opening.BatchID == primitive.NilObjectID {
+		return []domain.BoosterOpening{*opening}, nil
+	}
+
+	cursor, err := MongoDatabaseClient.
+		Database(DB_MAIN).
+		Collection(COLLECTION_BOOSTER_OPENINGS).
+		Find(ctx,
+			bson.M{"tournament_player_id": opening.TournamentPlayerID, "batch_id": opening.BatchID},
+			options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}),
+		)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInternal, err)
+	}
+
+	// Decode openings
+	var openings []domain.BoosterOpening
+	err = cursor.All(ctx, &openings)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInternal, err)
+	}
+	return openings, nil
 }
@@ -0,0 +1,171 @@
+package db
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/joaquinleonarg/wdml-mtg/backend/domain"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// recordCardMovements stores the movements of a player's cards. Only the card data and count of each movement
+// have to be set.
+func recordCardMovements(ctx context.Context, cause domain.LedgerCause, tournamentID, tournamentPlayerID primitive.ObjectID, movements []domain.CardMovement) error {
+	documents := make([]interface{}, 0, len(movements))
+	for _, movement := range movements {
+		if movement.Count == 0 {
+			continue
+		}
+		movement.ID = primitive.NewObjectID()
+		movement.TournamentID = tournamentID
+		movement.TournamentPlayerID = tournamentPlayerID
+		movement.LedgerCause = cause
+		movement.CreatedAt = primitive.NewDateTimeFromTime(time.Now())
+		documents = append(documents, movement)
+	}
+	if len(documents) == 0 {
+		return nil
+	}
+
+	_, err := MongoDatabaseClient.
+		Database(DB_MAIN).
+		Collection(COLLECTION_CARD_MOVEMENTS).
+		InsertMany(ctx, documents)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInternal, err)
+	}
+	return nil
+}
+
+// GetCardProvenance returns every movement of a card's printing and variant on a player's collection, oldest first.
+// Copies that came from a trade or an auction also bring the movements of the other player on that same trade or
+// auction.
+func GetCardProvenance(tournamentPlayerID string, card domain.CardData) ([]domain.CardMovement, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*20)
+	defer cancel()
+	dbTournamentPlayerID, err := primitive.ObjectIDFromHex(tournamentPlayerID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidID, err)
+	}
+
+	movements, err := findCardMovements(ctx, bson.M{"$and": bson.A{
+		bson.M{"tournament_player_id": dbTournamentPlayerID},
+		cardVariantFilter(card),
+	}})
+	if err != nil {
+		return nil, err
+	}
+
+	// Movements of the other side of trades and auctions
+	relatedIDs := []primitive.ObjectID{}
+	for _, movement := range movements {
+		switch movement.Reason {
+		case domain.LedgerReasonTrade, domain.LedgerReasonAuctionPurchase:
+			relatedIDs = append(relatedIDs, movement.RelatedID)
+		}
+	}
+	if len(relatedIDs) == 0 {
+		return movements, nil
+	}
+	counterparts, err := findCardMovements(ctx, bson.M{"$and": bson.A{
+		bson.M{
+			"tournament_player_id": bson.M{"$ne": dbTournamentPlayerID},
+			"related_id":           bson.M{"$in": relatedIDs},
+		},
+		cardVariantFilter(card),
+	}})
+	if err != nil {
+		return nil, err
+	}
+
+	movements = append(movements, counterparts...)
+	slices.SortFunc(movements, func(a, b domain.CardMovement) int {
+		if a.CreatedAt != b.CreatedAt {
+			return cmp.Compare(a.CreatedAt, b.CreatedAt)
+		}
+		return a.ID.Timestamp().Compare(b.ID.Timestamp())
+	})
+	return movements, nil
+}
+
+// GetCardMovements returns a page of the card movements of a tournament, newest first. It can be narrowed to a
+// player, and to only the cards they acquired.
+func GetCardMovements(tournamentID, tournamentPlayerID string, acquisitionsOnly bool, count, page int) ([]domain.CardMovement, int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*20)
+	defer cancel()
+	dbTournamentID, err := primitive.ObjectIDFromHex(tournamentID)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%w: %v", ErrInvalidID, err)
+	}
+
+	filter := bson.M{"tournament_id": dbTournamentID}
+	if tournamentPlayerID != "" {
+		dbTournamentPlayerID, err := primitive.ObjectIDFromHex(tournamentPlayerID)
+		if err != nil {
+			return nil, 0, fmt.Errorf("%w: %v", ErrInvalidID, err)
+		}
+		filter["tournament_player_id"] = dbTournamentPlayerID
+	}
+	if acquisitionsOnly {
+		// Listed copies that come back to the seller aren't acquired
+		filter["count"] = bson.M{"$gt": 0}
+		filter["reason"] = bson.M{"$ne": domain.LedgerReasonAuctionReturn}
+	}
+
+	// Find movements
+	cursor, err := MongoDatabaseClient.
+		Database(DB_MAIN).
+		Collection(COLLECTION_CARD_MOVEMENTS).
+		Find(ctx,
+			filter,
+			options.Find().
+				SetSort(bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}).
+				SetSkip(int64(count*(page-1))).
+				SetLimit(int64(count)),
+		)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%w: %v", ErrInternal, err)
+	}
+
+	// Decode movements
+	movements := []domain.CardMovement{}
+	err = cursor.All(ctx, &movements)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%w: %v", ErrInternal, err)
+	}
+
+	total, err := MongoDatabaseClient.
+		Database(DB_MAIN).
+		Collection(COLLECTION_CARD_MOVEMENTS).
+		CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%w: %v", ErrInternal, err)
+	}
+	return movements, int(total), nil
+}
+
+func findCardMovements(ctx context.Context, filter bson.M) ([]domain.CardMovement, error) {
+	cursor, err := MongoDatabaseClient.
+		Database(DB_MAIN).
+		Collection(COLLECTION_CARD_MOVEMENTS).
+		Find(ctx,
+			filter,
+			options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}, {Key: "_id", Value: 1}}),
+		)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInternal, err)
+	}
+
+	// Decode movements
+	movements := []domain.CardMovement{}
+	err = cursor.All(ctx, &movements)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInternal, err)
+	}
+	return movements, nil
+}
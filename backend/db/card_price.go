@@ -0,0 +1,85 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/joaquinleonarg/wdml-mtg/backend/domain"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Price updates sent to the database at once
+const CARD_PRICE_BATCH_SIZE = 500
+
+// UpdateCardPrices sets the price snapshot of every owned card whose printing has a price, on every tournament.
+// Prices are keyed by domain.CardPrintingKey. The amount of owned cards updated is returned.
+func UpdateCardPrices(prices map[string]domain.CardPrice) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute*10)
+	defer cancel()
+
+	// Find the printings that are owned
+	cursor, err := MongoDatabaseClient.
+		Database(DB_MAIN).
+		Collection(COLLECTION_CARD_COLLECTION).
+		Aggregate(ctx, bson.A{
+			bson.M{"$group": bson.M{"_id": bson.M{
+				"set_code":         "$card_data.set_code",
+				"collector_number": "$card_data.collector_number",
+			}}},
+		})
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrInternal, err)
+	}
+	var printings []struct {
+		ID struct {
+			SetCode         string `bson:"set_code"`
+			CollectorNumber string `bson:"collector_number"`
+		} `bson:"_id"`
+	}
+	err = cursor.All(ctx, &printings)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrInternal, err)
+	}
+
+	updated := 0
+	models := make([]mongo.WriteModel, 0, CARD_PRICE_BATCH_SIZE)
+	flush := func() error {
+		if len(models) == 0 {
+			return nil
+		}
+		result, err := MongoDatabaseClient.
+			Database(DB_MAIN).
+			Collection(COLLECTION_CARD_COLLECTION).
+			BulkWrite(ctx, models)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInternal, err)
+		}
+		updated += int(result.ModifiedCount)
+		models = models[:0]
+		return nil
+	}
+	for _, printing := range printings {
+		price, ok := prices[domain.CardPrintingKey(printing.ID.SetCode, printing.ID.CollectorNumber)]
+		if !ok {
+			continue
+		}
+		models = append(models, mongo.NewUpdateManyModel().
+			SetFilter(bson.M{
+				"card_data.set_code":         printing.ID.SetCode,
+				"card_data.collector_number": printing.ID.CollectorNumber,
+			}).
+			SetUpdate(bson.M{"$set": bson.M{"card_data.price": price}}),
+		)
+		if len(models) == CARD_PRICE_BATCH_SIZE {
+			if err := flush(); err != nil {
+				return 0, err
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		return 0, err
+	}
+	return updated, nil
+}
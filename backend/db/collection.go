@@ -3,46 +3,23 @@ package db
 import (
 	"context"
 	"fmt"
-	"strconv"
+	"regexp"
 	"strings"
 	"time"
 
 	"github.com/joaquinleonarg/wdml-mtg/backend/domain"
+	deckvalidation "github.com/joaquinleonarg/wdml-mtg/backend/internal/deck_validation"
 	"github.com/rs/zerolog/log"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 )
 
-type CardFilter struct {
-	Type      CardFilterType
-	Operation CardFilterOperation
-	Value     string
-}
-
-type CardFilterOperation string
-
-const (
-	CardFilterOperationEq CardFilterOperation = "="
-	CardFilterOperationLt CardFilterOperation = "<"
-	CardFilterOperationGt CardFilterOperation = ">"
-)
-
-type CardFilterType string
-
-const (
-	CardFilterTypeName    CardFilterType = "name"
-	CardFilterTypeTags    CardFilterType = "tags"
-	CardFilterTypeRarity  CardFilterType = "rarity"
-	CardFilterTypeColor   CardFilterType = "color"
-	CardFilterTypeTypes   CardFilterType = "types"
-	CardFilterTypeOracle  CardFilterType = "oracle"
-	CardFilterTypeSetCode CardFilterType = "setcode"
-	CardFilterTypeMV      CardFilterType = "mv"
-)
-
-func GetCardsFromTournamentPlayer(userID, tournamentID string, filters []CardFilter, count, page int) ([]domain.OwnedCard, int, error) {
-	log.Debug().Interface("filters", filters).Int("count", count).Int("page", page).Send()
+// GetCardsFromTournamentPlayer returns a page of the player's cards that match the filter, and the total amount
+// of cards that match it. Besides the card fields, the sort can use "rarity_order", the position of the card's
+// rarity from the most common to the rarest.
+func GetCardsFromTournamentPlayer(userID, tournamentID string, cardFilter bson.M, sort bson.D, count, page int) ([]domain.OwnedCard, int, error) {
+	log.Debug().Interface("filter", cardFilter).Interface("sort", sort).Int("count", count).Int("page", page).Send()
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second*20)
 	defer cancel()
 
@@ -54,87 +31,11 @@ func GetCardsFromTournamentPlayer(userID, tournamentID string, filters []CardFil
 	if err != nil {
 		return nil, 0, fmt.Errorf("%w: %v", ErrInvalidID, err)
 	}
-
-	dbFilters := bson.M{}
-
-	for _, filter := range filters {
-		switch filter.Type {
-		// By name
-		case CardFilterTypeName:
-			switch filter.Operation {
-			case CardFilterOperationEq:
-				dbFilters["card_data.name"] = bson.M{"$regex": filter.Value, "$options": "i"}
-			}
-
-		// By tags
-		case CardFilterTypeTags:
-			yesTags := []string{}
-			noTags := []string{}
-			for _, v := range strings.Split(filter.Value, " ") {
-				if strings.HasPrefix(v, "-") {
-					noTags = append(noTags, strings.TrimPrefix(v, "-"))
-				} else {
-					yesTags = append(yesTags, v)
-				}
-			}
-			switch filter.Operation {
-			case CardFilterOperationEq:
-				dbFilters["card_data.tags"] = bson.M{"$all": yesTags, "$nin": noTags}
-			}
-
-		// By rarity
-		case CardFilterTypeRarity:
-			switch filter.Operation {
-			case CardFilterOperationEq:
-				dbFilters["card_data.rarity"] = filter.Value
-			}
-
-		// By color
-		case CardFilterTypeColor:
-			switch filter.Operation {
-			case CardFilterOperationLt:
-				dbFilters["card_data.colors"] = filter.Value
-			case CardFilterOperationEq:
-				if filter.Value == "C" {
-					dbFilters["card_data.colors"] = bson.M{"$size": 0}
-				} else {
-					dbFilters["card_data.colors"] = bson.M{"$all": strings.Split(filter.Value, "")}
-				}
-			}
-
-		// By types
-		case CardFilterTypeTypes:
-			switch filter.Operation {
-			case CardFilterOperationEq:
-				dbFilters["card_data.types"] = bson.M{"$all": strings.Split(filter.Value, " ")}
-			}
-
-		// By oracle
-		case CardFilterTypeOracle:
-			switch filter.Operation {
-			case CardFilterOperationEq:
-				dbFilters["card_data.oracle"] = bson.M{"$regex": filter.Value, "$options": "i"}
-			}
-
-		// By set code
-		case CardFilterTypeSetCode:
-			switch filter.Operation {
-			case CardFilterOperationEq:
-				dbFilters["card_data.set_code"] = filter.Value
-			}
-
-		// By mv
-		case CardFilterTypeMV:
-			mv, err := strconv.Atoi(filter.Value)
-			if err != nil {
-				continue
-			}
-			switch filter.Operation {
-			case CardFilterOperationEq:
-				dbFilters["card_data.mana_value"] = mv
-			}
-
-		}
+	if cardFilter == nil {
+		cardFilter = bson.M{}
+	}
+	if len(sort) == 0 {
+		sort = bson.D{{Key: "_id", Value: 1}}
 	}
 
 	// Begin transaction
@@ -149,19 +50,22 @@ func GetCardsFromTournamentPlayer(userID, tournamentID string, filters []CardFil
 	res, err := session.WithTransaction(ctx, func(ctx mongo.SessionContext) (interface{}, error) {
 		// Find tournament user
 		filter := bson.M{
-			"tournament_id": dbTournamentID,
-			"user_id":       dbUserID,
-		}
-		for filterKey, filterValue := range dbFilters {
-			filter[filterKey] = filterValue
+			"$and": bson.A{
+				bson.M{
+					"tournament_id": dbTournamentID,
+					"user_id":       dbUserID,
+				},
+				cardFilter,
+			},
 		}
-		log.Debug().Interface("filter", filter).Send()
 		cursor, err := MongoDatabaseClient.
 			Database(DB_MAIN).
 			Collection(COLLECTION_CARD_COLLECTION).
 			Aggregate(ctx,
 				bson.A{
 					bson.M{"$match": filter},
+					bson.M{"$addFields": bson.M{"rarity_order": bson.M{"$indexOfArray": bson.A{domain.CardRarities, "$card_data.rarity"}}}},
+					bson.M{"$sort": sort},
 					bson.M{"$skip": count * (page - 1)},
 					bson.M{"$limit": count},
 				},
@@ -224,11 +128,126 @@ func GetOwnedCardById(cardId string) (domain.OwnedCard, error) {
 	return card, nil
 }
 
+func GetOwnedCardsByIDs(ownedCardIDs []primitive.ObjectID) ([]domain.OwnedCard, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*20)
+	defer cancel()
+
+	// Find cards
+	cursor, err := MongoDatabaseClient.
+		Database(DB_MAIN).
+		Collection(COLLECTION_CARD_COLLECTION).
+		Find(ctx, bson.M{
+			"_id": bson.M{"$in": ownedCardIDs},
+		})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInternal, err)
+	}
+
+	// Decode cards
+	var cards []domain.OwnedCard
+	err = cursor.All(ctx, &cards)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInternal, err)
+	}
+	return cards, nil
+}
+
+// GetOwnedCardsForTournament returns every card owned on a tournament, or only the ones owned by a player if
+// a user ID is given
+func GetOwnedCardsForTournament(tournamentID, userID string) ([]domain.OwnedCard, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*20)
+	defer cancel()
+
+	dbTournamentID, err := primitive.ObjectIDFromHex(tournamentID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidID, err)
+	}
+	filter := bson.M{"tournament_id": dbTournamentID}
+	if userID != "" {
+		dbUserID, err := primitive.ObjectIDFromHex(userID)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidID, err)
+		}
+		filter["user_id"] = dbUserID
+	}
+
+	// Find cards
+	cursor, err := MongoDatabaseClient.
+		Database(DB_MAIN).
+		Collection(COLLECTION_CARD_COLLECTION).
+		Find(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInternal, err)
+	}
+
+	// Decode cards
+	cards := []domain.OwnedCard{}
+	err = cursor.All(ctx, &cards)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInternal, err)
+	}
+	return cards, nil
+}
+
+// GetOwnedCardsByNames finds all the cards owned by a tournament player with any of the given names, or by every
+// player of the tournament if no user ID is given. Names are matched case insensitively, and a name also matches
+// the front face of double faced and split cards.
+func GetOwnedCardsByNames(tournamentID, userID string, names []string) ([]domain.OwnedCard, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*20)
+	defer cancel()
+
+	dbTournamentID, err := primitive.ObjectIDFromHex(tournamentID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidID, err)
+	}
+	if len(names) == 0 {
+		return []domain.OwnedCard{}, nil
+	}
+
+	quotedNames := make([]string, 0, len(names))
+	for _, name := range names {
+		quotedNames = append(quotedNames, regexp.QuoteMeta(name))
+	}
+	filter := bson.M{
+		"tournament_id":  dbTournamentID,
+		"card_data.name": bson.M{"$regex": fmt.Sprintf("^(?:%s)(?: // .*)?$", strings.Join(quotedNames, "|")), "$options": "i"},
+	}
+	if userID != "" {
+		dbUserID, err := primitive.ObjectIDFromHex(userID)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidID, err)
+		}
+		filter["user_id"] = dbUserID
+	}
+
+	// Find cards
+	cursor, err := MongoDatabaseClient.
+		Database(DB_MAIN).
+		Collection(COLLECTION_CARD_COLLECTION).
+		Find(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInternal, err)
+	}
+
+	// Decode cards
+	var cards []domain.OwnedCard
+	err = cursor.All(ctx, &cards)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInternal, err)
+	}
+	return cards, nil
+}
+
 type CardBySetNum struct {
 	Set, Num string
 }
 
-func ImportCollection(cards []domain.OwnedCard) error {
+// ImportCollection adds the cards to the player's collection, increasing the count of the cards that are already
+// there instead of creating duplicates, and gives them the coins extra copies were converted into, in one transaction
+func ImportCollection(cards []domain.OwnedCard, tournamentID, tournamentPlayerID primitive.ObjectID, coins int, cause domain.LedgerCause) error {
+	if len(cards) == 0 && coins == 0 {
+		return nil
+	}
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second*20)
 	defer cancel()
 	session, err := MongoDatabaseClient.
@@ -239,20 +258,61 @@ func ImportCollection(cards []domain.OwnedCard) error {
 	defer session.EndSession(ctx)
 
 	_, err = session.WithTransaction(ctx, func(ctx mongo.SessionContext) (interface{}, error) {
-		newValue := make([]interface{}, len(cards))
+		if coins > 0 {
+			err := giveCoins(ctx, tournamentID, tournamentPlayerID, coins, cause)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if len(cards) == 0 {
+			return nil, nil
+		}
 
-		for i := range cards {
-			newValue[i] = cards[i]
+		now := primitive.NewDateTimeFromTime(time.Now())
+		models := make([]mongo.WriteModel, 0, len(cards))
+		for _, card := range cards {
+			if card.Tags == nil {
+				card.Tags = []string{}
+			}
+			models = append(models, mongo.NewUpdateOneModel().
+				SetFilter(bson.M{"$and": bson.A{
+					bson.M{
+						"tournament_id": card.TournamentID,
+						"user_id":       card.UserID,
+					},
+					cardVariantFilter(card.CardData),
+				}}).
+				SetUpdate(bson.M{
+					"$inc": bson.M{"count": card.Count},
+					"$set": bson.M{"updated_at": now},
+					"$setOnInsert": bson.M{
+						"_id":           card.ID,
+						"tournament_id": card.TournamentID,
+						"user_id":       card.UserID,
+						"tags":          card.Tags,
+						"card_data":     card.CardData,
+						"created_at":    now,
+					},
+				}).
+				SetUpsert(true),
+			)
 		}
 
 		result, err := MongoDatabaseClient.
 			Database(DB_MAIN).
-			Collection(COLLECTION_CARD_COLLECTION).InsertMany(ctx, newValue)
+			Collection(COLLECTION_CARD_COLLECTION).
+			BulkWrite(ctx, models)
 		if err != nil {
-			if err == mongo.ErrNoDocuments {
-				return domain.OwnedCard{}, fmt.Errorf("%w: %v", ErrNotFound, err)
-			}
-			return domain.OwnedCard{}, fmt.Errorf("%w: %v", ErrInternal, err)
+			return nil, fmt.Errorf("%w: %v", ErrInternal, err)
+		}
+
+		movements := make([]domain.CardMovement, 0, len(cards))
+		for _, card := range cards {
+			movements = append(movements, domain.CardMovement{CardData: card.CardData, Count: card.Count})
+		}
+		err = recordCardMovements(ctx, cause, tournamentID, tournamentPlayerID, movements)
+		if err != nil {
+			return nil, err
 		}
 		return result, nil
 	})
@@ -266,8 +326,38 @@ func UpdateOwnedCard(ownedCard domain.OwnedCard) error {
 
 	result, err := MongoDatabaseClient.
 		Database(DB_MAIN).
-		Collection(COLLECTION_BOOSTER_PACKS).
-		UpdateByID(ctx, ownedCard.ID, ownedCard)
+		Collection(COLLECTION_CARD_COLLECTION).
+		UpdateByID(ctx, ownedCard.ID, bson.M{
+			"$set": bson.M{
+				"count":      ownedCard.Count,
+				"tags":       ownedCard.Tags,
+				"card_data":  ownedCard.CardData,
+				"updated_at": primitive.NewDateTimeFromTime(time.Now()),
+			},
+		})
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInternal, err)
+	}
+	if result.MatchedCount == 0 {
+		return fmt.Errorf("%w", ErrNotFound)
+	}
+	return nil
+}
+
+// UpdateOwnedCardTags replaces the tags of an owned card, leaving its count and data untouched
+func UpdateOwnedCardTags(ownedCardID primitive.ObjectID, tags []string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*20)
+	defer cancel()
+
+	result, err := MongoDatabaseClient.
+		Database(DB_MAIN).
+		Collection(COLLECTION_CARD_COLLECTION).
+		UpdateByID(ctx, ownedCardID, bson.M{
+			"$set": bson.M{
+				"tags":       tags,
+				"updated_at": primitive.NewDateTimeFromTime(time.Now()),
+			},
+		})
 	if err != nil {
 		return fmt.Errorf("%w: %v", ErrInternal, err)
 	}
@@ -281,6 +371,15 @@ func TradeUpCards(cardsToRemove map[string]int, cardsToAdd []domain.CardData, to
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second*20)
 	defer cancel()
 
+	dbTournamentID, err := primitive.ObjectIDFromHex(tournamentID)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidID, err)
+	}
+	dbOwnerID, err := primitive.ObjectIDFromHex(ownerID)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidID, err)
+	}
+
 	// Begin transaction
 	session, err := MongoDatabaseClient.
 		StartSession()
@@ -290,15 +389,19 @@ func TradeUpCards(cardsToRemove map[string]int, cardsToAdd []domain.CardData, to
 	defer session.EndSession(ctx)
 
 	_, err = session.WithTransaction(ctx, func(mongoCtx mongo.SessionContext) (interface{}, error) {
-		tournamentPlayer, err := GetTournamentPlayer(tournamentID, ownerID)
+		tournamentPlayer, err := findTournamentPlayerForUser(mongoCtx, dbTournamentID, dbOwnerID)
 		if err != nil {
-			return nil, fmt.Errorf("%w: %v", ErrInvalidID, err)
+			return nil, err
+		}
+		cause := domain.LedgerCause{
+			ActorID: tournamentPlayer.UserID,
+			Reason:  domain.LedgerReasonTradeUp,
 		}
-		err = RemoveCardsFromTournamentPlayer(tournamentPlayer.ID.Hex(), cardsToRemove)
+		err = removeCardsFromTournamentPlayer(mongoCtx, tournamentPlayer, cardsToRemove, cause)
 		if err != nil {
 			return nil, err
 		}
-		err = AddCardsToTournamentPlayer(tournamentPlayer.ID.Hex(), cardsToAdd)
+		_, err = addCardsToTournamentPlayer(mongoCtx, tournamentPlayer, cardsToAdd, cause)
 		if err != nil {
 			return nil, err
 		}
@@ -309,28 +412,30 @@ func TradeUpCards(cardsToRemove map[string]int, cardsToAdd []domain.CardData, to
 	return err
 }
 
-func AddCardsToTournamentPlayer(tournamentPlayerID string, cards []domain.CardData) error {
+// AddCardsToTournamentPlayer adds the cards to the player's collection. If the tournament has duplicate protection,
+// copies beyond the limit are converted into coins instead; the amount of coins is returned.
+func AddCardsToTournamentPlayer(tournamentPlayerID string, cards []domain.CardData, cause domain.LedgerCause) (int, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
 	defer cancel()
 
 	dbTournamentPlayerID, err := primitive.ObjectIDFromHex(tournamentPlayerID)
 	if err != nil {
-		return fmt.Errorf("%w: %v", ErrInvalidID, err)
+		return 0, fmt.Errorf("%w: %v", ErrInvalidID, err)
 	}
 
 	// Begin transaction
 	session, err := MongoDatabaseClient.
 		StartSession()
 	if err != nil {
-		return fmt.Errorf("%w: %v", ErrInternal, err)
+		return 0, fmt.Errorf("%w: %v", ErrInternal, err)
 	}
 	defer session.EndSession(ctx)
 
-	_, err = session.WithTransaction(ctx, func(mongoCtx mongo.SessionContext) (interface{}, error) {
+	coins, err := session.WithTransaction(ctx, func(mongoCtx mongo.SessionContext) (interface{}, error) {
 		result := MongoDatabaseClient.
 			Database(DB_MAIN).
 			Collection(COLLECTION_TOURNAMENT_PLAYERS).
-			FindOne(ctx,
+			FindOne(mongoCtx,
 				bson.M{"_id": dbTournamentPlayerID},
 			)
 		if err := result.Err(); err != nil {
@@ -347,113 +452,176 @@ func AddCardsToTournamentPlayer(tournamentPlayerID string, cards []domain.CardDa
 			return nil, fmt.Errorf("%w: %v", ErrInternal, err)
 		}
 
-		// Add the cards to the tournament player's collection
-		// For each card, find if the user already has some of that card, and update or add it accordingly
-		cardsToAdd := []domain.OwnedCard{}
-		for _, card := range cards {
-			result, err := MongoDatabaseClient.
-				Database(DB_MAIN).
-				Collection(COLLECTION_CARD_COLLECTION).
-				Find(ctx,
-					bson.M{
-						"tournament_id":              tournamentPlayer.TournamentID,
-						"user_id":                    tournamentPlayer.UserID,
-						"card_data.set_code":         card.SetCode,
-						"card_data.collector_number": card.CollectorNumber,
-					},
-				)
-			if err != nil {
-				return nil, fmt.Errorf("%w: %v", ErrInternal, err)
-			}
-			var foundCards []domain.OwnedCard
-			if err := result.All(ctx, &foundCards); err != nil {
-				return nil, fmt.Errorf("%w: %v", ErrInternal, err)
-			}
-			if len(foundCards) == 0 {
-				// Prepare card to add
-				cardsToAdd = append(cardsToAdd, domain.OwnedCard{
-					ID:           primitive.NewObjectID(),
-					TournamentID: tournamentPlayer.TournamentID,
-					UserID:       tournamentPlayer.UserID,
-					Tags:         []string{},
-					Count:        1,
-					CardData:     card,
-					CreatedAt:    primitive.NewDateTimeFromTime(time.Now()),
-					UpdatedAt:    primitive.NewDateTimeFromTime(time.Now()),
-				})
-
-			} else if len(foundCards) == 1 {
-				// Update count of existing card
-				foundCards[0].Count += 1
-				foundCards[0].UpdatedAt = primitive.NewDateTimeFromTime(time.Now())
-				result, err := MongoDatabaseClient.
-					Database(DB_MAIN).
-					Collection(COLLECTION_CARD_COLLECTION).
-					UpdateByID(ctx, foundCards[0].ID, bson.M{"$set": foundCards[0]})
-				if err != nil || result.MatchedCount == 0 {
-					return nil, fmt.Errorf("%w: %v", ErrInternal, err)
-				}
-			} else {
-				dbFoundCardsIDs := make([]primitive.ObjectID, len(foundCards))
-				newCount := 0
-				for _, foundCard := range foundCards {
-					dbFoundCardsIDs = append(dbFoundCardsIDs, foundCard.ID)
-					newCount += foundCard.Count
-				}
-				result, err := MongoDatabaseClient.
-					Database(DB_MAIN).
-					Collection(COLLECTION_CARD_COLLECTION).
-					DeleteMany(ctx, bson.M{"_id": bson.M{"$in": dbFoundCardsIDs}})
-				if err != nil || result.DeletedCount == 0 {
-					return nil, fmt.Errorf("%w: %v", ErrInternal, err)
-				}
-				cardsToAdd = append(cardsToAdd, domain.OwnedCard{
-					ID:           primitive.NewObjectID(),
-					TournamentID: tournamentPlayer.TournamentID,
-					UserID:       tournamentPlayer.UserID,
-					Tags:         []string{},
-					Count:        newCount + 1,
-					CardData:     card,
-					CreatedAt:    primitive.NewDateTimeFromTime(time.Now()),
-					UpdatedAt:    primitive.NewDateTimeFromTime(time.Now()),
-				})
-			}
-		}
-		// Consolidate duplicates in CardsToAdd
-		consolidatedCards := make([]domain.OwnedCard, 0)
-		for _, cardToAdd := range cardsToAdd {
-			found := false
-			for i, consolidatedCard := range consolidatedCards {
-				if cardToAdd.CardData.SetCode == consolidatedCard.CardData.SetCode && cardToAdd.CardData.CollectorNumber == consolidatedCard.CardData.CollectorNumber {
-					consolidatedCards[i].Count += cardToAdd.Count
-					found = true
-					break
-				}
-			}
-			if !found {
-				consolidatedCards = append(consolidatedCards, cardToAdd)
-			}
-		}
+		return addCardsToTournamentPlayer(mongoCtx, tournamentPlayer, cards, cause)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return coins.(int), nil
+}
 
-		// Add all cards at once
-		newValues := make([]interface{}, len(consolidatedCards))
-		for i, cardToAdd := range consolidatedCards {
-			newValues[i] = cardToAdd
+// addCardsToTournamentPlayer does the work of AddCardsToTournamentPlayer within the caller's transaction
+func addCardsToTournamentPlayer(ctx context.Context, tournamentPlayer *domain.TournamentPlayer, cards []domain.CardData, cause domain.LedgerCause) (int, error) {
+	tournament, copiesByName, err := getDuplicateProtection(ctx, tournamentPlayer, cards)
+	if err != nil {
+		return 0, err
+	}
+
+	// Copies beyond the duplicate protection limit are converted, the rest are grouped by variant
+	kept, converted, coins := applyDuplicateProtection(tournament, copiesByName, cards)
+	variants := []domain.CardData{}
+	countsByVariant := make(map[string]int)
+	for _, card := range kept {
+		key := domain.CardVariantKey(card)
+		if _, ok := countsByVariant[key]; !ok {
+			variants = append(variants, card)
 		}
+		countsByVariant[key] += 1
+	}
 
+	// Add all the cards in one batch, merging each variant with the copies the player already has of it
+	if len(variants) > 0 {
+		now := primitive.NewDateTimeFromTime(time.Now())
+		models := make([]mongo.WriteModel, 0, len(variants))
+		for _, card := range variants {
+			filter := cardVariantFilter(card)
+			filter["tournament_id"] = tournamentPlayer.TournamentID
+			filter["user_id"] = tournamentPlayer.UserID
+			models = append(models, mongo.NewUpdateOneModel().
+				SetFilter(filter).
+				SetUpdate(bson.M{
+					"$inc": bson.M{"count": countsByVariant[domain.CardVariantKey(card)]},
+					"$set": bson.M{"updated_at": now},
+					"$setOnInsert": bson.M{
+						"_id":           primitive.NewObjectID(),
+						"tournament_id": tournamentPlayer.TournamentID,
+						"user_id":       tournamentPlayer.UserID,
+						"tags":          []string{},
+						"card_data":     card,
+						"created_at":    now,
+					},
+				}).
+				SetUpsert(true))
+		}
 		_, err := MongoDatabaseClient.
 			Database(DB_MAIN).
 			Collection(COLLECTION_CARD_COLLECTION).
-			InsertMany(ctx, newValues)
+			BulkWrite(ctx, models)
 		if err != nil {
-			return nil, fmt.Errorf("%w: %v", ErrInternal, err)
+			return 0, fmt.Errorf("%w: %v", ErrInternal, err)
 		}
-		return nil, nil
-	})
-	return err
+	}
+
+	// Converted copies are recorded as acquired and converted right away
+	err = recordCardMovements(ctx, cause, tournamentPlayer.TournamentID, tournamentPlayer.ID, domain.GroupCardMovements(cards, 1))
+	if err != nil {
+		return 0, err
+	}
+
+	// Give the coins for the converted copies
+	if coins > 0 {
+		updateResult, err := MongoDatabaseClient.
+			Database(DB_MAIN).
+			Collection(COLLECTION_TOURNAMENT_PLAYERS).
+			UpdateByID(ctx, tournamentPlayer.ID, bson.M{"$inc": bson.M{"game_resources.coins": coins}})
+		if err != nil || updateResult.MatchedCount == 0 {
+			return 0, fmt.Errorf("%w: %v", ErrInternal, err)
+		}
+		cause.Reason = domain.LedgerReasonDuplicateConversion
+		err = recordLedgerChanges(ctx, cause, tournamentPlayer.TournamentID, tournamentPlayer.ID, []domain.LedgerBalance{
+			{Resource: domain.LedgerResourceCoins, Amount: coins},
+		})
+		if err != nil {
+			return 0, err
+		}
+		err = recordCardMovements(ctx, cause, tournamentPlayer.TournamentID, tournamentPlayer.ID, domain.GroupCardMovements(converted, -1))
+		if err != nil {
+			return 0, err
+		}
+	}
+	return coins, nil
+}
+
+// applyDuplicateProtection splits the cards into the copies the player keeps and the ones converted into coins,
+// counting the copies they already have by name on copiesByName. Cards a deck can have any number of are always kept.
+func applyDuplicateProtection(tournament *domain.Tournament, copiesByName map[string]int, cards []domain.CardData) ([]domain.CardData, []domain.CardData, int) {
+	kept := make([]domain.CardData, 0, len(cards))
+	converted := []domain.CardData{}
+	coins := 0
+	for _, card := range cards {
+		if tournament.DuplicateProtection.Enabled && !deckvalidation.IgnoresCopyLimit(card) {
+			if copiesByName[card.Name] >= tournament.DuplicateProtection.MaxCopies {
+				coins += domain.CardCoinValue(card, tournament.CoinValues)
+				converted = append(converted, card)
+				continue
+			}
+			copiesByName[card.Name] += 1
+		}
+		kept = append(kept, card)
+	}
+	return kept, converted, coins
 }
 
-func RemoveCardsFromTournamentPlayer(tournamentPlayerID string, cardsToRemove map[string]int) error {
+// getDuplicateProtection returns the player's tournament, for its duplicate protection and coin values, and, if
+// duplicate protection is enabled, how many copies the player already has of each of the cards
+func getDuplicateProtection(ctx context.Context, tournamentPlayer *domain.TournamentPlayer, cards []domain.CardData) (*domain.Tournament, map[string]int, error) {
+	copiesByName := make(map[string]int)
+
+	// Find tournament
+	result := MongoDatabaseClient.
+		Database(DB_MAIN).
+		Collection(COLLECTION_TOURNAMENTS).
+		FindOne(ctx,
+			bson.M{"_id": tournamentPlayer.TournamentID},
+		)
+	if err := result.Err(); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil, fmt.Errorf("%w: %v", ErrNotFound, err)
+		}
+		return nil, nil, fmt.Errorf("%w: %v", ErrInternal, err)
+	}
+
+	// Decode tournament
+	var tournament *domain.Tournament
+	err := result.Decode(&tournament)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrInternal, err)
+	}
+	if !tournament.DuplicateProtection.Enabled {
+		return tournament, copiesByName, nil
+	}
+
+	names := make([]string, 0, len(cards))
+	for _, card := range cards {
+		names = append(names, card.Name)
+	}
+
+	// Find copies already owned
+	cursor, err := MongoDatabaseClient.
+		Database(DB_MAIN).
+		Collection(COLLECTION_CARD_COLLECTION).
+		Find(ctx, bson.M{
+			"tournament_id":  tournamentPlayer.TournamentID,
+			"user_id":        tournamentPlayer.UserID,
+			"card_data.name": bson.M{"$in": names},
+		})
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrInternal, err)
+	}
+	var ownedCards []domain.OwnedCard
+	err = cursor.All(ctx, &ownedCards)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrInternal, err)
+	}
+	for _, ownedCard := range ownedCards {
+		copiesByName[ownedCard.CardData.Name] += ownedCard.Count
+	}
+	return tournament, copiesByName, nil
+}
+
+// DisenchantCards removes the cards from the player's collection and gives the coins and wildcard progress for them.
+// Every DISENCHANTS_PER_WILDCARD of progress on a rarity becomes a wildcard of that rarity.
+func DisenchantCards(tournamentPlayerID string, cardsToRemove map[string]int, coins int, wildcardProgress domain.OwnedWildcards, cause domain.LedgerCause) error {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
 	defer cancel()
 
@@ -471,81 +639,82 @@ func RemoveCardsFromTournamentPlayer(tournamentPlayerID string, cardsToRemove ma
 	defer session.EndSession(ctx)
 
 	_, err = session.WithTransaction(ctx, func(mongoCtx mongo.SessionContext) (interface{}, error) {
-		result := MongoDatabaseClient.
+		tournamentPlayer, err := findTournamentPlayer(mongoCtx, dbTournamentPlayerID)
+		if err != nil {
+			return nil, err
+		}
+
+		err = removeCardsFromTournamentPlayer(mongoCtx, tournamentPlayer, cardsToRemove, cause)
+		if err != nil {
+			return nil, err
+		}
+
+		before := domain.ResourceBalances(*tournamentPlayer)
+		tournamentPlayer.GameResources.Coins += coins
+		newProgress := domain.OwnedWildcards{}
+		for _, rarity := range domain.CardRarities {
+			progress := domain.WildcardCount(tournamentPlayer.GameResources.WildcardProgress, rarity) + domain.WildcardCount(wildcardProgress, rarity)
+			tournamentPlayer.GameResources.Wildcards = domain.AddWildcards(tournamentPlayer.GameResources.Wildcards, rarity, progress/domain.DISENCHANTS_PER_WILDCARD)
+			newProgress = domain.AddWildcards(newProgress, rarity, progress%domain.DISENCHANTS_PER_WILDCARD)
+		}
+		tournamentPlayer.GameResources.WildcardProgress = newProgress
+
+		// Only the changed resources are written
+		updateResult, err := MongoDatabaseClient.
 			Database(DB_MAIN).
 			Collection(COLLECTION_TOURNAMENT_PLAYERS).
-			FindOne(ctx,
-				bson.M{"_id": dbTournamentPlayerID},
-			)
-		if err := result.Err(); err != nil {
-			if err == mongo.ErrNoDocuments {
-				return nil, fmt.Errorf("%w: %v", ErrNotFound, err)
-			}
+			UpdateByID(mongoCtx, tournamentPlayer.ID, bson.M{
+				"$inc": bson.M{"game_resources.coins": coins},
+				"$set": bson.M{
+					"game_resources.wildcards":         tournamentPlayer.GameResources.Wildcards,
+					"game_resources.wildcard_progress": tournamentPlayer.GameResources.WildcardProgress,
+				},
+			})
+		if err != nil || updateResult.MatchedCount == 0 {
 			return nil, fmt.Errorf("%w: %v", ErrInternal, err)
 		}
-		// Decode user
-		var tournamentPlayer *domain.TournamentPlayer
-		err = result.Decode(&tournamentPlayer)
+		return nil, recordResourceChanges(mongoCtx, cause, before, tournamentPlayer)
+	})
+	return err
+}
+
+// removeCardsFromTournamentPlayer takes the cards, keyed by owned card ID, out of the player's collection within the
+// caller's transaction. Every deck of the player must keep the copies it uses.
+func removeCardsFromTournamentPlayer(ctx context.Context, tournamentPlayer *domain.TournamentPlayer, cardsToRemove map[string]int, cause domain.LedgerCause) error {
+	decks, err := findDecksForTournamentPlayer(ctx, tournamentPlayer.ID)
+	if err != nil {
+		return err
+	}
+
+	for cardID, count := range cardsToRemove {
+		dbCardID, err := primitive.ObjectIDFromHex(cardID)
 		if err != nil {
-			return nil, fmt.Errorf("%w: %v", ErrInternal, err)
+			return fmt.Errorf("%w: %v", ErrInvalidID, err)
 		}
-		// Remove the cards to the tournament player's collection
-		// For each card, find if the user already has some of that card, and update or remove it accordingly
-		for cardID, count := range cardsToRemove {
-			dbCardID, err := primitive.ObjectIDFromHex(cardID)
-			if err != nil {
-				return nil, fmt.Errorf("%w: %v", ErrInvalidID, err)
-			}
-			result, err := MongoDatabaseClient.
-				Database(DB_MAIN).
-				Collection(COLLECTION_CARD_COLLECTION).
-				Find(ctx,
-					bson.M{
-						"tournament_id": tournamentPlayer.TournamentID,
-						"user_id":       tournamentPlayer.UserID,
-						"_id":           dbCardID,
-					},
-				)
-			if err != nil {
-				return nil, fmt.Errorf("%w: %v", ErrInternal, err)
-			}
-			var foundCards []domain.OwnedCard
-			if err := result.All(ctx, &foundCards); err != nil {
-				return nil, fmt.Errorf("%w: %v", ErrInternal, err)
-			}
-			if len(foundCards) == 0 {
-				return nil, fmt.Errorf("%w: %v", ErrInternal, "card to remove not found")
-			} else if len(foundCards) == 1 {
-				if foundCards[0].Count > count {
-					// Update count of existing card
-					foundCards[0].Count -= count
-					foundCards[0].UpdatedAt = primitive.NewDateTimeFromTime(time.Now())
-					result, err := MongoDatabaseClient.
-						Database(DB_MAIN).
-						Collection(COLLECTION_CARD_COLLECTION).
-						UpdateByID(ctx, foundCards[0].ID, bson.M{"$set": foundCards[0]})
-					if err != nil || result.MatchedCount == 0 {
-						return nil, fmt.Errorf("%w: %v", ErrInternal, err)
-					}
-				} else {
-					// Remove the card entirely
-					result, err := MongoDatabaseClient.
-						Database(DB_MAIN).
-						Collection(COLLECTION_CARD_COLLECTION).
-						DeleteOne(ctx, bson.M{"_id": foundCards[0].ID})
-					if err != nil || result.DeletedCount == 0 {
-						return nil, fmt.Errorf("%w: %v", ErrInternal, err)
-					}
-				}
-			} else {
-				// TODO (POSTA): TODO: Consolidate duplicate entries just in case
-				return nil, fmt.Errorf("%w: duplicated entries for card found on database", ErrInternal)
-			}
+		_, err = removeOwnedCard(ctx, dbCardID, count, tournamentPlayer, decks, cause)
+		if err != nil {
+			return err
 		}
-		return nil, nil
-	})
-	if err != nil {
-		return fmt.Errorf("%w: %v", ErrInternal, err)
 	}
 	return nil
 }
+
+// cardVariantFilter matches the cards of the same printing, finish and language as the given one. Cards stored
+// before finishes and languages were tracked count as non foil English copies.
+func cardVariantFilter(card domain.CardData) bson.M {
+	card = domain.NormalizeCardVariant(card)
+	finish := interface{}(card.Finish)
+	if card.Finish == domain.CardFinishNonFoil {
+		finish = bson.M{"$in": bson.A{card.Finish, "", nil}}
+	}
+	language := interface{}(card.Language)
+	if card.Language == domain.DEFAULT_CARD_LANGUAGE {
+		language = bson.M{"$in": bson.A{card.Language, "", nil}}
+	}
+	return bson.M{
+		"card_data.set_code":         card.SetCode,
+		"card_data.collector_number": card.CollectorNumber,
+		"card_data.finish":           finish,
+		"card_data.language":         language,
+	}
+}
@@ -0,0 +1,87 @@
+package db
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/joaquinleonarg/wdml-mtg/backend/domain"
+)
+
+func TestApplyDuplicateProtection(t *testing.T) {
+	opt := domain.CardData{Name: "Opt", Rarity: domain.CardRarityCommon}
+	shock := domain.CardData{Name: "Shock", Rarity: domain.CardRarityCommon}
+	island := domain.CardData{Name: "Island", Types: []string{"Basic", "Land", "Island"}}
+	rats := domain.CardData{
+		Name:   "Relentless Rats",
+		Rarity: domain.CardRarityUncommon,
+		Oracle: "A deck can have any number of cards named Relentless Rats.",
+	}
+	enabled := &domain.Tournament{DuplicateProtection: domain.DuplicateProtection{Enabled: true, MaxCopies: 2}}
+
+	tests := []struct {
+		name              string
+		tournament        *domain.Tournament
+		copiesByName      map[string]int
+		cards             []domain.CardData
+		expectedKept      []domain.CardData
+		expectedConverted []domain.CardData
+		expectedCoins     int
+		expectedCopies    map[string]int
+	}{
+		{
+			name:              "disabled",
+			tournament:        &domain.Tournament{},
+			copiesByName:      map[string]int{},
+			cards:             []domain.CardData{opt, opt, opt},
+			expectedKept:      []domain.CardData{opt, opt, opt},
+			expectedConverted: []domain.CardData{},
+			expectedCopies:    map[string]int{},
+		},
+		{
+			name:              "copies over the limit",
+			tournament:        enabled,
+			copiesByName:      map[string]int{},
+			cards:             []domain.CardData{opt, shock, opt, opt},
+			expectedKept:      []domain.CardData{opt, shock, opt},
+			expectedConverted: []domain.CardData{opt},
+			expectedCoins:     domain.CoinsForRarity(domain.CardRarityCommon),
+			expectedCopies:    map[string]int{"Opt": 2, "Shock": 1},
+		},
+		{
+			name:              "owned copies count",
+			tournament:        enabled,
+			copiesByName:      map[string]int{"Opt": 2},
+			cards:             []domain.CardData{opt, shock},
+			expectedKept:      []domain.CardData{shock},
+			expectedConverted: []domain.CardData{opt},
+			expectedCoins:     domain.CoinsForRarity(domain.CardRarityCommon),
+			expectedCopies:    map[string]int{"Opt": 2, "Shock": 1},
+		},
+		{
+			name:              "cards without a copy limit",
+			tournament:        enabled,
+			copiesByName:      map[string]int{"Island": 5},
+			cards:             []domain.CardData{island, island, rats, rats, rats},
+			expectedKept:      []domain.CardData{island, island, rats, rats, rats},
+			expectedConverted: []domain.CardData{},
+			expectedCopies:    map[string]int{"Island": 5},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			kept, converted, coins := applyDuplicateProtection(test.tournament, test.copiesByName, test.cards)
+			if !reflect.DeepEqual(kept, test.expectedKept) {
+				t.Errorf("expected kept %+v, got %+v", test.expectedKept, kept)
+			}
+			if !reflect.DeepEqual(converted, test.expectedConverted) {
+				t.Errorf("expected converted %+v, got %+v", test.expectedConverted, converted)
+			}
+			if coins != test.expectedCoins {
+				t.Errorf("expected %d coins, got %d", test.expectedCoins, coins)
+			}
+			if !reflect.DeepEqual(test.copiesByName, test.expectedCopies) {
+				t.Errorf("expected copies %v, got %v", test.expectedCopies, test.copiesByName)
+			}
+		})
+	}
+}
@@ -18,6 +18,9 @@ var (
 	ErrInvalidID        = fmt.Errorf("invalid object id provided: %w", mongo.ErrNilValue)
 	ErrNotFound         = fmt.Errorf("not found: %w", mongo.ErrNoDocuments)
 	ErrAlreadyExists    = fmt.Errorf("already exists: %w", mongo.ErrEmptySlice)
+	ErrNotEnough        = fmt.Errorf("not enough resources: %w", mongo.ErrNilValue)
+	ErrCardsInUse       = fmt.Errorf("cards in use by a deck: %w", mongo.ErrNilValue)
+	ErrInvalidState     = fmt.Errorf("invalid state: %w", mongo.ErrNilValue)
 
 	ErrUninitialized = fmt.Errorf("uninitialized field: %w", mongo.ErrNilValue)
 )
@@ -34,6 +37,16 @@ const (
 	COLLECTION_SEASONS            = "seasons"
 	COLLECTION_MATCHES            = "matches"
 	COLLECTION_EVENT_LOGS         = "event_logs"
+	COLLECTION_DECK_REGISTRATIONS = "deck_registrations"
+	COLLECTION_DECK_REVISIONS     = "deck_revisions"
+	COLLECTION_TRADES             = "trades"
+	COLLECTION_AUCTION_LISTINGS   = "auction_listings"
+	COLLECTION_LEDGER             = "ledger"
+	COLLECTION_CARD_MOVEMENTS     = "card_movements"
+	COLLECTION_WISHLISTS          = "wishlists"
+	COLLECTION_NOTIFICATIONS      = "notifications"
+	COLLECTION_BOOSTER_OPENINGS   = "booster_openings"
+	COLLECTION_BOOSTER_ODDS       = "booster_odds"
 )
 
 func InitDBConnection() error {
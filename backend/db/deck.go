@@ -3,6 +3,7 @@ package db
 import (
 	"context"
 	"fmt"
+	"slices"
 	"time"
 
 	"github.com/joaquinleonarg/wdml-mtg/backend/domain"
@@ -124,6 +125,31 @@ func GetDecksForTournamentPlayer(tournamentPlayerID string) ([]domain.Deck, erro
 	return decks, nil
 }
 
+// GetDecksWithOwnedCards returns the decks that use any of the given owned cards
+func GetDecksWithOwnedCards(ownedCardIDs []primitive.ObjectID) ([]domain.Deck, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*20)
+	defer cancel()
+	if len(ownedCardIDs) == 0 {
+		return []domain.Deck{}, nil
+	}
+
+	cursor, err := MongoDatabaseClient.
+		Database(DB_MAIN).
+		Collection(COLLECTION_DECKS).
+		Find(ctx, bson.M{"cards.owned_card_id": bson.M{"$in": ownedCardIDs}})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInternal, err)
+	}
+
+	// Decode decks
+	decks := []domain.Deck{}
+	err = cursor.All(ctx, &decks)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInternal, err)
+	}
+	return decks, nil
+}
+
 func CreateEmptyDeck(deck domain.Deck) error {
 	if deck.ID != primitive.NilObjectID {
 		return ErrObjectIDProvided
@@ -189,6 +215,7 @@ func AddOwnedCardToDeck(cardID string, deckID string, amount int, board domain.D
 		if err != nil {
 			return nil, err
 		}
+		previousCards := slices.Clone(deck.Cards)
 
 		var foundCard domain.DeckCard
 		foundAmount := 0
@@ -221,6 +248,7 @@ func AddOwnedCardToDeck(cardID string, deckID string, amount int, board domain.D
 				Board:       board,
 			})
 		}
+		deck.UpdatedAt = primitive.NewDateTimeFromTime(time.Now())
 
 		updateResult, err := MongoDatabaseClient.
 			Database(DB_MAIN).
@@ -231,7 +259,7 @@ func AddOwnedCardToDeck(cardID string, deckID string, amount int, board domain.D
 			return nil, fmt.Errorf("%w: %v", ErrInternal, err)
 		}
 
-		return nil, nil
+		return nil, recordDeckRevision(ctx, deck.ID, previousCards, deck.Cards, domain.DeckRevisionReasonAddCard)
 	})
 	return err
 }
@@ -276,7 +304,61 @@ func RemoveDeckCardFromDeck(ownedCardID, deckID string, board domain.DeckBoard,
 			}
 		}
 
+		previousCards := deck.Cards
 		deck.Cards = newDeckCards
+		deck.UpdatedAt = primitive.NewDateTimeFromTime(time.Now())
+		updateResult, err := MongoDatabaseClient.
+			Database(DB_MAIN).
+			Collection(COLLECTION_DECKS).
+			UpdateByID(ctx, deck.ID, bson.M{"$set": deck})
+
+		if err != nil || updateResult.MatchedCount == 0 {
+			return nil, fmt.Errorf("%w: %v", ErrInternal, err)
+		}
+		return nil, recordDeckRevision(ctx, deck.ID, previousCards, deck.Cards, domain.DeckRevisionReasonRemoveCard)
+	})
+	return err
+}
+
+// AddDeckCardsToDeck adds several cards to a deck at once, merging them with the cards already on the same board.
+// If replace is set, the current contents of the deck are discarded first.
+func AddDeckCardsToDeck(deckID string, deckCards []domain.DeckCard, replace bool, reason domain.DeckRevisionReason) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*20)
+	defer cancel()
+
+	// Begin transaction
+	session, err := MongoDatabaseClient.
+		StartSession()
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInternal, err)
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(ctx mongo.SessionContext) (interface{}, error) {
+		deck, _, err := GetDeckByID(deckID)
+		if err != nil {
+			return nil, err
+		}
+		previousCards := slices.Clone(deck.Cards)
+		if replace {
+			deck.Cards = make([]domain.DeckCard, 0, len(deckCards))
+		}
+
+		for _, newDeckCard := range deckCards {
+			found := false
+			for index, deckCard := range deck.Cards {
+				if deckCard.OwnedCardID == newDeckCard.OwnedCardID && deckCard.Board == newDeckCard.Board {
+					deck.Cards[index].Count += newDeckCard.Count
+					found = true
+					break
+				}
+			}
+			if !found {
+				deck.Cards = append(deck.Cards, newDeckCard)
+			}
+		}
+		deck.UpdatedAt = primitive.NewDateTimeFromTime(time.Now())
+
 		updateResult, err := MongoDatabaseClient.
 			Database(DB_MAIN).
 			Collection(COLLECTION_DECKS).
@@ -285,7 +367,7 @@ func RemoveDeckCardFromDeck(ownedCardID, deckID string, board domain.DeckBoard,
 		if err != nil || updateResult.MatchedCount == 0 {
 			return nil, fmt.Errorf("%w: %v", ErrInternal, err)
 		}
-		return nil, nil
+		return nil, recordDeckRevision(ctx, deck.ID, previousCards, deck.Cards, reason)
 	})
 	return err
 }
@@ -0,0 +1,197 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/joaquinleonarg/wdml-mtg/backend/domain"
+	"github.com/rs/zerolog/log"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// CreateDeckRegistration stores a deck snapshot. A player can only register one deck per season, and one per match;
+// when the registration is for a match, the match is updated to reference it.
+func CreateDeckRegistration(deckRegistration domain.DeckRegistration) (primitive.ObjectID, error) {
+	if deckRegistration.ID != primitive.NilObjectID {
+		return primitive.NilObjectID, ErrObjectIDProvided
+	}
+	deckRegistration.ID = primitive.NewObjectID()
+	deckRegistration.CreatedAt = primitive.NewDateTimeFromTime(time.Now())
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*20)
+	defer cancel()
+
+	// Begin transaction
+	session, err := MongoDatabaseClient.
+		StartSession()
+	if err != nil {
+		return primitive.NilObjectID, fmt.Errorf("%w: %v", ErrInternal, err)
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(ctx mongo.SessionContext) (interface{}, error) {
+		// Check that the player hasn't registered a deck already
+		duplicateFilter := bson.M{
+			"tournament_player_id": deckRegistration.TournamentPlayerID,
+			"season_id":            deckRegistration.SeasonID,
+			"match_id":             deckRegistration.MatchID,
+		}
+		resultFind := MongoDatabaseClient.
+			Database(DB_MAIN).
+			Collection(COLLECTION_DECK_REGISTRATIONS).
+			FindOne(ctx, duplicateFilter)
+		if err := resultFind.Err(); err != mongo.ErrNoDocuments {
+			if err == nil {
+				return nil, fmt.Errorf("%w", ErrAlreadyExists)
+			}
+			return nil, fmt.Errorf("%w: %v", ErrInternal, err)
+		}
+
+		resultInsert, err := MongoDatabaseClient.
+			Database(DB_MAIN).
+			Collection(COLLECTION_DECK_REGISTRATIONS).
+			InsertOne(ctx, deckRegistration)
+		if err != nil {
+			// Registered by a concurrent request since the check above
+			if mongo.IsDuplicateKeyError(err) {
+				return nil, fmt.Errorf("%w: %v", ErrAlreadyExists, err)
+			}
+			return nil, fmt.Errorf("%w: %v", ErrInternal, err)
+		}
+
+		if deckRegistration.MatchID != primitive.NilObjectID {
+			err = linkDeckRegistrationToMatch(ctx, deckRegistration.MatchID, deckRegistration.TournamentPlayerID, deckRegistration.ID)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return resultInsert, nil
+	})
+
+	log.Debug().Str("deck_registration_id", deckRegistration.ID.String()).Msg("created deck registration")
+
+	return deckRegistration.ID, err
+}
+
+// LinkDeckRegistrationToMatch sets an existing registration as the deck a player uses on a match
+func LinkDeckRegistrationToMatch(matchID, tournamentPlayerID, deckRegistrationID string) error {
+	dbMatchID, err := primitive.ObjectIDFromHex(matchID)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidID, err)
+	}
+	dbTournamentPlayerID, err := primitive.ObjectIDFromHex(tournamentPlayerID)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidID, err)
+	}
+	dbDeckRegistrationID, err := primitive.ObjectIDFromHex(deckRegistrationID)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidID, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*20)
+	defer cancel()
+	return linkDeckRegistrationToMatch(ctx, dbMatchID, dbTournamentPlayerID, dbDeckRegistrationID)
+}
+
+func linkDeckRegistrationToMatch(ctx context.Context, matchID, tournamentPlayerID, deckRegistrationID primitive.ObjectID) error {
+	// Only pending matches can get their decks changed, and only if no deck was registered before
+	result, err := MongoDatabaseClient.
+		Database(DB_MAIN).
+		Collection(COLLECTION_MATCHES).
+		UpdateOne(ctx,
+			bson.M{
+				"_id":       matchID,
+				"completed": false,
+				"players_data": bson.M{"$elemMatch": bson.M{
+					"tournament_player_id": tournamentPlayerID,
+					"deck_registration_id": bson.M{"$in": bson.A{nil, primitive.NilObjectID}},
+				}},
+			},
+			bson.M{"$set": bson.M{
+				"players_data.$.deck_registration_id": deckRegistrationID,
+				"updated_at":                          primitive.NewDateTimeFromTime(time.Now()),
+			}},
+		)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInternal, err)
+	}
+	if result.MatchedCount == 0 {
+		return fmt.Errorf("%w: %s", ErrNotFound, "no pending match without a registered deck for this player")
+	}
+	return nil
+}
+
+func GetDeckRegistrationByID(deckRegistrationID string) (*domain.DeckRegistration, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*20)
+	defer cancel()
+	dbDeckRegistrationID, err := primitive.ObjectIDFromHex(deckRegistrationID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidID, err)
+	}
+
+	// Find registration
+	result := MongoDatabaseClient.
+		Database(DB_MAIN).
+		Collection(COLLECTION_DECK_REGISTRATIONS).
+		FindOne(ctx,
+			bson.M{"_id": dbDeckRegistrationID},
+		)
+	if err := result.Err(); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, fmt.Errorf("%w: %v", ErrNotFound, err)
+		}
+		return nil, fmt.Errorf("%w: %v", ErrInternal, err)
+	}
+
+	// Decode registration
+	var deckRegistration *domain.DeckRegistration
+	err = result.Decode(&deckRegistration)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInternal, err)
+	}
+	return deckRegistration, nil
+}
+
+// GetDeckRegistrations lists registrations filtered by any of the given IDs; empty IDs are ignored
+func GetDeckRegistrations(tournamentID, tournamentPlayerID, seasonID, matchID string) ([]domain.DeckRegistration, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*20)
+	defer cancel()
+
+	filter := bson.M{}
+	for key, id := range map[string]string{
+		"tournament_id":        tournamentID,
+		"tournament_player_id": tournamentPlayerID,
+		"season_id":            seasonID,
+		"match_id":             matchID,
+	} {
+		if id == "" {
+			continue
+		}
+		dbID, err := primitive.ObjectIDFromHex(id)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidID, err)
+		}
+		filter[key] = dbID
+	}
+
+	// Find registrations, newest first
+	opts := options.Find().SetSort(bson.M{"created_at": -1})
+	cursor, err := MongoDatabaseClient.
+		Database(DB_MAIN).
+		Collection(COLLECTION_DECK_REGISTRATIONS).
+		Find(ctx, filter, opts)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInternal, err)
+	}
+
+	// Decode registrations
+	var deckRegistrations []domain.DeckRegistration
+	err = cursor.All(ctx, &deckRegistrations)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInternal, err)
+	}
+	return deckRegistrations, nil
+}
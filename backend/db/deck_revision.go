@@ -0,0 +1,114 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/joaquinleonarg/wdml-mtg/backend/domain"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// recordDeckRevision stores the new contents of a deck as its next revision. It must be called within the
+// transaction that changes the deck.
+func recordDeckRevision(ctx context.Context, deckID primitive.ObjectID, before, after []domain.DeckCard, reason domain.DeckRevisionReason) error {
+	changes := domain.DiffDeckCards(before, after)
+	if len(changes) == 0 {
+		return nil
+	}
+
+	// Get the number of the last revision
+	revisionCount, err := MongoDatabaseClient.
+		Database(DB_MAIN).
+		Collection(COLLECTION_DECK_REVISIONS).
+		CountDocuments(ctx, bson.M{"deck_id": deckID})
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInternal, err)
+	}
+
+	revision := domain.DeckRevision{
+		ID:        primitive.NewObjectID(),
+		DeckID:    deckID,
+		Number:    int(revisionCount) + 1,
+		Reason:    reason,
+		Cards:     after,
+		Changes:   changes,
+		CreatedAt: primitive.NewDateTimeFromTime(time.Now()),
+	}
+	if revision.Cards == nil {
+		revision.Cards = make([]domain.DeckCard, 0)
+	}
+
+	_, err = MongoDatabaseClient.
+		Database(DB_MAIN).
+		Collection(COLLECTION_DECK_REVISIONS).
+		InsertOne(ctx, revision)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInternal, err)
+	}
+	return nil
+}
+
+// GetDeckRevisions returns every revision of a deck, newest first
+func GetDeckRevisions(deckID string) ([]domain.DeckRevision, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*20)
+	defer cancel()
+	dbDeckID, err := primitive.ObjectIDFromHex(deckID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidID, err)
+	}
+
+	// Find revisions
+	cursor, err := MongoDatabaseClient.
+		Database(DB_MAIN).
+		Collection(COLLECTION_DECK_REVISIONS).
+		Find(ctx,
+			bson.M{"deck_id": dbDeckID},
+			options.Find().SetSort(bson.M{"number": -1}),
+		)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInternal, err)
+	}
+
+	// Decode revisions
+	revisions := []domain.DeckRevision{}
+	err = cursor.All(ctx, &revisions)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInternal, err)
+	}
+	return revisions, nil
+}
+
+func GetDeckRevisionByID(deckRevisionID string) (*domain.DeckRevision, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*20)
+	defer cancel()
+	dbDeckRevisionID, err := primitive.ObjectIDFromHex(deckRevisionID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidID, err)
+	}
+
+	// Find revision
+	result := MongoDatabaseClient.
+		Database(DB_MAIN).
+		Collection(COLLECTION_DECK_REVISIONS).
+		FindOne(ctx,
+			bson.M{"_id": dbDeckRevisionID},
+		)
+	if err := result.Err(); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, fmt.Errorf("%w: %v", ErrNotFound, err)
+		}
+		return nil, fmt.Errorf("%w: %v", ErrInternal, err)
+	}
+
+	// Decode revision
+	var revision *domain.DeckRevision
+	err = result.Decode(&revision)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInternal, err)
+	}
+	return revision, nil
+}
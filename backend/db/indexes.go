@@ -0,0 +1,82 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// indexes that have to exist on each collection. Creating an index that already exists does nothing.
+var indexes = map[string][]mongo.IndexModel{
+	COLLECTION_CARD_COLLECTION: {
+		// Tag listing, bulk tagging and tag filters on a player's collection
+		{
+			Keys:    bson.D{{Key: "tournament_id", Value: 1}, {Key: "user_id", Value: 1}, {Key: "tags", Value: 1}},
+			Options: options.Index().SetName("collection_tags"),
+		},
+	},
+	COLLECTION_WISHLISTS: {
+		// Finding who wants the cards pulled on a tournament
+		{
+			Keys:    bson.D{{Key: "tournament_id", Value: 1}, {Key: "card_name", Value: 1}},
+			Options: options.Index().SetName("wishlist_cards"),
+		},
+	},
+	COLLECTION_NOTIFICATIONS: {
+		// A player's notifications, newest first
+		{
+			Keys:    bson.D{{Key: "tournament_player_id", Value: 1}, {Key: "created_at", Value: -1}},
+			Options: options.Index().SetName("notifications_player"),
+		},
+	},
+	COLLECTION_DECK_REGISTRATIONS: {
+		// One registration per player and season or match, so concurrent requests can't register twice
+		{
+			Keys: bson.D{
+				{Key: "tournament_player_id", Value: 1},
+				{Key: "season_id", Value: 1},
+				{Key: "match_id", Value: 1},
+			},
+			Options: options.Index().SetName("deck_registrations_player").SetUnique(true),
+		},
+	},
+	COLLECTION_BOOSTER_ODDS: {
+		// One set of published odds per set and tournament
+		{
+			Keys:    bson.D{{Key: "tournament_id", Value: 1}, {Key: "set_code", Value: 1}},
+			Options: options.Index().SetName("booster_odds_tournament_set").SetUnique(true),
+		},
+	},
+	COLLECTION_BOOSTER_OPENINGS: {
+		// One opening per idempotency key and player, so retried requests can't open a second pack
+		{
+			Keys: bson.D{{Key: "tournament_player_id", Value: 1}, {Key: "idempotency_key", Value: 1}},
+			Options: options.Index().
+				SetName("booster_openings_idempotency").
+				SetUnique(true).
+				SetPartialFilterExpression(bson.M{"idempotency_key": bson.M{"$type": "string"}}),
+		},
+	},
+}
+
+// EnsureIndexes creates the indexes the queries rely on
+func EnsureIndexes() error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*60)
+	defer cancel()
+
+	for collection, models := range indexes {
+		_, err := MongoDatabaseClient.
+			Database(DB_MAIN).
+			Collection(collection).
+			Indexes().
+			CreateMany(ctx, models)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInternal, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,209 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/joaquinleonarg/wdml-mtg/backend/domain"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// recordLedgerChanges adds an entry to the ledger for each of the changes of the player's resources
+func recordLedgerChanges(ctx context.Context, cause domain.LedgerCause, tournamentID, tournamentPlayerID primitive.ObjectID, changes []domain.LedgerBalance) error {
+	entries := make([]interface{}, 0, len(changes))
+	for _, change := range changes {
+		if change.Amount == 0 {
+			continue
+		}
+		entries = append(entries, domain.LedgerEntry{
+			ID:                 primitive.NewObjectID(),
+			TournamentID:       tournamentID,
+			TournamentPlayerID: tournamentPlayerID,
+			LedgerCause:        cause,
+			Resource:           change.Resource,
+			Detail:             change.Detail,
+			Amount:             change.Amount,
+			CreatedAt:          primitive.NewDateTimeFromTime(time.Now()),
+		})
+	}
+	if len(entries) == 0 {
+		return nil
+	}
+
+	_, err := MongoDatabaseClient.
+		Database(DB_MAIN).
+		Collection(COLLECTION_LEDGER).
+		InsertMany(ctx, entries)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInternal, err)
+	}
+	return nil
+}
+
+// recordResourceChanges adds to the ledger whatever changed on the player since the balances were taken
+func recordResourceChanges(ctx context.Context, cause domain.LedgerCause, before []domain.LedgerBalance, tournamentPlayer *domain.TournamentPlayer) error {
+	changes := domain.DiffBalances(before, domain.ResourceBalances(*tournamentPlayer))
+	return recordLedgerChanges(ctx, cause, tournamentPlayer.TournamentID, tournamentPlayer.ID, changes)
+}
+
+// GetLedgerEntries returns a page of the ledger of a tournament, newest first. It can be narrowed to a player
+// and to a resource.
+func GetLedgerEntries(tournamentID, tournamentPlayerID string, resource domain.LedgerResource, count, page int) ([]domain.LedgerEntry, int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*20)
+	defer cancel()
+	dbTournamentID, err := primitive.ObjectIDFromHex(tournamentID)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%w: %v", ErrInvalidID, err)
+	}
+
+	filter := bson.M{"tournament_id": dbTournamentID}
+	if tournamentPlayerID != "" {
+		dbTournamentPlayerID, err := primitive.ObjectIDFromHex(tournamentPlayerID)
+		if err != nil {
+			return nil, 0, fmt.Errorf("%w: %v", ErrInvalidID, err)
+		}
+		filter["tournament_player_id"] = dbTournamentPlayerID
+	}
+	if resource != "" {
+		filter["resource"] = resource
+	}
+
+	// Find entries
+	cursor, err := MongoDatabaseClient.
+		Database(DB_MAIN).
+		Collection(COLLECTION_LEDGER).
+		Find(ctx,
+			filter,
+			options.Find().
+				SetSort(bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}).
+				SetSkip(int64(count*(page-1))).
+				SetLimit(int64(count)),
+		)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%w: %v", ErrInternal, err)
+	}
+
+	// Decode entries
+	entries := []domain.LedgerEntry{}
+	err = cursor.All(ctx, &entries)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%w: %v", ErrInternal, err)
+	}
+
+	total, err := MongoDatabaseClient.
+		Database(DB_MAIN).
+		Collection(COLLECTION_LEDGER).
+		CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%w: %v", ErrInternal, err)
+	}
+	return entries, int(total), nil
+}
+
+// GetLedgerBalances adds up the ledger entries of a player into their balances
+func GetLedgerBalances(tournamentPlayerID string) ([]domain.LedgerBalance, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*20)
+	defer cancel()
+	dbTournamentPlayerID, err := primitive.ObjectIDFromHex(tournamentPlayerID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidID, err)
+	}
+	return sumLedgerEntries(ctx, dbTournamentPlayerID)
+}
+
+func sumLedgerEntries(ctx context.Context, tournamentPlayerID primitive.ObjectID) ([]domain.LedgerBalance, error) {
+	cursor, err := MongoDatabaseClient.
+		Database(DB_MAIN).
+		Collection(COLLECTION_LEDGER).
+		Aggregate(ctx, bson.A{
+			bson.M{"$match": bson.M{"tournament_player_id": tournamentPlayerID}},
+			bson.M{"$group": bson.M{
+				"_id":    bson.M{"resource": "$resource", "detail": "$detail"},
+				"amount": bson.M{"$sum": "$amount"},
+			}},
+			bson.M{"$project": bson.M{
+				"_id":      0,
+				"resource": "$_id.resource",
+				"detail":   "$_id.detail",
+				"amount":   1,
+			}},
+			bson.M{"$sort": bson.D{{Key: "resource", Value: 1}, {Key: "detail", Value: 1}}},
+		})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInternal, err)
+	}
+
+	// Decode balances
+	balances := []domain.LedgerBalance{}
+	err = cursor.All(ctx, &balances)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInternal, err)
+	}
+	return balances, nil
+}
+
+// ReconcileLedger adds opening balance entries for every player whose stored resources don't match their ledger,
+// like players that already had resources before the ledger existed. It returns how many players were reconciled.
+func ReconcileLedger(tournamentID, actorID string) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*60)
+	defer cancel()
+	dbTournamentID, err := primitive.ObjectIDFromHex(tournamentID)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrInvalidID, err)
+	}
+	dbActorID, err := primitive.ObjectIDFromHex(actorID)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrInvalidID, err)
+	}
+
+	// Begin transaction
+	session, err := MongoDatabaseClient.
+		StartSession()
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrInternal, err)
+	}
+	defer session.EndSession(ctx)
+
+	reconciled, err := session.WithTransaction(ctx, func(ctx mongo.SessionContext) (interface{}, error) {
+		// Find tournament players
+		cursor, err := MongoDatabaseClient.
+			Database(DB_MAIN).
+			Collection(COLLECTION_TOURNAMENT_PLAYERS).
+			Find(ctx, bson.M{"tournament_id": dbTournamentID})
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInternal, err)
+		}
+		var tournamentPlayers []domain.TournamentPlayer
+		err = cursor.All(ctx, &tournamentPlayers)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInternal, err)
+		}
+
+		reconciled := 0
+		cause := domain.LedgerCause{ActorID: dbActorID, Reason: domain.LedgerReasonOpeningBalance}
+		for _, tournamentPlayer := range tournamentPlayers {
+			ledgerBalances, err := sumLedgerEntries(ctx, tournamentPlayer.ID)
+			if err != nil {
+				return nil, err
+			}
+			changes := domain.DiffBalances(ledgerBalances, domain.ResourceBalances(tournamentPlayer))
+			if len(changes) == 0 {
+				continue
+			}
+			err = recordLedgerChanges(ctx, cause, tournamentPlayer.TournamentID, tournamentPlayer.ID, changes)
+			if err != nil {
+				return nil, err
+			}
+			reconciled++
+		}
+		return reconciled, nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return reconciled.(int), nil
+}
@@ -189,3 +189,34 @@ func UpdateMatch(matchID string, playerWins map[string]int, gamesPlayed int, com
 
 	return err
 }
+
+func GetMatchByID(matchID string) (*domain.Match, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*20)
+	defer cancel()
+	dbMatchID, err := primitive.ObjectIDFromHex(matchID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidID, err)
+	}
+
+	// Find match
+	result := MongoDatabaseClient.
+		Database(DB_MAIN).
+		Collection(COLLECTION_MATCHES).
+		FindOne(ctx,
+			bson.M{"_id": dbMatchID},
+		)
+	if err := result.Err(); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, fmt.Errorf("%w: %v", ErrNotFound, err)
+		}
+		return nil, fmt.Errorf("%w: %v", ErrInternal, err)
+	}
+
+	// Decode match
+	var match *domain.Match
+	err = result.Decode(&match)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInternal, err)
+	}
+	return match, nil
+}
@@ -0,0 +1,179 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/joaquinleonarg/wdml-mtg/backend/domain"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// GetNotifications returns a page of a tournament player's notifications, newest first, and how many there are
+func GetNotifications(tournamentPlayerID string, unreadOnly bool, count, page int) ([]domain.Notification, int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*20)
+	defer cancel()
+	dbTournamentPlayerID, err := primitive.ObjectIDFromHex(tournamentPlayerID)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%w: %v", ErrInvalidID, err)
+	}
+
+	filter := bson.M{"tournament_player_id": dbTournamentPlayerID}
+	if unreadOnly {
+		filter["read"] = false
+	}
+
+	// Find notifications
+	cursor, err := MongoDatabaseClient.
+		Database(DB_MAIN).
+		Collection(COLLECTION_NOTIFICATIONS).
+		Find(ctx,
+			filter,
+			options.Find().
+				SetSort(bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}).
+				SetSkip(int64(count*(page-1))).
+				SetLimit(int64(count)),
+		)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%w: %v", ErrInternal, err)
+	}
+
+	// Decode notifications
+	notifications := []domain.Notification{}
+	err = cursor.All(ctx, &notifications)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%w: %v", ErrInternal, err)
+	}
+
+	total, err := MongoDatabaseClient.
+		Database(DB_MAIN).
+		Collection(COLLECTION_NOTIFICATIONS).
+		CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%w: %v", ErrInternal, err)
+	}
+	return notifications, int(total), nil
+}
+
+// MarkNotificationsRead marks the given notifications of a tournament player as read, or all of them if no IDs are
+// given. Returns how many were marked.
+func MarkNotificationsRead(tournamentPlayerID string, notificationIDs []string) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*20)
+	defer cancel()
+	dbTournamentPlayerID, err := primitive.ObjectIDFromHex(tournamentPlayerID)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrInvalidID, err)
+	}
+
+	filter := bson.M{"tournament_player_id": dbTournamentPlayerID, "read": false}
+	if len(notificationIDs) > 0 {
+		dbNotificationIDs := make([]primitive.ObjectID, 0, len(notificationIDs))
+		for _, notificationID := range notificationIDs {
+			dbNotificationID, err := primitive.ObjectIDFromHex(notificationID)
+			if err != nil {
+				return 0, fmt.Errorf("%w: %v", ErrInvalidID, err)
+			}
+			dbNotificationIDs = append(dbNotificationIDs, dbNotificationID)
+		}
+		filter["_id"] = bson.M{"$in": dbNotificationIDs}
+	}
+
+	result, err := MongoDatabaseClient.
+		Database(DB_MAIN).
+		Collection(COLLECTION_NOTIFICATIONS).
+		UpdateMany(ctx, filter, bson.M{"$set": bson.M{"read": true}})
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrInternal, err)
+	}
+	return int(result.ModifiedCount), nil
+}
+
+// NotifyWishlistedPulls lets the players that have any of the cards a player pulled on their wishlists know about
+// it. Players that can't see the puller's collection aren't notified.
+func NotifyWishlistedPulls(puller *domain.TournamentPlayer, cards []domain.CardData) error {
+	names := make([]string, 0, len(cards))
+	for _, card := range cards {
+		names = append(names, card.Name)
+	}
+	wishlist, err := GetWishlistsForCards(puller.TournamentID, names)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*20)
+	defer cancel()
+
+	notifications := []interface{}{}
+	wishers := map[primitive.ObjectID]*domain.TournamentPlayer{}
+	username := ""
+	for _, wishlistCard := range wishlist {
+		if wishlistCard.TournamentPlayerID == puller.ID {
+			continue
+		}
+		wisher, ok := wishers[wishlistCard.TournamentPlayerID]
+		if !ok {
+			result := MongoDatabaseClient.
+				Database(DB_MAIN).
+				Collection(COLLECTION_TOURNAMENT_PLAYERS).
+				FindOne(ctx, bson.M{"_id": wishlistCard.TournamentPlayerID})
+			if err := result.Err(); err != nil && err != mongo.ErrNoDocuments {
+				return fmt.Errorf("%w: %v", ErrInternal, err)
+			}
+			// Players that left the tournament are left as nil
+			result.Decode(&wisher)
+			wishers[wishlistCard.TournamentPlayerID] = wisher
+		}
+		if wisher == nil || !domain.CanSeeCollection(*puller, wisher) {
+			continue
+		}
+
+		if username == "" {
+			var user domain.User
+			err := MongoDatabaseClient.
+				Database(DB_MAIN).
+				Collection(COLLECTION_USERS).
+				FindOne(ctx, bson.M{"_id": puller.UserID}).
+				Decode(&user)
+			if err != nil {
+				return fmt.Errorf("%w: %v", ErrInternal, err)
+			}
+			username = user.Username
+		}
+
+		for _, card := range cards {
+			if !domain.WishlistMatches(wishlistCard, card) {
+				continue
+			}
+			notifications = append(notifications, domain.Notification{
+				ID:                 primitive.NewObjectID(),
+				TournamentID:       puller.TournamentID,
+				TournamentPlayerID: wishlistCard.TournamentPlayerID,
+				Type:               domain.NotificationTypeWishlistPull,
+				WishlistPull: &domain.NotificationWishlistPull{
+					WishlistCardID: wishlistCard.ID,
+					PullerID:       puller.ID,
+					Username:       username,
+					Card:           card,
+				},
+				CreatedAt: primitive.NewDateTimeFromTime(time.Now()),
+			})
+			// One notification per wishlist entry, even if the pack had several copies
+			break
+		}
+	}
+	if len(notifications) == 0 {
+		return nil
+	}
+
+	_, err = MongoDatabaseClient.
+		Database(DB_MAIN).
+		Collection(COLLECTION_NOTIFICATIONS).
+		InsertMany(ctx, notifications)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInternal, err)
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/joaquinleonarg/wdml-mtg/backend/domain"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// GetTagCounts returns every tag on a player's collection, and how many cards have it
+func GetTagCounts(userID, tournamentID string) ([]domain.TagCount, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*20)
+	defer cancel()
+	collectionFilter, err := playerCollectionFilter(userID, tournamentID)
+	if err != nil {
+		return nil, err
+	}
+
+	cursor, err := MongoDatabaseClient.
+		Database(DB_MAIN).
+		Collection(COLLECTION_CARD_COLLECTION).
+		Aggregate(ctx, bson.A{
+			bson.M{"$match": collectionFilter},
+			bson.M{"$unwind": "$tags"},
+			bson.M{"$group": bson.M{
+				"_id":    "$tags",
+				"cards":  bson.M{"$sum": 1},
+				"copies": bson.M{"$sum": "$count"},
+			}},
+			bson.M{"$sort": bson.M{"_id": 1}},
+		})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInternal, err)
+	}
+
+	// Decode tag counts
+	tagCounts := []domain.TagCount{}
+	err = cursor.All(ctx, &tagCounts)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInternal, err)
+	}
+	return tagCounts, nil
+}
+
+// SetTagOnCards adds a tag to, or removes it from, every card of a player's collection that matches the filter.
+// The amount of cards changed is returned.
+func SetTagOnCards(userID, tournamentID string, cardFilter bson.M, tag string, remove bool) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*20)
+	defer cancel()
+	collectionFilter, err := playerCollectionFilter(userID, tournamentID)
+	if err != nil {
+		return 0, err
+	}
+	if cardFilter == nil {
+		cardFilter = bson.M{}
+	}
+
+	update := bson.M{"$addToSet": bson.M{"tags": tag}}
+	if remove {
+		update = bson.M{"$pull": bson.M{"tags": tag}}
+	}
+	update["$set"] = bson.M{"updated_at": primitive.NewDateTimeFromTime(time.Now())}
+
+	result, err := MongoDatabaseClient.
+		Database(DB_MAIN).
+		Collection(COLLECTION_CARD_COLLECTION).
+		UpdateMany(ctx,
+			bson.M{"$and": bson.A{collectionFilter, cardFilter}},
+			update,
+		)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrInternal, err)
+	}
+	return int(result.ModifiedCount), nil
+}
+
+// RenameTag replaces a tag with another on every card of a player's collection. Cards that already had both
+// are left with only the new one. The amount of cards changed is returned.
+func RenameTag(userID, tournamentID, tag, newTag string) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*20)
+	defer cancel()
+	collectionFilter, err := playerCollectionFilter(userID, tournamentID)
+	if err != nil {
+		return 0, err
+	}
+	collectionFilter["tags"] = tag
+
+	// Begin transaction
+	session, err := MongoDatabaseClient.
+		StartSession()
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrInternal, err)
+	}
+	defer session.EndSession(ctx)
+
+	renamed, err := session.WithTransaction(ctx, func(ctx mongo.SessionContext) (interface{}, error) {
+		now := primitive.NewDateTimeFromTime(time.Now())
+		result, err := MongoDatabaseClient.
+			Database(DB_MAIN).
+			Collection(COLLECTION_CARD_COLLECTION).
+			UpdateMany(ctx,
+				collectionFilter,
+				bson.M{
+					"$addToSet": bson.M{"tags": newTag},
+					"$set":      bson.M{"updated_at": now},
+				},
+			)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInternal, err)
+		}
+
+		_, err = MongoDatabaseClient.
+			Database(DB_MAIN).
+			Collection(COLLECTION_CARD_COLLECTION).
+			UpdateMany(ctx,
+				collectionFilter,
+				bson.M{
+					"$pull": bson.M{"tags": tag},
+					"$set":  bson.M{"updated_at": now},
+				},
+			)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInternal, err)
+		}
+		return int(result.MatchedCount), nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return renamed.(int), nil
+}
+
+func playerCollectionFilter(userID, tournamentID string) (bson.M, error) {
+	dbTournamentID, err := primitive.ObjectIDFromHex(tournamentID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidID, err)
+	}
+	dbUserID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidID, err)
+	}
+	return bson.M{
+		"tournament_id": dbTournamentID,
+		"user_id":       dbUserID,
+	}, nil
+}
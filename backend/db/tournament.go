@@ -192,3 +192,54 @@ func UpdateTournamentStore(tournamentID string, store domain.Store) error {
 
 	return nil
 }
+
+// updateTournamentSettings replaces one of the tournament's settings, stored as a sub-document on the field
+func updateTournamentSettings(tournamentID, field string, settings interface{}) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*20)
+	defer cancel()
+	dbTournamentID, err := primitive.ObjectIDFromHex(tournamentID)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidID, err)
+	}
+
+	result, err := MongoDatabaseClient.
+		Database(DB_MAIN).
+		Collection(COLLECTION_TOURNAMENTS).
+		UpdateOne(ctx,
+			bson.M{
+				"_id": dbTournamentID,
+			}, bson.M{
+				"$set": bson.M{
+					field:        settings,
+					"updated_at": primitive.NewDateTimeFromTime(time.Now()),
+				},
+			})
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInternal, err)
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func UpdateTournamentDeckRules(tournamentID string, deckRules domain.DeckRules) error {
+	return updateTournamentSettings(tournamentID, "deck_rules", deckRules)
+}
+
+func UpdateTournamentDuplicateProtection(tournamentID string, duplicateProtection domain.DuplicateProtection) error {
+	return updateTournamentSettings(tournamentID, "duplicate_protection", duplicateProtection)
+}
+
+func UpdateTournamentTradeRules(tournamentID string, tradeRules domain.TradeRules) error {
+	return updateTournamentSettings(tournamentID, "trade_rules", tradeRules)
+}
+
+func UpdateTournamentTradeUpRecipes(tournamentID string, recipes []domain.TradeUpRecipe) error {
+	return updateTournamentSettings(tournamentID, "trade_up_recipes", recipes)
+}
+
+func UpdateTournamentCoinValues(tournamentID string, coinValues domain.CoinValues) error {
+	return updateTournamentSettings(tournamentID, "coin_values", coinValues)
+}
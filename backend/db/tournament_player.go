@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"slices"
 	"time"
 
 	"github.com/joaquinleonarg/wdml-mtg/backend/domain"
@@ -244,34 +245,59 @@ func GetAvailablePacksForTournamentPlayer(tournamentID, userID string) ([]domain
 	return tournamentPlayer.GameResources.BoosterPacks, nil
 }
 
-func ConsumeBoosterPackForTournamentPlayer(userID, tournamentID string, setCode string, cards []domain.CardData) error {
+// findTournamentPlayerForUser finds the user's player on the tournament, within the caller's transaction
+func findTournamentPlayerForUser(ctx context.Context, tournamentID, userID primitive.ObjectID) (*domain.TournamentPlayer, error) {
+	result := MongoDatabaseClient.
+		Database(DB_MAIN).
+		Collection(COLLECTION_TOURNAMENT_PLAYERS).
+		FindOne(ctx, bson.M{"user_id": userID, "tournament_id": tournamentID})
+	if err := result.Err(); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, fmt.Errorf("%w: %v", ErrNotFound, err)
+		}
+		return nil, fmt.Errorf("%w: %v", ErrInternal, err)
+	}
+
+	// Decode tournament player
+	var tournamentPlayer *domain.TournamentPlayer
+	err := result.Decode(&tournamentPlayer)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInternal, err)
+	}
+	return tournamentPlayer, nil
+}
+
+// ConsumeBoosterPackForTournamentPlayer takes one of the opening's packs from the player, adds its cards to their
+// collection and records the opening, all in one transaction. If the player already has an opening with the same
+// idempotency key, nothing is consumed and that opening is returned instead.
+func ConsumeBoosterPackForTournamentPlayer(userID, tournamentID string, opening domain.BoosterOpening, cause domain.LedgerCause) (*domain.BoosterOpening, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second*20)
 	defer cancel()
 
 	dbTournamentID, err := primitive.ObjectIDFromHex(tournamentID)
 	if err != nil {
-		return fmt.Errorf("%w: %v", ErrInvalidID, err)
+		return nil, fmt.Errorf("%w: %v", ErrInvalidID, err)
 	}
 	dbUserID, err := primitive.ObjectIDFromHex(userID)
 	if err != nil {
-		return fmt.Errorf("%w: %v", ErrInvalidID, err)
+		return nil, fmt.Errorf("%w: %v", ErrInvalidID, err)
 	}
 
 	// Begin transaction
 	session, err := MongoDatabaseClient.
 		StartSession()
 	if err != nil {
-		return fmt.Errorf("%w: %v", ErrInternal, err)
+		return nil, fmt.Errorf("%w: %v", ErrInternal, err)
 	}
 	defer session.EndSession(ctx)
 
 	// Find if user has packs of the same type and add them, or create new
-	_, err = session.WithTransaction(ctx, func(mongoCtx mongo.SessionContext) (interface{}, error) {
+	consumed, err := session.WithTransaction(ctx, func(mongoCtx mongo.SessionContext) (interface{}, error) {
 		// Find tournament user
 		result := MongoDatabaseClient.
 			Database(DB_MAIN).
 			Collection(COLLECTION_TOURNAMENT_PLAYERS).
-			FindOne(ctx,
+			FindOne(mongoCtx,
 				bson.M{"user_id": dbUserID, "tournament_id": dbTournamentID},
 			)
 		if err := result.Err(); err != nil {
@@ -287,48 +313,198 @@ func ConsumeBoosterPackForTournamentPlayer(userID, tournamentID string, setCode
 			return nil, fmt.Errorf("%w: %v", ErrInternal, err)
 		}
 
-		removed := false
-		newPacks := make([]domain.OwnedBoosterPack, 0, len(tournamentPlayer.GameResources.BoosterPacks))
-		// Find and remove the booster pack
-		for _, boosterPack := range tournamentPlayer.GameResources.BoosterPacks {
-			if boosterPack.SetCode == setCode {
-				if boosterPack.Available == 1 && !removed {
-					removed = true
-					continue
-				}
-				if boosterPack.Available > 1 && !removed {
-					boosterPack.Available -= 1
-					newPacks = append(newPacks, boosterPack)
-					removed = true
-					continue
-				}
+		// A retried request gets the opening it already made
+		if opening.IdempotencyKey != "" {
+			existing, err := findBoosterOpeningByIdempotencyKey(mongoCtx, tournamentPlayer.ID, opening.IdempotencyKey)
+			if err == nil {
+				return existing, nil
+			}
+			if !errors.Is(err, ErrNotFound) {
+				return nil, err
 			}
-			newPacks = append(newPacks, boosterPack)
 		}
+
+		before := domain.ResourceBalances(*tournamentPlayer)
+		// Find and remove the booster pack
+		newPacks, removed := removeBoosterPacks(tournamentPlayer.GameResources.BoosterPacks, opening.SetCode, 1)
 		if !removed {
 			return nil, fmt.Errorf("%w: %s", ErrNotFound, "booster pack not available for tournament player")
 		}
 		tournamentPlayer.GameResources.BoosterPacks = newPacks
 
-		// Update the tournament player
+		// Update the tournament player's packs
 		updateResult, err := MongoDatabaseClient.
 			Database(DB_MAIN).
 			Collection(COLLECTION_TOURNAMENT_PLAYERS).
-			UpdateByID(ctx, tournamentPlayer.ID, bson.M{"$set": tournamentPlayer})
+			UpdateByID(mongoCtx, tournamentPlayer.ID, bson.M{"$set": bson.M{"game_resources.booster_packs": newPacks}})
 
 		if err != nil || updateResult.MatchedCount == 0 {
 			return nil, fmt.Errorf("%w: %v", ErrInternal, err)
 		}
 
-		AddCardsToTournamentPlayer(tournamentPlayer.ID.Hex(), cards)
+		err = recordResourceChanges(mongoCtx, cause, before, tournamentPlayer)
+		if err != nil {
+			return nil, err
+		}
 
-		return nil, nil
+		_, err = addCardsToTournamentPlayer(mongoCtx, tournamentPlayer, opening.Cards, cause)
+		if err != nil {
+			return nil, err
+		}
+
+		// Record the opening, so it can be drawn again
+		opening.TournamentID = tournamentPlayer.TournamentID
+		opening.TournamentPlayerID = tournamentPlayer.ID
+		opening.CreatedAt = primitive.NewDateTimeFromTime(time.Now())
+		_, err = MongoDatabaseClient.
+			Database(DB_MAIN).
+			Collection(COLLECTION_BOOSTER_OPENINGS).
+			InsertOne(mongoCtx, opening)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInternal, err)
+		}
+
+		return &opening, nil
 	})
+	if err != nil {
+		// A concurrent request with the same key won the race; return its opening
+		if opening.IdempotencyKey != "" && mongo.IsDuplicateKeyError(err) {
+			return GetBoosterOpeningByIdempotencyKey(tournamentID, userID, opening.IdempotencyKey)
+		}
+		return nil, err
+	}
+	return consumed.(*domain.BoosterOpening), nil
+}
 
-	return err
+// ConsumeBoosterPacksForTournamentPlayer takes the openings' packs from the player, all of the same set, adds all of
+// their cards to their collection in one batch and records the openings, all in one transaction. The batch's
+// idempotency key is kept on its first opening; if the player already has an opening with that key, nothing is
+// consumed and the openings of its batch are returned instead.
+func ConsumeBoosterPacksForTournamentPlayer(userID, tournamentID string, openings []domain.BoosterOpening, cause domain.LedgerCause) ([]domain.BoosterOpening, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*60)
+	defer cancel()
+
+	if len(openings) == 0 {
+		return openings, nil
+	}
+	dbTournamentID, err := primitive.ObjectIDFromHex(tournamentID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidID, err)
+	}
+	dbUserID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidID, err)
+	}
+	idempotencyKey := openings[0].IdempotencyKey
+
+	// Begin transaction
+	session, err := MongoDatabaseClient.
+		StartSession()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInternal, err)
+	}
+	defer session.EndSession(ctx)
+
+	consumed, err := session.WithTransaction(ctx, func(mongoCtx mongo.SessionContext) (interface{}, error) {
+		tournamentPlayer, err := findTournamentPlayerForUser(mongoCtx, dbTournamentID, dbUserID)
+		if err != nil {
+			return nil, err
+		}
+
+		// A retried request gets the openings it already made
+		if idempotencyKey != "" {
+			existing, err := findBoosterOpeningByIdempotencyKey(mongoCtx, tournamentPlayer.ID, idempotencyKey)
+			if err == nil {
+				return findBoosterOpeningBatch(mongoCtx, existing)
+			}
+			if !errors.Is(err, ErrNotFound) {
+				return nil, err
+			}
+		}
+
+		// Remove all the packs at once
+		before := domain.ResourceBalances(*tournamentPlayer)
+		newPacks, removed := removeBoosterPacks(tournamentPlayer.GameResources.BoosterPacks, openings[0].SetCode, len(openings))
+		if !removed {
+			return nil, fmt.Errorf("%w: %s", ErrNotFound, "not enough booster packs available for tournament player")
+		}
+		tournamentPlayer.GameResources.BoosterPacks = newPacks
+
+		// Update the tournament player's packs
+		updateResult, err := MongoDatabaseClient.
+			Database(DB_MAIN).
+			Collection(COLLECTION_TOURNAMENT_PLAYERS).
+			UpdateByID(mongoCtx, tournamentPlayer.ID, bson.M{"$set": bson.M{"game_resources.booster_packs": newPacks}})
+		if err != nil || updateResult.MatchedCount == 0 {
+			return nil, fmt.Errorf("%w: %v", ErrInternal, err)
+		}
+
+		err = recordResourceChanges(mongoCtx, cause, before, tournamentPlayer)
+		if err != nil {
+			return nil, err
+		}
+
+		// Add every pack's cards in one batch
+		cards := []domain.CardData{}
+		for _, opening := range openings {
+			cards = append(cards, opening.Cards...)
+		}
+		_, err = addCardsToTournamentPlayer(mongoCtx, tournamentPlayer, cards, cause)
+		if err != nil {
+			return nil, err
+		}
+
+		// Record the openings, so they can be drawn again
+		newOpenings := make([]interface{}, len(openings))
+		for i := range openings {
+			openings[i].TournamentID = tournamentPlayer.TournamentID
+			openings[i].TournamentPlayerID = tournamentPlayer.ID
+			openings[i].CreatedAt = primitive.NewDateTimeFromTime(time.Now())
+			newOpenings[i] = openings[i]
+		}
+		_, err = MongoDatabaseClient.
+			Database(DB_MAIN).
+			Collection(COLLECTION_BOOSTER_OPENINGS).
+			InsertMany(mongoCtx, newOpenings)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInternal, err)
+		}
+
+		return openings, nil
+	})
+	if err != nil {
+		// A concurrent request with the same key won the race; return its openings
+		if idempotencyKey != "" && mongo.IsDuplicateKeyError(err) {
+			existing, err := GetBoosterOpeningByIdempotencyKey(tournamentID, userID, idempotencyKey)
+			if err != nil {
+				return nil, err
+			}
+			return GetBoosterOpeningBatch(existing)
+		}
+		return nil, err
+	}
+	return consumed.([]domain.BoosterOpening), nil
+}
+
+// removeBoosterPacks takes count packs of the set from the player's packs. It returns false if they don't have enough.
+func removeBoosterPacks(boosterPacks []domain.OwnedBoosterPack, setCode string, count int) ([]domain.OwnedBoosterPack, bool) {
+	newPacks := make([]domain.OwnedBoosterPack, 0, len(boosterPacks))
+	for _, boosterPack := range boosterPacks {
+		if boosterPack.SetCode == setCode && count > 0 {
+			taken := min(boosterPack.Available, count)
+			boosterPack.Available -= taken
+			count -= taken
+			if boosterPack.Available == 0 {
+				continue
+			}
+		}
+		newPacks = append(newPacks, boosterPack)
+	}
+	return newPacks, count == 0
 }
 
-func AddPacksToTournamentPlayers(tournamentPlayers []domain.TournamentPlayer, pack domain.OwnedBoosterPack) error {
+// AddPacksToTournamentPlayers gives the packs to every one of the players, in one transaction
+func AddPacksToTournamentPlayers(tournamentPlayers []domain.TournamentPlayer, pack domain.OwnedBoosterPack, cause domain.LedgerCause) error {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second*20)
 	defer cancel()
 
@@ -340,14 +516,11 @@ func AddPacksToTournamentPlayers(tournamentPlayers []domain.TournamentPlayer, pa
 	}
 	defer session.EndSession(ctx)
 
-	_, err = session.WithTransaction(ctx, func(ctx mongo.SessionContext) (interface{}, error) {
+	_, err = session.WithTransaction(ctx, func(mongoCtx mongo.SessionContext) (interface{}, error) {
 		for _, tournamentPlayer := range tournamentPlayers {
-			err = AddPacksToTournamentPlayer(tournamentPlayer.ID.Hex(), pack)
+			err := addPacksToTournamentPlayer(mongoCtx, tournamentPlayer.ID, pack, cause)
 			if err != nil {
-				if errors.Is(err, ErrNotFound) {
-					return nil, ErrNotFound
-				}
-				return nil, ErrInternal
+				return nil, err
 			}
 		}
 
@@ -357,7 +530,7 @@ func AddPacksToTournamentPlayers(tournamentPlayers []domain.TournamentPlayer, pa
 	return err
 }
 
-func AddPacksToTournamentPlayer(tournamentPlayerID string, pack domain.OwnedBoosterPack) error {
+func AddPacksToTournamentPlayer(tournamentPlayerID string, pack domain.OwnedBoosterPack, cause domain.LedgerCause) error {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second*20)
 	defer cancel()
 
@@ -374,50 +547,60 @@ func AddPacksToTournamentPlayer(tournamentPlayerID string, pack domain.OwnedBoos
 	}
 	defer session.EndSession(ctx)
 
-	// Find if user has packs of the same type and add them, or create new
+	_, err = session.WithTransaction(ctx, func(mongoCtx mongo.SessionContext) (interface{}, error) {
+		return nil, addPacksToTournamentPlayer(mongoCtx, dbTournamentPlayerID, pack, cause)
+	})
+	return err
+}
 
-	// Find tournament player
-	result := MongoDatabaseClient.
-		Database(DB_MAIN).
-		Collection(COLLECTION_TOURNAMENT_PLAYERS).
-		FindOne(ctx,
-			bson.M{"_id": dbTournamentPlayerID},
-		)
-	if err := result.Err(); err != nil {
-		if err == mongo.ErrNoDocuments {
-			return fmt.Errorf("%w: %v", ErrNotFound, err)
-		}
-		return fmt.Errorf("%w: %v", ErrInternal, err)
-	}
-	// Decode user
-	var tournamentPlayer *domain.TournamentPlayer
-	err = result.Decode(&tournamentPlayer)
+// addPacksToTournamentPlayer adds the packs to the ones the player has of the same set, or as a new kind of pack,
+// within the caller's transaction. Only the player's packs are written.
+func addPacksToTournamentPlayer(ctx context.Context, tournamentPlayerID primitive.ObjectID, pack domain.OwnedBoosterPack, cause domain.LedgerCause) error {
+	tournamentPlayer, err := findTournamentPlayer(ctx, tournamentPlayerID)
 	if err != nil {
-		return fmt.Errorf("%w: %v", ErrInternal, err)
-	}
-	// Packs the user already has
-	seenPacks := make(map[string]int, len(tournamentPlayer.GameResources.BoosterPacks))
-	for index, pack := range tournamentPlayer.GameResources.BoosterPacks {
-		seenPacks[pack.SetCode] = index
+		return err
 	}
-	if index, ok := seenPacks[pack.SetCode]; ok {
-		tournamentPlayer.GameResources.BoosterPacks[index].Available += pack.Available
-	} else {
-		tournamentPlayer.GameResources.BoosterPacks = append(tournamentPlayer.GameResources.BoosterPacks, pack)
+
+	// Find if user has packs of the same type and add them, or create new
+	filter := bson.M{"_id": tournamentPlayerID}
+	var update bson.M
+	switch {
+	case slices.ContainsFunc(tournamentPlayer.GameResources.BoosterPacks, func(ownedPack domain.OwnedBoosterPack) bool {
+		return ownedPack.SetCode == pack.SetCode
+	}):
+		filter["game_resources.booster_packs.set_code"] = pack.SetCode
+		update = bson.M{"$inc": bson.M{"game_resources.booster_packs.$.available": pack.Available}}
+	case len(tournamentPlayer.GameResources.BoosterPacks) == 0:
+		// The packs may be stored as null, which can't be pushed to
+		update = bson.M{"$set": bson.M{"game_resources.booster_packs": []domain.OwnedBoosterPack{pack}}}
+	default:
+		update = bson.M{"$push": bson.M{"game_resources.booster_packs": pack}}
 	}
+
 	// Update the tournament player
 	updateResult, err := MongoDatabaseClient.
 		Database(DB_MAIN).
 		Collection(COLLECTION_TOURNAMENT_PLAYERS).
-		UpdateByID(ctx, dbTournamentPlayerID, bson.M{"$set": tournamentPlayer})
-
+		UpdateOne(ctx, filter, update)
 	if err != nil || updateResult.MatchedCount == 0 {
 		return fmt.Errorf("%w: %v", ErrInternal, err)
 	}
-	return nil
+	return recordLedgerChanges(ctx, cause, tournamentPlayer.TournamentID, tournamentPlayer.ID, []domain.LedgerBalance{
+		{Resource: domain.LedgerResourceBoosterPacks, Detail: pack.SetCode, Amount: pack.Available},
+	})
 }
 
-func AddCoinsToTournamentPlayer(coins int, userID, tournamentID string) error {
+func AddCoinsToTournamentPlayer(coins int, userID, tournamentID string, cause domain.LedgerCause) error {
+	return incrementTournamentPlayerResource(userID, tournamentID, "game_resources.coins", domain.LedgerResourceCoins, coins, cause)
+}
+
+func AddPointsToTournamentPlayer(points int, userID, tournamentID string, cause domain.LedgerCause) error {
+	return incrementTournamentPlayerResource(userID, tournamentID, "tournament_points", domain.LedgerResourcePoints, points, cause)
+}
+
+// incrementTournamentPlayerResource adds the amount to a single numeric resource of the player, and records it on
+// the ledger in the same transaction. Only that field is written, so concurrent changes to others are kept.
+func incrementTournamentPlayerResource(userID, tournamentID, field string, resource domain.LedgerResource, amount int, cause domain.LedgerCause) error {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second*20)
 	defer cancel()
 
@@ -439,90 +622,46 @@ func AddCoinsToTournamentPlayer(coins int, userID, tournamentID string) error {
 	defer session.EndSession(ctx)
 
 	_, err = session.WithTransaction(ctx, func(mongoCtx mongo.SessionContext) (interface{}, error) {
-		// Find tournament user
-		result := MongoDatabaseClient.
-			Database(DB_MAIN).
-			Collection(COLLECTION_TOURNAMENT_PLAYERS).
-			FindOne(ctx,
-				bson.M{"user_id": dbUserID, "tournament_id": dbTournamentID},
-			)
-		if err := result.Err(); err != nil {
-			if err == mongo.ErrNoDocuments {
-				return nil, fmt.Errorf("%w: %v", ErrNotFound, err)
-			}
-			return nil, fmt.Errorf("%w: %v", ErrInternal, err)
-		}
-		// Decode user
-		var tournamentPlayer *domain.TournamentPlayer
-		err = result.Decode(&tournamentPlayer)
+		tournamentPlayer, err := findTournamentPlayerForUser(mongoCtx, dbTournamentID, dbUserID)
 		if err != nil {
-			return nil, fmt.Errorf("%w: %v", ErrInternal, err)
+			return nil, err
 		}
-		tournamentPlayer.GameResources.Coins += coins
 		updateResult, err := MongoDatabaseClient.
 			Database(DB_MAIN).
 			Collection(COLLECTION_TOURNAMENT_PLAYERS).
-			UpdateByID(ctx, tournamentPlayer.ID, bson.M{"$set": tournamentPlayer})
-
+			UpdateByID(mongoCtx, tournamentPlayer.ID, bson.M{"$inc": bson.M{field: amount}})
 		if err != nil || updateResult.MatchedCount == 0 {
 			return nil, fmt.Errorf("%w: %v", ErrInternal, err)
 		}
-		return nil, nil
+		return nil, recordLedgerChanges(mongoCtx, cause, tournamentPlayer.TournamentID, tournamentPlayer.ID, []domain.LedgerBalance{
+			{Resource: resource, Amount: amount},
+		})
 	})
 	return err
 }
 
-func AddPointsToTournamentPlayer(points int, userID, tournamentID string) error {
+func UpdateTournamentPlayerPrivacy(tournamentPlayerID string, privacy domain.PrivacySettings) error {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second*20)
 	defer cancel()
-
-	dbTournamentID, err := primitive.ObjectIDFromHex(tournamentID)
-	if err != nil {
-		return fmt.Errorf("%w: %v", ErrInvalidID, err)
-	}
-	dbUserID, err := primitive.ObjectIDFromHex(userID)
+	dbTournamentPlayerID, err := primitive.ObjectIDFromHex(tournamentPlayerID)
 	if err != nil {
 		return fmt.Errorf("%w: %v", ErrInvalidID, err)
 	}
 
-	// Begin transaction
-	session, err := MongoDatabaseClient.
-		StartSession()
+	result, err := MongoDatabaseClient.
+		Database(DB_MAIN).
+		Collection(COLLECTION_TOURNAMENT_PLAYERS).
+		UpdateByID(ctx, dbTournamentPlayerID, bson.M{
+			"$set": bson.M{
+				"privacy":    privacy,
+				"updated_at": primitive.NewDateTimeFromTime(time.Now()),
+			},
+		})
 	if err != nil {
 		return fmt.Errorf("%w: %v", ErrInternal, err)
 	}
-	defer session.EndSession(ctx)
-
-	_, err = session.WithTransaction(ctx, func(mongoCtx mongo.SessionContext) (interface{}, error) {
-		// Find tournament player
-		result := MongoDatabaseClient.
-			Database(DB_MAIN).
-			Collection(COLLECTION_TOURNAMENT_PLAYERS).
-			FindOne(ctx,
-				bson.M{"user_id": dbUserID, "tournament_id": dbTournamentID},
-			)
-		if err := result.Err(); err != nil {
-			if err == mongo.ErrNoDocuments {
-				return nil, fmt.Errorf("%w: %v", ErrNotFound, err)
-			}
-			return nil, fmt.Errorf("%w: %v", ErrInternal, err)
-		}
-		// Decode tournament player
-		var tournamentPlayer *domain.TournamentPlayer
-		err = result.Decode(&tournamentPlayer)
-		if err != nil {
-			return nil, fmt.Errorf("%w: %v", ErrInternal, err)
-		}
-		tournamentPlayer.TournamentPoints += points
-		updateResult, err := MongoDatabaseClient.
-			Database(DB_MAIN).
-			Collection(COLLECTION_TOURNAMENT_PLAYERS).
-			UpdateByID(ctx, tournamentPlayer.ID, bson.M{"$set": tournamentPlayer})
-
-		if err != nil || updateResult.MatchedCount == 0 {
-			return nil, fmt.Errorf("%w: %v", ErrInternal, err)
-		}
-		return nil, nil
-	})
-	return err
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
 }
@@ -0,0 +1,465 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/joaquinleonarg/wdml-mtg/backend/domain"
+	"github.com/rs/zerolog/log"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// CreateTrade stores a new trade. If it's a counter offer, the countered trade is marked as such,
+// as long as it's still pending.
+func CreateTrade(trade domain.Trade) (primitive.ObjectID, error) {
+	if trade.ID != primitive.NilObjectID {
+		return primitive.NilObjectID, ErrObjectIDProvided
+	}
+	trade.ID = primitive.NewObjectID()
+	trade.Status = domain.TradeStatusPending
+	trade.CreatedAt = primitive.NewDateTimeFromTime(time.Now())
+	trade.UpdatedAt = primitive.NewDateTimeFromTime(time.Now())
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*20)
+	defer cancel()
+
+	// Begin transaction
+	session, err := MongoDatabaseClient.
+		StartSession()
+	if err != nil {
+		return primitive.NilObjectID, fmt.Errorf("%w: %v", ErrInternal, err)
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(ctx mongo.SessionContext) (interface{}, error) {
+		if trade.CounteredTradeID != primitive.NilObjectID {
+			err := updateTradeStatus(ctx, trade.CounteredTradeID, []domain.TradeStatus{domain.TradeStatusPending}, domain.TradeStatusCountered, primitive.NilObjectID)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		_, err := MongoDatabaseClient.
+			Database(DB_MAIN).
+			Collection(COLLECTION_TRADES).
+			InsertOne(ctx, trade)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInternal, err)
+		}
+		return nil, nil
+	})
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+
+	log.Debug().Str("trade_id", trade.ID.Hex()).Msg("created trade")
+	return trade.ID, nil
+}
+
+func GetTradeByID(tradeID string) (*domain.Trade, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*20)
+	defer cancel()
+	dbTradeID, err := primitive.ObjectIDFromHex(tradeID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidID, err)
+	}
+
+	// Find trade
+	result := MongoDatabaseClient.
+		Database(DB_MAIN).
+		Collection(COLLECTION_TRADES).
+		FindOne(ctx,
+			bson.M{"_id": dbTradeID},
+		)
+	if err := result.Err(); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, fmt.Errorf("%w: %v", ErrNotFound, err)
+		}
+		return nil, fmt.Errorf("%w: %v", ErrInternal, err)
+	}
+
+	// Decode trade
+	var trade *domain.Trade
+	err = result.Decode(&trade)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInternal, err)
+	}
+	return trade, nil
+}
+
+// GetTrades returns the trades of a tournament, newest first. If a tournament player is given, only the trades
+// they are part of are returned; if statuses are given, only trades on those statuses.
+func GetTrades(tournamentID, tournamentPlayerID string, statuses []domain.TradeStatus) ([]domain.Trade, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*20)
+	defer cancel()
+	dbTournamentID, err := primitive.ObjectIDFromHex(tournamentID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidID, err)
+	}
+
+	filter := bson.M{"tournament_id": dbTournamentID}
+	if tournamentPlayerID != "" {
+		dbTournamentPlayerID, err := primitive.ObjectIDFromHex(tournamentPlayerID)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidID, err)
+		}
+		filter["$or"] = bson.A{
+			bson.M{"proposer_id": dbTournamentPlayerID},
+			bson.M{"recipient_id": dbTournamentPlayerID},
+		}
+	}
+	if len(statuses) > 0 {
+		filter["status"] = bson.M{"$in": statuses}
+	}
+
+	// Find trades
+	cursor, err := MongoDatabaseClient.
+		Database(DB_MAIN).
+		Collection(COLLECTION_TRADES).
+		Find(ctx,
+			filter,
+			options.Find().SetSort(bson.M{"created_at": -1}),
+		)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInternal, err)
+	}
+
+	// Decode trades
+	trades := []domain.Trade{}
+	err = cursor.All(ctx, &trades)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInternal, err)
+	}
+	return trades, nil
+}
+
+// UpdateTradeStatus changes the status of a trade, only if it's currently on one of the given statuses
+func UpdateTradeStatus(tradeID string, from []domain.TradeStatus, to domain.TradeStatus, reviewerID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*20)
+	defer cancel()
+	dbTradeID, err := primitive.ObjectIDFromHex(tradeID)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidID, err)
+	}
+	dbReviewerID := primitive.NilObjectID
+	if reviewerID != "" {
+		dbReviewerID, err = primitive.ObjectIDFromHex(reviewerID)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidID, err)
+		}
+	}
+	return updateTradeStatus(ctx, dbTradeID, from, to, dbReviewerID)
+}
+
+func updateTradeStatus(ctx context.Context, tradeID primitive.ObjectID, from []domain.TradeStatus, to domain.TradeStatus, reviewerID primitive.ObjectID) error {
+	update := bson.M{
+		"status":     to,
+		"updated_at": primitive.NewDateTimeFromTime(time.Now()),
+	}
+	if reviewerID != primitive.NilObjectID {
+		update["reviewer_id"] = reviewerID
+	}
+
+	result, err := MongoDatabaseClient.
+		Database(DB_MAIN).
+		Collection(COLLECTION_TRADES).
+		UpdateOne(ctx,
+			bson.M{"_id": tradeID, "status": bson.M{"$in": from}},
+			bson.M{"$set": update},
+		)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInternal, err)
+	}
+	if result.MatchedCount == 0 {
+		return fmt.Errorf("%w: trade is not %v", ErrInvalidState, from)
+	}
+	return nil
+}
+
+// SettleTrade completes a trade that is on one of the given statuses, moving the cards, coins and booster packs
+// of both sides in a single transaction. It fails if either side doesn't have everything they offered anymore,
+// or if a card they give away is still needed by one of their decks.
+func SettleTrade(tradeID string, from []domain.TradeStatus, reviewerID, actorID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+	defer cancel()
+	dbTradeID, err := primitive.ObjectIDFromHex(tradeID)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidID, err)
+	}
+	dbReviewerID := primitive.NilObjectID
+	if reviewerID != "" {
+		dbReviewerID, err = primitive.ObjectIDFromHex(reviewerID)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidID, err)
+		}
+	}
+	dbActorID, err := primitive.ObjectIDFromHex(actorID)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidID, err)
+	}
+
+	// Begin transaction
+	session, err := MongoDatabaseClient.
+		StartSession()
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInternal, err)
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(ctx mongo.SessionContext) (interface{}, error) {
+		// Mark the trade as completed first, so it can't be settled twice
+		err := updateTradeStatus(ctx, dbTradeID, from, domain.TradeStatusCompleted, dbReviewerID)
+		if err != nil {
+			return nil, err
+		}
+
+		// Find trade
+		result := MongoDatabaseClient.
+			Database(DB_MAIN).
+			Collection(COLLECTION_TRADES).
+			FindOne(ctx, bson.M{"_id": dbTradeID})
+		if err := result.Err(); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInternal, err)
+		}
+		var trade *domain.Trade
+		err = result.Decode(&trade)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInternal, err)
+		}
+
+		proposer, err := findTournamentPlayer(ctx, trade.ProposerID)
+		if err != nil {
+			return nil, err
+		}
+		recipient, err := findTournamentPlayer(ctx, trade.RecipientID)
+		if err != nil {
+			return nil, err
+		}
+
+		cause := domain.LedgerCause{ActorID: dbActorID, Reason: domain.LedgerReasonTrade, RelatedID: trade.ID}
+		proposerBefore := domain.ResourceBalances(*proposer)
+		recipientBefore := domain.ResourceBalances(*recipient)
+		coinsBefore := map[primitive.ObjectID]int{
+			proposer.ID:  proposer.GameResources.Coins,
+			recipient.ID: recipient.GameResources.Coins,
+		}
+		err = moveTradeOffer(ctx, trade.ProposerOffer, proposer, recipient, cause)
+		if err != nil {
+			return nil, err
+		}
+		err = moveTradeOffer(ctx, trade.RecipientOffer, recipient, proposer, cause)
+		if err != nil {
+			return nil, err
+		}
+
+		// Update the coins and packs of both players. Coins are incremented, to keep the ones duplicate protection
+		// gave for the received cards.
+		for _, tournamentPlayer := range []*domain.TournamentPlayer{proposer, recipient} {
+			updateResult, err := MongoDatabaseClient.
+				Database(DB_MAIN).
+				Collection(COLLECTION_TOURNAMENT_PLAYERS).
+				UpdateByID(ctx, tournamentPlayer.ID, bson.M{
+					"$inc": bson.M{"game_resources.coins": tournamentPlayer.GameResources.Coins - coinsBefore[tournamentPlayer.ID]},
+					"$set": bson.M{
+						"game_resources.booster_packs": tournamentPlayer.GameResources.BoosterPacks,
+						"updated_at":                   primitive.NewDateTimeFromTime(time.Now()),
+					},
+				})
+			if err != nil || updateResult.MatchedCount == 0 {
+				return nil, fmt.Errorf("%w: %v", ErrInternal, err)
+			}
+		}
+
+		err = recordResourceChanges(ctx, cause, proposerBefore, proposer)
+		if err != nil {
+			return nil, err
+		}
+		return nil, recordResourceChanges(ctx, cause, recipientBefore, recipient)
+	})
+	return err
+}
+
+func findTournamentPlayer(ctx context.Context, tournamentPlayerID primitive.ObjectID) (*domain.TournamentPlayer, error) {
+	result := MongoDatabaseClient.
+		Database(DB_MAIN).
+		Collection(COLLECTION_TOURNAMENT_PLAYERS).
+		FindOne(ctx, bson.M{"_id": tournamentPlayerID})
+	if err := result.Err(); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, fmt.Errorf("%w: %v", ErrNotFound, err)
+		}
+		return nil, fmt.Errorf("%w: %v", ErrInternal, err)
+	}
+
+	// Decode tournament player
+	var tournamentPlayer *domain.TournamentPlayer
+	err := result.Decode(&tournamentPlayer)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInternal, err)
+	}
+	return tournamentPlayer, nil
+}
+
+// moveTradeOffer moves the cards of an offer from the giver's collection to the receiver's, and the coins and
+// booster packs between both players. Players are only changed in memory, and have to be saved afterwards.
+func moveTradeOffer(ctx context.Context, offer domain.TradeOffer, giver, receiver *domain.TournamentPlayer, cause domain.LedgerCause) error {
+	if len(offer.Cards) > 0 {
+		// Find the decks of the giver, to check they don't lose cards they use
+		decks, err := findDecksForTournamentPlayer(ctx, giver.ID)
+		if err != nil {
+			return err
+		}
+
+		for _, tradeCard := range offer.Cards {
+			ownedCard, err := removeOwnedCard(ctx, tradeCard.OwnedCardID, tradeCard.Count, giver, decks, cause)
+			if err != nil {
+				return err
+			}
+			err = addOwnedCard(ctx, ownedCard.CardData, tradeCard.Count, receiver, cause)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	// Coins
+	if giver.GameResources.Coins < offer.Coins {
+		return fmt.Errorf("%w: not enough coins", ErrNotEnough)
+	}
+	giver.GameResources.Coins -= offer.Coins
+	receiver.GameResources.Coins += offer.Coins
+
+	// Booster packs
+	for _, pack := range offer.BoosterPacks {
+		index := -1
+		for i, ownedPack := range giver.GameResources.BoosterPacks {
+			if ownedPack.SetCode == pack.SetCode {
+				index = i
+				break
+			}
+		}
+		if index == -1 || giver.GameResources.BoosterPacks[index].Available < pack.Available {
+			return fmt.Errorf("%w: not enough booster packs of %s", ErrNotEnough, pack.SetCode)
+		}
+		givenPack := giver.GameResources.BoosterPacks[index]
+		if givenPack.Available == pack.Available {
+			giver.GameResources.BoosterPacks = append(giver.GameResources.BoosterPacks[:index], giver.GameResources.BoosterPacks[index+1:]...)
+		} else {
+			giver.GameResources.BoosterPacks[index].Available -= pack.Available
+		}
+
+		found := false
+		for i, ownedPack := range receiver.GameResources.BoosterPacks {
+			if ownedPack.SetCode == pack.SetCode {
+				receiver.GameResources.BoosterPacks[i].Available += pack.Available
+				found = true
+				break
+			}
+		}
+		if !found {
+			givenPack.Available = pack.Available
+			receiver.GameResources.BoosterPacks = append(receiver.GameResources.BoosterPacks, givenPack)
+		}
+	}
+	return nil
+}
+
+func findDecksForTournamentPlayer(ctx context.Context, tournamentPlayerID primitive.ObjectID) ([]domain.Deck, error) {
+	cursor, err := MongoDatabaseClient.
+		Database(DB_MAIN).
+		Collection(COLLECTION_DECKS).
+		Find(ctx, bson.M{"tournament_player_id": tournamentPlayerID})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInternal, err)
+	}
+
+	// Decode decks
+	var decks []domain.Deck
+	err = cursor.All(ctx, &decks)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInternal, err)
+	}
+	return decks, nil
+}
+
+// removeOwnedCard takes copies of a card out of the owner's collection, as long as every one of their decks
+// keeps the copies it uses
+func removeOwnedCard(ctx context.Context, ownedCardID primitive.ObjectID, count int, owner *domain.TournamentPlayer, ownerDecks []domain.Deck, cause domain.LedgerCause) (*domain.OwnedCard, error) {
+	// Find card
+	result := MongoDatabaseClient.
+		Database(DB_MAIN).
+		Collection(COLLECTION_CARD_COLLECTION).
+		FindOne(ctx, bson.M{
+			"_id":           ownedCardID,
+			"tournament_id": owner.TournamentID,
+			"user_id":       owner.UserID,
+		})
+	if err := result.Err(); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, fmt.Errorf("%w: card %s is not owned anymore", ErrNotEnough, ownedCardID.Hex())
+		}
+		return nil, fmt.Errorf("%w: %v", ErrInternal, err)
+	}
+	var ownedCard *domain.OwnedCard
+	err := result.Decode(&ownedCard)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInternal, err)
+	}
+	if ownedCard.Count < count {
+		return nil, fmt.Errorf("%w: not enough copies of %s", ErrNotEnough, ownedCard.CardData.Name)
+	}
+
+	// Every deck must keep all of its copies
+	remaining := ownedCard.Count - count
+	for _, deck := range ownerDecks {
+		used := 0
+		for _, deckCard := range deck.Cards {
+			if deckCard.OwnedCardID == ownedCard.ID {
+				used += deckCard.Count
+			}
+		}
+		if used > remaining {
+			return nil, fmt.Errorf("%w: %s is used by deck %s", ErrCardsInUse, ownedCard.CardData.Name, deck.Name)
+		}
+	}
+
+	if remaining == 0 {
+		_, err = MongoDatabaseClient.
+			Database(DB_MAIN).
+			Collection(COLLECTION_CARD_COLLECTION).
+			DeleteOne(ctx, bson.M{"_id": ownedCard.ID})
+	} else {
+		_, err = MongoDatabaseClient.
+			Database(DB_MAIN).
+			Collection(COLLECTION_CARD_COLLECTION).
+			UpdateByID(ctx, ownedCard.ID, bson.M{
+				"$inc": bson.M{"count": -count},
+				"$set": bson.M{"updated_at": primitive.NewDateTimeFromTime(time.Now())},
+			})
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInternal, err)
+	}
+
+	err = recordCardMovements(ctx, cause, owner.TournamentID, owner.ID, []domain.CardMovement{
+		{CardData: ownedCard.CardData, Count: -count},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ownedCard, nil
+}
+
+// addOwnedCard adds copies of a card to a player's collection, merging them with the same printing and variant if
+// they have it. Copies beyond the tournament's duplicate protection limit are converted into coins.
+func addOwnedCard(ctx context.Context, cardData domain.CardData, count int, owner *domain.TournamentPlayer, cause domain.LedgerCause) error {
+	cards := make([]domain.CardData, count)
+	for i := range cards {
+		cards[i] = cardData
+	}
+	_, err := addCardsToTournamentPlayer(ctx, owner, cards, cause)
+	return err
+}
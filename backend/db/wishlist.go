@@ -0,0 +1,132 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/joaquinleonarg/wdml-mtg/backend/domain"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// GetWishlist returns the cards a tournament player is looking for, oldest first
+func GetWishlist(tournamentPlayerID string) ([]domain.WishlistCard, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*20)
+	defer cancel()
+	dbTournamentPlayerID, err := primitive.ObjectIDFromHex(tournamentPlayerID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidID, err)
+	}
+
+	cursor, err := MongoDatabaseClient.
+		Database(DB_MAIN).
+		Collection(COLLECTION_WISHLISTS).
+		Find(ctx,
+			bson.M{"tournament_player_id": dbTournamentPlayerID},
+			options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}}),
+		)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInternal, err)
+	}
+
+	// Decode wishlist
+	wishlist := []domain.WishlistCard{}
+	err = cursor.All(ctx, &wishlist)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInternal, err)
+	}
+	return wishlist, nil
+}
+
+// AddWishlistCard adds a card to a player's wishlist, unless the same card is already on it
+func AddWishlistCard(wishlistCard domain.WishlistCard) (primitive.ObjectID, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*20)
+	defer cancel()
+	if wishlistCard.ID != primitive.NilObjectID {
+		return primitive.NilObjectID, ErrObjectIDProvided
+	}
+	wishlistCard.ID = primitive.NewObjectID()
+	wishlistCard.CreatedAt = primitive.NewDateTimeFromTime(time.Now())
+
+	existing, err := MongoDatabaseClient.
+		Database(DB_MAIN).
+		Collection(COLLECTION_WISHLISTS).
+		CountDocuments(ctx, bson.M{
+			"tournament_player_id": wishlistCard.TournamentPlayerID,
+			"card_name":            wishlistCard.CardName,
+			// Entries stored before set codes were upper cased may be in lower case
+			"set_code": bson.M{"$regex": "^" + regexp.QuoteMeta(wishlistCard.SetCode) + "$", "$options": "i"},
+		})
+	if err != nil {
+		return primitive.NilObjectID, fmt.Errorf("%w: %v", ErrInternal, err)
+	}
+	if existing > 0 {
+		return primitive.NilObjectID, fmt.Errorf("%w: %s is already on the wishlist", ErrAlreadyExists, wishlistCard.CardName)
+	}
+
+	_, err = MongoDatabaseClient.
+		Database(DB_MAIN).
+		Collection(COLLECTION_WISHLISTS).
+		InsertOne(ctx, wishlistCard)
+	if err != nil {
+		return primitive.NilObjectID, fmt.Errorf("%w: %v", ErrInternal, err)
+	}
+	return wishlistCard.ID, nil
+}
+
+// RemoveWishlistCard takes a card out of a player's wishlist
+func RemoveWishlistCard(tournamentPlayerID, wishlistCardID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*20)
+	defer cancel()
+	dbTournamentPlayerID, err := primitive.ObjectIDFromHex(tournamentPlayerID)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidID, err)
+	}
+	dbWishlistCardID, err := primitive.ObjectIDFromHex(wishlistCardID)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidID, err)
+	}
+
+	result, err := MongoDatabaseClient.
+		Database(DB_MAIN).
+		Collection(COLLECTION_WISHLISTS).
+		DeleteOne(ctx, bson.M{"_id": dbWishlistCardID, "tournament_player_id": dbTournamentPlayerID})
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInternal, err)
+	}
+	if result.DeletedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// GetWishlistsForCards returns the wishlist entries of a tournament that want any of the given cards
+func GetWishlistsForCards(tournamentID primitive.ObjectID, cardNames []string) ([]domain.WishlistCard, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*20)
+	defer cancel()
+	if len(cardNames) == 0 {
+		return []domain.WishlistCard{}, nil
+	}
+
+	cursor, err := MongoDatabaseClient.
+		Database(DB_MAIN).
+		Collection(COLLECTION_WISHLISTS).
+		Find(ctx, bson.M{
+			"tournament_id": tournamentID,
+			"card_name":     bson.M{"$in": cardNames},
+		})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInternal, err)
+	}
+
+	// Decode wishlist entries
+	wishlist := []domain.WishlistCard{}
+	err = cursor.All(ctx, &wishlist)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInternal, err)
+	}
+	return wishlist, nil
+}
@@ -0,0 +1,58 @@
+package domain
+
+import "go.mongodb.org/mongo-driver/bson/primitive"
+
+// Auction listings collection. Listed copies are taken out of the seller's collection until the listing ends.
+type AuctionListing struct {
+	ID           primitive.ObjectID `bson:"_id" json:"id"`
+	TournamentID primitive.ObjectID `bson:"tournament_id" json:"tournament_id"`
+	// Tournament player selling the card
+	SellerID primitive.ObjectID `bson:"seller_id" json:"seller_id"`
+	// Owned card the copies were taken from, and a copy of its data
+	OwnedCardID primitive.ObjectID `bson:"owned_card_id" json:"owned_card_id"`
+	Count       int                `bson:"count" json:"count"`
+	CardData    CardData           `bson:"card_data" json:"card_data"`
+	Type        ListingType        `bson:"type" json:"type"`
+	// Price for fixed price listings, starting bid for auctions
+	Price int `bson:"price" json:"price"`
+	// Bids are escrowed: the highest bidder's coins are held until they are outbid or the auction ends
+	HighestBid      int                `bson:"highest_bid" json:"highest_bid"`
+	HighestBidderID primitive.ObjectID `bson:"highest_bidder_id" json:"highest_bidder_id"`
+	Bids            []AuctionBid       `bson:"bids" json:"bids"`
+	Status          ListingStatus      `bson:"status" json:"status"`
+	// Tournament player that got the card, if it was sold
+	BuyerID   primitive.ObjectID `bson:"buyer_id" json:"buyer_id"`
+	EndsAt    primitive.DateTime `bson:"ends_at" json:"ends_at"`
+	CreatedAt primitive.DateTime `bson:"created_at" json:"created_at"`
+	UpdatedAt primitive.DateTime `bson:"updated_at" json:"updated_at"`
+}
+
+type ListingType string
+
+const (
+	ListingTypeFixedPrice ListingType = "lt_fixed_price"
+	ListingTypeAuction    ListingType = "lt_auction"
+)
+
+type ListingStatus string
+
+const (
+	ListingStatusActive    ListingStatus = "ls_active"
+	ListingStatusSold      ListingStatus = "ls_sold"
+	ListingStatusExpired   ListingStatus = "ls_expired"
+	ListingStatusCancelled ListingStatus = "ls_cancelled"
+)
+
+type AuctionBid struct {
+	BidderID  primitive.ObjectID `bson:"bidder_id" json:"bidder_id"`
+	Amount    int                `bson:"amount" json:"amount"`
+	CreatedAt primitive.DateTime `bson:"created_at" json:"created_at"`
+}
+
+// MinimumBid returns the lowest amount the next bid on the listing can have
+func MinimumBid(listing AuctionListing) int {
+	if len(listing.Bids) == 0 {
+		return listing.Price
+	}
+	return listing.HighestBid + 1
+}
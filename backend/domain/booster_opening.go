@@ -0,0 +1,21 @@
+package domain
+
+import "go.mongodb.org/mongo-driver/bson/primitive"
+
+// BoosterOpenings collection. Every pack opened, with what's needed to draw it again.
+type BoosterOpening struct {
+	ID                 primitive.ObjectID `bson:"_id" json:"id"`
+	TournamentID       primitive.ObjectID `bson:"tournament_id" json:"tournament_id"`
+	TournamentPlayerID primitive.ObjectID `bson:"tournament_player_id" json:"tournament_player_id"`
+	SetCode            string             `bson:"set_code" json:"set_code"`
+	// Shared by the openings of a bulk open, and the related ID of its ledger entries
+	BatchID primitive.ObjectID `bson:"batch_id,omitempty" json:"batch_id"`
+	// Key supplied by the client, so a retried request returns this opening instead of opening another pack
+	IdempotencyKey string `bson:"idempotency_key,omitempty" json:"idempotency_key,omitempty"`
+	// Hex encoded seed the cards were drawn with, derived from the server's secret and the opening's ID
+	Seed string `bson:"seed" json:"seed"`
+	// The pack as it was defined when it was opened
+	BoosterPack BoosterPack        `bson:"booster_pack" json:"booster_pack"`
+	Cards       []CardData         `bson:"cards" json:"cards"`
+	CreatedAt   primitive.DateTime `bson:"created_at" json:"created_at"`
+}
@@ -19,9 +19,64 @@ type BoosterPackSlot struct {
 	Options []Option `bson:"options" json:"options"`
 	Filter  string   `bson:"filter" json:"filter"`
 	Count   int      `bson:"count" json:"count"`
+	// Finish of the cards of this slot, non foil if empty
+	Finish CardFinish `bson:"finish,omitempty" json:"finish,omitempty"`
 }
 
 type Option struct {
 	Filter string `bson:"filter" json:"filter"`
 	Weight int    `bson:"weight" json:"weight"`
+	// Overrides the finish of the slot when this option is chosen
+	Finish CardFinish `bson:"finish,omitempty" json:"finish,omitempty"`
+}
+
+// BoosterOdds collection. Pull rates of a set's packs measured by a tournament's administrator, shown to the
+// tournament's players. Deleted when the pack changes.
+type PublishedBoosterOdds struct {
+	ID           primitive.ObjectID `bson:"_id" json:"id"`
+	TournamentID primitive.ObjectID `bson:"tournament_id" json:"tournament_id"`
+	SetCode      string             `bson:"set_code" json:"set_code"`
+	Odds         BoosterOdds        `bson:"odds" json:"odds"`
+}
+
+// BoosterOdds are the pull rates of a booster pack, measured by opening simulated packs
+type BoosterOdds struct {
+	SimulatedPacks int `bson:"simulated_packs" json:"simulated_packs"`
+	// Packs that couldn't have been opened, because a slot had no cards to draw from
+	FailedPacks int `bson:"failed_packs" json:"failed_packs"`
+	// Over the packs that could be opened
+	Rarities    []RarityRate       `bson:"rarities" json:"rarities"`
+	Slots       []BoosterSlotOdds  `bson:"slots" json:"slots"`
+	ExpectedUSD float64            `bson:"expected_usd" json:"expected_usd"`
+	UpdatedAt   primitive.DateTime `bson:"updated_at" json:"updated_at"`
+}
+
+type RarityRate struct {
+	Rarity CardRarity `bson:"rarity" json:"rarity"`
+	Cards  int        `bson:"cards" json:"cards"`
+	// Average cards of the rarity on a pack
+	PerPack float64 `bson:"per_pack" json:"per_pack"`
+	// Share of the cards drawn that had the rarity
+	Chance float64 `bson:"chance" json:"chance"`
+}
+
+type BoosterSlotOdds struct {
+	Filter  string              `bson:"filter" json:"filter"`
+	Count   int                 `bson:"count" json:"count"`
+	Options []BoosterOptionOdds `bson:"options" json:"options"`
+	// Draws of the slot that found no cards
+	EmptyDraws int          `bson:"empty_draws" json:"empty_draws"`
+	Rarities   []RarityRate `bson:"rarities" json:"rarities"`
+}
+
+type BoosterOptionOdds struct {
+	Filter string     `bson:"filter" json:"filter"`
+	Finish CardFinish `bson:"finish,omitempty" json:"finish,omitempty"`
+	Weight int        `bson:"weight" json:"weight"`
+	// Chance of the option being chosen, by its weight
+	Chance     float64 `bson:"chance" json:"chance"`
+	Draws      int     `bson:"draws" json:"draws"`
+	EmptyDraws int     `bson:"empty_draws" json:"empty_draws"`
+	// Draws that asked for a rarity and got another one, because Scryfall had no cards of it
+	RarityFallbacks int `bson:"rarity_fallbacks" json:"rarity_fallbacks"`
 }
@@ -0,0 +1,32 @@
+package domain
+
+import "go.mongodb.org/mongo-driver/bson/primitive"
+
+// Card movements collection. Every time copies of a card enter or leave a player's collection a movement is
+// recorded, so cards can be traced even though owned cards are merged, split and deleted along the way.
+type CardMovement struct {
+	ID                 primitive.ObjectID `bson:"_id" json:"id"`
+	TournamentID       primitive.ObjectID `bson:"tournament_id" json:"tournament_id"`
+	TournamentPlayerID primitive.ObjectID `bson:"tournament_player_id" json:"tournament_player_id"`
+	LedgerCause        `bson:",inline"`
+	CardData           CardData `bson:"card_data" json:"card_data"`
+	// Positive when copies are acquired, negative when they leave the collection
+	Count     int                `bson:"count" json:"count"`
+	CreatedAt primitive.DateTime `bson:"created_at" json:"created_at"`
+}
+
+// GroupCardMovements turns a list of cards into one movement per printing and variant, with the given sign
+func GroupCardMovements(cards []CardData, sign int) []CardMovement {
+	movements := []CardMovement{}
+	indexByVariant := make(map[string]int)
+	for _, card := range cards {
+		variant := CardVariantKey(card)
+		if index, ok := indexByVariant[variant]; ok {
+			movements[index].Count += sign
+			continue
+		}
+		indexByVariant[variant] = len(movements)
+		movements = append(movements, CardMovement{CardData: card, Count: sign})
+	}
+	return movements
+}
@@ -0,0 +1,79 @@
+package domain
+
+import (
+	"math"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// CardPrice is a snapshot of the market prices of a printing on each finish. Prices that aren't known are 0.
+type CardPrice struct {
+	USD       float64            `bson:"usd" json:"usd"`
+	USDFoil   float64            `bson:"usd_foil" json:"usd_foil"`
+	USDEtched float64            `bson:"usd_etched" json:"usd_etched"`
+	EUR       float64            `bson:"eur" json:"eur"`
+	EURFoil   float64            `bson:"eur_foil" json:"eur_foil"`
+	UpdatedAt primitive.DateTime `bson:"updated_at" json:"updated_at"`
+}
+
+// CardPrintingKey identifies a printing, regardless of its finish and language
+func CardPrintingKey(setCode, collectorNumber string) string {
+	return strings.ToUpper(setCode) + "/" + collectorNumber
+}
+
+// CardPriceUSD returns the price of a copy of the card, by its finish. Foils without a price of their own use the
+// non foil one, and the other way around.
+func CardPriceUSD(card CardData) float64 {
+	if card.Price == nil {
+		return 0
+	}
+	card = NormalizeCardVariant(card)
+	switch card.Finish {
+	case CardFinishEtched:
+		return firstPrice(card.Price.USDEtched, card.Price.USDFoil, card.Price.USD)
+	case CardFinishFoil:
+		return firstPrice(card.Price.USDFoil, card.Price.USD)
+	}
+	return firstPrice(card.Price.USD, card.Price.USDFoil, card.Price.USDEtched)
+}
+
+// CardPriceEUR returns the price of a copy of the card in euros, by its finish
+func CardPriceEUR(card CardData) float64 {
+	if card.Price == nil {
+		return 0
+	}
+	card = NormalizeCardVariant(card)
+	if card.Finish != CardFinishNonFoil {
+		return firstPrice(card.Price.EURFoil, card.Price.EUR)
+	}
+	return firstPrice(card.Price.EUR, card.Price.EURFoil)
+}
+
+func firstPrice(prices ...float64) float64 {
+	for _, price := range prices {
+		if price > 0 {
+			return price
+		}
+	}
+	return 0
+}
+
+// CardCoinValue returns the coins a copy of a card is worth on a tournament. Unless the tournament uses price based
+// coin values, or if the card has no price, it's the fixed value of its rarity.
+func CardCoinValue(card CardData, coinValues CoinValues) int {
+	price := CardPriceUSD(card)
+	if !coinValues.PriceBased || price <= 0 {
+		return CoinsForRarity(card.Rarity)
+	}
+	return max(int(math.Round(price*coinValues.CoinsPerUSD)), coinValues.MinimumCoins)
+}
+
+// StoreCoinPrice returns the coins a booster pack costs on a tournament's store. Packs with a price in dollars use
+// it when the tournament has price based coin values.
+func StoreCoinPrice(pack StoreBoosterPack, coinValues CoinValues) int {
+	if !coinValues.PriceBased || pack.USDPrice <= 0 {
+		return pack.CoinPrice
+	}
+	return int(math.Round(pack.USDPrice * coinValues.CoinsPerUSD))
+}
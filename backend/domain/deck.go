@@ -18,10 +18,36 @@ type DeckCard struct {
 	Board       DeckBoard          `bson:"board" json:"board"`
 }
 
+// CopiesInUse returns the copies of an owned card the decks need. Decks share the collection, so it's the most any
+// single deck uses.
+func CopiesInUse(decks []Deck, ownedCardID primitive.ObjectID) int {
+	inUse := 0
+	for _, deck := range decks {
+		used := 0
+		for _, deckCard := range deck.Cards {
+			if deckCard.OwnedCardID == ownedCardID {
+				used += deckCard.Count
+			}
+		}
+		inUse = max(inUse, used)
+	}
+	return inUse
+}
+
 type DeckBoard string
 
 const (
-	MainBoard  MatchResult = "b_mainboard"
-	SideBoard  MatchResult = "b_sideboard"
-	MaybeBoard MatchResult = "b_maybeboard"
+	MainBoard  DeckBoard = "b_mainboard"
+	SideBoard  DeckBoard = "b_sideboard"
+	MaybeBoard DeckBoard = "b_maybeboard"
+	// Commander decks keep their commanders apart from the rest of the deck
+	CommanderBoard DeckBoard = "b_commander"
+)
+
+type DeckFormat string
+
+const (
+	DeckFormatLimited     DeckFormat = "df_limited"
+	DeckFormatConstructed DeckFormat = "df_constructed"
+	DeckFormatCommander   DeckFormat = "df_commander"
 )
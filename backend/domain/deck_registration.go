@@ -0,0 +1,25 @@
+package domain
+
+import "go.mongodb.org/mongo-driver/bson/primitive"
+
+// DeckRegistrations collection
+// A registration is an immutable snapshot of a deck, taken when a player commits to it for a season or match
+type DeckRegistration struct {
+	ID                 primitive.ObjectID `bson:"_id" json:"id"`
+	TournamentID       primitive.ObjectID `bson:"tournament_id" json:"tournament_id"`
+	TournamentPlayerID primitive.ObjectID `bson:"tournament_player_id" json:"tournament_player_id"`
+	DeckID             primitive.ObjectID `bson:"deck_id" json:"deck_id"`
+	SeasonID           primitive.ObjectID `bson:"season_id" json:"season_id"`
+	MatchID            primitive.ObjectID `bson:"match_id" json:"match_id"`
+	Name               string             `bson:"name" json:"name"`
+	Format             DeckFormat         `bson:"format" json:"format"`
+	Cards              []RegisteredCard   `bson:"cards" json:"cards"`
+	CreatedAt          primitive.DateTime `bson:"created_at" json:"created_at"`
+}
+
+type RegisteredCard struct {
+	OwnedCardID primitive.ObjectID `bson:"owned_card_id" json:"owned_card_id"`
+	CardData    CardData           `bson:"card_data" json:"card_data"`
+	Count       int                `bson:"count" json:"count"`
+	Board       DeckBoard          `bson:"board" json:"board"`
+}
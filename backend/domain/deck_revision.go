@@ -0,0 +1,64 @@
+package domain
+
+import "go.mongodb.org/mongo-driver/bson/primitive"
+
+// DeckRevision is a snapshot of a deck's cards after a change, along with what changed
+type DeckRevision struct {
+	ID        primitive.ObjectID `bson:"_id" json:"id"`
+	DeckID    primitive.ObjectID `bson:"deck_id" json:"deck_id"`
+	Number    int                `bson:"number" json:"number"`
+	Reason    DeckRevisionReason `bson:"reason" json:"reason"`
+	Cards     []DeckCard         `bson:"cards" json:"cards"`
+	Changes   []DeckCardChange   `bson:"changes" json:"changes"`
+	CreatedAt primitive.DateTime `bson:"created_at" json:"created_at"`
+}
+
+type DeckRevisionReason string
+
+const (
+	DeckRevisionReasonAddCard    DeckRevisionReason = "drr_add_card"
+	DeckRevisionReasonRemoveCard DeckRevisionReason = "drr_remove_card"
+	DeckRevisionReasonImport     DeckRevisionReason = "drr_import"
+	DeckRevisionReasonRestore    DeckRevisionReason = "drr_restore"
+)
+
+type DeckCardChange struct {
+	OwnedCardID primitive.ObjectID `bson:"owned_card_id" json:"owned_card_id"`
+	Board       DeckBoard          `bson:"board" json:"board"`
+	Before      int                `bson:"before" json:"before"`
+	After       int                `bson:"after" json:"after"`
+}
+
+// DiffDeckCards returns the count changes for every card and board that differs between two versions of a deck
+func DiffDeckCards(before, after []DeckCard) []DeckCardChange {
+	type key struct {
+		ownedCardID primitive.ObjectID
+		board       DeckBoard
+	}
+	keys := []key{}
+	counts := make(map[key]*DeckCardChange)
+	for _, deckCard := range before {
+		k := key{deckCard.OwnedCardID, deckCard.Board}
+		if _, ok := counts[k]; !ok {
+			keys = append(keys, k)
+			counts[k] = &DeckCardChange{OwnedCardID: deckCard.OwnedCardID, Board: deckCard.Board}
+		}
+		counts[k].Before += deckCard.Count
+	}
+	for _, deckCard := range after {
+		k := key{deckCard.OwnedCardID, deckCard.Board}
+		if _, ok := counts[k]; !ok {
+			keys = append(keys, k)
+			counts[k] = &DeckCardChange{OwnedCardID: deckCard.OwnedCardID, Board: deckCard.Board}
+		}
+		counts[k].After += deckCard.Count
+	}
+
+	changes := []DeckCardChange{}
+	for _, k := range keys {
+		if counts[k].Before != counts[k].After {
+			changes = append(changes, *counts[k])
+		}
+	}
+	return changes
+}
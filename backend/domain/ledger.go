@@ -0,0 +1,114 @@
+package domain
+
+import "go.mongodb.org/mongo-driver/bson/primitive"
+
+// Ledger entries collection. Entries are only ever inserted, so a player's balances are the sum of their entries.
+type LedgerEntry struct {
+	ID                 primitive.ObjectID `bson:"_id" json:"id"`
+	TournamentID       primitive.ObjectID `bson:"tournament_id" json:"tournament_id"`
+	TournamentPlayerID primitive.ObjectID `bson:"tournament_player_id" json:"tournament_player_id"`
+	LedgerCause        `bson:",inline"`
+	Resource           LedgerResource `bson:"resource" json:"resource"`
+	// Set code for booster packs, rarity for wildcards
+	Detail    string             `bson:"detail" json:"detail"`
+	Amount    int                `bson:"amount" json:"amount"`
+	CreatedAt primitive.DateTime `bson:"created_at" json:"created_at"`
+}
+
+// LedgerCause is why a player's resources or cards changed
+type LedgerCause struct {
+	// User that made the change, nil for changes made by the server itself, like settling auctions
+	ActorID primitive.ObjectID `bson:"actor_id" json:"actor_id"`
+	Reason  LedgerReason       `bson:"reason" json:"reason"`
+	// Trade, auction listing, booster pack, etc. the change comes from
+	RelatedID primitive.ObjectID `bson:"related_id" json:"related_id"`
+}
+
+type LedgerResource string
+
+const (
+	LedgerResourceCoins            LedgerResource = "lr_coins"
+	LedgerResourceBoosterPacks     LedgerResource = "lr_booster_packs"
+	LedgerResourceWildcards        LedgerResource = "lr_wildcards"
+	LedgerResourceWildcardProgress LedgerResource = "lr_wildcard_progress"
+	LedgerResourcePoints           LedgerResource = "lr_points"
+)
+
+type LedgerReason string
+
+const (
+	LedgerReasonOpeningBalance      LedgerReason = "lre_opening_balance"
+	LedgerReasonAdminGrant          LedgerReason = "lre_admin_grant"
+	LedgerReasonBoosterPurchase     LedgerReason = "lre_booster_purchase"
+	LedgerReasonBoosterDistribution LedgerReason = "lre_booster_distribution"
+	LedgerReasonBoosterOpen         LedgerReason = "lre_booster_open"
+	LedgerReasonDuplicateConversion LedgerReason = "lre_duplicate_conversion"
+	LedgerReasonCollectionImport    LedgerReason = "lre_collection_import"
+	LedgerReasonTradeUp             LedgerReason = "lre_trade_up"
+	LedgerReasonDisenchant          LedgerReason = "lre_disenchant"
+	LedgerReasonTrade               LedgerReason = "lre_trade"
+	LedgerReasonAuctionBid          LedgerReason = "lre_auction_bid"
+	LedgerReasonAuctionRefund       LedgerReason = "lre_auction_refund"
+	LedgerReasonAuctionPurchase     LedgerReason = "lre_auction_purchase"
+	LedgerReasonAuctionSale         LedgerReason = "lre_auction_sale"
+	LedgerReasonAuctionListing      LedgerReason = "lre_auction_listing"
+	LedgerReasonAuctionReturn       LedgerReason = "lre_auction_return"
+)
+
+// LedgerBalance is the amount of a resource a player has
+type LedgerBalance struct {
+	Resource LedgerResource `bson:"resource" json:"resource"`
+	Detail   string         `bson:"detail" json:"detail"`
+	Amount   int            `bson:"amount" json:"amount"`
+}
+
+// ResourceBalances returns the balances the player has stored, as the ledger would derive them
+func ResourceBalances(tournamentPlayer TournamentPlayer) []LedgerBalance {
+	resources := tournamentPlayer.GameResources
+	balances := []LedgerBalance{
+		{Resource: LedgerResourceCoins, Amount: resources.Coins},
+		{Resource: LedgerResourcePoints, Amount: tournamentPlayer.TournamentPoints},
+	}
+	for _, pack := range resources.BoosterPacks {
+		balances = append(balances, LedgerBalance{Resource: LedgerResourceBoosterPacks, Detail: pack.SetCode, Amount: pack.Available})
+	}
+	for _, rarity := range CardRarities {
+		balances = append(balances,
+			LedgerBalance{Resource: LedgerResourceWildcards, Detail: string(rarity), Amount: WildcardCount(resources.Wildcards, rarity)},
+			LedgerBalance{Resource: LedgerResourceWildcardProgress, Detail: string(rarity), Amount: WildcardCount(resources.WildcardProgress, rarity)},
+		)
+	}
+	return balances
+}
+
+// DiffBalances returns how much each balance changed from before to after, leaving out the ones that didn't
+func DiffBalances(before, after []LedgerBalance) []LedgerBalance {
+	type key struct {
+		resource LedgerResource
+		detail   string
+	}
+	amounts := make(map[key]int)
+	keys := []key{}
+	for _, balance := range after {
+		k := key{balance.Resource, balance.Detail}
+		if _, ok := amounts[k]; !ok {
+			keys = append(keys, k)
+		}
+		amounts[k] += balance.Amount
+	}
+	for _, balance := range before {
+		k := key{balance.Resource, balance.Detail}
+		if _, ok := amounts[k]; !ok {
+			keys = append(keys, k)
+		}
+		amounts[k] -= balance.Amount
+	}
+
+	changes := []LedgerBalance{}
+	for _, k := range keys {
+		if amounts[k] != 0 {
+			changes = append(changes, LedgerBalance{Resource: k.resource, Detail: k.detail, Amount: amounts[k]})
+		}
+	}
+	return changes
+}
@@ -0,0 +1,77 @@
+package domain
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiffBalances(t *testing.T) {
+	tests := []struct {
+		name          string
+		before, after []LedgerBalance
+		expected      []LedgerBalance
+	}{
+		{"no balances", nil, nil, []LedgerBalance{}},
+		{
+			"unchanged balances are left out",
+			[]LedgerBalance{{Resource: LedgerResourceCoins, Amount: 10}, {Resource: LedgerResourcePoints, Amount: 3}},
+			[]LedgerBalance{{Resource: LedgerResourceCoins, Amount: 15}, {Resource: LedgerResourcePoints, Amount: 3}},
+			[]LedgerBalance{{Resource: LedgerResourceCoins, Amount: 5}},
+		},
+		{
+			"details are separate balances",
+			[]LedgerBalance{{Resource: LedgerResourceBoosterPacks, Detail: "NEO", Amount: 2}},
+			[]LedgerBalance{{Resource: LedgerResourceBoosterPacks, Detail: "NEO", Amount: 1}, {Resource: LedgerResourceBoosterPacks, Detail: "DMU", Amount: 3}},
+			[]LedgerBalance{{Resource: LedgerResourceBoosterPacks, Detail: "NEO", Amount: -1}, {Resource: LedgerResourceBoosterPacks, Detail: "DMU", Amount: 3}},
+		},
+		{
+			"balances missing after are spent",
+			[]LedgerBalance{{Resource: LedgerResourceWildcards, Detail: string(CardRarityRare), Amount: 2}},
+			nil,
+			[]LedgerBalance{{Resource: LedgerResourceWildcards, Detail: string(CardRarityRare), Amount: -2}},
+		},
+		{
+			"repeated balances add up",
+			[]LedgerBalance{{Resource: LedgerResourceCoins, Amount: 4}, {Resource: LedgerResourceCoins, Amount: 6}},
+			[]LedgerBalance{{Resource: LedgerResourceCoins, Amount: 10}},
+			[]LedgerBalance{},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if changes := DiffBalances(test.before, test.after); !reflect.DeepEqual(changes, test.expected) {
+				t.Errorf("expected %+v, got %+v", test.expected, changes)
+			}
+		})
+	}
+}
+
+func TestResourceBalancesReconcile(t *testing.T) {
+	tournamentPlayer := TournamentPlayer{
+		TournamentPoints: 7,
+		GameResources: GameResources{
+			Coins:            120,
+			BoosterPacks:     []OwnedBoosterPack{{SetCode: "NEO", Available: 2}},
+			Wildcards:        OwnedWildcards{RareCount: 1},
+			WildcardProgress: OwnedWildcards{CommonCount: 4},
+		},
+	}
+
+	// A player without ledger entries gets an opening balance for every resource they have
+	expected := []LedgerBalance{
+		{Resource: LedgerResourceCoins, Amount: 120},
+		{Resource: LedgerResourcePoints, Amount: 7},
+		{Resource: LedgerResourceBoosterPacks, Detail: "NEO", Amount: 2},
+		{Resource: LedgerResourceWildcardProgress, Detail: string(CardRarityCommon), Amount: 4},
+		{Resource: LedgerResourceWildcards, Detail: string(CardRarityRare), Amount: 1},
+	}
+	balances := ResourceBalances(tournamentPlayer)
+	if changes := DiffBalances(nil, balances); !reflect.DeepEqual(changes, expected) {
+		t.Errorf("expected %+v, got %+v", expected, changes)
+	}
+
+	// And once the ledger matches the stored resources there's nothing left to reconcile
+	if changes := DiffBalances(expected, balances); len(changes) != 0 {
+		t.Errorf("expected no changes, got %+v", changes)
+	}
+}
@@ -17,6 +17,7 @@ type MatchPlayerData struct {
 	TournamentPlayerID primitive.ObjectID `bson:"tournament_player_id" json:"tournament_player_id"`
 	Wins               int                `bson:"wins" json:"wins"`
 	Tags               []string           `bson:"tags" json:"tags"`
+	DeckRegistrationID primitive.ObjectID `bson:"deck_registration_id" json:"deck_registration_id"`
 }
 
 type Gamemode string
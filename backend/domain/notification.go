@@ -0,0 +1,30 @@
+package domain
+
+import "go.mongodb.org/mongo-driver/bson/primitive"
+
+// Notifications collection
+type Notification struct {
+	ID                 primitive.ObjectID `bson:"_id" json:"id"`
+	TournamentID       primitive.ObjectID `bson:"tournament_id" json:"tournament_id"`
+	TournamentPlayerID primitive.ObjectID `bson:"tournament_player_id" json:"tournament_player_id"`
+	Type               NotificationType   `bson:"type" json:"type"`
+	// Set for nt_wishlist_pull notifications
+	WishlistPull *NotificationWishlistPull `bson:"wishlist_pull,omitempty" json:"wishlist_pull,omitempty"`
+	Read         bool                      `bson:"read" json:"read"`
+	CreatedAt    primitive.DateTime        `bson:"created_at" json:"created_at"`
+}
+
+type NotificationType string
+
+const (
+	// Someone else pulled a card on the player's wishlist
+	NotificationTypeWishlistPull NotificationType = "nt_wishlist_pull"
+)
+
+type NotificationWishlistPull struct {
+	WishlistCardID primitive.ObjectID `bson:"wishlist_card_id" json:"wishlist_card_id"`
+	// Tournament player that pulled the card
+	PullerID primitive.ObjectID `bson:"puller_id" json:"puller_id"`
+	Username string             `bson:"username" json:"username"`
+	Card     CardData           `bson:"card" json:"card"`
+}
@@ -23,8 +23,74 @@ type CardData struct {
 	ManaValue       int        `bson:"mana_value" json:"mana_value"`
 	ManaCost        string     `bson:"mana_cost" json:"mana_cost"`
 	Colors          []string   `bson:"colors" json:"colors"`
+	ColorIdentity   []string   `bson:"color_identity" json:"color_identity"`
 	ImageURL        string     `bson:"image_url" json:"image_url"`
 	BackImageURL    string     `bson:"back_image_url" json:"back_image_url"`
+	// Every face of double-faced, split, adventure and flip cards, front first. Empty for single faced cards.
+	Faces []CardFace `bson:"faces" json:"faces"`
+	// Copies of the same printing with a different finish or language are different cards
+	Finish       CardFinish `bson:"finish" json:"finish"`
+	Language     string     `bson:"language" json:"language"`
+	FrameEffects []string   `bson:"frame_effects" json:"frame_effects"`
+	Promo        bool       `bson:"promo" json:"promo"`
+	// Refreshed from Scryfall's bulk data, empty if the printing has no price
+	Price *CardPrice `bson:"price,omitempty" json:"price,omitempty"`
+}
+
+type CardFace struct {
+	Name     string   `bson:"name" json:"name"`
+	ManaCost string   `bson:"mana_cost" json:"mana_cost"`
+	TypeLine string   `bson:"type_line" json:"type_line"`
+	Types    []string `bson:"types" json:"types"`
+	Oracle   string   `bson:"oracle" json:"oracle"`
+	// Empty when all faces share the card's image, like split and adventure cards
+	ImageURL string `bson:"image_url" json:"image_url"`
+}
+
+// CardFaces returns the faces of a card. Single faced cards, and cards stored before faces were tracked, have a
+// single face made from the card itself.
+func CardFaces(card CardData) []CardFace {
+	if len(card.Faces) > 0 {
+		return card.Faces
+	}
+	return []CardFace{{
+		Name:     card.Name,
+		ManaCost: card.ManaCost,
+		Types:    card.Types,
+		Oracle:   card.Oracle,
+		ImageURL: card.ImageURL,
+	}}
+}
+
+type CardFinish string
+
+const (
+	CardFinishNonFoil CardFinish = "nonfoil"
+	CardFinishFoil    CardFinish = "foil"
+	CardFinishEtched  CardFinish = "etched"
+)
+
+var CardFinishes = []CardFinish{CardFinishNonFoil, CardFinishFoil, CardFinishEtched}
+
+// Language of the cards that don't have one
+const DEFAULT_CARD_LANGUAGE = "en"
+
+// NormalizeCardVariant fills the finish and language of cards stored before they were tracked, which are non foil
+// English copies
+func NormalizeCardVariant(card CardData) CardData {
+	if card.Finish == "" {
+		card.Finish = CardFinishNonFoil
+	}
+	if card.Language == "" {
+		card.Language = DEFAULT_CARD_LANGUAGE
+	}
+	return card
+}
+
+// CardVariantKey identifies the printing, finish and language of a card. Copies with the same key are merged.
+func CardVariantKey(card CardData) string {
+	card = NormalizeCardVariant(card)
+	return card.SetCode + "/" + card.CollectorNumber + "/" + string(card.Finish) + "/" + card.Language
 }
 
 type CardRarity string
@@ -36,3 +102,15 @@ const (
 	CardRarityMythic   CardRarity = "mythic"
 	CardRaritySpecial  CardRarity = "special"
 )
+
+// CardRarities has every rarity, from the most common to the rarest
+var CardRarities = []CardRarity{CardRarityCommon, CardRarityUncommon, CardRarityRare, CardRarityMythic, CardRaritySpecial}
+
+// TagCount is how many cards of a collection have a tag
+type TagCount struct {
+	Tag string `bson:"_id" json:"tag"`
+	// Different cards with the tag
+	Cards int `bson:"cards" json:"cards"`
+	// Copies of those cards
+	Copies int `bson:"copies" json:"copies"`
+}
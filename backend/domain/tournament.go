@@ -11,15 +11,53 @@ type Tournament struct {
 	Name            string             `bson:"name" json:"name"`
 	Description     string             `bson:"description" json:"description"`
 	Store           Store              `bson:"store" json:"store"`
-	CreatedAt       primitive.DateTime `bson:"created_at" json:"created_at"`
-	UpdatedAt       primitive.DateTime `bson:"updated_at" json:"updated_at"`
+	DeckRules       DeckRules          `bson:"deck_rules" json:"deck_rules"`
+	// Extra copies of a card are converted into coins when granted
+	DuplicateProtection DuplicateProtection `bson:"duplicate_protection" json:"duplicate_protection"`
+	TradeRules          TradeRules          `bson:"trade_rules" json:"trade_rules"`
+	// How many coins cards and booster packs are worth
+	CoinValues CoinValues `bson:"coin_values" json:"coin_values"`
+	// Ways players can trade up their cards. The default recipe is used if empty.
+	TradeUpRecipes []TradeUpRecipe    `bson:"trade_up_recipes" json:"trade_up_recipes"`
+	CreatedAt      primitive.DateTime `bson:"created_at" json:"created_at"`
+	UpdatedAt      primitive.DateTime `bson:"updated_at" json:"updated_at"`
 }
 
 type Store struct {
 	BoosterPacks []StoreBoosterPack `bson:"booster_packs" json:"booster_packs"`
 }
 
+type DeckRules struct {
+	// Format used to validate decks when none is specified
+	Format DeckFormat `bson:"format" json:"format"`
+	// Names of the cards that can't be played in this tournament
+	Banlist []string `bson:"banlist" json:"banlist"`
+}
+
+type DuplicateProtection struct {
+	Enabled bool `bson:"enabled" json:"enabled"`
+	// Copies of a card, by name, a player can have before the rest are converted into coins. Cards a deck can have any
+	// number of, like basic lands, are never converted.
+	MaxCopies int `bson:"max_copies" json:"max_copies"`
+}
+
+type TradeRules struct {
+	// Trades have to be approved by a moderator after being accepted
+	RequireApproval bool `bson:"require_approval" json:"require_approval"`
+}
+
+type CoinValues struct {
+	// Value cards by their market price instead of by their rarity
+	PriceBased bool `bson:"price_based" json:"price_based"`
+	// Coins a card worth one US dollar is valued at
+	CoinsPerUSD float64 `bson:"coins_per_usd" json:"coins_per_usd"`
+	// Coins a card with a price is valued at least
+	MinimumCoins int `bson:"minimum_coins" json:"minimum_coins"`
+}
+
 type StoreBoosterPack struct {
 	BoosterPackID primitive.ObjectID `bson:"booster_pack_id" json:"booster_pack_id"`
 	CoinPrice     int                `bson:"coin_price" json:"coin_price"`
+	// Used instead of the coin price if the tournament has price based coin values
+	USDPrice float64 `bson:"usd_price" json:"usd_price"`
 }
@@ -1,6 +1,10 @@
 package domain
 
-import "go.mongodb.org/mongo-driver/bson/primitive"
+import (
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
 
 // TournamentPlayers collection
 type TournamentPlayer struct {
@@ -10,6 +14,7 @@ type TournamentPlayer struct {
 	AccessLevel      AccessLevel        `bson:"access_level" json:"access_level"`
 	GameResources    GameResources      `bson:"game_resources" json:"game_resources"`
 	TournamentPoints int                `bson:"tournament_points" json:"tournament_points"`
+	Privacy          PrivacySettings    `bson:"privacy" json:"privacy"`
 	CreatedAt        primitive.DateTime `bson:"created_at" json:"created_at"`
 	UpdatedAt        primitive.DateTime `bson:"updated_at" json:"updated_at"`
 }
@@ -22,12 +27,100 @@ const (
 	AccessLevelAdministrator AccessLevel = "al_administrator"
 )
 
+// PrivacySettings decide who can see what a player has. Moderators can always see everything.
+type PrivacySettings struct {
+	Collection CollectionVisibility `bson:"collection" json:"collection"`
+	Decks      DeckVisibility       `bson:"decks" json:"decks"`
+	// Coins are shown as 0 to everyone else
+	HideCoins bool `bson:"hide_coins" json:"hide_coins"`
+}
+
+type CollectionVisibility string
+
+const (
+	// Anyone can see the collection, even from outside the tournament
+	CollectionVisibilityPublic CollectionVisibility = "cv_public"
+	// Only players of the tournament can see the collection. Used when not set.
+	CollectionVisibilityTournament CollectionVisibility = "cv_tournament"
+	// Only the owner can see the collection
+	CollectionVisibilityPrivate CollectionVisibility = "cv_private"
+)
+
+type DeckVisibility string
+
+const (
+	// Anyone can see the decks. Used when not set.
+	DeckVisibilityPublic DeckVisibility = "dv_public"
+	// Only the owner can see the decks
+	DeckVisibilityPrivate DeckVisibility = "dv_private"
+)
+
+// ValidatePrivacySettings checks the settings only use known visibilities
+func ValidatePrivacySettings(privacy PrivacySettings) error {
+	switch privacy.Collection {
+	case "", CollectionVisibilityPublic, CollectionVisibilityTournament, CollectionVisibilityPrivate:
+	default:
+		return fmt.Errorf("unknown collection visibility %s", privacy.Collection)
+	}
+	switch privacy.Decks {
+	case "", DeckVisibilityPublic, DeckVisibilityPrivate:
+	default:
+		return fmt.Errorf("unknown deck visibility %s", privacy.Decks)
+	}
+	return nil
+}
+
+// seesEverything returns whether the viewer is the owner, or moderates the owner's tournament
+func seesEverything(owner TournamentPlayer, viewer *TournamentPlayer) bool {
+	if viewer == nil || viewer.TournamentID != owner.TournamentID {
+		return false
+	}
+	return viewer.ID == owner.ID || viewer.AccessLevel == AccessLevelModerator || viewer.AccessLevel == AccessLevelAdministrator
+}
+
+// CanSeeCollection returns whether a viewer can see the owner's collection. The viewer is nil if they don't play the
+// owner's tournament.
+func CanSeeCollection(owner TournamentPlayer, viewer *TournamentPlayer) bool {
+	if seesEverything(owner, viewer) {
+		return true
+	}
+	switch owner.Privacy.Collection {
+	case CollectionVisibilityPublic:
+		return true
+	case CollectionVisibilityPrivate:
+		return false
+	}
+	return viewer != nil && viewer.TournamentID == owner.TournamentID
+}
+
+// CanSeeDecks returns whether a viewer can see the owner's decks. The viewer is nil if they don't play the owner's
+// tournament.
+func CanSeeDecks(owner TournamentPlayer, viewer *TournamentPlayer) bool {
+	return seesEverything(owner, viewer) || owner.Privacy.Decks != DeckVisibilityPrivate
+}
+
+// VisibleTournamentPlayer returns the player as the viewer can see them, without what their privacy settings hide
+func VisibleTournamentPlayer(owner TournamentPlayer, viewer *TournamentPlayer) TournamentPlayer {
+	if seesEverything(owner, viewer) {
+		return owner
+	}
+	if owner.Privacy.HideCoins {
+		owner.GameResources.Coins = 0
+	}
+	if !CanSeeDecks(owner, viewer) {
+		owner.GameResources.Decks = []Deck{}
+	}
+	return owner
+}
+
 type GameResources struct {
 	Decks        []Deck             `bson:"decks" json:"decks"`
 	Wildcards    OwnedWildcards     `bson:"wildcards" json:"wildcards"`
 	BoosterPacks []OwnedBoosterPack `bson:"booster_packs" json:"booster_packs"`
 	Rerolls      int                `bson:"rerolls" json:"rerolls"`
 	Coins        int                `bson:"coins" json:"coins"`
+	// Cards disenchanted towards the next wildcard of each rarity
+	WildcardProgress OwnedWildcards `bson:"wildcard_progress" json:"wildcard_progress"`
 }
 
 const (
@@ -38,6 +131,23 @@ const (
 	COMMON_TO_COIN   = 1
 )
 
+// CoinsForRarity returns the coins a single duplicated card of the given rarity is converted into
+func CoinsForRarity(rarity CardRarity) int {
+	switch rarity {
+	case CardRaritySpecial:
+		return SPECIAL_TO_COIN
+	case CardRarityMythic:
+		return MYTHIC_TO_COIN
+	case CardRarityRare:
+		return RARE_TO_COIN
+	case CardRarityUncommon:
+		return UNCOMMON_TO_COIN
+	case CardRarityCommon:
+		return COMMON_TO_COIN
+	}
+	return 0
+}
+
 type OwnedWildcards struct {
 	// By rarity
 	CommonCount     int `bson:"common_count" json:"common_count"`
@@ -49,6 +159,43 @@ type OwnedWildcards struct {
 	MasterpieceCount int `bson:"masterpiece_count" json:"masterpiece_count"`
 }
 
+// Cards of a rarity that have to be disenchanted to get a wildcard of that rarity
+const DISENCHANTS_PER_WILDCARD = 5
+
+// AddWildcards returns the wildcards with count more of the given rarity. Special cards use masterpiece wildcards.
+func AddWildcards(wildcards OwnedWildcards, rarity CardRarity, count int) OwnedWildcards {
+	switch rarity {
+	case CardRarityCommon:
+		wildcards.CommonCount += count
+	case CardRarityUncommon:
+		wildcards.UncommonCount += count
+	case CardRarityRare:
+		wildcards.RareCount += count
+	case CardRarityMythic:
+		wildcards.MythicRareCount += count
+	case CardRaritySpecial:
+		wildcards.MasterpieceCount += count
+	}
+	return wildcards
+}
+
+// WildcardCount returns the wildcards of the given rarity
+func WildcardCount(wildcards OwnedWildcards, rarity CardRarity) int {
+	switch rarity {
+	case CardRarityCommon:
+		return wildcards.CommonCount
+	case CardRarityUncommon:
+		return wildcards.UncommonCount
+	case CardRarityRare:
+		return wildcards.RareCount
+	case CardRarityMythic:
+		return wildcards.MythicRareCount
+	case CardRaritySpecial:
+		return wildcards.MasterpieceCount
+	}
+	return 0
+}
+
 type OwnedBoosterPack struct {
 	Available   int    `bson:"available" json:"available"`
 	SetCode     string `bson:"set_code" json:"set_code"`
@@ -0,0 +1,47 @@
+package domain
+
+import "go.mongodb.org/mongo-driver/bson/primitive"
+
+// Trades collection
+type Trade struct {
+	ID           primitive.ObjectID `bson:"_id" json:"id"`
+	TournamentID primitive.ObjectID `bson:"tournament_id" json:"tournament_id"`
+	// Tournament players on each side of the trade
+	ProposerID  primitive.ObjectID `bson:"proposer_id" json:"proposer_id"`
+	RecipientID primitive.ObjectID `bson:"recipient_id" json:"recipient_id"`
+	// What each side gives
+	ProposerOffer  TradeOffer  `bson:"proposer_offer" json:"proposer_offer"`
+	RecipientOffer TradeOffer  `bson:"recipient_offer" json:"recipient_offer"`
+	Status         TradeStatus `bson:"status" json:"status"`
+	// Trade this one is a counter offer of
+	CounteredTradeID primitive.ObjectID `bson:"countered_trade_id" json:"countered_trade_id"`
+	// Moderator that approved or rejected the trade, if the tournament requires approval
+	ReviewerID primitive.ObjectID `bson:"reviewer_id" json:"reviewer_id"`
+	CreatedAt  primitive.DateTime `bson:"created_at" json:"created_at"`
+	UpdatedAt  primitive.DateTime `bson:"updated_at" json:"updated_at"`
+}
+
+type TradeStatus string
+
+const (
+	TradeStatusPending          TradeStatus = "ts_pending"
+	TradeStatusAwaitingApproval TradeStatus = "ts_awaiting_approval"
+	TradeStatusCompleted        TradeStatus = "ts_completed"
+	TradeStatusDeclined         TradeStatus = "ts_declined"
+	TradeStatusCountered        TradeStatus = "ts_countered"
+	TradeStatusCancelled        TradeStatus = "ts_cancelled"
+	TradeStatusRejected         TradeStatus = "ts_rejected"
+)
+
+type TradeOffer struct {
+	Cards        []TradeCard        `bson:"cards" json:"cards"`
+	Coins        int                `bson:"coins" json:"coins"`
+	BoosterPacks []OwnedBoosterPack `bson:"booster_packs" json:"booster_packs"`
+}
+
+type TradeCard struct {
+	OwnedCardID primitive.ObjectID `bson:"owned_card_id" json:"owned_card_id"`
+	Count       int                `bson:"count" json:"count"`
+	// Copy of the card's data, so the trade can be shown after the card changes hands
+	CardData CardData `bson:"card_data" json:"card_data"`
+}
@@ -0,0 +1,147 @@
+package domain
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+)
+
+// TradeUpRecipe describes which cards a player can trade in, and what they get for them
+type TradeUpRecipe struct {
+	// Chosen by the tournament's administrators, unique within the tournament
+	ID          string `bson:"id" json:"id"`
+	Name        string `bson:"name" json:"name"`
+	Description string `bson:"description" json:"description"`
+	// Cards that have to be traded in
+	InputCount int `bson:"input_count" json:"input_count"`
+	// Rarities the traded cards can have. Any rarity is allowed if empty.
+	InputRarities []CardRarity `bson:"input_rarities" json:"input_rarities"`
+	// Cards received
+	OutputCount int `bson:"output_count" json:"output_count"`
+	// Odds of each rarity on the received cards. If empty, rarities are weighted by the traded cards.
+	OutputRarities []RarityWeight `bson:"output_rarities" json:"output_rarities"`
+	// Sets the received cards can come from, all equally likely. If empty, sets are weighted by the traded cards.
+	SetPool []string `bson:"set_pool" json:"set_pool"`
+	// Scryfall filter every received card has to match
+	Filter string `bson:"filter" json:"filter"`
+}
+
+type RarityWeight struct {
+	Rarity CardRarity `bson:"rarity" json:"rarity"`
+	Weight int        `bson:"weight" json:"weight"`
+}
+
+// TradeUpOdds is the chance of a received card being of a set and rarity. An empty set or rarity stands for the
+// ones of the traded cards, weighted by how many of them there are.
+type TradeUpOdds struct {
+	SetCode string     `bson:"set_code" json:"set_code"`
+	Rarity  CardRarity `bson:"rarity" json:"rarity"`
+	Weight  int        `bson:"weight" json:"weight"`
+	Chance  float64    `bson:"chance" json:"chance"`
+}
+
+// DefaultTradeUpRecipe is used by tournaments that don't define their own recipes
+var DefaultTradeUpRecipe = TradeUpRecipe{
+	ID:          "default",
+	Name:        "30 cards for 5",
+	Description: "Trade 30 cards for 5 from the same sets and rarities, more likely the more of them were traded",
+	InputCount:  30,
+	OutputCount: 5,
+	Filter:      "-type:basic",
+}
+
+// TournamentTradeUpRecipes returns the recipes available on a tournament
+func TournamentTradeUpRecipes(tournament Tournament) []TradeUpRecipe {
+	if len(tournament.TradeUpRecipes) == 0 {
+		return []TradeUpRecipe{DefaultTradeUpRecipe}
+	}
+	return tournament.TradeUpRecipes
+}
+
+// ValidateTradeUpRecipe checks that a recipe can be used to trade up cards
+func ValidateTradeUpRecipe(recipe TradeUpRecipe) error {
+	if recipe.ID == "" {
+		return fmt.Errorf("recipe has no id")
+	}
+	if recipe.InputCount < 1 || recipe.OutputCount < 1 {
+		return fmt.Errorf("recipe %s has to take and give at least one card", recipe.ID)
+	}
+	for _, rarity := range recipe.InputRarities {
+		if !slices.Contains(CardRarities, rarity) {
+			return fmt.Errorf("recipe %s takes an unknown rarity %s", recipe.ID, rarity)
+		}
+	}
+	totalWeight := 0
+	for _, rarityWeight := range recipe.OutputRarities {
+		if !slices.Contains(CardRarities, rarityWeight.Rarity) {
+			return fmt.Errorf("recipe %s gives an unknown rarity %s", recipe.ID, rarityWeight.Rarity)
+		}
+		if rarityWeight.Weight < 0 {
+			return fmt.Errorf("recipe %s has a negative weight", recipe.ID)
+		}
+		totalWeight += rarityWeight.Weight
+	}
+	if len(recipe.OutputRarities) > 0 && totalWeight == 0 {
+		return fmt.Errorf("recipe %s can't give any rarity", recipe.ID)
+	}
+	for _, setCode := range recipe.SetPool {
+		if setCode == "" {
+			return fmt.Errorf("recipe %s has an empty set on its pool", recipe.ID)
+		}
+	}
+	return nil
+}
+
+// CalculateTradeUpOdds returns the odds of the cards a recipe gives. The weights of the traded cards by set and by
+// rarity are only used when the recipe doesn't fix them; if they are nil, the odds are left relative to the traded
+// cards, with an empty set or rarity.
+func CalculateTradeUpOdds(recipe TradeUpRecipe, weightBySet map[string]int, weightByRarity map[CardRarity]int) []TradeUpOdds {
+	setWeights := []TradeUpOdds{}
+	switch {
+	case len(recipe.SetPool) > 0:
+		for _, setCode := range recipe.SetPool {
+			setWeights = append(setWeights, TradeUpOdds{SetCode: setCode, Weight: 1})
+		}
+	case weightBySet == nil:
+		setWeights = append(setWeights, TradeUpOdds{Weight: 1})
+	default:
+		for setCode, weight := range weightBySet {
+			setWeights = append(setWeights, TradeUpOdds{SetCode: setCode, Weight: weight})
+		}
+	}
+
+	rarityWeights := []TradeUpOdds{}
+	switch {
+	case len(recipe.OutputRarities) > 0:
+		for _, rarityWeight := range recipe.OutputRarities {
+			rarityWeights = append(rarityWeights, TradeUpOdds{Rarity: rarityWeight.Rarity, Weight: rarityWeight.Weight})
+		}
+	case weightByRarity == nil:
+		rarityWeights = append(rarityWeights, TradeUpOdds{Weight: 1})
+	default:
+		for rarity, weight := range weightByRarity {
+			rarityWeights = append(rarityWeights, TradeUpOdds{Rarity: rarity, Weight: weight})
+		}
+	}
+
+	odds := make([]TradeUpOdds, 0, len(setWeights)*len(rarityWeights))
+	totalWeight := 0
+	for _, set := range setWeights {
+		for _, rarity := range rarityWeights {
+			weight := set.Weight * rarity.Weight
+			if weight <= 0 {
+				continue
+			}
+			odds = append(odds, TradeUpOdds{SetCode: set.SetCode, Rarity: rarity.Rarity, Weight: weight})
+			totalWeight += weight
+		}
+	}
+	for i := range odds {
+		odds[i].Chance = float64(odds[i].Weight) / float64(totalWeight)
+	}
+
+	slices.SortFunc(odds, func(a, b TradeUpOdds) int {
+		return cmp.Or(cmp.Compare(b.Weight, a.Weight), cmp.Compare(a.SetCode, b.SetCode), cmp.Compare(a.Rarity, b.Rarity))
+	})
+	return odds
+}
@@ -0,0 +1,28 @@
+package domain
+
+import (
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Wishlists collection. Each document is a card a player is looking for.
+type WishlistCard struct {
+	ID                 primitive.ObjectID `bson:"_id" json:"id"`
+	TournamentID       primitive.ObjectID `bson:"tournament_id" json:"tournament_id"`
+	TournamentPlayerID primitive.ObjectID `bson:"tournament_player_id" json:"tournament_player_id"`
+	// Scryfall's name for the card, so it matches the name of pulled cards
+	CardName string `bson:"card_name" json:"card_name"`
+	// Upper case, like the set codes of pulled cards. Any printing of the card is wanted if empty.
+	SetCode   string             `bson:"set_code" json:"set_code"`
+	Note      string             `bson:"note" json:"note"`
+	CreatedAt primitive.DateTime `bson:"created_at" json:"created_at"`
+}
+
+// WishlistMatches returns whether a card is the one wanted by a wishlist entry
+func WishlistMatches(wishlistCard WishlistCard, card CardData) bool {
+	if wishlistCard.CardName != card.Name {
+		return false
+	}
+	return wishlistCard.SetCode == "" || strings.EqualFold(wishlistCard.SetCode, card.SetCode)
+}
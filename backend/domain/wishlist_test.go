@@ -0,0 +1,26 @@
+package domain
+
+import "testing"
+
+func TestWishlistMatches(t *testing.T) {
+	tests := []struct {
+		name         string
+		wishlistCard WishlistCard
+		card         CardData
+		expected     bool
+	}{
+		{"any printing", WishlistCard{CardName: "Opt"}, CardData{Name: "Opt", SetCode: "XLN"}, true},
+		{"same set", WishlistCard{CardName: "Opt", SetCode: "XLN"}, CardData{Name: "Opt", SetCode: "XLN"}, true},
+		{"set code case", WishlistCard{CardName: "Opt", SetCode: "XLN"}, CardData{Name: "Opt", SetCode: "xln"}, true},
+		{"other set", WishlistCard{CardName: "Opt", SetCode: "XLN"}, CardData{Name: "Opt", SetCode: "DOM"}, false},
+		{"other card", WishlistCard{CardName: "Opt"}, CardData{Name: "Shock", SetCode: "XLN"}, false},
+		{"double faced card", WishlistCard{CardName: "Fire // Ice"}, CardData{Name: "Fire // Ice", SetCode: "MH2"}, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if matches := WishlistMatches(test.wishlistCard, test.card); matches != test.expected {
+				t.Errorf("expected %v, got %v", test.expected, matches)
+			}
+		})
+	}
+}
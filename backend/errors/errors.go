@@ -11,10 +11,16 @@ var (
 	ErrUnauthorized       = fmt.Errorf("UNAUTHORIZED")
 	ErrNoData             = fmt.Errorf("NO_DATA")
 	ErrBadRequest         = fmt.Errorf("BAD_REQUEST")
+	ErrNotEnough          = fmt.Errorf("NOT_ENOUGH_RESOURCES")
+	ErrInvalidState       = fmt.Errorf("INVALID_STATE")
 
 	// Auth
 	ErrUsernameInvalid = fmt.Errorf("USERNAME_INVALID")
 	ErrPasswordWeak    = fmt.Errorf("PASSWORD_WEAK")
 	ErrPasswordTooLong = fmt.Errorf("PASSWORD_LONG")
 	ErrEmailInvalid    = fmt.Errorf("EMAIL_INVALID")
+
+	// Decks
+	ErrInvalidDeck = fmt.Errorf("INVALID_DECK")
+	ErrCardsInDeck = fmt.Errorf("CARDS_IN_DECK")
 )
@@ -2,9 +2,9 @@ package boostergen
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
-	"math"
-	"math/rand"
+	"math/rand/v2"
 	"os"
 	"path/filepath"
 	"strings"
@@ -20,7 +20,12 @@ type CardListsBySet map[string][]scryfallapi.Card
 
 type BoosterDataGetter func(setCode string) (*domain.BoosterPack, error)
 
-func GenerateBooster(setCode string, genFunc BoosterDataGetter) ([]domain.CardData, error) {
+// ErrNoCards is returned when a slot of a pack has no cards to draw from
+var ErrNoCards = errors.New("no cards error")
+
+// GenerateBooster draws the cards of a pack. The same generator state and pack always give the same cards, as long
+// as Scryfall returns the same cards for each filter.
+func GenerateBooster(setCode string, genFunc BoosterDataGetter, rng *rand.Rand) ([]domain.CardData, error) {
 	boosterData, err := genFunc(setCode)
 	if err != nil {
 		return nil, err
@@ -28,60 +33,72 @@ func GenerateBooster(setCode string, genFunc BoosterDataGetter) ([]domain.CardDa
 	boosterPack := make([]domain.CardData, 0, boosterData.CardCount)
 
 	for _, slot := range boosterData.Slots {
-		optionKeys := []int{}
-		optionsByWeight := make(map[int]domain.Option)
-		currentWeight := 0
-		for _, option := range slot.Options {
-			currentWeight += option.Weight
-			optionsByWeight[currentWeight] = option
-			optionKeys = append(optionKeys, currentWeight)
-		}
 		for i := 0; i < slot.Count; i++ {
-			chosenOption := domain.Option{}
-			if currentWeight > 0 {
-				chosenWeight := rand.Int() % currentWeight
-				for _, w := range optionKeys {
-					if chosenWeight < w {
-						chosenOption = optionsByWeight[w]
-						break
-					}
-				}
+			draw, err := drawSlotCard(boosterData, slot, rng)
+			if err != nil {
+				log.Debug().Str("set", setCode).Str("filter", draw.Filter).Err(err).Msg("failed to generate booster pack")
+				return nil, err
 			}
+			boosterPack = append(boosterPack, draw.Card)
+		}
+	}
 
-			filter := fmt.Sprintf("%s %s %s", boosterData.Filter, slot.Filter, chosenOption.Filter)
+	return boosterPack, nil
+}
 
-			cards, err := scryfall.GetAllCardsByFilter(filter)
-			if err != nil || len(cards) == 0 {
-				log.Debug().Str("set", setCode).Str("filter", filter).Err(err).Msg("failed to generate booster pack")
-				return nil, fmt.Errorf("no cards error")
-			}
-			card := cards[rand.Int()%len(cards)]
+// slotDraw is a card drawn for a slot, and how it was chosen
+type slotDraw struct {
+	Card domain.CardData
+	// Index of the chosen option of the slot, -1 if the slot has none
+	OptionIndex int
+	Filter      string
+}
 
-			colors := []string{}
-			for _, col := range card.Colors {
-				colors = append(colors, string(col))
+// drawSlotCard picks one of the slot's options by weight, and a card among the ones matching it
+func drawSlotCard(boosterData *domain.BoosterPack, slot domain.BoosterPackSlot, rng *rand.Rand) (slotDraw, error) {
+	draw := slotDraw{OptionIndex: -1}
+	totalWeight := 0
+	for _, option := range slot.Options {
+		totalWeight += option.Weight
+	}
+	chosenOption := domain.Option{}
+	if totalWeight > 0 {
+		chosenWeight := rng.IntN(totalWeight)
+		currentWeight := 0
+		for index, option := range slot.Options {
+			currentWeight += option.Weight
+			if chosenWeight < currentWeight {
+				chosenOption = option
+				draw.OptionIndex = index
+				break
 			}
-
-			cardFront, cardBack := scryfall.GetImageFromFaces(card)
-			boosterPack = append(boosterPack,
-				domain.CardData{
-					SetCode:         strings.ToUpper(card.Set),
-					CollectorNumber: card.CollectorNumber,
-					Name:            card.Name,
-					Oracle:          card.OracleText,
-					Rarity:          domain.CardRarity(card.Rarity),
-					Types:           scryfall.ParseScryfallTypeline(card.TypeLine),
-					ManaValue:       int(math.Floor(card.CMC)),
-					ManaCost:        card.ManaCost,
-					Colors:          colors,
-					ImageURL:        cardFront,
-					BackImageURL:    cardBack,
-				},
-			)
 		}
 	}
 
-	return boosterPack, nil
+	filter := fmt.Sprintf("%s %s %s", boosterData.Filter, slot.Filter, chosenOption.Filter)
+	finish := slot.Finish
+	if chosenOption.Finish != "" {
+		finish = chosenOption.Finish
+	}
+	if finish == domain.CardFinishFoil || finish == domain.CardFinishEtched {
+		filter = fmt.Sprintf("%s is:%s", filter, finish)
+	}
+	draw.Filter = filter
+
+	cards, err := scryfall.GetAllCardsByFilter(filter)
+	if err != nil {
+		return draw, err
+	}
+	if len(cards) == 0 {
+		return draw, ErrNoCards
+	}
+	card := cards[rng.IntN(len(cards))]
+
+	draw.Card = scryfall.GetCardDataFromScryCard(card)
+	if finish != "" && scryfall.HasFinish(card, finish) {
+		draw.Card.Finish = finish
+	}
+	return draw, nil
 }
 
 func GetBoosterDataFromJson(setCode string) (*domain.BoosterPack, error) {
@@ -0,0 +1,36 @@
+package boostergen
+
+import (
+	"crypto/hmac"
+	cryptorand "crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"math/rand/v2"
+)
+
+// OpeningSeed derives the seed of a pack opening from the server's secret and the opening's ID, so anyone who
+// knows the secret can open the same pack again
+func OpeningSeed(secret, openingID string) [32]byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(openingID))
+	var seed [32]byte
+	copy(seed[:], mac.Sum(nil))
+	return seed
+}
+
+// EncodeSeed returns the seed as stored with the opening
+func EncodeSeed(seed [32]byte) string {
+	return hex.EncodeToString(seed[:])
+}
+
+// NewSeededRandom returns a generator that always draws the same numbers for the same seed
+func NewSeededRandom(seed [32]byte) *rand.Rand {
+	return rand.New(rand.NewChaCha8(seed))
+}
+
+// NewRandom returns a generator with a random seed, for draws that don't have to be reproduced
+func NewRandom() *rand.Rand {
+	var seed [32]byte
+	cryptorand.Read(seed[:])
+	return NewSeededRandom(seed)
+}
@@ -0,0 +1,105 @@
+package boostergen
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+
+	scryfallapi "github.com/BlueMonday/go-scryfall"
+	"github.com/joaquinleonarg/wdml-mtg/backend/domain"
+	"github.com/joaquinleonarg/wdml-mtg/backend/pkg/scryfall"
+)
+
+// testBoosterPack is a pack whose cards are cached, so it's drawn without searching Scryfall
+func testBoosterPack() domain.BoosterPack {
+	boosterPack := domain.BoosterPack{
+		SetCode:   "tst",
+		Filter:    "set:tst",
+		CardCount: 15,
+		Slots: []domain.BoosterPackSlot{
+			{Count: 10, Options: []domain.Option{{Filter: "rarity:common", Weight: 1}}},
+			{Count: 5, Options: []domain.Option{
+				{Filter: "rarity:uncommon", Weight: 7},
+				{Filter: "rarity:rare", Weight: 2},
+				{Filter: "rarity:mythic", Weight: 1},
+			}},
+		},
+	}
+	for _, slot := range boosterPack.Slots {
+		for _, option := range slot.Options {
+			cards := []scryfallapi.Card{}
+			for i := 0; i < 20; i++ {
+				cards = append(cards, scryfallapi.Card{
+					Name:   fmt.Sprintf("%s %d", option.Filter, i),
+					Set:    "tst",
+					Rarity: option.Filter[len("rarity:"):],
+				})
+			}
+			filter := fmt.Sprintf("%s %s %s", boosterPack.Filter, slot.Filter, option.Filter)
+			scryfall.CacheCardsByFilter(filter, cards)
+		}
+	}
+	return boosterPack
+}
+
+func cardNames(cards []domain.CardData) []string {
+	names := []string{}
+	for _, card := range cards {
+		names = append(names, card.Name)
+	}
+	return names
+}
+
+func TestOpeningSeed(t *testing.T) {
+	tests := []struct {
+		name            string
+		secretA, openA  string
+		secretB, openB  string
+		expectSameSeeds bool
+	}{
+		{"same secret and opening", "secret", "opening", "secret", "opening", true},
+		{"different opening", "secret", "opening", "secret", "other opening", false},
+		{"different secret", "secret", "opening", "other secret", "opening", false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			seedA := OpeningSeed(test.secretA, test.openA)
+			seedB := OpeningSeed(test.secretB, test.openB)
+			if (seedA == seedB) != test.expectSameSeeds {
+				t.Errorf("expected same seeds %v, got %s and %s", test.expectSameSeeds, EncodeSeed(seedA), EncodeSeed(seedB))
+			}
+		})
+	}
+}
+
+func TestGenerateBoosterIsDeterministic(t *testing.T) {
+	getter := GetBoosterDataPassthrough(testBoosterPack())
+
+	tests := []struct {
+		name              string
+		openA, openB      string
+		expectSameBooster bool
+	}{
+		{"same opening", "opening", "opening", true},
+		{"different opening", "opening", "other opening", false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			boosterA, err := GenerateBooster("tst", getter, NewSeededRandom(OpeningSeed("secret", test.openA)))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			boosterB, err := GenerateBooster("tst", getter, NewSeededRandom(OpeningSeed("secret", test.openB)))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(boosterA) != 15 || len(boosterB) != 15 {
+				t.Fatalf("expected 15 cards, got %d and %d", len(boosterA), len(boosterB))
+			}
+			same := slices.Equal(cardNames(boosterA), cardNames(boosterB))
+			if same != test.expectSameBooster {
+				t.Errorf("expected same booster %v, got %v and %v", test.expectSameBooster, cardNames(boosterA), cardNames(boosterB))
+			}
+		})
+	}
+}
@@ -0,0 +1,178 @@
+package boostergen
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/joaquinleonarg/wdml-mtg/backend/domain"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Simulation is the result of opening simulated packs
+type Simulation struct {
+	Odds domain.BoosterOdds `json:"odds"`
+	// Average coins the cards of a pack are worth, with the tournament's coin values
+	ExpectedCoins float64 `json:"expected_coins"`
+}
+
+// SimulateBoosters opens packs the same way GenerateBooster does, without granting them, and measures how often
+// each slot, option and rarity comes up. Unlike GenerateBooster, a slot without cards doesn't stop the pack.
+func SimulateBoosters(boosterData *domain.BoosterPack, packs int, coinValues domain.CoinValues) (*Simulation, error) {
+	odds := domain.BoosterOdds{
+		SimulatedPacks: packs,
+		Slots:          make([]domain.BoosterSlotOdds, 0, len(boosterData.Slots)),
+		UpdatedAt:      primitive.NewDateTimeFromTime(time.Now()),
+	}
+	for _, slot := range boosterData.Slots {
+		totalWeight := 0
+		for _, option := range slot.Options {
+			totalWeight += option.Weight
+		}
+		slotOdds := domain.BoosterSlotOdds{
+			Filter:  slot.Filter,
+			Count:   slot.Count,
+			Options: make([]domain.BoosterOptionOdds, 0, len(slot.Options)),
+		}
+		for _, option := range slot.Options {
+			optionOdds := domain.BoosterOptionOdds{Filter: option.Filter, Finish: option.Finish, Weight: option.Weight}
+			if totalWeight > 0 {
+				optionOdds.Chance = float64(max(option.Weight, 0)) / float64(totalWeight)
+			}
+			slotOdds.Options = append(slotOdds.Options, optionOdds)
+		}
+		odds.Slots = append(odds.Slots, slotOdds)
+	}
+
+	rng := NewRandom()
+	openedPacks := 0
+	totalUSD := 0.0
+	totalCoins := 0
+	rarityCards := map[domain.CardRarity]int{}
+	slotRarityCards := make([]map[domain.CardRarity]int, len(boosterData.Slots))
+	for slotIndex := range slotRarityCards {
+		slotRarityCards[slotIndex] = map[domain.CardRarity]int{}
+	}
+	for i := 0; i < packs; i++ {
+		packUSD := 0.0
+		packCoins := 0
+		packRarities := map[domain.CardRarity]int{}
+		packSlotRarities := make([]map[domain.CardRarity]int, len(boosterData.Slots))
+		failed := false
+		for slotIndex, slot := range boosterData.Slots {
+			packSlotRarities[slotIndex] = map[domain.CardRarity]int{}
+			slotOdds := &odds.Slots[slotIndex]
+			for j := 0; j < slot.Count; j++ {
+				draw, err := drawSlotCard(boosterData, slot, rng)
+				var optionOdds *domain.BoosterOptionOdds
+				if draw.OptionIndex >= 0 {
+					optionOdds = &slotOdds.Options[draw.OptionIndex]
+					optionOdds.Draws++
+				}
+				if errors.Is(err, ErrNoCards) {
+					failed = true
+					slotOdds.EmptyDraws++
+					if optionOdds != nil {
+						optionOdds.EmptyDraws++
+					}
+					continue
+				}
+				if err != nil {
+					return nil, err
+				}
+
+				requested, ok := requestedRarity(draw.Filter)
+				if ok && requested != draw.Card.Rarity && optionOdds != nil {
+					optionOdds.RarityFallbacks++
+				}
+				packSlotRarities[slotIndex][draw.Card.Rarity]++
+				packRarities[draw.Card.Rarity]++
+				packUSD += domain.CardPriceUSD(draw.Card)
+				packCoins += domain.CardCoinValue(draw.Card, coinValues)
+			}
+		}
+		if failed {
+			odds.FailedPacks++
+			continue
+		}
+		openedPacks++
+		totalUSD += packUSD
+		totalCoins += packCoins
+		for rarity, cards := range packRarities {
+			rarityCards[rarity] += cards
+		}
+		// Slot rates are measured over the same packs as the overall ones
+		for slotIndex, slotRarities := range packSlotRarities {
+			for rarity, cards := range slotRarities {
+				slotRarityCards[slotIndex][rarity] += cards
+			}
+		}
+	}
+
+	odds.Rarities = rarityRates(rarityCards, openedPacks)
+	for slotIndex := range odds.Slots {
+		odds.Slots[slotIndex].Rarities = rarityRates(slotRarityCards[slotIndex], openedPacks)
+	}
+	simulation := Simulation{Odds: odds}
+	if openedPacks > 0 {
+		simulation.Odds.ExpectedUSD = math.Round(totalUSD/float64(openedPacks)*100) / 100
+		simulation.ExpectedCoins = math.Round(float64(totalCoins)/float64(openedPacks)*100) / 100
+	}
+	return &simulation, nil
+}
+
+// rarityRates turns the cards drawn of each rarity into rates, from the most common rarity to the rarest
+func rarityRates(cardsByRarity map[domain.CardRarity]int, packs int) []domain.RarityRate {
+	totalCards := 0
+	for _, cards := range cardsByRarity {
+		totalCards += cards
+	}
+	rates := []domain.RarityRate{}
+	for _, rarity := range domain.CardRarities {
+		cards, ok := cardsByRarity[rarity]
+		if !ok {
+			continue
+		}
+		rate := domain.RarityRate{Rarity: rarity, Cards: cards}
+		if packs > 0 {
+			rate.PerPack = float64(cards) / float64(packs)
+		}
+		if totalCards > 0 {
+			rate.Chance = float64(cards) / float64(totalCards)
+		}
+		rates = append(rates, rate)
+	}
+	return rates
+}
+
+var rarityAbbreviations = map[string]domain.CardRarity{
+	"c": domain.CardRarityCommon,
+	"u": domain.CardRarityUncommon,
+	"r": domain.CardRarityRare,
+	"m": domain.CardRarityMythic,
+	"s": domain.CardRaritySpecial,
+}
+
+// requestedRarity returns the rarity a Scryfall filter asks for, if it asks for exactly one
+func requestedRarity(filter string) (domain.CardRarity, bool) {
+	found := []domain.CardRarity{}
+	for _, term := range strings.Fields(strings.ToLower(filter)) {
+		for _, prefix := range []string{"rarity:", "rarity=", "r:", "r="} {
+			value, ok := strings.CutPrefix(term, prefix)
+			if !ok {
+				continue
+			}
+			rarity, ok := rarityAbbreviations[value]
+			if !ok {
+				rarity = domain.CardRarity(value)
+			}
+			found = append(found, rarity)
+			break
+		}
+	}
+	if len(found) != 1 {
+		return "", false
+	}
+	return found[0], true
+}
@@ -0,0 +1,139 @@
+package boostergen
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"testing"
+
+	scryfallapi "github.com/BlueMonday/go-scryfall"
+	"github.com/joaquinleonarg/wdml-mtg/backend/domain"
+	"github.com/joaquinleonarg/wdml-mtg/backend/pkg/scryfall"
+)
+
+func TestRequestedRarity(t *testing.T) {
+	tests := []struct {
+		filter   string
+		expected domain.CardRarity
+		ok       bool
+	}{
+		{"set:neo rarity:rare", domain.CardRarityRare, true},
+		{"set:neo r:m", domain.CardRarityMythic, true},
+		{"set:neo R=U", domain.CardRarityUncommon, true},
+		{"set:neo", "", false},
+		{"set:neo r:c r:u", "", false},
+	}
+	for _, test := range tests {
+		t.Run(test.filter, func(t *testing.T) {
+			rarity, ok := requestedRarity(test.filter)
+			if rarity != test.expected || ok != test.ok {
+				t.Errorf("expected %s %v, got %s %v", test.expected, test.ok, rarity, ok)
+			}
+		})
+	}
+}
+
+func TestRarityRates(t *testing.T) {
+	tests := []struct {
+		name     string
+		cards    map[domain.CardRarity]int
+		packs    int
+		expected []domain.RarityRate
+	}{
+		{"no packs", map[domain.CardRarity]int{}, 0, []domain.RarityRate{}},
+		{"sorted by rarity", map[domain.CardRarity]int{domain.CardRarityRare: 1, domain.CardRarityCommon: 3}, 2, []domain.RarityRate{
+			{Rarity: domain.CardRarityCommon, Cards: 3, PerPack: 1.5, Chance: 0.75},
+			{Rarity: domain.CardRarityRare, Cards: 1, PerPack: 0.5, Chance: 0.25},
+		}},
+		{"failed packs only", map[domain.CardRarity]int{domain.CardRarityCommon: 3}, 0, []domain.RarityRate{
+			{Rarity: domain.CardRarityCommon, Cards: 3, Chance: 1},
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if rates := rarityRates(test.cards, test.packs); !reflect.DeepEqual(rates, test.expected) {
+				t.Errorf("expected %+v, got %+v", test.expected, rates)
+			}
+		})
+	}
+}
+
+// perPack returns the average cards of a rarity on a pack, 0 if none were drawn
+func perPack(rates []domain.RarityRate, rarity domain.CardRarity) float64 {
+	for _, rate := range rates {
+		if rate.Rarity == rarity {
+			return rate.PerPack
+		}
+	}
+	return 0
+}
+
+func TestSimulateBoosters(t *testing.T) {
+	boosterPack := testBoosterPack()
+
+	// A pack that fails whenever its last slot chooses the option without cards
+	failingPack := domain.BoosterPack{
+		SetCode:   "tsf",
+		Filter:    "set:tsf",
+		CardCount: 11,
+		Slots: []domain.BoosterPackSlot{
+			{Count: 10, Options: []domain.Option{{Filter: "rarity:common", Weight: 1}}},
+			{Count: 1, Options: []domain.Option{
+				{Filter: "rarity:common", Weight: 1},
+				{Filter: "rarity:special", Weight: 1},
+			}},
+		},
+	}
+	scryfall.CacheCardsByFilter(fmt.Sprintf("%s  rarity:common", failingPack.Filter), []scryfallapi.Card{
+		{Name: "Common", Set: "tsf", Rarity: "common"},
+	})
+	scryfall.CacheCardsByFilter(fmt.Sprintf("%s  rarity:special", failingPack.Filter), []scryfallapi.Card{})
+
+	tests := []struct {
+		name         string
+		boosterPack  domain.BoosterPack
+		expectFailed bool
+	}{
+		{"every pack opens", boosterPack, false},
+		{"some packs fail", failingPack, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			packs := 500
+			simulation, err := SimulateBoosters(&test.boosterPack, packs, domain.CoinValues{})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			odds := simulation.Odds
+			if odds.SimulatedPacks != packs {
+				t.Errorf("expected %d simulated packs, got %d", packs, odds.SimulatedPacks)
+			}
+			if (odds.FailedPacks > 0) != test.expectFailed || odds.FailedPacks == packs {
+				t.Fatalf("unexpected %d failed packs", odds.FailedPacks)
+			}
+
+			// Every slot draws its count on each pack, and slot rates add up to the pack's
+			draws := 0
+			for slotIndex, slot := range odds.Slots {
+				for _, option := range slot.Options {
+					draws += option.Draws
+				}
+				if slotIndex == 0 && perPack(slot.Rarities, domain.CardRarityCommon) != 10 {
+					t.Errorf("expected 10 commons per pack on the first slot, got %+v", slot.Rarities)
+				}
+			}
+			if draws != test.boosterPack.CardCount*packs {
+				t.Errorf("expected %d draws, got %d", test.boosterPack.CardCount*packs, draws)
+			}
+			for _, rarity := range domain.CardRarities {
+				slotsPerPack := 0.0
+				for _, slot := range odds.Slots {
+					slotsPerPack += perPack(slot.Rarities, rarity)
+				}
+				if math.Abs(slotsPerPack-perPack(odds.Rarities, rarity)) > 1e-9 {
+					t.Errorf("expected the slots' %s rates to add up to %v, got %v", rarity, perPack(odds.Rarities, rarity), slotsPerPack)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package cardprices
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	scryfallapi "github.com/BlueMonday/go-scryfall"
+	"github.com/joaquinleonarg/wdml-mtg/backend/domain"
+	"github.com/joaquinleonarg/wdml-mtg/backend/pkg/scryfall"
+)
+
+// bulkCard has the only fields of a bulk data card that are read, so the rest of it isn't decoded
+type bulkCard struct {
+	Set             string             `json:"set"`
+	CollectorNumber string             `json:"collector_number"`
+	Prices          scryfallapi.Prices `json:"prices"`
+}
+
+// Load reads the prices of every printing on a Scryfall bulk data file ("Default Cards" or "All Cards"), by
+// domain.CardPrintingKey. The file is a single JSON array that can be hundreds of megabytes, so cards are decoded
+// one at a time. When a printing appears in several languages, the first price found is kept.
+func Load(path string) (map[string]domain.CardPrice, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	decoder := json.NewDecoder(file)
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+	if delim, ok := token.(json.Delim); !ok || delim != '[' {
+		return nil, fmt.Errorf("bulk data file is not a list of cards")
+	}
+
+	prices := make(map[string]domain.CardPrice)
+	for decoder.More() {
+		var card bulkCard
+		err := decoder.Decode(&card)
+		if err != nil {
+			return nil, err
+		}
+		key := domain.CardPrintingKey(card.Set, card.CollectorNumber)
+		if _, ok := prices[key]; ok {
+			continue
+		}
+		price := scryfall.GetPriceFromScryPrices(card.Prices)
+		if price != nil {
+			prices[key] = *price
+		}
+	}
+	return prices, nil
+}
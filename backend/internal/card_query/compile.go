@@ -0,0 +1,336 @@
+package cardquery
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/joaquinleonarg/wdml-mtg/backend/domain"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const (
+	FieldName          = "name"
+	FieldOracle        = "oracle"
+	FieldType          = "type"
+	FieldTag           = "tag"
+	FieldRarity        = "rarity"
+	FieldSet           = "set"
+	FieldColor         = "color"
+	FieldColorIdentity = "identity"
+	FieldManaValue     = "mv"
+	FieldCount         = "count"
+	FieldFinish        = "finish"
+	FieldLanguage      = "lang"
+	FieldFrame         = "frame"
+	FieldIs            = "is"
+)
+
+var fieldAliases = map[string]string{
+	"name":     FieldName,
+	"n":        FieldName,
+	"oracle":   FieldOracle,
+	"o":        FieldOracle,
+	"type":     FieldType,
+	"types":    FieldType,
+	"t":        FieldType,
+	"tag":      FieldTag,
+	"tags":     FieldTag,
+	"rarity":   FieldRarity,
+	"r":        FieldRarity,
+	"set":      FieldSet,
+	"setcode":  FieldSet,
+	"s":        FieldSet,
+	"e":        FieldSet,
+	"color":    FieldColor,
+	"colors":   FieldColor,
+	"c":        FieldColor,
+	"identity": FieldColorIdentity,
+	"id":       FieldColorIdentity,
+	"mv":       FieldManaValue,
+	"cmc":      FieldManaValue,
+	"count":    FieldCount,
+	"finish":   FieldFinish,
+	"lang":     FieldLanguage,
+	"language": FieldLanguage,
+	"frame":    FieldFrame,
+	"is":       FieldIs,
+}
+
+var numericOperators = map[string]string{
+	":":  "$eq",
+	"=":  "$eq",
+	"!=": "$ne",
+	"<":  "$lt",
+	"<=": "$lte",
+	">":  "$gt",
+	">=": "$gte",
+}
+
+// Compile turns a parsed query into a Mongo filter over the card collection. A nil node matches every card.
+func Compile(node *Node) (bson.M, error) {
+	if node == nil {
+		return bson.M{}, nil
+	}
+	return compileNode(*node)
+}
+
+func compileNode(node Node) (bson.M, error) {
+	switch node.Kind {
+	case NodeAnd, NodeOr, NodeNot:
+		children := make(bson.A, 0, len(node.Children))
+		for _, child := range node.Children {
+			filter, err := compileNode(child)
+			if err != nil {
+				return nil, err
+			}
+			children = append(children, filter)
+		}
+		switch node.Kind {
+		case NodeAnd:
+			return bson.M{"$and": children}, nil
+		case NodeOr:
+			return bson.M{"$or": children}, nil
+		default:
+			return bson.M{"$nor": children}, nil
+		}
+	}
+	return compileTerm(node)
+}
+
+func compileTerm(node Node) (bson.M, error) {
+	field, ok := fieldAliases[node.Field]
+	if !ok {
+		return nil, fmt.Errorf("unknown field %s", node.Field)
+	}
+
+	switch field {
+	case FieldName, FieldOracle:
+		path := "name"
+		if field == FieldOracle {
+			path = "oracle"
+		}
+		pattern := regexp.QuoteMeta(node.Value)
+		if field == FieldName && (node.Operator == "=" || node.Operator == "!=") {
+			pattern = "^" + pattern + "$"
+		}
+		// Any face of the card can match
+		matches := bson.M{"$or": bson.A{
+			bson.M{"card_data." + path: bson.M{"$regex": pattern, "$options": "i"}},
+			bson.M{"card_data.faces." + path: bson.M{"$regex": pattern, "$options": "i"}},
+		}}
+		switch node.Operator {
+		case ":", "=":
+			return matches, nil
+		case "!=":
+			return bson.M{"$nor": bson.A{matches}}, nil
+		}
+
+	case FieldType:
+		conditions := bson.A{}
+		for _, cardType := range strings.Fields(node.Value) {
+			typeRegex := bson.M{"$regex": "^" + regexp.QuoteMeta(cardType) + "$", "$options": "i"}
+			conditions = append(conditions, bson.M{"$or": bson.A{
+				bson.M{"card_data.types": typeRegex},
+				bson.M{"card_data.faces.types": typeRegex},
+			}})
+		}
+		if len(conditions) == 0 {
+			return nil, fmt.Errorf("missing value for %s", node.Field)
+		}
+		switch node.Operator {
+		case ":", "=":
+			return bson.M{"$and": conditions}, nil
+		case "!=":
+			return bson.M{"$nor": bson.A{bson.M{"$and": conditions}}}, nil
+		}
+
+	case FieldTag:
+		switch node.Operator {
+		case ":", "=":
+			return bson.M{"tags": node.Value}, nil
+		case "!=":
+			return bson.M{"tags": bson.M{"$ne": node.Value}}, nil
+		}
+
+	case FieldSet:
+		switch node.Operator {
+		case ":", "=":
+			return bson.M{"card_data.set_code": strings.ToUpper(node.Value)}, nil
+		case "!=":
+			return bson.M{"card_data.set_code": bson.M{"$ne": strings.ToUpper(node.Value)}}, nil
+		}
+
+	case FieldRarity:
+		return compileRarity(node)
+
+	case FieldFinish, FieldLanguage, FieldIs:
+		filter, err := compileVariant(field, strings.ToLower(node.Value))
+		if err != nil {
+			return nil, err
+		}
+		switch node.Operator {
+		case ":", "=":
+			return filter, nil
+		case "!=":
+			return bson.M{"$nor": bson.A{filter}}, nil
+		}
+
+	case FieldFrame:
+		switch node.Operator {
+		case ":", "=":
+			return bson.M{"card_data.frame_effects": strings.ToLower(node.Value)}, nil
+		case "!=":
+			return bson.M{"card_data.frame_effects": bson.M{"$ne": strings.ToLower(node.Value)}}, nil
+		}
+
+	case FieldColor:
+		return compileColors("card_data.colors", node)
+
+	case FieldColorIdentity:
+		return compileColors("card_data.color_identity", node)
+
+	case FieldManaValue, FieldCount:
+		path := "card_data.mana_value"
+		if field == FieldCount {
+			path = "count"
+		}
+		value, err := strconv.Atoi(node.Value)
+		if err != nil {
+			return nil, fmt.Errorf("%s must be a number", node.Field)
+		}
+		return bson.M{path: bson.M{numericOperators[node.Operator]: value}}, nil
+	}
+
+	return nil, fmt.Errorf("operator %s can't be used with %s", node.Operator, node.Field)
+}
+
+func compileRarity(node Node) (bson.M, error) {
+	index := slices.IndexFunc(domain.CardRarities, func(rarity domain.CardRarity) bool {
+		return strings.HasPrefix(string(rarity), strings.ToLower(node.Value))
+	})
+	if index == -1 {
+		return nil, fmt.Errorf("unknown rarity %s", node.Value)
+	}
+
+	rarities := []domain.CardRarity{}
+	for i, rarity := range domain.CardRarities {
+		var matches bool
+		switch node.Operator {
+		case ":", "=":
+			matches = i == index
+		case "!=":
+			matches = i != index
+		case "<":
+			matches = i < index
+		case "<=":
+			matches = i <= index
+		case ">":
+			matches = i > index
+		case ">=":
+			matches = i >= index
+		}
+		if matches {
+			rarities = append(rarities, rarity)
+		}
+	}
+	return bson.M{"card_data.rarity": bson.M{"$in": rarities}}, nil
+}
+
+// compileVariant matches a card's finish, language, or an "is" flag like Scryfall's is:foil and is:promo. Cards
+// stored before finishes and languages were tracked are non foil English copies.
+func compileVariant(field, value string) (bson.M, error) {
+	if field == FieldIs {
+		if value == "promo" {
+			return bson.M{"card_data.promo": true}, nil
+		}
+		field = FieldFinish
+	}
+
+	switch field {
+	case FieldFinish:
+		finish := domain.CardFinish(value)
+		if !slices.Contains(domain.CardFinishes, finish) {
+			return nil, fmt.Errorf("unknown finish %s", value)
+		}
+		if finish == domain.CardFinishNonFoil {
+			return bson.M{"card_data.finish": bson.M{"$in": bson.A{finish, "", nil}}}, nil
+		}
+		return bson.M{"card_data.finish": finish}, nil
+	case FieldLanguage:
+		if value == "" {
+			return nil, fmt.Errorf("missing value for %s", field)
+		}
+		if value == domain.DEFAULT_CARD_LANGUAGE {
+			return bson.M{"card_data.language": bson.M{"$in": bson.A{value, "", nil}}}, nil
+		}
+		return bson.M{"card_data.language": value}, nil
+	}
+	return nil, fmt.Errorf("unknown field %s", field)
+}
+
+var colorNames = map[string][]string{
+	"white":     {"W"},
+	"blue":      {"U"},
+	"black":     {"B"},
+	"red":       {"R"},
+	"green":     {"G"},
+	"colorless": {},
+	"c":         {},
+}
+
+// compileColors follows Scryfall: ":" and ">=" mean at least these colors, "=" exactly these colors,
+// "<=" at most these colors, and "<" and ">" are the strict versions
+func compileColors(path string, node Node) (bson.M, error) {
+	colors, ok := colorNames[strings.ToLower(node.Value)]
+	if !ok {
+		colors = []string{}
+		for _, color := range strings.ToUpper(node.Value) {
+			if !strings.ContainsRune("WUBRG", color) {
+				return nil, fmt.Errorf("unknown color %c", color)
+			}
+			if !slices.Contains(colors, string(color)) {
+				colors = append(colors, string(color))
+			}
+		}
+	}
+	others := []string{}
+	for _, color := range []string{"W", "U", "B", "R", "G"} {
+		if !slices.Contains(colors, color) {
+			others = append(others, color)
+		}
+	}
+
+	atMost := bson.M{path: bson.M{"$nin": others}}
+	exactly := bson.M{"$and": bson.A{atMost, bson.M{path: bson.M{"$size": len(colors)}}}}
+	atLeast := bson.M{path: bson.M{"$all": colors}}
+	if len(colors) == 0 {
+		// $all doesn't match anything with an empty list
+		atLeast = bson.M{}
+	}
+	moreThan := bson.M{"$and": bson.A{atLeast, bson.M{fmt.Sprintf("%s.%d", path, len(colors)): bson.M{"$exists": true}}}}
+
+	switch node.Operator {
+	case ":":
+		// Colorless can only be searched exactly
+		if len(colors) == 0 {
+			return exactly, nil
+		}
+		return atLeast, nil
+	case ">=":
+		return atLeast, nil
+	case "=":
+		return exactly, nil
+	case "!=":
+		return bson.M{"$nor": bson.A{exactly}}, nil
+	case "<=":
+		return atMost, nil
+	case "<":
+		return bson.M{"$and": bson.A{atMost, bson.M{"$nor": bson.A{exactly}}}}, nil
+	case ">":
+		return moreThan, nil
+	}
+	return nil, fmt.Errorf("operator %s can't be used with %s", node.Operator, node.Field)
+}
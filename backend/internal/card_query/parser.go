@@ -0,0 +1,265 @@
+package cardquery
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Query language for collection cards, loosely based on Scryfall's:
+//
+//	t:creature (c:wu or c=r) mv<=3 -tag:trade "lightning bolt"
+//
+// Terms are "field operator value", or a bare value that is searched on the card name. Terms next to
+// each other are joined with "and"; "or", "not" (or a leading "-") and parentheses are also supported.
+// Values with spaces go between double quotes.
+
+type tokenKind int
+
+const (
+	tokenTerm tokenKind = iota
+	tokenAnd
+	tokenOr
+	tokenNot
+	tokenOpenParen
+	tokenCloseParen
+)
+
+type token struct {
+	Kind     tokenKind
+	Field    string
+	Operator string
+	Value    string
+	Position int
+}
+
+// Operators sorted so the longest ones are matched first
+var operators = []string{"!=", "<=", ">=", ":", "=", "<", ">"}
+
+func tokenize(query string) ([]token, error) {
+	tokens := []token{}
+	runes := []rune(query)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(':
+			tokens = append(tokens, token{Kind: tokenOpenParen, Position: i})
+			i++
+		case r == ')':
+			tokens = append(tokens, token{Kind: tokenCloseParen, Position: i})
+			i++
+		case r == '-' && i+1 < len(runes) && !unicode.IsSpace(runes[i+1]):
+			tokens = append(tokens, token{Kind: tokenNot, Position: i})
+			i++
+		default:
+			term, next, err := readTerm(runes, i)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, term)
+			i = next
+		}
+	}
+	return tokens, nil
+}
+
+// readTerm reads "field operator value", a bare word, a quoted string or a keyword
+func readTerm(runes []rune, start int) (token, int, error) {
+	i := start
+	for i < len(runes) && (unicode.IsLetter(runes[i]) || runes[i] == '_') {
+		i++
+	}
+	field := strings.ToLower(string(runes[start:i]))
+	if field != "" {
+		for _, operator := range operators {
+			if strings.HasPrefix(string(runes[i:]), operator) {
+				value, next, err := readValue(runes, i+len([]rune(operator)))
+				if err != nil {
+					return token{}, 0, err
+				}
+				if value == "" {
+					return token{}, 0, fmt.Errorf("missing value for %s at position %d", field, start)
+				}
+				return token{Kind: tokenTerm, Field: field, Operator: operator, Value: value, Position: start}, next, nil
+			}
+		}
+	}
+
+	quoted := runes[start] == '"'
+	value, next, err := readValue(runes, start)
+	if err != nil {
+		return token{}, 0, err
+	}
+	if !quoted {
+		switch strings.ToLower(value) {
+		case "and":
+			return token{Kind: tokenAnd, Position: start}, next, nil
+		case "or":
+			return token{Kind: tokenOr, Position: start}, next, nil
+		case "not":
+			return token{Kind: tokenNot, Position: start}, next, nil
+		}
+	}
+	return token{Kind: tokenTerm, Field: FieldName, Operator: ":", Value: value, Position: start}, next, nil
+}
+
+// readValue reads a quoted string, with \" escapes, or everything up to the next space or parenthesis
+func readValue(runes []rune, start int) (string, int, error) {
+	if start < len(runes) && runes[start] == '"' {
+		var value strings.Builder
+		for i := start + 1; i < len(runes); i++ {
+			switch {
+			case runes[i] == '\\' && i+1 < len(runes):
+				i++
+				value.WriteRune(runes[i])
+			case runes[i] == '"':
+				return value.String(), i + 1, nil
+			default:
+				value.WriteRune(runes[i])
+			}
+		}
+		return "", 0, fmt.Errorf("unterminated quote at position %d", start)
+	}
+
+	i := start
+	for i < len(runes) && !unicode.IsSpace(runes[i]) && runes[i] != '(' && runes[i] != ')' {
+		i++
+	}
+	return string(runes[start:i]), i, nil
+}
+
+type NodeKind int
+
+const (
+	NodeTerm NodeKind = iota
+	NodeAnd
+	NodeOr
+	NodeNot
+)
+
+// Node is an element of a parsed query. Terms have a field, operator and value; the rest only have children.
+type Node struct {
+	Kind     NodeKind
+	Field    string
+	Operator string
+	Value    string
+	Children []Node
+}
+
+// Parse turns a query into a tree of nodes. An empty query returns nil.
+func Parse(query string) (*Node, error) {
+	tokens, err := tokenize(query)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, nil
+	}
+
+	p := parser{tokens: tokens}
+	node, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.position < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected token at position %d", p.tokens[p.position].Position)
+	}
+	return &node, nil
+}
+
+type parser struct {
+	tokens   []token
+	position int
+}
+
+func (p *parser) peek() (token, bool) {
+	if p.position >= len(p.tokens) {
+		return token{}, false
+	}
+	return p.tokens[p.position], true
+}
+
+func (p *parser) parseOr() (Node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return Node{}, err
+	}
+	children := []Node{left}
+	for {
+		next, ok := p.peek()
+		if !ok || next.Kind != tokenOr {
+			break
+		}
+		p.position++
+		right, err := p.parseAnd()
+		if err != nil {
+			return Node{}, err
+		}
+		children = append(children, right)
+	}
+	if len(children) == 1 {
+		return left, nil
+	}
+	return Node{Kind: NodeOr, Children: children}, nil
+}
+
+func (p *parser) parseAnd() (Node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return Node{}, err
+	}
+	children := []Node{left}
+	for {
+		next, ok := p.peek()
+		if !ok || next.Kind == tokenOr || next.Kind == tokenCloseParen {
+			break
+		}
+		// "and" is optional
+		if next.Kind == tokenAnd {
+			p.position++
+		}
+		right, err := p.parseUnary()
+		if err != nil {
+			return Node{}, err
+		}
+		children = append(children, right)
+	}
+	if len(children) == 1 {
+		return left, nil
+	}
+	return Node{Kind: NodeAnd, Children: children}, nil
+}
+
+func (p *parser) parseUnary() (Node, error) {
+	next, ok := p.peek()
+	if !ok {
+		return Node{}, fmt.Errorf("unexpected end of query")
+	}
+	switch next.Kind {
+	case tokenNot:
+		p.position++
+		child, err := p.parseUnary()
+		if err != nil {
+			return Node{}, err
+		}
+		return Node{Kind: NodeNot, Children: []Node{child}}, nil
+	case tokenOpenParen:
+		p.position++
+		node, err := p.parseOr()
+		if err != nil {
+			return Node{}, err
+		}
+		closing, ok := p.peek()
+		if !ok || closing.Kind != tokenCloseParen {
+			return Node{}, fmt.Errorf("missing closing parenthesis for position %d", next.Position)
+		}
+		p.position++
+		return node, nil
+	case tokenTerm:
+		p.position++
+		return Node{Kind: NodeTerm, Field: next.Field, Operator: next.Operator, Value: next.Value}, nil
+	}
+	return Node{}, fmt.Errorf("unexpected token at position %d", next.Position)
+}
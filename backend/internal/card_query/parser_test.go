@@ -0,0 +1,184 @@
+package cardquery
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/joaquinleonarg/wdml-mtg/backend/domain"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func term(field, operator, value string) Node {
+	return Node{Kind: NodeTerm, Field: field, Operator: operator, Value: value}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    string
+		expected *Node
+	}{
+		{"empty", "   ", nil},
+		{"bare word", "bolt", &Node{Kind: NodeTerm, Field: FieldName, Operator: ":", Value: "bolt"}},
+		{"quoted name", `"lightning bolt"`, &Node{Kind: NodeTerm, Field: FieldName, Operator: ":", Value: "lightning bolt"}},
+		{"escaped quote", `"say \"hi\""`, &Node{Kind: NodeTerm, Field: FieldName, Operator: ":", Value: `say "hi"`}},
+		{"field is lower cased", "T:Creature", &Node{Kind: NodeTerm, Field: "t", Operator: ":", Value: "Creature"}},
+		{"longest operator wins", "mv<=3", &Node{Kind: NodeTerm, Field: "mv", Operator: "<=", Value: "3"}},
+		{"not equal", "c!=r", &Node{Kind: NodeTerm, Field: "c", Operator: "!=", Value: "r"}},
+		{"implicit and", "t:creature mv<3", &Node{Kind: NodeAnd, Children: []Node{
+			term("t", ":", "creature"),
+			term("mv", "<", "3"),
+		}}},
+		{"explicit and", "t:creature AND mv<3", &Node{Kind: NodeAnd, Children: []Node{
+			term("t", ":", "creature"),
+			term("mv", "<", "3"),
+		}}},
+		{"and binds tighter than or", "c:w t:angel or c:b", &Node{Kind: NodeOr, Children: []Node{
+			{Kind: NodeAnd, Children: []Node{term("c", ":", "w"), term("t", ":", "angel")}},
+			term("c", ":", "b"),
+		}}},
+		{"parentheses", "t:creature (c:wu or c=r)", &Node{Kind: NodeAnd, Children: []Node{
+			term("t", ":", "creature"),
+			{Kind: NodeOr, Children: []Node{term("c", ":", "wu"), term("c", "=", "r")}},
+		}}},
+		{"dash negates", "-tag:trade", &Node{Kind: NodeNot, Children: []Node{term("tag", ":", "trade")}}},
+		{"not keyword negates", "not tag:trade", &Node{Kind: NodeNot, Children: []Node{term("tag", ":", "trade")}}},
+		{"quoted keyword is a name", `"or"`, &Node{Kind: NodeTerm, Field: FieldName, Operator: ":", Value: "or"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			node, err := Parse(test.query)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(node, test.expected) {
+				t.Errorf("expected %+v, got %+v", test.expected, node)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+	}{
+		{"unterminated quote", `"lightning bolt`},
+		{"missing value", "t: creature"},
+		{"missing closing parenthesis", "(c:w or c:u"},
+		{"unexpected closing parenthesis", "c:w)"},
+		{"dangling or", "c:w or"},
+		{"dangling not", "not"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := Parse(test.query); err == nil {
+				t.Errorf("expected an error for %q", test.query)
+			}
+		})
+	}
+}
+
+func TestCompile(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    string
+		expected bson.M
+	}{
+		{"empty matches everything", "", bson.M{}},
+		{"set is upper cased", "s:neo", bson.M{"card_data.set_code": "NEO"}},
+		{"tag excluded", "tag!=trade", bson.M{"tags": bson.M{"$ne": "trade"}}},
+		{"mana value", "cmc>=4", bson.M{"card_data.mana_value": bson.M{"$gte": 4}}},
+		{"count", "count=2", bson.M{"count": bson.M{"$eq": 2}}},
+		{"rarity at least rare", "r>=rare", bson.M{"card_data.rarity": bson.M{"$in": []domain.CardRarity{domain.CardRarityRare, domain.CardRarityMythic, domain.CardRaritySpecial}}}},
+		{"rarity prefix", "r:m", bson.M{"card_data.rarity": bson.M{"$in": []domain.CardRarity{domain.CardRarityMythic}}}},
+		{"foil", "is:foil", bson.M{"card_data.finish": domain.CardFinishFoil}},
+		{"non foil includes untracked finishes", "finish:nonfoil", bson.M{"card_data.finish": bson.M{"$in": bson.A{domain.CardFinishNonFoil, "", nil}}}},
+		{"promo", "is:promo", bson.M{"card_data.promo": true}},
+		{"at least colors", "c:wu", bson.M{"card_data.colors": bson.M{"$all": []string{"W", "U"}}}},
+		{"at most colors", "id<=g", bson.M{"card_data.color_identity": bson.M{"$nin": []string{"W", "U", "B", "R"}}}},
+		{"not", "-tag:trade", bson.M{"$nor": bson.A{bson.M{"tags": "trade"}}}},
+		{"and", "tag:a tag:b", bson.M{"$and": bson.A{bson.M{"tags": "a"}, bson.M{"tags": "b"}}}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			node, err := Parse(test.query)
+			if err != nil {
+				t.Fatalf("unexpected parse error: %v", err)
+			}
+			filter, err := Compile(node)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(filter, test.expected) {
+				t.Errorf("expected %v, got %v", test.expected, filter)
+			}
+		})
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+	}{
+		{"unknown field", "power>3"},
+		{"mana value not a number", "mv:x"},
+		{"unknown rarity", "r:legendary"},
+		{"unknown color", "c:wx"},
+		{"unknown finish", "finish:shiny"},
+		{"comparison on a tag", "tag>trade"},
+		{"comparison on a name", "name<bolt"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			node, err := Parse(test.query)
+			if err != nil {
+				t.Fatalf("unexpected parse error: %v", err)
+			}
+			if _, err := Compile(node); err == nil {
+				t.Errorf("expected an error for %q", test.query)
+			}
+		})
+	}
+}
+
+func TestParseSort(t *testing.T) {
+	tests := []struct {
+		name        string
+		sort        string
+		expected    bson.D
+		expectError bool
+	}{
+		{"default", "", bson.D{{Key: "_id", Value: 1}}, false},
+		{"descending", "-mv", bson.D{{Key: "card_data.mana_value", Value: -1}, {Key: "_id", Value: 1}}, false},
+		{"several fields", "rarity, name", bson.D{
+			{Key: RarityOrderField, Value: 1},
+			{Key: "card_data.name", Value: 1},
+			{Key: "_id", Value: 1},
+		}, false},
+		{"set sorts by collector number", "-set", bson.D{
+			{Key: "card_data.set_code", Value: -1},
+			{Key: "card_data.collector_number", Value: -1},
+			{Key: "_id", Value: 1},
+		}, false},
+		{"unknown field", "power", nil, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			sort, err := ParseSort(test.sort)
+			if test.expectError {
+				if err == nil {
+					t.Errorf("expected an error for %q", test.sort)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(sort, test.expected) {
+				t.Errorf("expected %v, got %v", test.expected, sort)
+			}
+		})
+	}
+}
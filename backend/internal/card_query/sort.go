@@ -0,0 +1,46 @@
+package cardquery
+
+import (
+	"fmt"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// RarityOrderField is computed by db.GetCardsFromTournamentPlayer with the position of the card's rarity
+// on domain.CardRarities, since rarities can't be sorted alphabetically
+const RarityOrderField = "rarity_order"
+
+var sortFields = map[string][]string{
+	"name":   {"card_data.name"},
+	"mv":     {"card_data.mana_value"},
+	"rarity": {RarityOrderField},
+	"set":    {"card_data.set_code", "card_data.collector_number"},
+	"count":  {"count"},
+	"date":   {"created_at"},
+}
+
+// ParseSort reads a comma separated list of fields to sort by, each one descending if it starts with "-".
+// Ties are always broken by ID, so results are stable between pages.
+func ParseSort(sort string) (bson.D, error) {
+	sortDocument := bson.D{}
+	for _, field := range strings.Split(sort, ",") {
+		field = strings.ToLower(strings.TrimSpace(field))
+		if field == "" {
+			continue
+		}
+		direction := 1
+		if strings.HasPrefix(field, "-") {
+			direction = -1
+			field = strings.TrimPrefix(field, "-")
+		}
+		paths, ok := sortFields[field]
+		if !ok {
+			return nil, fmt.Errorf("can't sort by %s", field)
+		}
+		for _, path := range paths {
+			sortDocument = append(sortDocument, bson.E{Key: path, Value: direction})
+		}
+	}
+	return append(sortDocument, bson.E{Key: "_id", Value: 1}), nil
+}
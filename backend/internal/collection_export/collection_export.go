@@ -0,0 +1,214 @@
+package collectionexport
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/joaquinleonarg/wdml-mtg/backend/domain"
+)
+
+type Format string
+
+const (
+	FormatMoxfield Format = "cef_moxfield"
+	FormatManaBox  Format = "cef_manabox"
+	FormatArena    Format = "cef_arena"
+	FormatJSON     Format = "cef_json"
+)
+
+// Writer writes a collection in some format. Cards can be written in several calls, so big collections
+// can be streamed; Close must be called after the last card.
+type Writer interface {
+	Write(cards []domain.OwnedCard) error
+	Close() error
+}
+
+func NewWriter(format Format, w io.Writer) (Writer, error) {
+	switch format {
+	case FormatMoxfield:
+		return newCSVWriter(w, moxfieldHeader, moxfieldRecord), nil
+	case FormatManaBox:
+		return newCSVWriter(w, manaBoxHeader, manaBoxRecord), nil
+	case FormatArena:
+		return &arenaWriter{w: w}, nil
+	case FormatJSON:
+		return &jsonWriter{w: w}, nil
+	}
+	return nil, fmt.Errorf("unknown export format %s", format)
+}
+
+// ContentType returns the MIME type and file extension for a format
+func ContentType(format Format) (string, string) {
+	switch format {
+	case FormatMoxfield, FormatManaBox:
+		return "text/csv", "csv"
+	case FormatJSON:
+		return "application/json", "json"
+	}
+	return "text/plain", "txt"
+}
+
+type csvWriter struct {
+	w             *csv.Writer
+	header        []string
+	record        func(card domain.OwnedCard) []string
+	headerWritten bool
+}
+
+func newCSVWriter(w io.Writer, header []string, record func(card domain.OwnedCard) []string) *csvWriter {
+	return &csvWriter{w: csv.NewWriter(w), header: header, record: record}
+}
+
+func (cw *csvWriter) Write(cards []domain.OwnedCard) error {
+	if !cw.headerWritten {
+		cw.headerWritten = true
+		if err := cw.w.Write(cw.header); err != nil {
+			return err
+		}
+	}
+	for _, card := range cards {
+		if err := cw.w.Write(cw.record(card)); err != nil {
+			return err
+		}
+	}
+	cw.w.Flush()
+	return cw.w.Error()
+}
+
+func (cw *csvWriter) Close() error {
+	// Empty collections still get a header
+	return cw.Write(nil)
+}
+
+var moxfieldHeader = []string{"Count", "Tradelist Count", "Name", "Edition", "Condition", "Language", "Foil", "Tags", "Last Modified", "Collector Number", "Alter", "Proxy", "Purchase Price"}
+
+func moxfieldRecord(card domain.OwnedCard) []string {
+	cardData := domain.NormalizeCardVariant(card.CardData)
+	foil := ""
+	if cardData.Finish != domain.CardFinishNonFoil {
+		foil = string(cardData.Finish)
+	}
+	return []string{
+		strconv.Itoa(card.Count),
+		"0",
+		card.CardData.Name,
+		strings.ToLower(card.CardData.SetCode),
+		"Near Mint",
+		languageName(cardData.Language),
+		foil,
+		strings.Join(card.Tags, ","),
+		card.UpdatedAt.Time().UTC().Format("2006-01-02 15:04:05.000000"),
+		card.CardData.CollectorNumber,
+		"False",
+		"False",
+		"",
+	}
+}
+
+var manaBoxHeader = []string{"Name", "Set code", "Set name", "Collector number", "Foil", "Rarity", "Quantity", "ManaBox ID", "Scryfall ID", "Misprint", "Altered", "Condition", "Language", "Tags"}
+
+func manaBoxRecord(card domain.OwnedCard) []string {
+	cardData := domain.NormalizeCardVariant(card.CardData)
+	foil := "normal"
+	if cardData.Finish != domain.CardFinishNonFoil {
+		foil = string(cardData.Finish)
+	}
+	return []string{
+		card.CardData.Name,
+		strings.ToUpper(card.CardData.SetCode),
+		"",
+		card.CardData.CollectorNumber,
+		foil,
+		string(card.CardData.Rarity),
+		strconv.Itoa(card.Count),
+		"",
+		"",
+		"false",
+		"false",
+		"near_mint",
+		cardData.Language,
+		strings.Join(card.Tags, ","),
+	}
+}
+
+// Language names used by CSV exports, by Scryfall language code
+var languageNames = map[string]string{
+	"en":  "English",
+	"es":  "Spanish",
+	"fr":  "French",
+	"de":  "German",
+	"it":  "Italian",
+	"pt":  "Portuguese",
+	"ja":  "Japanese",
+	"ko":  "Korean",
+	"ru":  "Russian",
+	"zhs": "Chinese Simplified",
+	"zht": "Chinese Traditional",
+	"ph":  "Phyrexian",
+}
+
+func languageName(code string) string {
+	if name, ok := languageNames[code]; ok {
+		return name
+	}
+	return languageNames[domain.DEFAULT_CARD_LANGUAGE]
+}
+
+// arenaWriter writes one "4 Name (SET) 123" line per card. The format has no room for tags.
+type arenaWriter struct {
+	w io.Writer
+}
+
+func (aw *arenaWriter) Write(cards []domain.OwnedCard) error {
+	for _, card := range cards {
+		_, err := fmt.Fprintf(aw.w, "%d %s (%s) %s\n", card.Count, card.CardData.Name, strings.ToUpper(card.CardData.SetCode), card.CardData.CollectorNumber)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (aw *arenaWriter) Close() error {
+	return nil
+}
+
+// jsonWriter writes the cards as a single JSON array, one element at a time
+type jsonWriter struct {
+	w       io.Writer
+	written int
+}
+
+func (jw *jsonWriter) Write(cards []domain.OwnedCard) error {
+	for _, card := range cards {
+		separator := ","
+		if jw.written == 0 {
+			separator = "["
+		}
+		data, err := json.Marshal(card)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(jw.w, separator); err != nil {
+			return err
+		}
+		if _, err := jw.w.Write(data); err != nil {
+			return err
+		}
+		jw.written++
+	}
+	return nil
+}
+
+func (jw *jsonWriter) Close() error {
+	closing := "]"
+	if jw.written == 0 {
+		closing = "[]"
+	}
+	_, err := io.WriteString(jw.w, closing)
+	return err
+}
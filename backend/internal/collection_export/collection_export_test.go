@@ -0,0 +1,128 @@
+package collectionexport
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/joaquinleonarg/wdml-mtg/backend/domain"
+	collectionimport "github.com/joaquinleonarg/wdml-mtg/backend/internal/collection_import"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var exportedCards = []domain.OwnedCard{
+	{
+		ID:    primitive.NewObjectID(),
+		Count: 3,
+		Tags:  []string{"trade"},
+		CardData: domain.CardData{
+			Name:            "Opt",
+			SetCode:         "XLN",
+			CollectorNumber: "65",
+			Rarity:          domain.CardRarityCommon,
+		},
+	},
+	{
+		ID:    primitive.NewObjectID(),
+		Count: 1,
+		Tags:  []string{},
+		CardData: domain.CardData{
+			Name:            "Fire // Ice",
+			SetCode:         "MH2",
+			CollectorNumber: "290",
+			Rarity:          domain.CardRarityUncommon,
+			Finish:          domain.CardFinishFoil,
+			Language:        "ja",
+		},
+	},
+}
+
+// export writes the cards one at a time, the way collections are streamed
+func export(t *testing.T, format Format, cards []domain.OwnedCard) string {
+	var output strings.Builder
+	writer, err := NewWriter(format, &output)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, card := range cards {
+		if err := writer.Write([]domain.OwnedCard{card}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return output.String()
+}
+
+func TestExportCanBeImported(t *testing.T) {
+	tests := []struct {
+		format         Format
+		importedFormat collectionimport.Format
+		expected       []collectionimport.Row
+	}{
+		{FormatMoxfield, collectionimport.FormatMoxfield, []collectionimport.Row{
+			{Line: 2, Count: 3, Name: "Opt", SetCode: "XLN", CollectorNumber: "65", Finish: domain.CardFinishNonFoil, Language: "en"},
+			{Line: 3, Count: 1, Name: "Fire // Ice", SetCode: "MH2", CollectorNumber: "290", Finish: domain.CardFinishFoil, Language: "ja"},
+		}},
+		{FormatManaBox, collectionimport.FormatManaBox, []collectionimport.Row{
+			{Line: 2, Count: 3, Name: "Opt", SetCode: "XLN", CollectorNumber: "65", Finish: domain.CardFinishNonFoil, Language: "en"},
+			{Line: 3, Count: 1, Name: "Fire // Ice", SetCode: "MH2", CollectorNumber: "290", Finish: domain.CardFinishFoil, Language: "ja"},
+		}},
+		{FormatArena, collectionimport.FormatArena, []collectionimport.Row{
+			{Line: 1, Count: 3, Name: "Opt", SetCode: "XLN", CollectorNumber: "65"},
+			{Line: 2, Count: 1, Name: "Fire // Ice", SetCode: "MH2", CollectorNumber: "290"},
+		}},
+	}
+	for _, test := range tests {
+		t.Run(string(test.format), func(t *testing.T) {
+			raw := export(t, test.format, exportedCards)
+			format, rows, unmatched, err := collectionimport.Parse(raw)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if format != test.importedFormat || len(unmatched) > 0 {
+				t.Errorf("expected format %s without unmatched rows, got %s and %+v", test.importedFormat, format, unmatched)
+			}
+			if !reflect.DeepEqual(rows, test.expected) {
+				t.Errorf("expected rows %+v, got %+v", test.expected, rows)
+			}
+		})
+	}
+}
+
+func TestExportEmptyCollection(t *testing.T) {
+	tests := []struct {
+		format   Format
+		expected string
+	}{
+		{FormatMoxfield, strings.Join(moxfieldHeader, ",") + "\n"},
+		{FormatManaBox, strings.Join(manaBoxHeader, ",") + "\n"},
+		{FormatArena, ""},
+		{FormatJSON, "[]"},
+	}
+	for _, test := range tests {
+		t.Run(string(test.format), func(t *testing.T) {
+			if raw := export(t, test.format, nil); raw != test.expected {
+				t.Errorf("expected %q, got %q", test.expected, raw)
+			}
+		})
+	}
+}
+
+func TestExportJSON(t *testing.T) {
+	var cards []domain.OwnedCard
+	if err := json.Unmarshal([]byte(export(t, FormatJSON, exportedCards)), &cards); err != nil {
+		t.Fatalf("expected a valid JSON array: %v", err)
+	}
+	if len(cards) != len(exportedCards) || cards[1].CardData.Name != "Fire // Ice" || cards[0].Tags[0] != "trade" {
+		t.Errorf("unexpected cards %+v", cards)
+	}
+}
+
+func TestNewWriterUnknownFormat(t *testing.T) {
+	if _, err := NewWriter("cef_unknown", &strings.Builder{}); err == nil {
+		t.Errorf("expected an error for an unknown format")
+	}
+}
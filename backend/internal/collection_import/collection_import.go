@@ -0,0 +1,315 @@
+package collectionimport
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+
+	scryfallapi "github.com/BlueMonday/go-scryfall"
+	"github.com/joaquinleonarg/wdml-mtg/backend/domain"
+	"github.com/joaquinleonarg/wdml-mtg/backend/internal/decklist"
+)
+
+type Format string
+
+const (
+	FormatManaBox    Format = "cif_manabox"
+	FormatMoxfield   Format = "cif_moxfield"
+	FormatDeckbox    Format = "cif_deckbox"
+	FormatDelverLens Format = "cif_delver_lens"
+	FormatTCGPlayer  Format = "cif_tcgplayer"
+	FormatArena      Format = "cif_arena"
+)
+
+// Row is a single card of an imported collection, as written on the file
+type Row struct {
+	Line            int    `json:"line"`
+	Count           int    `json:"count"`
+	Name            string `json:"name"`
+	SetCode         string `json:"set_code"`
+	CollectorNumber string `json:"collector_number"`
+	ScryfallID      string `json:"scryfall_id"`
+	// Non foil on CSV rows unless the foil column says otherwise. Empty on Arena lists, which have no finish, so the
+	// printing's default finish is used.
+	Finish   domain.CardFinish `json:"finish"`
+	Language string            `json:"language"`
+}
+
+// UnmatchedRow is a line of the file that couldn't be turned into a card
+type UnmatchedRow struct {
+	Line   int    `json:"line"`
+	Raw    string `json:"raw"`
+	Reason string `json:"reason"`
+}
+
+// csvFormat describes the columns of a CSV export. Column names are compared in lowercase.
+type csvFormat struct {
+	Format Format
+	// Columns that only this format has, used to detect it
+	Signature       []string
+	Count           string
+	Name            string
+	SetCode         string
+	CollectorNumber string
+	ScryfallID      string
+	Foil            string
+	// Value of the foil column for foil cards, any non empty value if not set
+	FoilValue string
+	Language  string
+}
+
+// Formats are checked in order, so the ones with more specific signatures go first
+var csvFormats = []csvFormat{
+	{
+		Format:          FormatManaBox,
+		Signature:       []string{"manabox id"},
+		Count:           "quantity",
+		Name:            "name",
+		SetCode:         "set code",
+		CollectorNumber: "collector number",
+		ScryfallID:      "scryfall id",
+		Foil:            "foil",
+		FoilValue:       "foil",
+		Language:        "language",
+	},
+	{
+		Format:          FormatTCGPlayer,
+		Signature:       []string{"product id", "card number"},
+		Count:           "quantity",
+		Name:            "name",
+		SetCode:         "set code",
+		CollectorNumber: "card number",
+		Foil:            "printing",
+		FoilValue:       "foil",
+		Language:        "language",
+	},
+	{
+		Format:          FormatDelverLens,
+		Signature:       []string{"collector's number"},
+		Count:           "quantity",
+		Name:            "name",
+		SetCode:         "edition code",
+		CollectorNumber: "collector's number",
+		ScryfallID:      "scryfall id",
+		Foil:            "foil",
+		Language:        "language",
+	},
+	{
+		Format:          FormatMoxfield,
+		Signature:       []string{"tradelist count", "collector number"},
+		Count:           "count",
+		Name:            "name",
+		SetCode:         "edition",
+		CollectorNumber: "collector number",
+		Foil:            "foil",
+		Language:        "language",
+	},
+	{
+		Format:          FormatDeckbox,
+		Signature:       []string{"tradelist count", "card number"},
+		Count:           "count",
+		Name:            "name",
+		SetCode:         "edition code",
+		CollectorNumber: "card number",
+		Foil:            "foil",
+		Language:        "language",
+	},
+}
+
+// Parse reads a collection export and returns its format and rows, plus the lines it couldn't understand.
+// CSV exports are detected by their header; anything else is read as an Arena style list ("4 Name (SET) 123"),
+// since card names can have commas too.
+func Parse(raw string) (Format, []Row, []UnmatchedRow, error) {
+	reader := csv.NewReader(strings.NewReader(raw))
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	header, err := reader.Read()
+	if err == nil {
+		columns := make(map[string]int, len(header))
+		for i, column := range header {
+			columns[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(column, "\uFEFF")))] = i
+		}
+		for _, format := range csvFormats {
+			if hasColumns(columns, format.Signature...) {
+				rows, unmatched, err := parseCSV(reader, format, columns)
+				return format.Format, rows, unmatched, err
+			}
+		}
+	}
+
+	rows, unmatched := parseArena(raw)
+	if len(rows) == 0 {
+		return "", nil, nil, fmt.Errorf("unknown collection format")
+	}
+	return FormatArena, rows, unmatched, nil
+}
+
+func hasColumns(columns map[string]int, names ...string) bool {
+	for _, name := range names {
+		if _, ok := columns[name]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func parseCSV(reader *csv.Reader, format csvFormat, columns map[string]int) ([]Row, []UnmatchedRow, error) {
+	if !hasColumns(columns, format.Count, format.Name) {
+		return nil, nil, fmt.Errorf("missing quantity or name columns")
+	}
+	get := func(record []string, column string) string {
+		index, ok := columns[column]
+		if column == "" || !ok || index >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[index])
+	}
+
+	rows := []Row{}
+	unmatched := []UnmatchedRow{}
+	// The header is the first line
+	line := 1
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		line++
+		if err != nil {
+			unmatched = append(unmatched, UnmatchedRow{Line: line, Reason: err.Error()})
+			continue
+		}
+		if slices.IndexFunc(record, func(field string) bool { return strings.TrimSpace(field) != "" }) == -1 {
+			continue
+		}
+
+		count, err := strconv.Atoi(get(record, format.Count))
+		if err != nil || count <= 0 {
+			unmatched = append(unmatched, UnmatchedRow{Line: line, Raw: strings.Join(record, ","), Reason: "invalid quantity"})
+			continue
+		}
+		row := Row{
+			Line:            line,
+			Count:           count,
+			Name:            get(record, format.Name),
+			SetCode:         strings.ToUpper(get(record, format.SetCode)),
+			CollectorNumber: get(record, format.CollectorNumber),
+			ScryfallID:      get(record, format.ScryfallID),
+		}
+		row.Finish = parseFinish(get(record, format.Foil), format.FoilValue)
+		row.Language = parseLanguage(get(record, format.Language))
+		if row.Name == "" && row.ScryfallID == "" && (row.SetCode == "" || row.CollectorNumber == "") {
+			unmatched = append(unmatched, UnmatchedRow{Line: line, Raw: strings.Join(record, ","), Reason: "missing card name"})
+			continue
+		}
+		rows = append(rows, row)
+	}
+	return rows, unmatched, nil
+}
+
+// parseFinish reads the foil column of a row. Etched foils are told apart by name, since no format has a column
+// for them.
+func parseFinish(foil, foilValue string) domain.CardFinish {
+	foil = strings.ToLower(foil)
+	switch {
+	case foil == "" || foil == "false" || foil == "normal":
+		return domain.CardFinishNonFoil
+	case strings.Contains(foil, "etched"):
+		return domain.CardFinishEtched
+	case foilValue == "" || strings.Contains(foil, foilValue):
+		return domain.CardFinishFoil
+	}
+	return domain.CardFinishNonFoil
+}
+
+// Scryfall language codes by the names exports use
+var languageCodes = map[string]string{
+	"english":             "en",
+	"spanish":             "es",
+	"french":              "fr",
+	"german":              "de",
+	"italian":             "it",
+	"portuguese":          "pt",
+	"japanese":            "ja",
+	"korean":              "ko",
+	"russian":             "ru",
+	"chinese simplified":  "zhs",
+	"simplified chinese":  "zhs",
+	"chinese traditional": "zht",
+	"traditional chinese": "zht",
+	"phyrexian":           "ph",
+}
+
+// parseLanguage returns the Scryfall code of a language column, which can have either the code or the name.
+// Unknown languages are left empty.
+func parseLanguage(language string) string {
+	language = strings.ToLower(strings.TrimSpace(language))
+	if code, ok := languageCodes[language]; ok {
+		return code
+	}
+	for _, code := range languageCodes {
+		if code == language {
+			return code
+		}
+	}
+	return ""
+}
+
+func parseArena(raw string) ([]Row, []UnmatchedRow) {
+	rows := []Row{}
+	unmatched := []UnmatchedRow{}
+	for i, line := range strings.Split(raw, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		entries, unparsed := decklist.Parse(line)
+		if len(unparsed) > 0 {
+			unmatched = append(unmatched, UnmatchedRow{Line: i + 1, Raw: line, Reason: "invalid line"})
+			continue
+		}
+		// Board headers don't have entries
+		for _, entry := range entries {
+			rows = append(rows, Row{
+				Line:            i + 1,
+				Count:           entry.Count,
+				Name:            entry.Name,
+				SetCode:         entry.SetCode,
+				CollectorNumber: entry.CollectorNumber,
+			})
+		}
+	}
+	return rows, unmatched
+}
+
+// Identifier returns the most precise Scryfall identifier available for a row
+func (row Row) Identifier() scryfallapi.CardIdentifier {
+	switch {
+	case row.ScryfallID != "":
+		return scryfallapi.CardIdentifier{ID: row.ScryfallID}
+	case row.SetCode != "" && row.CollectorNumber != "":
+		return scryfallapi.CardIdentifier{Set: strings.ToLower(row.SetCode), CollectorNumber: row.CollectorNumber}
+	case row.SetCode != "":
+		return scryfallapi.CardIdentifier{Name: row.Name, Set: strings.ToLower(row.SetCode)}
+	default:
+		return scryfallapi.CardIdentifier{Name: row.Name}
+	}
+}
+
+// Matches reports if a card returned by Scryfall is the one the row refers to
+func (row Row) Matches(card scryfallapi.Card) bool {
+	identifier := row.Identifier()
+	switch {
+	case identifier.ID != "":
+		return card.ID == identifier.ID
+	case identifier.CollectorNumber != "":
+		return strings.EqualFold(card.Set, identifier.Set) && card.CollectorNumber == identifier.CollectorNumber
+	case identifier.Set != "":
+		return strings.EqualFold(card.Set, identifier.Set) && decklist.NormalizeName(card.Name) == decklist.NormalizeName(identifier.Name)
+	default:
+		return decklist.NormalizeName(card.Name) == decklist.NormalizeName(identifier.Name)
+	}
+}
@@ -0,0 +1,185 @@
+package collectionimport
+
+import (
+	"reflect"
+	"testing"
+
+	scryfallapi "github.com/BlueMonday/go-scryfall"
+	"github.com/joaquinleonarg/wdml-mtg/backend/domain"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name              string
+		raw               string
+		expectedFormat    Format
+		expectedRows      []Row
+		expectedUnmatched []UnmatchedRow
+	}{
+		{
+			name: "manabox",
+			raw: "Name,Set code,Collector number,Foil,Quantity,ManaBox ID,Scryfall ID,Language\n" +
+				"Lightning Bolt,m11,149,normal,2,1,abc,en\n",
+			expectedFormat: FormatManaBox,
+			expectedRows: []Row{
+				{Line: 2, Count: 2, Name: "Lightning Bolt", SetCode: "M11", CollectorNumber: "149", ScryfallID: "abc", Finish: "nonfoil", Language: "en"},
+			},
+		},
+		{
+			name: "moxfield with byte order mark",
+			raw: "\uFEFFCount,Tradelist Count,Name,Edition,Condition,Language,Foil,Collector Number\n" +
+				"1,0,\"Fire // Ice\",mh2,NM,English,foil,290\n",
+			expectedFormat: FormatMoxfield,
+			expectedRows: []Row{
+				{Line: 2, Count: 1, Name: "Fire // Ice", SetCode: "MH2", CollectorNumber: "290", Finish: "foil", Language: "en"},
+			},
+		},
+		{
+			name: "deckbox with invalid rows",
+			raw: "Count,Tradelist Count,Name,Edition Code,Card Number,Foil\n" +
+				"x,0,Opt,XLN,65,\n" +
+				",,,,,\n" +
+				"1,0,,,,\n" +
+				"3,0,Opt,XLN,65,\n",
+			expectedFormat: FormatDeckbox,
+			expectedRows: []Row{
+				{Line: 5, Count: 3, Name: "Opt", SetCode: "XLN", CollectorNumber: "65", Finish: "nonfoil"},
+			},
+			expectedUnmatched: []UnmatchedRow{
+				{Line: 2, Raw: "x,0,Opt,XLN,65,", Reason: "invalid quantity"},
+				{Line: 4, Raw: "1,0,,,,", Reason: "missing card name"},
+			},
+		},
+		{
+			name:           "arena list",
+			raw:            "Deck\n4 Opt (XLN) 65\n0 Shock\n\nSol Ring\n",
+			expectedFormat: FormatArena,
+			expectedRows: []Row{
+				{Line: 2, Count: 4, Name: "Opt", SetCode: "XLN", CollectorNumber: "65"},
+				{Line: 5, Count: 1, Name: "Sol Ring"},
+			},
+			expectedUnmatched: []UnmatchedRow{
+				{Line: 3, Raw: "0 Shock", Reason: "invalid line"},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			format, rows, unmatched, err := Parse(test.raw)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if format != test.expectedFormat {
+				t.Errorf("expected format %s, got %s", test.expectedFormat, format)
+			}
+			if !reflect.DeepEqual(rows, test.expectedRows) {
+				t.Errorf("expected rows %+v, got %+v", test.expectedRows, rows)
+			}
+			if test.expectedUnmatched == nil {
+				test.expectedUnmatched = []UnmatchedRow{}
+			}
+			if !reflect.DeepEqual(unmatched, test.expectedUnmatched) {
+				t.Errorf("expected unmatched %+v, got %+v", test.expectedUnmatched, unmatched)
+			}
+		})
+	}
+}
+
+func TestParseUnknownFormat(t *testing.T) {
+	if _, _, _, err := Parse("\n\n"); err == nil {
+		t.Errorf("expected an error for an empty file")
+	}
+}
+
+func TestRowIdentifier(t *testing.T) {
+	tests := []struct {
+		name     string
+		row      Row
+		expected scryfallapi.CardIdentifier
+		matches  scryfallapi.Card
+	}{
+		{
+			name:     "scryfall id",
+			row:      Row{Name: "Opt", SetCode: "XLN", CollectorNumber: "65", ScryfallID: "abc"},
+			expected: scryfallapi.CardIdentifier{ID: "abc"},
+			matches:  scryfallapi.Card{ID: "abc"},
+		},
+		{
+			name:     "set and collector number",
+			row:      Row{Name: "Opt", SetCode: "XLN", CollectorNumber: "65"},
+			expected: scryfallapi.CardIdentifier{Set: "xln", CollectorNumber: "65"},
+			matches:  scryfallapi.Card{Set: "xln", CollectorNumber: "65"},
+		},
+		{
+			name:     "name and set",
+			row:      Row{Name: "Fire", SetCode: "MH2"},
+			expected: scryfallapi.CardIdentifier{Name: "Fire", Set: "mh2"},
+			matches:  scryfallapi.Card{Name: "Fire // Ice", Set: "mh2"},
+		},
+		{
+			name:     "name",
+			row:      Row{Name: "opt"},
+			expected: scryfallapi.CardIdentifier{Name: "opt"},
+			matches:  scryfallapi.Card{Name: "Opt"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if identifier := test.row.Identifier(); !reflect.DeepEqual(identifier, test.expected) {
+				t.Errorf("expected identifier %+v, got %+v", test.expected, identifier)
+			}
+			if !test.row.Matches(test.matches) {
+				t.Errorf("expected the row to match %+v", test.matches)
+			}
+			if test.row.Matches(scryfallapi.Card{ID: "other", Name: "Other", Set: "oth", CollectorNumber: "1"}) {
+				t.Errorf("expected the row not to match another card")
+			}
+		})
+	}
+}
+
+func TestParseFinish(t *testing.T) {
+	tests := []struct {
+		name      string
+		foil      string
+		foilValue string
+		expected  domain.CardFinish
+	}{
+		{"empty column", "", "", domain.CardFinishNonFoil},
+		{"false", "false", "", domain.CardFinishNonFoil},
+		{"manabox normal", "normal", "foil", domain.CardFinishNonFoil},
+		{"any value is foil", "true", "", domain.CardFinishFoil},
+		{"foil value", "Foil", "foil", domain.CardFinishFoil},
+		{"other printing", "Normal Holo", "foil", domain.CardFinishNonFoil},
+		{"etched", "Etched", "foil", domain.CardFinishEtched},
+		{"etched foil", "etched foil", "", domain.CardFinishEtched},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if finish := parseFinish(test.foil, test.foilValue); finish != test.expected {
+				t.Errorf("expected %s, got %s", test.expected, finish)
+			}
+		})
+	}
+}
+
+func TestParseLanguage(t *testing.T) {
+	tests := []struct {
+		language string
+		expected string
+	}{
+		{"", ""},
+		{"en", "en"},
+		{" Japanese ", "ja"},
+		{"Chinese Simplified", "zhs"},
+		{"ZHT", "zht"},
+		{"klingon", ""},
+	}
+	for _, test := range tests {
+		t.Run(test.language, func(t *testing.T) {
+			if code := parseLanguage(test.language); code != test.expected {
+				t.Errorf("expected %q, got %q", test.expected, code)
+			}
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package collectionstats
+
+import (
+	"slices"
+
+	"github.com/joaquinleonarg/wdml-mtg/backend/domain"
+)
+
+// SetTotals is the amount of unique cards printed on a set, as reported by the card source
+type SetTotals struct {
+	Name     string                    `json:"name"`
+	Unique   int                       `json:"unique"`
+	ByRarity map[domain.CardRarity]int `json:"by_rarity"`
+}
+
+type CollectionStats struct {
+	TotalCards  int               `json:"total_cards"`
+	UniqueCards int               `json:"unique_cards"`
+	Value       int               `json:"value"`
+	Colors      map[string]int    `json:"colors"`
+	Types       map[string]int    `json:"types"`
+	Sets        []SetCompletion   `json:"sets"`
+	Rarities    map[string]Counts `json:"rarities"`
+}
+
+type SetCompletion struct {
+	SetCode  string            `json:"set_code"`
+	Name     string            `json:"name"`
+	Counts   Counts            `json:"counts"`
+	Rarities map[string]Counts `json:"rarities"`
+}
+
+// Counts compares the unique cards owned with the ones that exist. Total is zero when it's unknown.
+type Counts struct {
+	Owned      int     `json:"owned"`
+	Total      int     `json:"total"`
+	Completion float64 `json:"completion"`
+}
+
+var cardTypes = []string{"Creature", "Artifact", "Enchantment", "Planeswalker", "Battle", "Instant", "Sorcery", "Land"}
+
+// Compute calculates the stats of a collection. Cards are unique by name within each set, so different printings
+// of the same card on a set count once.
+func Compute(cards []domain.OwnedCard, setTotals map[string]SetTotals) CollectionStats {
+	stats := CollectionStats{
+		Colors:   map[string]int{"W": 0, "U": 0, "B": 0, "R": 0, "G": 0, "C": 0, "M": 0},
+		Types:    make(map[string]int),
+		Sets:     []SetCompletion{},
+		Rarities: make(map[string]Counts),
+	}
+
+	uniqueNames := make(map[string]bool)
+	// Unique names by set and by set and rarity
+	namesBySet := make(map[string]map[string]bool)
+	namesBySetRarity := make(map[string]map[domain.CardRarity]map[string]bool)
+	setCodes := []string{}
+	for _, card := range cards {
+		if card.Count <= 0 {
+			continue
+		}
+		data := card.CardData
+		stats.TotalCards += card.Count
+		stats.Value += card.Count * domain.CoinsForRarity(data.Rarity)
+		uniqueNames[data.Name] = true
+
+		switch len(data.Colors) {
+		case 0:
+			stats.Colors["C"] += card.Count
+		case 1:
+			stats.Colors[data.Colors[0]] += card.Count
+		default:
+			stats.Colors["M"] += card.Count
+		}
+		for _, cardType := range cardTypes {
+			if slices.Contains(data.Types, cardType) {
+				stats.Types[cardType] += card.Count
+			}
+		}
+
+		if _, ok := namesBySet[data.SetCode]; !ok {
+			setCodes = append(setCodes, data.SetCode)
+			namesBySet[data.SetCode] = make(map[string]bool)
+			namesBySetRarity[data.SetCode] = make(map[domain.CardRarity]map[string]bool)
+		}
+		namesBySet[data.SetCode][data.Name] = true
+		if _, ok := namesBySetRarity[data.SetCode][data.Rarity]; !ok {
+			namesBySetRarity[data.SetCode][data.Rarity] = make(map[string]bool)
+		}
+		namesBySetRarity[data.SetCode][data.Rarity][data.Name] = true
+	}
+	stats.UniqueCards = len(uniqueNames)
+
+	// Overall totals are unknown if any set with cards of the rarity has no totals
+	ownedByRarity := make(map[domain.CardRarity]int)
+	totalByRarity := make(map[domain.CardRarity]int)
+	unknownTotals := make(map[domain.CardRarity]bool)
+	slices.Sort(setCodes)
+	for _, setCode := range setCodes {
+		totals := setTotals[setCode]
+		completion := SetCompletion{
+			SetCode:  setCode,
+			Name:     totals.Name,
+			Counts:   newCounts(len(namesBySet[setCode]), totals.Unique),
+			Rarities: make(map[string]Counts),
+		}
+		for _, rarity := range domain.CardRarities {
+			owned := len(namesBySetRarity[setCode][rarity])
+			if owned == 0 && totals.ByRarity[rarity] == 0 {
+				continue
+			}
+			completion.Rarities[string(rarity)] = newCounts(owned, totals.ByRarity[rarity])
+
+			ownedByRarity[rarity] += owned
+			totalByRarity[rarity] += totals.ByRarity[rarity]
+			if totals.ByRarity[rarity] == 0 {
+				unknownTotals[rarity] = true
+			}
+		}
+		stats.Sets = append(stats.Sets, completion)
+	}
+	for rarity, owned := range ownedByRarity {
+		if unknownTotals[rarity] {
+			totalByRarity[rarity] = 0
+		}
+		stats.Rarities[string(rarity)] = newCounts(owned, totalByRarity[rarity])
+	}
+
+	return stats
+}
+
+func newCounts(owned, total int) Counts {
+	counts := Counts{Owned: owned, Total: total}
+	if total > 0 {
+		counts.Completion = float64(owned) / float64(total)
+	}
+	return counts
+}
@@ -0,0 +1,81 @@
+package collectionstats
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/joaquinleonarg/wdml-mtg/backend/domain"
+)
+
+func ownedCard(count int, name, setCode string, rarity domain.CardRarity, colors []string, types []string) domain.OwnedCard {
+	return domain.OwnedCard{
+		Count: count,
+		CardData: domain.CardData{
+			Name:    name,
+			SetCode: setCode,
+			Rarity:  rarity,
+			Colors:  colors,
+			Types:   types,
+		},
+	}
+}
+
+func TestCompute(t *testing.T) {
+	cards := []domain.OwnedCard{
+		ownedCard(4, "Opt", "XLN", domain.CardRarityCommon, []string{"U"}, []string{"Instant"}),
+		// Another printing of the same card on the set counts once
+		ownedCard(1, "Opt", "XLN", domain.CardRarityCommon, []string{"U"}, []string{"Instant"}),
+		ownedCard(2, "Vraska's Contempt", "XLN", domain.CardRarityRare, []string{"B", "G"}, []string{"Instant"}),
+		ownedCard(1, "Opt", "DOM", domain.CardRarityCommon, []string{"U"}, []string{"Instant"}),
+		ownedCard(3, "Evolving Wilds", "DOM", domain.CardRarityCommon, nil, []string{"Land"}),
+		ownedCard(0, "Llanowar Elves", "DOM", domain.CardRarityCommon, []string{"G"}, []string{"Creature"}),
+	}
+	setTotals := map[string]SetTotals{
+		"XLN": {Name: "Ixalan", Unique: 4, ByRarity: map[domain.CardRarity]int{domain.CardRarityCommon: 2, domain.CardRarityRare: 2}},
+	}
+
+	stats := Compute(cards, setTotals)
+
+	if stats.TotalCards != 11 || stats.UniqueCards != 3 {
+		t.Errorf("expected 11 cards and 3 unique ones, got %d and %d", stats.TotalCards, stats.UniqueCards)
+	}
+	expectedValue := 9*domain.CoinsForRarity(domain.CardRarityCommon) + 2*domain.CoinsForRarity(domain.CardRarityRare)
+	if stats.Value != expectedValue {
+		t.Errorf("expected a value of %d, got %d", expectedValue, stats.Value)
+	}
+	if expected := map[string]int{"W": 0, "U": 6, "B": 0, "R": 0, "G": 0, "C": 3, "M": 2}; !reflect.DeepEqual(stats.Colors, expected) {
+		t.Errorf("expected colors %v, got %v", expected, stats.Colors)
+	}
+	if expected := map[string]int{"Instant": 8, "Land": 3}; !reflect.DeepEqual(stats.Types, expected) {
+		t.Errorf("expected types %v, got %v", expected, stats.Types)
+	}
+
+	expectedSets := []SetCompletion{
+		{
+			SetCode:  "DOM",
+			Counts:   Counts{Owned: 2},
+			Rarities: map[string]Counts{string(domain.CardRarityCommon): {Owned: 2}},
+		},
+		{
+			SetCode: "XLN",
+			Name:    "Ixalan",
+			Counts:  Counts{Owned: 2, Total: 4, Completion: 0.5},
+			Rarities: map[string]Counts{
+				string(domain.CardRarityCommon): {Owned: 1, Total: 2, Completion: 0.5},
+				string(domain.CardRarityRare):   {Owned: 1, Total: 2, Completion: 0.5},
+			},
+		},
+	}
+	if !reflect.DeepEqual(stats.Sets, expectedSets) {
+		t.Errorf("expected sets %+v, got %+v", expectedSets, stats.Sets)
+	}
+
+	expectedRarities := map[string]Counts{
+		// Dominaria's totals are unknown
+		string(domain.CardRarityCommon): {Owned: 3},
+		string(domain.CardRarityRare):   {Owned: 1, Total: 2, Completion: 0.5},
+	}
+	if !reflect.DeepEqual(stats.Rarities, expectedRarities) {
+		t.Errorf("expected rarities %+v, got %+v", expectedRarities, stats.Rarities)
+	}
+}
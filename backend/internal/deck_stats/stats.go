@@ -0,0 +1,173 @@
+package deckstats
+
+import (
+	"math"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/joaquinleonarg/wdml-mtg/backend/domain"
+	deckvalidation "github.com/joaquinleonarg/wdml-mtg/backend/internal/deck_validation"
+)
+
+const OPENING_HAND_SIZE = 7
+
+// Turns on the play for which draw odds are calculated
+const DRAW_ODDS_TURNS = 6
+
+type DeckStats struct {
+	// Cards on the library: the mainboard, without the commanders
+	TotalCards   int `json:"total_cards"`
+	LandCount    int `json:"land_count"`
+	NonLandCount int `json:"non_land_count"`
+	// Commanders start on the command zone, so they aren't drawn, but they count on the curve, colors and types
+	Commanders []string `json:"commanders"`
+	// Non land cards with a land on their back face, like modal double-faced cards
+	ModalLandCount   int            `json:"modal_land_count"`
+	AverageManaValue float64        `json:"average_mana_value"`
+	ManaCurve        map[int]int    `json:"mana_curve"`
+	ColorPips        map[string]int `json:"color_pips"`
+	Types            map[string]int `json:"types"`
+	// Chance of drawing exactly N lands on the opening hand, by N
+	OpeningHandLands []float64  `json:"opening_hand_lands"`
+	DrawOdds         []DrawOdds `json:"draw_odds"`
+}
+
+type DrawOdds struct {
+	Name   string `json:"name"`
+	Copies int    `json:"copies"`
+	// Chance of having at least one copy on the opening hand
+	OpeningHand float64 `json:"opening_hand"`
+	// Chance of having seen at least one copy by each turn on the play, starting on turn 1
+	ByTurn []float64 `json:"by_turn"`
+}
+
+var cardTypes = []string{"Creature", "Artifact", "Enchantment", "Planeswalker", "Battle", "Instant", "Sorcery", "Land", "Kindred", "Tribal"}
+
+// Compute calculates the stats for the cards that are played: the mainboard and the commanders. Only the mainboard
+// makes up the library the opening hand and draw odds are calculated from.
+func Compute(entries []deckvalidation.DeckEntry) DeckStats {
+	stats := DeckStats{
+		Commanders:       []string{},
+		ManaCurve:        make(map[int]int),
+		ColorPips:        map[string]int{"W": 0, "U": 0, "B": 0, "R": 0, "G": 0, "C": 0},
+		Types:            make(map[string]int),
+		OpeningHandLands: []float64{},
+		DrawOdds:         []DrawOdds{},
+	}
+
+	copiesByName := make(map[string]int)
+	names := []string{}
+	spellCount := 0
+	totalManaValue := 0
+	for _, entry := range entries {
+		if entry.Board != domain.MainBoard && entry.Board != domain.CommanderBoard {
+			continue
+		}
+		card := entry.Card
+		library := entry.Board == domain.MainBoard
+		if library {
+			stats.TotalCards += entry.Count
+			if _, ok := copiesByName[card.Name]; !ok {
+				names = append(names, card.Name)
+			}
+			copiesByName[card.Name] += entry.Count
+		} else if !slices.Contains(stats.Commanders, card.Name) {
+			stats.Commanders = append(stats.Commanders, card.Name)
+		}
+
+		// Types of every face count, but only the front face makes a card a land
+		faces := domain.CardFaces(card)
+		for _, cardType := range cardTypes {
+			if slices.ContainsFunc(faces, func(face domain.CardFace) bool { return slices.Contains(face.Types, cardType) }) {
+				stats.Types[cardType] += entry.Count
+			}
+		}
+
+		if slices.Contains(faces[0].Types, "Land") {
+			if library {
+				stats.LandCount += entry.Count
+			}
+			continue
+		}
+		if library {
+			if slices.ContainsFunc(faces[1:], func(face domain.CardFace) bool { return slices.Contains(face.Types, "Land") }) {
+				stats.ModalLandCount += entry.Count
+			}
+			stats.NonLandCount += entry.Count
+		}
+		spellCount += entry.Count
+		stats.ManaCurve[card.ManaValue] += entry.Count
+		totalManaValue += card.ManaValue * entry.Count
+		for _, face := range faces {
+			for color, pips := range ColorPips(face.ManaCost) {
+				stats.ColorPips[color] += pips * entry.Count
+			}
+		}
+	}
+	if spellCount > 0 {
+		stats.AverageManaValue = float64(totalManaValue) / float64(spellCount)
+	}
+	if stats.TotalCards == 0 {
+		return stats
+	}
+
+	handSize := min(OPENING_HAND_SIZE, stats.TotalCards)
+	for lands := 0; lands <= handSize; lands++ {
+		stats.OpeningHandLands = append(stats.OpeningHandLands, Hypergeometric(stats.TotalCards, stats.LandCount, handSize, lands))
+	}
+
+	for _, name := range names {
+		odds := DrawOdds{
+			Name:        name,
+			Copies:      copiesByName[name],
+			OpeningHand: AtLeastOne(stats.TotalCards, copiesByName[name], handSize),
+			ByTurn:      make([]float64, 0, DRAW_ODDS_TURNS),
+		}
+		for turn := 1; turn <= DRAW_ODDS_TURNS; turn++ {
+			// On the play, the first draw happens on turn 2
+			seen := min(OPENING_HAND_SIZE+turn-1, stats.TotalCards)
+			odds.ByTurn = append(odds.ByTurn, AtLeastOne(stats.TotalCards, copiesByName[name], seen))
+		}
+		stats.DrawOdds = append(stats.DrawOdds, odds)
+	}
+
+	return stats
+}
+
+var manaSymbolRegex = regexp.MustCompile(`\{([^}]+)\}`)
+
+// ColorPips counts the colored symbols on a mana cost. Hybrid symbols count towards each of their colors,
+// and phyrexian symbols count towards their color.
+func ColorPips(manaCost string) map[string]int {
+	pips := make(map[string]int)
+	for _, symbol := range manaSymbolRegex.FindAllStringSubmatch(manaCost, -1) {
+		for _, part := range strings.Split(symbol[1], "/") {
+			switch part {
+			case "W", "U", "B", "R", "G", "C":
+				pips[part] += 1
+			}
+		}
+	}
+	return pips
+}
+
+// Hypergeometric returns the chance of drawing exactly k successes when drawing from a population
+func Hypergeometric(population, successes, draws, k int) float64 {
+	if k < 0 || k > successes || k > draws || draws-k > population-successes {
+		return 0
+	}
+	return math.Exp(logChoose(successes, k) + logChoose(population-successes, draws-k) - logChoose(population, draws))
+}
+
+// AtLeastOne returns the chance of drawing at least one success when drawing from a population
+func AtLeastOne(population, successes, draws int) float64 {
+	return 1 - Hypergeometric(population, successes, draws, 0)
+}
+
+func logChoose(n, k int) float64 {
+	a, _ := math.Lgamma(float64(n + 1))
+	b, _ := math.Lgamma(float64(k + 1))
+	c, _ := math.Lgamma(float64(n - k + 1))
+	return a - b - c
+}
@@ -0,0 +1,190 @@
+package deckstats
+
+import (
+	"math"
+	"reflect"
+	"slices"
+	"testing"
+
+	"github.com/joaquinleonarg/wdml-mtg/backend/domain"
+	deckvalidation "github.com/joaquinleonarg/wdml-mtg/backend/internal/deck_validation"
+)
+
+func TestColorPips(t *testing.T) {
+	tests := []struct {
+		manaCost string
+		expected map[string]int
+	}{
+		{"", map[string]int{}},
+		{"{3}", map[string]int{}},
+		{"{1}{R}{R}", map[string]int{"R": 2}},
+		{"{W/U}{W/U}", map[string]int{"W": 2, "U": 2}},
+		{"{G/P}{C}", map[string]int{"G": 1, "C": 1}},
+		{"{2/B}{X}", map[string]int{"B": 1}},
+	}
+	for _, test := range tests {
+		t.Run(test.manaCost, func(t *testing.T) {
+			if pips := ColorPips(test.manaCost); !reflect.DeepEqual(pips, test.expected) {
+				t.Errorf("expected %v, got %v", test.expected, pips)
+			}
+		})
+	}
+}
+
+func TestHypergeometric(t *testing.T) {
+	tests := []struct {
+		name                            string
+		population, successes, draws, k int
+		expected                        float64
+	}{
+		{"three lands in a limited hand", 40, 17, 7, 3, 0.3229747966590072},
+		{"more successes than there are", 40, 2, 7, 3, 0},
+		{"more failures than there are", 10, 9, 7, 5, 0},
+		{"every card drawn", 7, 3, 7, 3, 1},
+		{"negative k", 40, 17, 7, -1, 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			chance := Hypergeometric(test.population, test.successes, test.draws, test.k)
+			if math.Abs(chance-test.expected) > 1e-9 {
+				t.Errorf("expected %v, got %v", test.expected, chance)
+			}
+		})
+	}
+}
+
+func TestCompute(t *testing.T) {
+	bolt := domain.CardData{Name: "Lightning Bolt", ManaValue: 1, ManaCost: "{R}", Types: []string{"Instant"}}
+	goblin := domain.CardData{Name: "Goblin Guide", ManaValue: 1, ManaCost: "{R}", Types: []string{"Creature", "Goblin"}}
+	hellkite := domain.CardData{Name: "Thundermaw Hellkite", ManaValue: 5, ManaCost: "{3}{R}{R}", Types: []string{"Creature", "Dragon"}}
+	mountain := domain.CardData{Name: "Mountain", Types: []string{"Basic", "Land", "Mountain"}}
+	modal := domain.CardData{
+		Name:      "Valakut Awakening // Valakut Stoneforge",
+		ManaValue: 3,
+		Faces: []domain.CardFace{
+			{Name: "Valakut Awakening", ManaCost: "{2}{R}", Types: []string{"Instant"}},
+			{Name: "Valakut Stoneforge", Types: []string{"Land"}},
+		},
+	}
+	entries := []deckvalidation.DeckEntry{
+		{Card: bolt, Count: 4, Board: domain.MainBoard},
+		{Card: goblin, Count: 4, Board: domain.MainBoard},
+		{Card: hellkite, Count: 2, Board: domain.MainBoard},
+		{Card: modal, Count: 2, Board: domain.MainBoard},
+		{Card: mountain, Count: 48, Board: domain.MainBoard},
+		{Card: bolt, Count: 4, Board: domain.SideBoard},
+		{Card: hellkite, Count: 1, Board: domain.MaybeBoard},
+	}
+
+	stats := Compute(entries)
+
+	if stats.TotalCards != 60 || stats.LandCount != 48 || stats.NonLandCount != 12 || stats.ModalLandCount != 2 {
+		t.Errorf("expected 60 cards, 48 lands, 12 non lands and 2 modal lands, got %d, %d, %d and %d",
+			stats.TotalCards, stats.LandCount, stats.NonLandCount, stats.ModalLandCount)
+	}
+	if expected := map[int]int{1: 8, 3: 2, 5: 2}; !reflect.DeepEqual(stats.ManaCurve, expected) {
+		t.Errorf("expected mana curve %v, got %v", expected, stats.ManaCurve)
+	}
+	if expected := float64(1*8+3*2+5*2) / 12; math.Abs(stats.AverageManaValue-expected) > 1e-9 {
+		t.Errorf("expected average mana value %v, got %v", expected, stats.AverageManaValue)
+	}
+	if pips := stats.ColorPips["R"]; pips != 4+4+2*2+2 {
+		t.Errorf("expected 14 red pips, got %d", pips)
+	}
+	if expected := map[string]int{"Instant": 6, "Creature": 6, "Land": 50}; !reflect.DeepEqual(stats.Types, expected) {
+		t.Errorf("expected types %v, got %v", expected, stats.Types)
+	}
+	if len(stats.OpeningHandLands) != OPENING_HAND_SIZE+1 {
+		t.Errorf("expected %d opening hand odds, got %d", OPENING_HAND_SIZE+1, len(stats.OpeningHandLands))
+	}
+
+	// Sideboard and maybeboard copies aren't drawn
+	boltOdds := stats.DrawOdds[0]
+	if boltOdds.Name != bolt.Name || boltOdds.Copies != 4 || len(boltOdds.ByTurn) != DRAW_ODDS_TURNS {
+		t.Fatalf("unexpected draw odds %+v", boltOdds)
+	}
+	if math.Abs(boltOdds.OpeningHand-0.3994996257446656) > 1e-9 {
+		t.Errorf("expected opening hand odds 0.3995, got %v", boltOdds.OpeningHand)
+	}
+	if boltOdds.ByTurn[0] != boltOdds.OpeningHand || math.Abs(boltOdds.ByTurn[1]-0.4448204087073323) > 1e-9 {
+		t.Errorf("expected turn 1 odds to be the opening hand's and turn 2 to see one more card, got %v", boltOdds.ByTurn)
+	}
+}
+
+func TestComputeLibrary(t *testing.T) {
+	bolt := domain.CardData{Name: "Lightning Bolt", ManaValue: 1, ManaCost: "{R}", Types: []string{"Instant"}}
+	mountain := domain.CardData{Name: "Mountain", Types: []string{"Basic", "Land", "Mountain"}}
+	atraxa := domain.CardData{
+		Name:      "Atraxa, Praetors' Voice",
+		ManaValue: 4,
+		ManaCost:  "{G}{W}{U}{B}",
+		Types:     []string{"Legendary", "Creature", "Phyrexian", "Angel", "Horror"},
+	}
+	library := []deckvalidation.DeckEntry{
+		{Card: bolt, Count: 4, Board: domain.MainBoard},
+		{Card: mountain, Count: 16, Board: domain.MainBoard},
+	}
+
+	tests := []struct {
+		name               string
+		entries            []deckvalidation.DeckEntry
+		expectedCommanders []string
+		expectedCurve      map[int]int
+		expectedCreatures  int
+	}{
+		{
+			name: "sideboard and maybeboard",
+			entries: append(slices.Clone(library),
+				deckvalidation.DeckEntry{Card: bolt, Count: 4, Board: domain.SideBoard},
+				deckvalidation.DeckEntry{Card: atraxa, Count: 1, Board: domain.MaybeBoard},
+			),
+			expectedCommanders: []string{},
+			expectedCurve:      map[int]int{1: 4},
+		},
+		{
+			name:               "commander",
+			entries:            append(slices.Clone(library), deckvalidation.DeckEntry{Card: atraxa, Count: 1, Board: domain.CommanderBoard}),
+			expectedCommanders: []string{atraxa.Name},
+			expectedCurve:      map[int]int{1: 4, 4: 1},
+			expectedCreatures:  1,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			stats := Compute(test.entries)
+
+			// Only the mainboard is drawn
+			if stats.TotalCards != 20 || stats.LandCount != 16 || stats.NonLandCount != 4 {
+				t.Errorf("expected 20 cards, 16 lands and 4 non lands, got %d, %d and %d",
+					stats.TotalCards, stats.LandCount, stats.NonLandCount)
+			}
+			if len(stats.DrawOdds) != 2 || stats.DrawOdds[0].Name != bolt.Name || stats.DrawOdds[1].Name != mountain.Name {
+				t.Fatalf("expected draw odds for the mainboard cards, got %+v", stats.DrawOdds)
+			}
+			if expected := AtLeastOne(20, 4, OPENING_HAND_SIZE); math.Abs(stats.DrawOdds[0].OpeningHand-expected) > 1e-9 {
+				t.Errorf("expected opening hand odds %v, got %v", expected, stats.DrawOdds[0].OpeningHand)
+			}
+			if len(stats.OpeningHandLands) != OPENING_HAND_SIZE+1 {
+				t.Errorf("expected %d opening hand odds, got %d", OPENING_HAND_SIZE+1, len(stats.OpeningHandLands))
+			}
+
+			// But commanders are cast like any other spell
+			if !reflect.DeepEqual(stats.Commanders, test.expectedCommanders) {
+				t.Errorf("expected commanders %v, got %v", test.expectedCommanders, stats.Commanders)
+			}
+			if !reflect.DeepEqual(stats.ManaCurve, test.expectedCurve) {
+				t.Errorf("expected mana curve %v, got %v", test.expectedCurve, stats.ManaCurve)
+			}
+			if stats.Types["Creature"] != test.expectedCreatures {
+				t.Errorf("expected %d creatures, got %d", test.expectedCreatures, stats.Types["Creature"])
+			}
+		})
+	}
+}
+
+func TestComputeEmptyDeck(t *testing.T) {
+	stats := Compute(nil)
+	if stats.TotalCards != 0 || len(stats.OpeningHandLands) != 0 || len(stats.DrawOdds) != 0 {
+		t.Errorf("expected empty stats, got %+v", stats)
+	}
+}
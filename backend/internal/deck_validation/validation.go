@@ -0,0 +1,271 @@
+package deckvalidation
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/joaquinleonarg/wdml-mtg/backend/domain"
+)
+
+// DeckEntry is a card on a deck, with its data already resolved from the collection
+type DeckEntry struct {
+	Card  domain.CardData
+	Count int
+	Board domain.DeckBoard
+}
+
+type Violation struct {
+	Rule     string `json:"rule"`
+	Message  string `json:"message"`
+	CardName string `json:"card_name,omitempty"`
+}
+
+// Rule checks a deck and returns every violation it finds
+type Rule func(entries []DeckEntry) []Violation
+
+type Profile struct {
+	Format domain.DeckFormat
+	Rules  []Rule
+}
+
+var Profiles = map[domain.DeckFormat]Profile{
+	domain.DeckFormatLimited: {
+		Format: domain.DeckFormatLimited,
+		Rules: []Rule{
+			MinMainboardSize(40),
+		},
+	},
+	domain.DeckFormatConstructed: {
+		Format: domain.DeckFormatConstructed,
+		Rules: []Rule{
+			MinMainboardSize(60),
+			MaxSideboardSize(15),
+			MaxCopies(4),
+		},
+	},
+	domain.DeckFormatCommander: {
+		Format: domain.DeckFormatCommander,
+		Rules: []Rule{
+			ExactDeckSize(100),
+			MaxCopies(1),
+			ValidCommanders,
+			CommanderColorIdentity,
+		},
+	},
+}
+
+// Validate runs every rule of the profile plus the banlist against the deck
+func Validate(profile Profile, entries []DeckEntry, banlist []string) []Violation {
+	violations := []Violation{}
+	for _, rule := range profile.Rules {
+		violations = append(violations, rule(entries)...)
+	}
+	violations = append(violations, Banlist(banlist)(entries)...)
+	return violations
+}
+
+// FormatForGamemode returns the deck format a match of the given gamemode is played with
+func FormatForGamemode(gamemode domain.Gamemode, fallback domain.DeckFormat) domain.DeckFormat {
+	if gamemode == domain.Commander {
+		return domain.DeckFormatCommander
+	}
+	return fallback
+}
+
+func countBoard(entries []DeckEntry, boards ...domain.DeckBoard) int {
+	total := 0
+	for _, entry := range entries {
+		if slices.Contains(boards, entry.Board) {
+			total += entry.Count
+		}
+	}
+	return total
+}
+
+func MinMainboardSize(size int) Rule {
+	return func(entries []DeckEntry) []Violation {
+		if count := countBoard(entries, domain.MainBoard, domain.CommanderBoard); count < size {
+			return []Violation{{
+				Rule:    "min_deck_size",
+				Message: fmt.Sprintf("deck has %d cards, needs at least %d", count, size),
+			}}
+		}
+		return nil
+	}
+}
+
+func ExactDeckSize(size int) Rule {
+	return func(entries []DeckEntry) []Violation {
+		if count := countBoard(entries, domain.MainBoard, domain.CommanderBoard); count != size {
+			return []Violation{{
+				Rule:    "exact_deck_size",
+				Message: fmt.Sprintf("deck has %d cards, needs exactly %d", count, size),
+			}}
+		}
+		return nil
+	}
+}
+
+func MaxSideboardSize(size int) Rule {
+	return func(entries []DeckEntry) []Violation {
+		if count := countBoard(entries, domain.SideBoard); count > size {
+			return []Violation{{
+				Rule:    "max_sideboard_size",
+				Message: fmt.Sprintf("sideboard has %d cards, can have at most %d", count, size),
+			}}
+		}
+		return nil
+	}
+}
+
+var anyNumberRegex = regexp.MustCompile(`(?i)a deck can have any number of cards named`)
+
+// IgnoresCopyLimit reports if a card can have any number of copies on a deck, like basic lands or Relentless Rats
+func IgnoresCopyLimit(card domain.CardData) bool {
+	return slices.Contains(card.Types, "Basic") || anyNumberRegex.MatchString(card.Oracle)
+}
+
+// MaxCopies limits the copies of each card, by name, across the main deck and sideboard
+func MaxCopies(copies int) Rule {
+	return func(entries []DeckEntry) []Violation {
+		countByName := make(map[string]int)
+		names := []string{}
+		for _, entry := range entries {
+			if entry.Board == domain.MaybeBoard || IgnoresCopyLimit(entry.Card) {
+				continue
+			}
+			if _, ok := countByName[entry.Card.Name]; !ok {
+				names = append(names, entry.Card.Name)
+			}
+			countByName[entry.Card.Name] += entry.Count
+		}
+
+		violations := []Violation{}
+		for _, name := range names {
+			if countByName[name] > copies {
+				violations = append(violations, Violation{
+					Rule:     "max_copies",
+					Message:  fmt.Sprintf("deck has %d copies, can have at most %d", countByName[name], copies),
+					CardName: name,
+				})
+			}
+		}
+		return violations
+	}
+}
+
+func ValidCommanders(entries []DeckEntry) []Violation {
+	commanders := []domain.CardData{}
+	for _, entry := range entries {
+		if entry.Board == domain.CommanderBoard {
+			for i := 0; i < entry.Count; i++ {
+				commanders = append(commanders, entry.Card)
+			}
+		}
+	}
+	if len(commanders) == 0 || len(commanders) > 2 {
+		return []Violation{{
+			Rule:    "commander_count",
+			Message: fmt.Sprintf("deck has %d commanders, needs one or two", len(commanders)),
+		}}
+	}
+
+	violations := []Violation{}
+	for _, commander := range commanders {
+		isLegendaryCreature := slices.Contains(commander.Types, "Legendary") && slices.Contains(commander.Types, "Creature")
+		if !isLegendaryCreature && !strings.Contains(strings.ToLower(commander.Oracle), "can be your commander") {
+			violations = append(violations, Violation{
+				Rule:     "invalid_commander",
+				Message:  "commander must be a legendary creature",
+				CardName: commander.Name,
+			})
+		}
+	}
+	if len(commanders) == 2 {
+		for _, commander := range commanders {
+			if !strings.Contains(strings.ToLower(commander.Oracle), "partner") {
+				violations = append(violations, Violation{
+					Rule:     "invalid_commander",
+					Message:  "two commanders are only allowed if both have partner",
+					CardName: commander.Name,
+				})
+			}
+		}
+	}
+	return violations
+}
+
+var manaSymbolRegex = regexp.MustCompile(`\{([^}]+)\}`)
+
+// ColorIdentity returns the color identity of a card. Cards stored before the identity was tracked
+// have it derived from their colors and the mana symbols on their cost and rules text.
+func ColorIdentity(card domain.CardData) []string {
+	if len(card.ColorIdentity) > 0 {
+		return card.ColorIdentity
+	}
+	identity := []string{}
+	for _, color := range card.Colors {
+		if !slices.Contains(identity, color) {
+			identity = append(identity, color)
+		}
+	}
+	for _, symbol := range manaSymbolRegex.FindAllStringSubmatch(card.ManaCost+card.Oracle, -1) {
+		for _, color := range []string{"W", "U", "B", "R", "G"} {
+			if strings.Contains(symbol[1], color) && !slices.Contains(identity, color) {
+				identity = append(identity, color)
+			}
+		}
+	}
+	return identity
+}
+
+func CommanderColorIdentity(entries []DeckEntry) []Violation {
+	allowed := []string{}
+	for _, entry := range entries {
+		if entry.Board == domain.CommanderBoard {
+			allowed = append(allowed, ColorIdentity(entry.Card)...)
+		}
+	}
+
+	violations := []Violation{}
+	for _, entry := range entries {
+		if entry.Board != domain.MainBoard {
+			continue
+		}
+		for _, color := range ColorIdentity(entry.Card) {
+			if !slices.Contains(allowed, color) {
+				violations = append(violations, Violation{
+					Rule:     "color_identity",
+					Message:  fmt.Sprintf("card's color identity includes %s, which is not on the commander's", color),
+					CardName: entry.Card.Name,
+				})
+				break
+			}
+		}
+	}
+	return violations
+}
+
+func Banlist(banned []string) Rule {
+	return func(entries []DeckEntry) []Violation {
+		violations := []Violation{}
+		for _, entry := range entries {
+			if entry.Board == domain.MaybeBoard {
+				continue
+			}
+			for _, bannedName := range banned {
+				if strings.EqualFold(entry.Card.Name, bannedName) {
+					violations = append(violations, Violation{
+						Rule:     "banned",
+						Message:  "card is banned on this tournament",
+						CardName: entry.Card.Name,
+					})
+					break
+				}
+			}
+		}
+		return violations
+	}
+}
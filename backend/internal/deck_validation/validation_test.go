@@ -0,0 +1,166 @@
+package deckvalidation
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/joaquinleonarg/wdml-mtg/backend/domain"
+)
+
+func entry(name string, count int, board domain.DeckBoard) DeckEntry {
+	return DeckEntry{Card: domain.CardData{Name: name, Types: []string{"Instant"}}, Count: count, Board: board}
+}
+
+func basicLand(name string, count int) DeckEntry {
+	return DeckEntry{Card: domain.CardData{Name: name, Types: []string{"Basic", "Land"}}, Count: count, Board: domain.MainBoard}
+}
+
+func commander(name string, colors []string, oracle string) DeckEntry {
+	return DeckEntry{
+		Card: domain.CardData{
+			Name:          name,
+			Types:         []string{"Legendary", "Creature"},
+			ColorIdentity: colors,
+			Oracle:        oracle,
+		},
+		Count: 1,
+		Board: domain.CommanderBoard,
+	}
+}
+
+func violationRules(violations []Violation) []string {
+	rules := []string{}
+	for _, violation := range violations {
+		rules = append(rules, violation.Rule)
+	}
+	return rules
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		format   domain.DeckFormat
+		entries  []DeckEntry
+		banlist  []string
+		expected []string
+	}{
+		{
+			name:     "legal limited deck",
+			format:   domain.DeckFormatLimited,
+			entries:  []DeckEntry{entry("Shock", 23, domain.MainBoard), basicLand("Mountain", 17)},
+			expected: []string{},
+		},
+		{
+			name:     "small limited deck",
+			format:   domain.DeckFormatLimited,
+			entries:  []DeckEntry{entry("Shock", 22, domain.MainBoard), basicLand("Mountain", 17)},
+			expected: []string{"min_deck_size"},
+		},
+		{
+			name:   "too many copies across main and sideboard",
+			format: domain.DeckFormatConstructed,
+			entries: []DeckEntry{
+				entry("Shock", 3, domain.MainBoard),
+				entry("Shock", 2, domain.SideBoard),
+				basicLand("Mountain", 57),
+			},
+			expected: []string{"max_copies"},
+		},
+		{
+			name:   "maybeboard and basics ignore the copy limit",
+			format: domain.DeckFormatConstructed,
+			entries: []DeckEntry{
+				entry("Shock", 4, domain.MainBoard),
+				entry("Shock", 4, domain.MaybeBoard),
+				basicLand("Mountain", 56),
+			},
+			expected: []string{},
+		},
+		{
+			name:   "large sideboard",
+			format: domain.DeckFormatConstructed,
+			entries: []DeckEntry{
+				basicLand("Mountain", 60),
+				{Card: domain.CardData{Name: "Island", Types: []string{"Basic", "Land"}}, Count: 16, Board: domain.SideBoard},
+			},
+			expected: []string{"max_sideboard_size"},
+		},
+		{
+			name:   "banned card",
+			format: domain.DeckFormatLimited,
+			entries: []DeckEntry{
+				entry("Black Lotus", 1, domain.MainBoard),
+				basicLand("Swamp", 39),
+			},
+			banlist:  []string{"black lotus"},
+			expected: []string{"banned"},
+		},
+		{
+			name:   "legal commander deck",
+			format: domain.DeckFormatCommander,
+			entries: []DeckEntry{
+				commander("Krenko, Mob Boss", []string{"R"}, ""),
+				{Card: domain.CardData{Name: "Shock", ColorIdentity: []string{"R"}}, Count: 1, Board: domain.MainBoard},
+				basicLand("Mountain", 98),
+			},
+			expected: []string{},
+		},
+		{
+			name:   "card outside the commander's identity",
+			format: domain.DeckFormatCommander,
+			entries: []DeckEntry{
+				commander("Krenko, Mob Boss", []string{"R"}, ""),
+				{Card: domain.CardData{Name: "Opt", ManaCost: "{U}"}, Count: 1, Board: domain.MainBoard},
+				basicLand("Mountain", 98),
+			},
+			expected: []string{"color_identity"},
+		},
+		{
+			name:   "two commanders without partner",
+			format: domain.DeckFormatCommander,
+			entries: []DeckEntry{
+				commander("Krenko, Mob Boss", []string{"R"}, ""),
+				commander("Tymna the Weaver", []string{"W", "B"}, "Partner"),
+				basicLand("Mountain", 98),
+			},
+			expected: []string{"invalid_commander"},
+		},
+		{
+			name:   "missing commander",
+			format: domain.DeckFormatCommander,
+			entries: []DeckEntry{
+				basicLand("Mountain", 100),
+			},
+			expected: []string{"commander_count"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			violations := Validate(Profiles[test.format], test.entries, test.banlist)
+			if rules := violationRules(violations); !reflect.DeepEqual(rules, test.expected) {
+				t.Errorf("expected violations %v, got %v", test.expected, rules)
+			}
+		})
+	}
+}
+
+func TestColorIdentity(t *testing.T) {
+	tests := []struct {
+		name     string
+		card     domain.CardData
+		expected []string
+	}{
+		{"stored identity", domain.CardData{ColorIdentity: []string{"G"}, Colors: []string{"R"}}, []string{"G"}},
+		{"colors", domain.CardData{Colors: []string{"R", "G"}}, []string{"R", "G"}},
+		{"hybrid cost", domain.CardData{ManaCost: "{2}{W/U}"}, []string{"W", "U"}},
+		{"rules text", domain.CardData{Colors: []string{"B"}, Oracle: "{G}: Regenerate this creature."}, []string{"B", "G"}},
+		{"colorless", domain.CardData{ManaCost: "{3}"}, []string{}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if identity := ColorIdentity(test.card); !reflect.DeepEqual(identity, test.expected) {
+				t.Errorf("expected %v, got %v", test.expected, identity)
+			}
+		})
+	}
+}
@@ -0,0 +1,134 @@
+package decklist
+
+import (
+	"bufio"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/joaquinleonarg/wdml-mtg/backend/domain"
+)
+
+// Entry is a single parsed line of a decklist
+type Entry struct {
+	Count           int              `json:"count"`
+	Name            string           `json:"name"`
+	SetCode         string           `json:"set_code"`
+	CollectorNumber string           `json:"collector_number"`
+	Board           domain.DeckBoard `json:"board"`
+}
+
+var (
+	// Matches "4 Name", "4x Name", "Name", "4 Name (SET) 123" and "SB: 4 Name"
+	entryRegex = regexp.MustCompile(`^(?i:SB:\s*)?(?:(\d+)x?\s+)?(.+?)(?:\s+\(([A-Za-z0-9]+)\)(?:\s+(\S+))?)?$`)
+	// Moxfield and Archidekt markers that are not part of the card name
+	markerRegex = regexp.MustCompile(`\s+\*[A-Za-z]+\*`)
+)
+
+// Board headers used by Arena, Moxfield and Cockatrice exports
+var boardHeaders = map[string]domain.DeckBoard{
+	"deck":        domain.MainBoard,
+	"main":        domain.MainBoard,
+	"mainboard":   domain.MainBoard,
+	"commander":   domain.CommanderBoard,
+	"companion":   domain.SideBoard,
+	"sideboard":   domain.SideBoard,
+	"maybeboard":  domain.MaybeBoard,
+	"considering": domain.MaybeBoard,
+}
+
+// Parse reads an Arena, MTGO or plain-text decklist and returns its entries, plus the lines it couldn't understand.
+// Cards are placed on the mainboard until a board header ("Sideboard", "// Sideboard") or, when the list has no headers,
+// an empty line separating the mainboard from the sideboard is found.
+func Parse(raw string) ([]Entry, []string) {
+	entries := []Entry{}
+	unparsed := []string{}
+
+	board := domain.MainBoard
+	usesHeaders := false
+	seenCards := false
+	inMetadata := false
+
+	scanner := bufio.NewScanner(strings.NewReader(raw))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		// Empty lines split main and sideboard on MTGO and Cockatrice lists
+		if line == "" {
+			if !usesHeaders && seenCards && board == domain.MainBoard {
+				board = domain.SideBoard
+			}
+			continue
+		}
+
+		// Board headers, with or without comment markers
+		header := strings.ToLower(strings.TrimSuffix(strings.TrimSpace(strings.TrimLeft(line, "/#")), ":"))
+		if headerBoard, ok := boardHeaders[header]; ok {
+			board = headerBoard
+			usesHeaders = true
+			inMetadata = false
+			continue
+		}
+
+		// Arena metadata lasts until the next board header
+		if header == "about" {
+			inMetadata = true
+			continue
+		}
+
+		// Comments
+		if inMetadata || strings.HasPrefix(line, "//") || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		entry, ok := parseLine(line)
+		if !ok {
+			unparsed = append(unparsed, line)
+			continue
+		}
+		entry.Board = board
+		if strings.HasPrefix(strings.ToUpper(line), "SB:") {
+			entry.Board = domain.SideBoard
+		}
+		entries = append(entries, entry)
+		seenCards = true
+	}
+
+	return entries, unparsed
+}
+
+func parseLine(line string) (Entry, bool) {
+	line = markerRegex.ReplaceAllString(line, "")
+	matches := entryRegex.FindStringSubmatch(line)
+	if matches == nil {
+		return Entry{}, false
+	}
+
+	count := 1
+	if matches[1] != "" {
+		val, err := strconv.Atoi(matches[1])
+		if err != nil || val <= 0 {
+			return Entry{}, false
+		}
+		count = val
+	}
+
+	name := strings.TrimSpace(matches[2])
+	if name == "" {
+		return Entry{}, false
+	}
+
+	return Entry{
+		Count:           count,
+		Name:            name,
+		SetCode:         strings.ToUpper(matches[3]),
+		CollectorNumber: matches[4],
+	}, true
+}
+
+// NormalizeName lowercases a card name and keeps only its front face, so "Fire // Ice", "fire" and "Fire"
+// are all considered the same card
+func NormalizeName(name string) string {
+	front, _, _ := strings.Cut(name, "//")
+	return strings.ToLower(strings.TrimSpace(front))
+}
@@ -0,0 +1,102 @@
+package decklist
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/joaquinleonarg/wdml-mtg/backend/domain"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name             string
+		raw              string
+		expectedEntries  []Entry
+		expectedUnparsed []string
+	}{
+		{
+			name: "plain counts",
+			raw:  "4 Lightning Bolt\n2x Counterspell\nSol Ring",
+			expectedEntries: []Entry{
+				{Count: 4, Name: "Lightning Bolt", Board: domain.MainBoard},
+				{Count: 2, Name: "Counterspell", Board: domain.MainBoard},
+				{Count: 1, Name: "Sol Ring", Board: domain.MainBoard},
+			},
+		},
+		{
+			name: "arena export",
+			raw: "About\nName My Deck\n\nCommander\n1 Atraxa, Praetors' Voice (2X2) 190\n\nDeck\n" +
+				"1 Fire // Ice (MH2) 290\n\nSideboard\n3 Duress (m20) 96",
+			expectedEntries: []Entry{
+				{Count: 1, Name: "Atraxa, Praetors' Voice", SetCode: "2X2", CollectorNumber: "190", Board: domain.CommanderBoard},
+				{Count: 1, Name: "Fire // Ice", SetCode: "MH2", CollectorNumber: "290", Board: domain.MainBoard},
+				{Count: 3, Name: "Duress", SetCode: "M20", CollectorNumber: "96", Board: domain.SideBoard},
+			},
+		},
+		{
+			name: "empty line starts the sideboard without headers",
+			raw:  "4 Island\n\n2 Negate",
+			expectedEntries: []Entry{
+				{Count: 4, Name: "Island", Board: domain.MainBoard},
+				{Count: 2, Name: "Negate", Board: domain.SideBoard},
+			},
+		},
+		{
+			name: "empty lines are ignored with headers",
+			raw:  "// Mainboard\n4 Island\n\n4 Swamp\n// Maybeboard\n1 Opt",
+			expectedEntries: []Entry{
+				{Count: 4, Name: "Island", Board: domain.MainBoard},
+				{Count: 4, Name: "Swamp", Board: domain.MainBoard},
+				{Count: 1, Name: "Opt", Board: domain.MaybeBoard},
+			},
+		},
+		{
+			name: "mtgo sideboard prefix and markers",
+			raw:  "4 Ponder *F*\nSB: 2 Pyroblast",
+			expectedEntries: []Entry{
+				{Count: 4, Name: "Ponder", Board: domain.MainBoard},
+				{Count: 2, Name: "Pyroblast", Board: domain.SideBoard},
+			},
+		},
+		{
+			name: "comments and zero counts",
+			raw:  "# burn\n0 Lightning Bolt\n1 Shock",
+			expectedEntries: []Entry{
+				{Count: 1, Name: "Shock", Board: domain.MainBoard},
+			},
+			expectedUnparsed: []string{"0 Lightning Bolt"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			entries, unparsed := Parse(test.raw)
+			if !reflect.DeepEqual(entries, test.expectedEntries) {
+				t.Errorf("expected entries %+v, got %+v", test.expectedEntries, entries)
+			}
+			if test.expectedUnparsed == nil {
+				test.expectedUnparsed = []string{}
+			}
+			if !reflect.DeepEqual(unparsed, test.expectedUnparsed) {
+				t.Errorf("expected unparsed %v, got %v", test.expectedUnparsed, unparsed)
+			}
+		})
+	}
+}
+
+func TestNormalizeName(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected string
+	}{
+		{"Lightning Bolt", "lightning bolt"},
+		{"Fire // Ice", "fire"},
+		{"  FIRE  ", "fire"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if normalized := NormalizeName(test.name); normalized != test.expected {
+				t.Errorf("expected %q, got %q", test.expected, normalized)
+			}
+		})
+	}
+}
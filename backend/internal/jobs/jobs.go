@@ -0,0 +1,81 @@
+package jobs
+
+import (
+	"os"
+	"time"
+
+	"github.com/joaquinleonarg/wdml-mtg/backend/config"
+	"github.com/joaquinleonarg/wdml-mtg/backend/db"
+	cardprices "github.com/joaquinleonarg/wdml-mtg/backend/internal/card_prices"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	AUCTION_SETTLEMENT_INTERVAL = time.Minute
+	// The bulk data file is only read again if it changed
+	CARD_PRICE_REFRESH_INTERVAL = time.Hour
+)
+
+// Start runs every background job on its own goroutine, for as long as the server is up
+func Start() {
+	go every(AUCTION_SETTLEMENT_INTERVAL, "settle_auctions", settleAuctions)
+	go func() {
+		run("refresh_card_prices", refreshCardPrices)
+		every(CARD_PRICE_REFRESH_INTERVAL, "refresh_card_prices", refreshCardPrices)
+	}()
+}
+
+func every(interval time.Duration, name string, job func() error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		run(name, job)
+	}
+}
+
+func run(name string, job func() error) {
+	err := job()
+	if err != nil {
+		log.Error().Err(err).Str("job", name).Msg("background job failed")
+	}
+}
+
+func settleAuctions() error {
+	settled, err := db.SettleExpiredAuctionListings()
+	if err != nil {
+		return err
+	}
+	if settled > 0 {
+		log.Info().Int("listings", settled).Msg("settled expired auction listings")
+	}
+	return nil
+}
+
+// Modification time of the bulk data file prices were last read from
+var cardPricesLoadedAt time.Time
+
+func refreshCardPrices() error {
+	path := config.Config.ScryfallBulkDataPath
+	if path == "" {
+		return nil
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if !info.ModTime().After(cardPricesLoadedAt) {
+		return nil
+	}
+
+	prices, err := cardprices.Load(path)
+	if err != nil {
+		return err
+	}
+	updated, err := db.UpdateCardPrices(prices)
+	if err != nil {
+		return err
+	}
+	cardPricesLoadedAt = info.ModTime()
+	log.Info().Int("printings", len(prices)).Int("owned_cards", updated).Msg("refreshed card prices")
+	return nil
+}
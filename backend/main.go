@@ -4,6 +4,7 @@ import (
 	"github.com/joaquinleonarg/wdml-mtg/backend/api"
 	"github.com/joaquinleonarg/wdml-mtg/backend/config"
 	"github.com/joaquinleonarg/wdml-mtg/backend/db"
+	"github.com/joaquinleonarg/wdml-mtg/backend/internal/jobs"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 )
@@ -25,6 +26,15 @@ func main() {
 			Msg("failed to init db connection")
 	}
 
+	err = db.EnsureIndexes()
+	if err != nil {
+		log.Panic().
+			Err(err).
+			Msg("failed to create db indexes")
+	}
+
+	jobs.Start()
+
 	log.Info().
 		Int("port", config.Config.ApiPort).
 		Msg("starting server")
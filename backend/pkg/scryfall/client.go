@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"slices"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/BlueMonday/go-scryfall"
@@ -15,18 +16,31 @@ import (
 var sets []scryfallapi.Set
 var lastUpdated time.Time
 var client *scryfallapi.Client
+var clientMutex sync.Mutex
 
-func GetAllSets() ([]scryfallapi.Set, error) {
-	if len(sets) > 0 && lastUpdated.Add(time.Hour*24).After(time.Now()) {
-		return sets, nil
-	}
+// getClient returns the shared Scryfall client, creating it on first use. Safe to call concurrently.
+func getClient() (*scryfallapi.Client, error) {
+	clientMutex.Lock()
+	defer clientMutex.Unlock()
 
-	var err error
 	if client == nil {
-		client, err = scryfallapi.NewClient()
+		newClient, err := scryfallapi.NewClient()
 		if err != nil {
 			return nil, err
 		}
+		client = newClient
+	}
+	return client, nil
+}
+
+func GetAllSets() ([]scryfallapi.Set, error) {
+	if len(sets) > 0 && lastUpdated.Add(time.Hour*24).After(time.Now()) {
+		return sets, nil
+	}
+
+	client, err := getClient()
+	if err != nil {
+		return nil, err
 	}
 
 	ctx := context.Background()
@@ -51,12 +65,9 @@ func GetSetCards(set string) ([]scryfallapi.Card, error) {
 		return cachedCards, nil
 	}
 
-	var err error
-	if client == nil {
-		client, err = scryfallapi.NewClient()
-		if err != nil {
-			return nil, err
-		}
+	client, err := getClient()
+	if err != nil {
+		return nil, err
 	}
 
 	page := 1
@@ -80,26 +91,17 @@ func GetSetCards(set string) ([]scryfallapi.Card, error) {
 	return allCards, nil
 }
 
-var cachedPossibleCards *lru.Cache[string, []scryfallapi.Card]
+// The cache is safe for concurrent use; it only fails to be created for a non positive size
+var cachedPossibleCards, _ = lru.New[string, []scryfallapi.Card](64)
 
 func GetAllCardsByFilter(filter string) ([]scryfallapi.Card, error) {
-	var err error
-	if cachedPossibleCards == nil {
-		cachedPossibleCards, err = lru.New[string, []scryfallapi.Card](64)
-	}
-	if err != nil {
-		return nil, err
-	}
-
 	if cards, ok := cachedPossibleCards.Get(filter); ok {
 		return cards, nil
 	}
 
-	if client == nil {
-		client, err = scryfallapi.NewClient()
-		if err != nil {
-			return nil, err
-		}
+	client, err := getClient()
+	if err != nil {
+		return nil, err
 	}
 
 	page := 1
@@ -134,14 +136,17 @@ func GetAllCardsByFilter(filter string) ([]scryfallapi.Card, error) {
 				page += 1
 			}
 		}
-		cachedPossibleCards.Add(newFilter, allCards)
-	} else {
-		cachedPossibleCards.Add(filter, allCards)
-
 	}
+	// Cached by the filter asked for, so the fallback isn't searched again every time
+	cachedPossibleCards.Add(filter, allCards)
 	return allCards, nil
 }
 
+// CacheCardsByFilter stores the cards matching a filter, so drawing from it doesn't search Scryfall
+func CacheCardsByFilter(filter string, cards []scryfallapi.Card) {
+	cachedPossibleCards.Add(filter, cards)
+}
+
 type CardsByIdentifier struct {
 	Identifier scryfallapi.CardIdentifier
 	Amount     int
@@ -151,13 +156,9 @@ type ScryfallCollectionRequest struct {
 }
 
 func GetAllCardsByIdentifiers(scryfallRequestBody ScryfallCollectionRequest) ([]scryfallapi.Card, error) {
-	var err error
-
-	if client == nil {
-		client, err = scryfallapi.NewClient()
-		if err != nil {
-			return nil, err
-		}
+	client, err := getClient()
+	if err != nil {
+		return nil, err
 	}
 
 	ctx := context.Background()
@@ -169,3 +170,21 @@ func GetAllCardsByIdentifiers(scryfallRequestBody ScryfallCollectionRequest) ([]
 	allCards := response.Data
 	return allCards, nil
 }
+
+func GetCardByName(name, setCode string) (scryfallapi.Card, error) {
+	client, err := getClient()
+	if err != nil {
+		return scryfallapi.Card{}, err
+	}
+
+	ctx := context.Background()
+	card, err := client.GetCardByName(ctx, name, true, scryfallapi.GetCardByNameOptions{Set: strings.ToLower(setCode)})
+	if err != nil && setCode != "" {
+		// The printing may not exist on that set, try any printing instead
+		card, err = client.GetCardByName(ctx, name, true, scryfallapi.GetCardByNameOptions{})
+	}
+	if err != nil {
+		return scryfallapi.Card{}, err
+	}
+	return card, nil
+}
@@ -1,10 +1,14 @@
 package scryfall
 
 import (
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/BlueMonday/go-scryfall"
 	"github.com/joaquinleonarg/wdml-mtg/backend/domain"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 func ParseScryfallTypeline(rawType string) []string {
@@ -23,11 +27,75 @@ func ParseScryfallTypeline(rawType string) []string {
 }
 
 func GetImageFromFaces(card scryfall.Card) (string, string) {
-	if card.CardFaces != nil && card.CardFaces[0].ImageURIs.Normal != "" && card.CardFaces[1].ImageURIs.Normal != "" {
+	if len(card.CardFaces) >= 2 && card.CardFaces[0].ImageURIs.Normal != "" && card.CardFaces[1].ImageURIs.Normal != "" {
 		return card.CardFaces[0].ImageURIs.Normal, card.CardFaces[1].ImageURIs.Normal
-	} else {
+	} else if card.ImageURIs != nil {
 		return card.ImageURIs.Normal, ""
+	} else if len(card.CardFaces) > 0 {
+		return card.CardFaces[0].ImageURIs.Normal, ""
 	}
+	return "", ""
+}
+
+// GetFacesFromScryCard returns every face of a multi-faced card, or nothing for single faced cards
+func GetFacesFromScryCard(card scryfall.Card) []domain.CardFace {
+	faces := []domain.CardFace{}
+	for _, face := range card.CardFaces {
+		cardFace := domain.CardFace{
+			Name:     face.Name,
+			ManaCost: face.ManaCost,
+			TypeLine: face.TypeLine,
+			Types:    ParseScryfallTypeline(face.TypeLine),
+			ImageURL: face.ImageURIs.Normal,
+		}
+		if face.OracleText != nil {
+			cardFace.Oracle = *face.OracleText
+		}
+		faces = append(faces, cardFace)
+	}
+	return faces
+}
+
+// GetPriceFromScryPrices parses the prices of a printing, or returns nil if it has none
+func GetPriceFromScryPrices(prices scryfall.Prices) *domain.CardPrice {
+	parse := func(price string) float64 {
+		value, err := strconv.ParseFloat(price, 64)
+		if err != nil {
+			return 0
+		}
+		return value
+	}
+	cardPrice := domain.CardPrice{
+		USD:       parse(prices.USD),
+		USDFoil:   parse(prices.USDFoil),
+		USDEtched: parse(prices.USDEtched),
+		EUR:       parse(prices.EUR),
+		EURFoil:   parse(prices.EURFoil),
+		UpdatedAt: primitive.NewDateTimeFromTime(time.Now()),
+	}
+	if cardPrice.USD == 0 && cardPrice.USDFoil == 0 && cardPrice.USDEtched == 0 && cardPrice.EUR == 0 && cardPrice.EURFoil == 0 {
+		return nil
+	}
+	return &cardPrice
+}
+
+// GetDefaultFinish returns the finish a copy of the card has unless it's known to be another one: non foil if the
+// printing exists in non foil
+func GetDefaultFinish(card scryfall.Card) domain.CardFinish {
+	for _, finish := range []scryfall.Finish{scryfall.FinishNonFoil, scryfall.FinishFoil, scryfall.FinishEtched} {
+		if slices.Contains(card.Finishes, finish) {
+			return domain.CardFinish(finish)
+		}
+	}
+	return domain.CardFinishNonFoil
+}
+
+// HasFinish reports if the printing exists in the finish
+func HasFinish(card scryfall.Card, finish domain.CardFinish) bool {
+	if len(card.Finishes) == 0 {
+		return finish == domain.CardFinishNonFoil
+	}
+	return slices.Contains(card.Finishes, scryfall.Finish(finish))
 }
 
 func GetCardDataFromScryCard(card scryfall.Card) domain.CardData {
@@ -35,7 +103,15 @@ func GetCardDataFromScryCard(card scryfall.Card) domain.CardData {
 	for _, col := range card.Colors {
 		colors = append(colors, string(col))
 	}
+	colorIdentity := []string{}
+	for _, col := range card.ColorIdentity {
+		colorIdentity = append(colorIdentity, string(col))
+	}
 	types := ParseScryfallTypeline(card.TypeLine)
+	frameEffects := []string{}
+	for _, frameEffect := range card.FrameEffects {
+		frameEffects = append(frameEffects, string(frameEffect))
+	}
 
 	newCard := domain.CardData{
 		SetCode:         strings.ToUpper(card.Set),
@@ -47,7 +123,30 @@ func GetCardDataFromScryCard(card scryfall.Card) domain.CardData {
 		ManaValue:       int(card.CMC),
 		ManaCost:        card.ManaCost,
 		Colors:          colors,
+		ColorIdentity:   colorIdentity,
+		Finish:          GetDefaultFinish(card),
+		Language:        string(card.Lang),
+		FrameEffects:    frameEffects,
+		Promo:           card.Promo,
+		Price:           GetPriceFromScryPrices(card.Prices),
 	}
 	newCard.ImageURL, newCard.BackImageURL = GetImageFromFaces(card)
+	newCard.Faces = GetFacesFromScryCard(card)
+
+	// Double-faced cards only have oracle text and mana costs on their faces
+	oracles := []string{}
+	manaCosts := []string{}
+	for _, face := range newCard.Faces {
+		oracles = append(oracles, face.Oracle)
+		if face.ManaCost != "" {
+			manaCosts = append(manaCosts, face.ManaCost)
+		}
+	}
+	if newCard.Oracle == "" && len(oracles) > 0 {
+		newCard.Oracle = strings.Join(oracles, "\n//\n")
+	}
+	if newCard.ManaCost == "" && len(manaCosts) > 0 {
+		newCard.ManaCost = strings.Join(manaCosts, " // ")
+	}
 	return newCard
 }